@@ -0,0 +1,1674 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"golang.org/x/term"
+
+	"github.com/internet-kid/tenote/internal/config"
+	"github.com/internet-kid/tenote/internal/diff"
+	"github.com/internet-kid/tenote/internal/editor"
+	"github.com/internet-kid/tenote/internal/exporter"
+	"github.com/internet-kid/tenote/internal/importer"
+	"github.com/internet-kid/tenote/internal/storage"
+	"github.com/internet-kid/tenote/internal/storage/fs"
+	"github.com/internet-kid/tenote/internal/storage/links"
+	"github.com/internet-kid/tenote/internal/storage/search"
+	"github.com/internet-kid/tenote/internal/storage/tags"
+	"github.com/internet-kid/tenote/internal/storage/vault"
+	"github.com/internet-kid/tenote/internal/templates"
+)
+
+// Exit codes are part of the CLI contract; scripts may rely on them.
+const (
+	exitOK        = 0
+	exitError     = 1
+	exitUsage     = 2
+	exitNotFound  = 3
+	exitAmbiguous = 4
+)
+
+// passphraseEnv unlocks an encrypted store without a terminal, e.g. in
+// scripts.
+const passphraseEnv = "TENOTE_PASSPHRASE"
+
+var (
+	errNotFound  = errors.New("note not found")
+	errAmbiguous = errors.New("note id is ambiguous")
+)
+
+// usageError marks bad invocations so they exit with exitUsage.
+type usageError struct{ msg string }
+
+func (e usageError) Error() string { return e.msg }
+
+func usagef(format string, args ...any) error {
+	return usageError{msg: fmt.Sprintf(format, args...)}
+}
+
+// cli carries the streams and store shared by every subcommand.
+type cli struct {
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+
+	cfg   config.AppConfig
+	store storage.NoteStore
+
+	// key and pass are set once an encrypted store has been unlocked.
+	key  *vault.Key
+	pass string
+}
+
+type command struct {
+	name    string
+	args    string
+	summary string
+	run     func(c *cli, args []string) error
+}
+
+var commands = []command{
+	{"new", "[--section S] [--template T] [--var NAME=VALUE]... [--json] [title]", "create a note, from a template if given; the body is read from stdin when piped", (*cli).cmdNew},
+	{"templates", "[--json]", "list note templates and the fields they ask for", (*cli).cmdTemplates},
+	{"today", "[--date YYYY-MM-DD] [--json]", "open the journal note for today, or --date, in the editor, creating it first; piped stdin is appended instead", (*cli).cmdToday},
+	{"list", "[--section S] [--tag T,...] [--any] [--sort O] [--json]", "list notes in a section or with tags, pinned notes first", (*cli).cmdList},
+	{"notebooks", "[--json]", "list notebooks with their note counts", (*cli).cmdNotebooks},
+	{"mknotebook", "<notebook>", "create a notebook and any missing parents", (*cli).cmdMkNotebook},
+	{"rmnotebook", "<notebook>", "delete an empty notebook", (*cli).cmdRmNotebook},
+	{"move", "[--json] <id> <notebook>", "move a note to another notebook", (*cli).cmdMove},
+	{"show", "[--json] <id>", "print a note", (*cli).cmdShow},
+	{"edit", "[--force] <id>", "open a note in the configured editor, $VISUAL or $EDITOR", (*cli).cmdEdit},
+	{"search", "[--section S] [--limit N] [--json] <query>", "full-text search", (*cli).cmdSearch},
+	{"links", "[--json] <id>", "show a note's [[links]] and the notes linking to it", (*cli).cmdLinks},
+	{"tags", "[--json]", "list tags with their note counts", (*cli).cmdTags},
+	{"renametag", "<old> <new>", "rename a tag in every note; merges when <new> exists", (*cli).cmdRenameTag},
+	{"mergetags", "<tag>... <into>", "merge several tags into one", (*cli).cmdMergeTags},
+	{"pin", "[--json] <id>", "pin a note to the top of its notebook", (*cli).cmdPin},
+	{"unpin", "[--json] <id>", "unpin a note", (*cli).cmdUnpin},
+	{"trash", "[--json] <id>", "move a note to the trash", (*cli).cmdTrash},
+	{"restore", "[--to S] [--json] <id>", "restore a note to the notebook it was trashed from", (*cli).cmdRestore},
+	{"purge", "[--all] [--json]", "permanently delete notes trashed longer than trash_retention, or with --all everything in the trash", (*cli).cmdPurge},
+	{"import", "[--notebook NB] [--json] <format> <path>", "import notes; format is obsidian, joplin, enex or simplenote", (*cli).cmdImport},
+	{"export", "[--format F] [--out PATH] [--section S | --search Q] [--title T] [id...]", "export notes as html, a static site, one markdown document or an epub", (*cli).cmdExport},
+	{"serve", "[--addr HOST:PORT]", "serve an HTTP JSON API and a read-only web UI; needs serve_token in the config", (*cli).cmdServe},
+	{"mcp", "", "answer Model Context Protocol requests on stdin for AI assistants; see mcp_read_only and mcp_sections", (*cli).cmdMCP},
+	{"encrypt", "", "encrypt the note store with a passphrase", (*cli).cmdEncrypt},
+	{"decrypt", "", "turn an encrypted note store back into plain files", (*cli).cmdDecrypt},
+	{"sync", "[--json]", "pull from and push to the git remote; lists conflicted notes", (*cli).cmdSync},
+}
+
+// runCLI runs a non-interactive subcommand and returns the process exit code.
+func runCLI(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	name := args[0]
+	if name == "help" || name == "-h" || name == "--help" {
+		printUsage(stdout)
+		return exitOK
+	}
+
+	var cmd *command
+	for i := range commands {
+		if commands[i].name == name {
+			cmd = &commands[i]
+		}
+	}
+	if cmd == nil {
+		fmt.Fprintf(stderr, "tenote: unknown command %q\n\n", name)
+		printUsage(stderr)
+		return exitUsage
+	}
+
+	c := &cli{stdin: stdin, stdout: stdout, stderr: stderr}
+	err := c.open()
+	if err == nil {
+		err = cmd.run(c, args[1:])
+		if cerr := storage.Close(c.store); err == nil {
+			err = cerr
+		}
+	}
+
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.Is(err, flag.ErrHelp):
+		return exitOK
+	case errors.As(err, new(usageError)):
+		fmt.Fprintf(stderr, "tenote %s: %v\nusage: tenote %s %s\n", cmd.name, err, cmd.name, cmd.args)
+		return exitUsage
+	case errors.Is(err, errNotFound):
+		fmt.Fprintf(stderr, "tenote %s: %v\n", cmd.name, err)
+		return exitNotFound
+	case errors.Is(err, errAmbiguous):
+		fmt.Fprintf(stderr, "tenote %s: %v\n", cmd.name, err)
+		return exitAmbiguous
+	default:
+		fmt.Fprintf(stderr, "tenote %s: %v\n", cmd.name, err)
+		return exitError
+	}
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "usage: tenote [command]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Without a command tenote starts the interactive UI.")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "commands:")
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, c := range commands {
+		fmt.Fprintf(tw, "  %s %s\t%s\n", c.name, c.args, c.summary)
+	}
+	tw.Flush()
+}
+
+func (c *cli) open() error {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return err
+	}
+	store, err := storage.Open(cfg)
+	if errors.Is(err, vault.ErrLocked) {
+		var pass string
+		if pass, err = c.passphrase(); err == nil {
+			c.key, err = storage.Unlock(cfg, pass)
+		}
+		if err == nil {
+			store, err = storage.OpenWithKey(cfg, c.key)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	c.cfg = cfg
+	c.store = store
+
+	if r, ok := store.(storage.Recoverer); ok {
+		kept, err := r.Recover()
+		for _, path := range kept {
+			fmt.Fprintf(c.stderr, "tenote: kept an interrupted save in %s\n", path)
+		}
+		if err != nil {
+			fmt.Fprintf(c.stderr, "tenote: recover interrupted saves: %v\n", err)
+		}
+	}
+	return nil
+}
+
+// passphrase returns the passphrase of an encrypted store from
+// $TENOTE_PASSPHRASE or, failing that, asks for it on the terminal. It
+// asks at most once per run.
+func (c *cli) passphrase() (string, error) {
+	if c.pass != "" {
+		return c.pass, nil
+	}
+	if p := os.Getenv(passphraseEnv); p != "" {
+		c.pass = p
+		return p, nil
+	}
+	p, err := c.readPassword("Passphrase: ")
+	if err != nil {
+		return "", err
+	}
+	c.pass = p
+	return p, nil
+}
+
+// readPassword reads a line from the terminal without echoing it.
+func (c *cli) readPassword(prompt string) (string, error) {
+	f, ok := c.stdin.(*os.File)
+	if !ok || !term.IsTerminal(int(f.Fd())) {
+		return "", fmt.Errorf("%w: set %s or run in a terminal", vault.ErrLocked, passphraseEnv)
+	}
+	fmt.Fprint(c.stderr, prompt)
+	b, err := term.ReadPassword(int(f.Fd()))
+	fmt.Fprintln(c.stderr)
+	if err != nil {
+		return "", fmt.Errorf("read passphrase: %w", err)
+	}
+	return string(b), nil
+}
+
+// flags returns a flag set that reports errors instead of exiting.
+func (c *cli) flags(name string) *flag.FlagSet {
+	fset := flag.NewFlagSet(name, flag.ContinueOnError)
+	fset.SetOutput(c.stderr)
+	return fset
+}
+
+// parse parses args and checks the number of positional arguments.
+func parse(fset *flag.FlagSet, args []string, minArgs, maxArgs int) error {
+	if err := fset.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return usageError{msg: err.Error()}
+	}
+	if n := fset.NArg(); n < minArgs || n > maxArgs {
+		return usagef("expected %s, got %d", plural(minArgs, maxArgs), n)
+	}
+	return nil
+}
+
+func plural(minArgs, maxArgs int) string {
+	switch {
+	case minArgs == maxArgs && minArgs == 1:
+		return "1 argument"
+	case minArgs == maxArgs:
+		return fmt.Sprintf("%d arguments", minArgs)
+	default:
+		return fmt.Sprintf("%d to %d arguments", minArgs, maxArgs)
+	}
+}
+
+// parseSection accepts "notes", "journal", "trash" and notebook paths,
+// either in full ("notes/work/ideas") or relative to the root notebook
+// ("work/ideas").
+func parseSection(s string) (fs.Section, error) {
+	sec := fs.Section(strings.Trim(s, "/"))
+	if sec != fs.SectionTrash && sec != fs.SectionJournal && !sec.IsNotebook() {
+		sec = fs.Notebook(string(sec))
+	}
+	if err := fs.ValidSection(sec); err != nil {
+		return "", usagef("invalid section %q: %v", s, err)
+	}
+	return sec, nil
+}
+
+// parseNotebook is parseSection restricted to notebooks.
+func parseNotebook(s string) (fs.Section, error) {
+	sec, err := parseSection(s)
+	if err != nil {
+		return "", err
+	}
+	if !sec.IsNotebook() {
+		return "", usagef("%q is not a notebook", s)
+	}
+	return sec, nil
+}
+
+// inSection reports whether s is sec or a notebook inside it.
+func inSection(s, sec fs.Section) bool {
+	return s == sec || sec.IsNotebook() && strings.HasPrefix(string(s), string(sec)+"/")
+}
+
+func (c *cli) notebooks() (storage.Notebooks, error) {
+	nbs, ok := c.store.(storage.Notebooks)
+	if !ok {
+		return nil, errors.New("notebooks are not supported by this storage backend")
+	}
+	return nbs, nil
+}
+
+// ---------------------------------------------------------------------------
+// commands
+// ---------------------------------------------------------------------------
+
+func (c *cli) cmdNew(args []string) error {
+	fset := c.flags("new")
+	section := fset.String("section", string(fs.SectionNotes), "section to create the note in")
+	tmplName := fset.String("template", "", "template to fill in; see tenote templates")
+	values := make(map[string]string)
+	fset.Func("var", "value of a template field, as NAME=VALUE; repeatable", func(s string) error {
+		name, value, ok := strings.Cut(s, "=")
+		if !ok || strings.TrimSpace(name) == "" {
+			return fmt.Errorf("want NAME=VALUE, got %q", s)
+		}
+		values[strings.TrimSpace(name)] = value
+		return nil
+	})
+	asJSON := fset.Bool("json", false, "print the note as JSON")
+	if err := parse(fset, args, 0, 1); err != nil {
+		return err
+	}
+	sec, err := parseSection(*section)
+	if err != nil {
+		return err
+	}
+	switch sec {
+	case fs.SectionTrash:
+		return usagef("cannot create notes in the trash")
+	case fs.SectionJournal:
+		return usagef("journal notes are made with tenote today")
+	}
+
+	var body strings.Builder
+	title := strings.TrimSpace(fset.Arg(0))
+	if *tmplName != "" {
+		t, err := c.template(*tmplName)
+		if err != nil {
+			return err
+		}
+		if title != "" {
+			values["title"] = title
+		}
+		fields := t.Fields()
+		if err := c.askFields(fields, values); err != nil {
+			return err
+		}
+		filled := t.Fill(time.Now(), values)
+		if title != "" && !slices.ContainsFunc(fields, func(f templates.Field) bool { return f.Name == "title" }) {
+			// The heading goes below the template's front matter, which
+			// has to start the note.
+			_, rest, _ := fs.SplitFrontMatter(filled)
+			filled = filled[:len(filled)-len(rest)] + "# " + title + "\n\n" + rest
+		}
+		body.WriteString(filled)
+	} else if title != "" {
+		body.WriteString("# " + title + "\n\n")
+	}
+	if stdinIsPiped(c.stdin) {
+		in, err := io.ReadAll(c.stdin)
+		if err != nil {
+			return fmt.Errorf("read stdin: %w", err)
+		}
+		body.Write(in)
+	}
+
+	n, err := c.store.Create(sec)
+	if err != nil {
+		return err
+	}
+	if body.Len() > 0 {
+		if err := c.store.WriteBody(n.Path, body.String()); err != nil {
+			return c.discard(n, err)
+		}
+		if n, err = c.find(n.ID); err != nil {
+			return err
+		}
+	}
+
+	if *asJSON {
+		return c.writeJSON(toJSON(n))
+	}
+	fmt.Fprintln(c.stdout, n.ID)
+	return nil
+}
+
+func (c *cli) cmdToday(args []string) error {
+	fset := c.flags("today")
+	date := fset.String("date", "", "the day, as YYYY-MM-DD; defaults to today")
+	asJSON := fset.Bool("json", false, "print the note as JSON instead of opening it")
+	if err := parse(fset, args, 0, 0); err != nil {
+		return err
+	}
+	day := time.Now()
+	if *date != "" {
+		var err error
+		if day, err = time.ParseInLocation(time.DateOnly, *date, time.Local); err != nil {
+			return usagef("invalid date %q: want YYYY-MM-DD", *date)
+		}
+	}
+
+	n, created, err := storage.OpenJournal(c.cfg, c.store, day)
+	if err != nil {
+		return err
+	}
+	if created {
+		fmt.Fprintf(c.stderr, "created %s %s\n", n.ID, n.Title)
+	}
+
+	if stdinIsPiped(c.stdin) {
+		in, err := io.ReadAll(c.stdin)
+		if err != nil {
+			return fmt.Errorf("read stdin: %w", err)
+		}
+		body, base, err := storage.ReadVersion(c.store, n.Path)
+		if err != nil {
+			return err
+		}
+		if body != "" && !strings.HasSuffix(body, "\n") {
+			body += "\n"
+		}
+		if err := c.save(n, body+string(in), base); err != nil {
+			return err
+		}
+	} else if !*asJSON {
+		return c.edit(n, false)
+	}
+
+	if *asJSON {
+		if n, err = c.find(n.ID); err != nil {
+			return err
+		}
+		return c.writeJSON(toJSON(n))
+	}
+	return nil
+}
+
+func (c *cli) cmdTemplates(args []string) error {
+	fset := c.flags("templates")
+	asJSON := fset.Bool("json", false, "print templates as JSON")
+	if err := parse(fset, args, 0, 0); err != nil {
+		return err
+	}
+	dir, err := storage.TemplateDir(c.cfg)
+	if err != nil {
+		return err
+	}
+	list, err := templates.List(dir)
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		out := make([]templateJSON, 0, len(list))
+		for _, t := range list {
+			tj := templateJSON{Name: t.Name, Fields: []string{}}
+			for _, f := range t.Fields() {
+				tj.Fields = append(tj.Fields, f.Name)
+			}
+			out = append(out, tj)
+		}
+		return c.writeJSON(out)
+	}
+	if len(list) == 0 {
+		fmt.Fprintf(c.stderr, "no templates in %s\n", dir)
+		return nil
+	}
+	tw := tabwriter.NewWriter(c.stdout, 0, 4, 2, ' ', 0)
+	for _, t := range list {
+		var names []string
+		for _, f := range t.Fields() {
+			names = append(names, f.Name)
+		}
+		fmt.Fprintf(tw, "%s\t%s\n", t.Name, strings.Join(names, ", "))
+	}
+	return tw.Flush()
+}
+
+func (c *cli) cmdList(args []string) error {
+	fset := c.flags("list")
+	section := fset.String("section", string(fs.SectionNotes), "section to list: notes, trash or a notebook")
+	tagList := fset.String("tag", "", "comma separated tags; lists matching notes from every notebook")
+	anyTag := fset.Bool("any", false, "with --tag, match notes with any of the tags instead of all")
+	sortBy := fset.String("sort", "", "order: updated, created, title or size; defaults to the section's order in the config")
+	asJSON := fset.Bool("json", false, "print notes as JSON")
+	if err := parse(fset, args, 0, 0); err != nil {
+		return err
+	}
+	sec, err := parseSection(*section)
+	if err != nil {
+		return err
+	}
+	order := storage.SortOrder(c.cfg, sec)
+	if *sortBy != "" {
+		if order, err = fs.ParseSortOrder(*sortBy); err != nil {
+			return usagef("%v", err)
+		}
+	}
+
+	var notes []fs.Note
+	if *tagList != "" {
+		notes, err = c.tagged(strings.Split(*tagList, ","), *anyTag)
+		if err != nil {
+			return err
+		}
+		// An explicit --section narrows the tag filter to that notebook.
+		if flagSet(fset, "section") {
+			notes = slices.DeleteFunc(notes, func(n fs.Note) bool { return n.Section != sec })
+		}
+	} else if notes, err = c.store.List(sec); err != nil {
+		return err
+	}
+	fs.SortNotes(notes, order)
+
+	if *asJSON {
+		out := make([]noteJSON, 0, len(notes))
+		for _, n := range notes {
+			out = append(out, toJSON(n))
+		}
+		return c.writeJSON(out)
+	}
+
+	tw := tabwriter.NewWriter(c.stdout, 0, 4, 2, ' ', 0)
+	for _, n := range notes {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", n.ID, n.UpdatedAt.Format("2006-01-02 15:04"), n.Title)
+	}
+	return tw.Flush()
+}
+
+func (c *cli) cmdShow(args []string) error {
+	fset := c.flags("show")
+	asJSON := fset.Bool("json", false, "print the note and its body as JSON")
+	if err := parse(fset, args, 1, 1); err != nil {
+		return err
+	}
+
+	n, err := c.find(fset.Arg(0))
+	if err != nil {
+		return err
+	}
+	body, err := c.store.ReadBody(n.Path)
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		out := toJSON(n)
+		out.Body = &body
+		return c.writeJSON(out)
+	}
+	_, err = io.WriteString(c.stdout, body)
+	return err
+}
+
+func (c *cli) cmdEdit(args []string) error {
+	fset := c.flags("edit")
+	force := fset.Bool("force", false, "save even if the note changed on disk while it was being edited")
+	if err := parse(fset, args, 1, 1); err != nil {
+		return err
+	}
+
+	n, err := c.find(fset.Arg(0))
+	if err != nil {
+		return err
+	}
+	return c.edit(n, *force)
+}
+
+// edit opens n in the user's editor and saves the result, asking what to
+// do when the note changed on disk meanwhile. force saves regardless.
+func (c *cli) edit(n fs.Note, force bool) error {
+	body, base, err := storage.ReadVersion(c.store, n.Path)
+	if err != nil {
+		return err
+	}
+	edited, changed, err := c.editBody(n.ID, body)
+	if err != nil || !changed {
+		return err
+	}
+
+	for {
+		if force {
+			base = fs.Version{}
+		}
+		err := c.save(n, edited, base)
+		if !errors.Is(err, fs.ErrConflict) {
+			return err
+		}
+
+		disk, version, err := storage.ReadVersion(c.store, n.Path)
+		if err != nil {
+			return err
+		}
+		choice, err := c.ask("The note changed on disk while you were editing it.\n[o]verwrite, [r]eload (discard your edit) or [m]erge? ", "o", "r", "m")
+		if err != nil {
+			return c.keepEdit(n, edited, err)
+		}
+		switch choice {
+		case "o":
+			base = version
+		case "r":
+			fmt.Fprintln(c.stderr, "kept the note on disk")
+			return nil
+		case "m":
+			merged, conflicts := diff.Merge(body, edited, disk, "yours", "on disk")
+			body, base, edited = disk, version, merged
+			if conflicts > 0 {
+				fmt.Fprintf(c.stderr, "%d conflict(s); resolve the marked parts in the editor\n", conflicts)
+				if edited, _, err = c.editBody(n.ID, merged); err != nil {
+					return c.keepEdit(n, merged, err)
+				}
+			}
+		}
+	}
+}
+
+// editBody edits body in the user's editor through a temporary file.
+func (c *cli) editBody(id, body string) (string, bool, error) {
+	dir, err := storage.EditDir(c.cfg)
+	if err != nil {
+		return "", false, err
+	}
+	sess, err := editor.NewSession(dir, id, body)
+	if err != nil {
+		return "", false, err
+	}
+	cmd := sess.Command(c.cfg.Editor)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	runErr := cmd.Run()
+
+	edited, changed, err := sess.Finish()
+	if runErr != nil {
+		return "", false, fmt.Errorf("run editor: %w", runErr)
+	}
+	return edited, changed, err
+}
+
+// save writes an edited body unless the note no longer matches base, and
+// rewrites links to the note's old title.
+func (c *cli) save(n fs.Note, body string, base fs.Version) error {
+	graph, err := c.links()
+	if err != nil {
+		return err
+	}
+	rewritten, err := graph.Write(storage.Guard(c.store, n.Path, base), n, body)
+	for _, r := range rewritten {
+		fmt.Fprintf(c.stderr, "updated links in %s %s\n", r.ID, r.Title)
+	}
+	return err
+}
+
+// keepEdit saves an edit that could not be written to the note to a file
+// of its own, so it is not lost, and returns cause with its location. An
+// encrypted store keeps it as a draft instead, sealed like the notes, for
+// the app to recover.
+func (c *cli) keepEdit(n fs.Note, body string, cause error) error {
+	if encrypted, err := storage.Encrypted(c.cfg); err != nil || encrypted {
+		d, ok := c.store.(storage.Drafter)
+		if !ok || err != nil {
+			return cause
+		}
+		if err := d.SaveDraft(n, body); err != nil {
+			return cause
+		}
+		return fmt.Errorf("%w; your edit is kept as a draft, which tenote offers to recover when it starts", cause)
+	}
+
+	f, err := os.CreateTemp("", "tenote-"+n.ID+"-unsaved-*.md")
+	if err != nil {
+		return cause
+	}
+	defer f.Close()
+	if _, err := f.WriteString(body); err != nil {
+		return cause
+	}
+	return fmt.Errorf("%w; your edit is in %s", cause, f.Name())
+}
+
+func (c *cli) cmdSearch(args []string) error {
+	fset := c.flags("search")
+	section := fset.String("section", "", "only search this section")
+	limit := fset.Int("limit", 20, "maximum number of results, 0 for all")
+	asJSON := fset.Bool("json", false, "print results as JSON")
+	if err := parse(fset, args, 1, 1<<30); err != nil {
+		return err
+	}
+
+	var opts search.Options
+	opts.Limit = *limit
+	if *section != "" {
+		sec, err := parseSection(*section)
+		if err != nil {
+			return err
+		}
+		opts.Sections = []fs.Section{sec}
+	}
+
+	idx, err := c.index()
+	if err != nil {
+		return err
+	}
+	hits, err := idx.Search(c.store, strings.Join(fset.Args(), " "), opts)
+	if err != nil {
+		return usageError{msg: err.Error()}
+	}
+
+	if *asJSON {
+		out := make([]hitJSON, 0, len(hits))
+		for _, h := range hits {
+			out = append(out, hitJSON{noteJSON: toJSON(h.Note), Score: h.Score, Snippet: h.Snippet, Line: h.Line})
+		}
+		return c.writeJSON(out)
+	}
+
+	tw := tabwriter.NewWriter(c.stdout, 0, 4, 2, ' ', 0)
+	for _, h := range hits {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", h.Note.ID, h.Note.Title, h.Snippet)
+	}
+	return tw.Flush()
+}
+
+func (c *cli) cmdLinks(args []string) error {
+	fset := c.flags("links")
+	asJSON := fset.Bool("json", false, "print links as JSON")
+	if err := parse(fset, args, 1, 1); err != nil {
+		return err
+	}
+
+	n, err := c.find(fset.Arg(0))
+	if err != nil {
+		return err
+	}
+	graph, err := c.links()
+	if err != nil {
+		return err
+	}
+	out := graph.Outgoing(n.ID)
+	back := graph.Backlinks(n.ID)
+
+	if *asJSON {
+		res := linksJSON{Links: []linkJSON{}, Backlinks: []noteJSON{}}
+		for _, r := range out {
+			l := linkJSON{Target: r.Target}
+			if r.Resolved {
+				note := toJSON(r.Note)
+				l.Note = &note
+			}
+			res.Links = append(res.Links, l)
+		}
+		for _, b := range back {
+			res.Backlinks = append(res.Backlinks, toJSON(b))
+		}
+		return c.writeJSON(res)
+	}
+
+	tw := tabwriter.NewWriter(c.stdout, 0, 4, 2, ' ', 0)
+	for _, r := range out {
+		if r.Resolved {
+			fmt.Fprintf(tw, "->\t%s\t%s\n", r.Note.ID, r.Note.Title)
+		} else {
+			fmt.Fprintf(tw, "->\t-\t%s (missing)\n", r.Target)
+		}
+	}
+	for _, b := range back {
+		fmt.Fprintf(tw, "<-\t%s\t%s\n", b.ID, b.Title)
+	}
+	return tw.Flush()
+}
+
+func (c *cli) cmdTags(args []string) error {
+	fset := c.flags("tags")
+	asJSON := fset.Bool("json", false, "print tags as JSON")
+	if err := parse(fset, args, 0, 0); err != nil {
+		return err
+	}
+
+	idx, err := c.index()
+	if err != nil {
+		return err
+	}
+	counts := idx.Tags()
+
+	if *asJSON {
+		out := make([]tagJSON, 0, len(counts))
+		for _, t := range counts {
+			out = append(out, tagJSON{Name: t.Name, Notes: t.Notes})
+		}
+		return c.writeJSON(out)
+	}
+	tw := tabwriter.NewWriter(c.stdout, 0, 4, 2, ' ', 0)
+	for _, t := range counts {
+		fmt.Fprintf(tw, "#%s\t%d\n", t.Name, t.Notes)
+	}
+	return tw.Flush()
+}
+
+func (c *cli) cmdRenameTag(args []string) error {
+	fset := c.flags("renametag")
+	if err := parse(fset, args, 2, 2); err != nil {
+		return err
+	}
+	return c.retag(fset.Args()[:1], fset.Arg(1))
+}
+
+func (c *cli) cmdMergeTags(args []string) error {
+	fset := c.flags("mergetags")
+	if err := parse(fset, args, 2, 1<<30); err != nil {
+		return err
+	}
+	from := fset.Args()[:fset.NArg()-1]
+	return c.retag(from, fset.Arg(fset.NArg()-1))
+}
+
+// retag renames from to to in every note and reports the notes it changed.
+func (c *cli) retag(from []string, to string) error {
+	for _, t := range append([]string{to}, from...) {
+		if tags.Normalize(t) == "" {
+			return usagef("invalid tag %q", t)
+		}
+	}
+	changed, err := tags.Retag(c.store, from, to)
+	for _, n := range changed {
+		fmt.Fprintf(c.stdout, "%s\t%s\n", n.ID, n.Title)
+	}
+	return err
+}
+
+func (c *cli) cmdPin(args []string) error   { return c.setPinned("pin", args, true) }
+func (c *cli) cmdUnpin(args []string) error { return c.setPinned("unpin", args, false) }
+
+func (c *cli) setPinned(name string, args []string, pinned bool) error {
+	fset := c.flags(name)
+	asJSON := fset.Bool("json", false, "print the note as JSON")
+	if err := parse(fset, args, 1, 1); err != nil {
+		return err
+	}
+
+	n, err := c.find(fset.Arg(0))
+	if err != nil {
+		return err
+	}
+	if n.Section == fs.SectionTrash {
+		return fmt.Errorf("note %s is in the trash", n.ID)
+	}
+	if _, err := storage.SetPinned(c.store, n, pinned); err != nil {
+		return err
+	}
+	if *asJSON {
+		if n, err = c.find(n.ID); err != nil {
+			return err
+		}
+		return c.writeJSON(toJSON(n))
+	}
+	return nil
+}
+
+func (c *cli) cmdTrash(args []string) error {
+	fset := c.flags("trash")
+	asJSON := fset.Bool("json", false, "print the trashed note as JSON")
+	if err := parse(fset, args, 1, 1); err != nil {
+		return err
+	}
+
+	n, err := c.find(fset.Arg(0))
+	if err != nil {
+		return err
+	}
+	if n, err = c.store.MoveToTrash(n); err != nil {
+		return err
+	}
+	if *asJSON {
+		return c.writeJSON(toJSON(n))
+	}
+	return nil
+}
+
+func (c *cli) cmdRestore(args []string) error {
+	fset := c.flags("restore")
+	to := fset.String("to", "", "notebook to restore into instead of the original one")
+	asJSON := fset.Bool("json", false, "print the restored note as JSON")
+	if err := parse(fset, args, 1, 1); err != nil {
+		return err
+	}
+
+	n, err := c.find(fset.Arg(0))
+	if err != nil {
+		return err
+	}
+	if n.Section != fs.SectionTrash {
+		return fmt.Errorf("note %s is not in the trash", n.ID)
+	}
+	var target fs.Section
+	if *to != "" {
+		if target, err = parseNotebook(*to); err != nil {
+			return err
+		}
+	}
+	if n, err = c.store.RestoreFromTrash(n, target); err != nil {
+		return err
+	}
+	if *asJSON {
+		return c.writeJSON(toJSON(n))
+	}
+	return nil
+}
+
+func (c *cli) cmdNotebooks(args []string) error {
+	fset := c.flags("notebooks")
+	asJSON := fset.Bool("json", false, "print notebooks as JSON")
+	if err := parse(fset, args, 0, 0); err != nil {
+		return err
+	}
+
+	sections, err := c.store.Sections()
+	if err != nil {
+		return err
+	}
+	var out []notebookJSON
+	for _, sec := range sections {
+		if !sec.IsNotebook() {
+			continue
+		}
+		notes, err := c.store.List(sec)
+		if err != nil {
+			return err
+		}
+		out = append(out, notebookJSON{Section: sec, Name: sec.Name(), Depth: sec.Depth(), Notes: len(notes)})
+	}
+
+	if *asJSON {
+		return c.writeJSON(out)
+	}
+	tw := tabwriter.NewWriter(c.stdout, 0, 4, 2, ' ', 0)
+	for _, nb := range out {
+		fmt.Fprintf(tw, "%s\t%d\n", nb.Section, nb.Notes)
+	}
+	return tw.Flush()
+}
+
+func (c *cli) cmdMkNotebook(args []string) error {
+	fset := c.flags("mknotebook")
+	if err := parse(fset, args, 1, 1); err != nil {
+		return err
+	}
+	target, err := parseNotebook(fset.Arg(0))
+	if err != nil {
+		return err
+	}
+	if target == fs.SectionNotes {
+		return usagef("the root notebook always exists")
+	}
+	nbs, err := c.notebooks()
+	if err != nil {
+		return err
+	}
+
+	existing, err := c.store.Sections()
+	if err != nil {
+		return err
+	}
+	have := make(map[fs.Section]bool, len(existing))
+	for _, sec := range existing {
+		have[sec] = true
+	}
+	if have[target] {
+		return fmt.Errorf("notebook %s already exists", target)
+	}
+
+	parent := fs.SectionNotes
+	for _, name := range strings.Split(target.Rel(), "/") {
+		nb := fs.Notebook(parent.Rel() + "/" + name)
+		if !have[nb] {
+			if _, err := nbs.CreateNotebook(parent, name); err != nil {
+				return err
+			}
+		}
+		parent = nb
+	}
+	return nil
+}
+
+func (c *cli) cmdRmNotebook(args []string) error {
+	fset := c.flags("rmnotebook")
+	if err := parse(fset, args, 1, 1); err != nil {
+		return err
+	}
+	nb, err := parseNotebook(fset.Arg(0))
+	if err != nil {
+		return err
+	}
+	nbs, err := c.notebooks()
+	if err != nil {
+		return err
+	}
+	return nbs.DeleteNotebook(nb)
+}
+
+func (c *cli) cmdMove(args []string) error {
+	fset := c.flags("move")
+	asJSON := fset.Bool("json", false, "print the moved note as JSON")
+	if err := parse(fset, args, 2, 2); err != nil {
+		return err
+	}
+
+	n, err := c.find(fset.Arg(0))
+	if err != nil {
+		return err
+	}
+	target, err := parseNotebook(fset.Arg(1))
+	if err != nil {
+		return err
+	}
+	nbs, err := c.notebooks()
+	if err != nil {
+		return err
+	}
+	if n, err = nbs.Move(n, target); err != nil {
+		return err
+	}
+	if *asJSON {
+		return c.writeJSON(toJSON(n))
+	}
+	return nil
+}
+
+func (c *cli) cmdPurge(args []string) error {
+	fset := c.flags("purge")
+	all := fset.Bool("all", false, "empty the trash, however recently the notes were trashed")
+	asJSON := fset.Bool("json", false, "print the deleted notes as JSON")
+	if err := parse(fset, args, 0, 0); err != nil {
+		return err
+	}
+
+	var purged []fs.Note
+	if *all {
+		notes, err := c.store.List(fs.SectionTrash)
+		if err != nil {
+			return err
+		}
+		for _, n := range notes {
+			if err := c.store.DeleteFromTrash(n); err != nil {
+				return err
+			}
+			purged = append(purged, n)
+		}
+	} else {
+		if storage.TrashRetention(c.cfg) == 0 {
+			return errors.New("trash_retention keeps notes until deleted; use --all to empty the trash")
+		}
+		var err error
+		if purged, err = storage.PurgeExpired(c.cfg, c.store); err != nil {
+			return err
+		}
+	}
+
+	if *asJSON {
+		out := make([]noteJSON, 0, len(purged))
+		for _, n := range purged {
+			out = append(out, toJSON(n))
+		}
+		return c.writeJSON(out)
+	}
+	return nil
+}
+
+func (c *cli) cmdImport(args []string) error {
+	fset := c.flags("import")
+	notebook := fset.String("notebook", string(fs.SectionNotes), "notebook to import into; the export's notebooks go below it")
+	asJSON := fset.Bool("json", false, "print the imported notes and the problems as JSON")
+	if err := parse(fset, args, 2, 2); err != nil {
+		return err
+	}
+	format, path := fset.Arg(0), fset.Arg(1)
+	if !slices.Contains(importer.Formats, format) {
+		return usagef("unknown format %q; expected one of %s", format, strings.Join(importer.Formats, ", "))
+	}
+	into, err := parseNotebook(*notebook)
+	if err != nil {
+		return err
+	}
+
+	src, err := importer.Read(format, path)
+	if err != nil {
+		return err
+	}
+	report, err := importer.Write(c.store, src, into)
+
+	if *asJSON {
+		out := importJSON{Imported: []noteJSON{}, Problems: []problemJSON{}}
+		for _, n := range report.Notes {
+			out.Imported = append(out.Imported, toJSON(n))
+		}
+		for _, p := range report.Problems {
+			out.Problems = append(out.Problems, problemJSON{Item: p.Item, Reason: p.Reason})
+		}
+		if jsonErr := c.writeJSON(out); jsonErr != nil {
+			return jsonErr
+		}
+		return err
+	}
+
+	fmt.Fprintf(c.stderr, "imported %d of %d notes, %d problems\n", len(report.Notes), len(src.Notes), len(report.Problems))
+	tw := tabwriter.NewWriter(c.stdout, 0, 4, 2, ' ', 0)
+	// After a failure, say which notes made it so the import can be
+	// finished by hand.
+	if err != nil {
+		for _, n := range report.Notes {
+			fmt.Fprintf(tw, "%s\timported as %s\n", n.Title, n.ID)
+		}
+	}
+	for _, p := range report.Problems {
+		fmt.Fprintf(tw, "%s\t%s\n", p.Item, p.Reason)
+	}
+	if flushErr := tw.Flush(); flushErr != nil {
+		return flushErr
+	}
+	return err
+}
+
+func (c *cli) cmdExport(args []string) error {
+	fset := c.flags("export")
+	format := fset.String("format", "markdown", "html, site, markdown or epub")
+	out := fset.String("out", "", "file or directory to write; markdown goes to stdout without it")
+	section := fset.String("section", "", "export this section and the notebooks inside it")
+	query := fset.String("search", "", "export the notes matching this search")
+	title := fset.String("title", "Notes", "title of the site or book")
+	if err := parse(fset, args, 0, 1<<30); err != nil {
+		return err
+	}
+	if !slices.Contains(exporter.Formats, *format) {
+		return usagef("unknown format %q; expected one of %s", *format, strings.Join(exporter.Formats, ", "))
+	}
+	if *out == "" && *format != "markdown" {
+		return usagef("--out is required for %s", *format)
+	}
+	if fset.NArg() > 0 && (*section != "" || *query != "") {
+		return usagef("note ids cannot be combined with --section or --search")
+	}
+
+	selected, err := c.exportNotes(fset.Args(), *section, *query)
+	if err != nil {
+		return err
+	}
+	if len(selected) == 0 {
+		return errors.New("no notes to export")
+	}
+	notes, err := exporter.Load(c.store, selected)
+	if err != nil {
+		return err
+	}
+
+	switch *format {
+	case "html":
+		if len(notes) == 1 && strings.HasSuffix(*out, ".html") {
+			err = createFile(*out, func(w io.Writer) error { return exporter.HTMLPage(w, notes[0], notes) })
+		} else {
+			err = exporter.HTML(notes, *out)
+		}
+	case "site":
+		err = exporter.Site(notes, *out, *title)
+	case "markdown":
+		if *out == "" {
+			err = exporter.Markdown(c.stdout, notes)
+		} else {
+			err = createFile(*out, func(w io.Writer) error { return exporter.Markdown(w, notes) })
+		}
+	case "epub":
+		err = createFile(*out, func(w io.Writer) error { return exporter.EPUB(w, notes, *title) })
+	}
+	if err != nil {
+		return err
+	}
+	if *out != "" {
+		fmt.Fprintf(c.stderr, "exported %d notes to %s\n", len(notes), *out)
+	}
+	return nil
+}
+
+// exportNotes picks the notes to export: the ones with ids, or else those
+// matching query, in section and the notebooks inside it, or everywhere
+// but the trash. Notes listed from notebooks are ordered by title, search
+// results by rank.
+func (c *cli) exportNotes(ids []string, section, query string) ([]fs.Note, error) {
+	if len(ids) > 0 {
+		notes := make([]fs.Note, 0, len(ids))
+		for _, id := range ids {
+			n, err := c.find(id)
+			if err != nil {
+				return nil, err
+			}
+			notes = append(notes, n)
+		}
+		return notes, nil
+	}
+
+	sections, err := c.store.Sections()
+	if err != nil {
+		return nil, err
+	}
+	var want []fs.Section
+	if section != "" {
+		sec, err := parseSection(section)
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range sections {
+			if inSection(s, sec) {
+				want = append(want, s)
+			}
+		}
+		if len(want) == 0 {
+			return nil, fmt.Errorf("notebook %s does not exist", sec)
+		}
+	} else {
+		for _, s := range sections {
+			if s != fs.SectionTrash {
+				want = append(want, s)
+			}
+		}
+	}
+
+	if query != "" {
+		idx, err := c.index()
+		if err != nil {
+			return nil, err
+		}
+		hits, err := idx.Search(c.store, query, search.Options{Sections: want})
+		if err != nil {
+			return nil, usageError{msg: err.Error()}
+		}
+		notes := make([]fs.Note, 0, len(hits))
+		for _, h := range hits {
+			notes = append(notes, h.Note)
+		}
+		return notes, nil
+	}
+
+	var notes []fs.Note
+	for _, sec := range want {
+		list, err := c.store.List(sec)
+		if err != nil {
+			return nil, err
+		}
+		slices.SortStableFunc(list, func(a, b fs.Note) int {
+			return strings.Compare(strings.ToLower(a.Title), strings.ToLower(b.Title))
+		})
+		notes = append(notes, list...)
+	}
+	return notes, nil
+}
+
+// createFile creates path and has write fill it.
+func createFile(path string, write func(w io.Writer) error) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (c *cli) cmdEncrypt(args []string) error {
+	fset := c.flags("encrypt")
+	if err := parse(fset, args, 0, 0); err != nil {
+		return err
+	}
+
+	// An encrypted store was unlocked by open; encrypting it again
+	// finishes an interrupted migration with the same passphrase.
+	pass := c.pass
+	if c.key == nil {
+		pass = os.Getenv(passphraseEnv)
+	}
+	if pass == "" {
+		p, err := c.readPassword("New passphrase: ")
+		if err != nil {
+			return err
+		}
+		again, err := c.readPassword("Repeat passphrase: ")
+		if err != nil {
+			return err
+		}
+		if p != again {
+			return errors.New("passphrases do not match")
+		}
+		pass = p
+	}
+
+	n, err := storage.Encrypt(c.cfg, pass)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(c.stderr, "encrypted %d files\n", n)
+	return nil
+}
+
+func (c *cli) cmdDecrypt(args []string) error {
+	fset := c.flags("decrypt")
+	if err := parse(fset, args, 0, 0); err != nil {
+		return err
+	}
+	if c.key == nil {
+		return errors.New("note store is not encrypted")
+	}
+
+	n, err := storage.Decrypt(c.cfg, c.key)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(c.stderr, "decrypted %d files\n", n)
+	return nil
+}
+
+func (c *cli) cmdSync(args []string) error {
+	fset := c.flags("sync")
+	asJSON := fset.Bool("json", false, "print the result as JSON")
+	if err := parse(fset, args, 0, 0); err != nil {
+		return err
+	}
+	syncer, ok := c.store.(storage.Syncer)
+	if !ok {
+		return errors.New(`sync needs the "git" storage backend`)
+	}
+
+	res, err := syncer.Sync()
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		out := syncJSON{Pulled: res.Pulled, Pushed: res.Pushed, Conflicts: []noteJSON{}}
+		for _, n := range res.Conflicts {
+			out.Conflicts = append(out.Conflicts, toJSON(n))
+		}
+		return c.writeJSON(out)
+	}
+
+	pushed := "nothing to push"
+	if res.Pushed {
+		pushed = "pushed"
+	}
+	fmt.Fprintf(c.stderr, "pulled %d commits, %s\n", res.Pulled, pushed)
+	if len(res.Conflicts) > 0 {
+		fmt.Fprintf(c.stderr, "%d conflicted notes; edit them to resolve:\n", len(res.Conflicts))
+		tw := tabwriter.NewWriter(c.stdout, 0, 4, 2, ' ', 0)
+		for _, n := range res.Conflicts {
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", n.ID, n.Section, n.Title)
+		}
+		return tw.Flush()
+	}
+	return nil
+}
+
+// ---------------------------------------------------------------------------
+// helpers
+// ---------------------------------------------------------------------------
+
+// ask prompts on stderr until a line from stdin is one of choices.
+func (c *cli) ask(prompt string, choices ...string) (string, error) {
+	in := bufio.NewReader(c.stdin)
+	for {
+		fmt.Fprint(c.stderr, prompt)
+		line, err := in.ReadString('\n')
+		if answer := strings.ToLower(strings.TrimSpace(line)); slices.Contains(choices, answer) {
+			return answer, nil
+		}
+		if err != nil {
+			return "", fmt.Errorf("note not saved: %w", fs.ErrConflict)
+		}
+	}
+}
+
+// discard deletes a note that was created but could not be written, so a
+// failed tenote new leaves no empty note behind, and returns cause.
+func (c *cli) discard(n fs.Note, cause error) error {
+	trashed, err := c.store.MoveToTrash(n)
+	if err == nil {
+		err = c.store.DeleteFromTrash(trashed)
+	}
+	if err != nil {
+		fmt.Fprintf(c.stderr, "tenote: delete empty note %s: %v\n", n.ID, err)
+	}
+	return cause
+}
+
+// template reads the template called name from the templates directory.
+func (c *cli) template(name string) (templates.Template, error) {
+	dir, err := storage.TemplateDir(c.cfg)
+	if err != nil {
+		return templates.Template{}, err
+	}
+	t, err := templates.Find(dir, name)
+	if errors.Is(err, templates.ErrNotFound) {
+		return t, usagef("no template %q in %s", name, dir)
+	}
+	return t, err
+}
+
+// askFields asks on the terminal for the template fields that values has
+// no value for. Without a terminal, and for empty answers, fields get their
+// defaults.
+func (c *cli) askFields(fields []templates.Field, values map[string]string) error {
+	f, ok := c.stdin.(*os.File)
+	if !ok || !term.IsTerminal(int(f.Fd())) {
+		return nil
+	}
+	in := bufio.NewReader(c.stdin)
+	for _, field := range fields {
+		if _, ok := values[field.Name]; ok {
+			continue
+		}
+		if field.Default != "" {
+			fmt.Fprintf(c.stderr, "%s [%s]: ", field.Name, field.Default)
+		} else {
+			fmt.Fprintf(c.stderr, "%s: ", field.Name)
+		}
+		line, err := in.ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("read %s: %w", field.Name, err)
+		}
+		if line = strings.TrimSpace(line); line != "" {
+			values[field.Name] = line
+		}
+		if err != nil {
+			return nil
+		}
+	}
+	return nil
+}
+
+// find looks a note up by ID in every section. IDs are matched without
+// regard to case, and a unique prefix of one is accepted too. An ID that
+// names notes in several notebooks is ambiguous.
+func (c *cli) find(arg string) (fs.Note, error) {
+	return c.lookup(arg, true)
+}
+
+// get looks a note up by its full ID. Callers that are not a person typing
+// take no prefixes, so they cannot hit another note than the one meant.
+func (c *cli) get(id string) (fs.Note, error) {
+	return c.lookup(id, false)
+}
+
+func (c *cli) lookup(arg string, prefixes bool) (fs.Note, error) {
+	id := strings.ToUpper(strings.TrimSpace(arg))
+	if id == "" {
+		return fs.Note{}, usagef("empty note id")
+	}
+
+	sections, err := c.store.Sections()
+	if err != nil {
+		return fs.Note{}, err
+	}
+	var exact, matches []fs.Note
+	for _, sec := range sections {
+		notes, err := c.store.List(sec)
+		if err != nil {
+			return fs.Note{}, err
+		}
+		for _, n := range notes {
+			switch {
+			case strings.EqualFold(n.ID, id):
+				exact = append(exact, n)
+			case prefixes && strings.HasPrefix(strings.ToUpper(n.ID), id):
+				matches = append(matches, n)
+			}
+		}
+	}
+	if len(exact) > 0 {
+		matches = exact
+	}
+
+	switch len(matches) {
+	case 0:
+		return fs.Note{}, fmt.Errorf("%w: %s", errNotFound, arg)
+	case 1:
+		return matches[0], nil
+	default:
+		return fs.Note{}, fmt.Errorf("%w: %s matches %d notes", errAmbiguous, arg, len(matches))
+	}
+}
+
+// index opens the search index and brings it up to date.
+func (c *cli) index() (*search.Index, error) {
+	idx, err := storage.OpenIndex(c.cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := idx.Sync(c.store); err != nil {
+		return nil, err
+	}
+	return idx, nil
+}
+
+// tagged returns the notes outside the trash carrying all, or with anyOf
+// any, of want.
+func (c *cli) tagged(want []string, anyOf bool) ([]fs.Note, error) {
+	for i, t := range want {
+		if want[i] = tags.Normalize(t); want[i] == "" {
+			return nil, usagef("invalid tag %q", t)
+		}
+	}
+	idx, err := c.index()
+	if err != nil {
+		return nil, err
+	}
+	return idx.Tagged(want, anyOf), nil
+}
+
+// flagSet reports whether the named flag was given on the command line.
+func flagSet(fset *flag.FlagSet, name string) bool {
+	found := false
+	fset.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			found = true
+		}
+	})
+	return found
+}
+
+// links opens the link graph and brings it up to date.
+func (c *cli) links() (*links.Graph, error) {
+	graph, err := storage.OpenLinks(c.cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := graph.Sync(c.store); err != nil {
+		return nil, err
+	}
+	return graph, nil
+}
+
+// watch keeps the store's notion of its notes current while a long-running
+// command, like serve or mcp, answers from it. The returned func stops
+// watching.
+func (c *cli) watch() (func(), error) {
+	w, err := storage.Watch(c.cfg)
+	if err != nil || w == nil {
+		return func() {}, err
+	}
+	go func() {
+		for ev := range w.Events() {
+			storage.Refresh(c.store, ev.Paths)
+		}
+	}()
+	return func() { w.Close() }, nil
+}
+
+// stdinIsPiped reports whether r is a pipe or a file rather than a
+// terminal. Readers that are not files never count as piped input.
+func stdinIsPiped(r io.Reader) bool {
+	f, ok := r.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice == 0
+}
+
+func (c *cli) writeJSON(v any) error {
+	enc := json.NewEncoder(c.stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// noteJSON is the stable JSON shape of a note in CLI output.
+type noteJSON struct {
+	ID        string         `json:"id"`
+	Title     string         `json:"title"`
+	Section   fs.Section     `json:"section"`
+	Path      string         `json:"path"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	Created   *time.Time     `json:"created,omitempty"`
+	Tags      []string       `json:"tags"`
+	Aliases   []string       `json:"aliases"`
+	Pinned    bool           `json:"pinned"`
+	Fields    map[string]any `json:"fields,omitempty"`
+	DeletedAt *time.Time     `json:"deleted_at,omitempty"`
+	Origin    fs.Section     `json:"origin,omitempty"`
+	Body      *string        `json:"body,omitempty"`
+}
+
+type notebookJSON struct {
+	Section fs.Section `json:"section"`
+	Name    string     `json:"name"`
+	Depth   int        `json:"depth"`
+	Notes   int        `json:"notes"`
+}
+
+type templateJSON struct {
+	Name   string   `json:"name"`
+	Fields []string `json:"fields"`
+}
+
+type tagJSON struct {
+	Name  string `json:"name"`
+	Notes int    `json:"notes"`
+}
+
+type linkJSON struct {
+	Target string    `json:"target"`
+	Note   *noteJSON `json:"note"` // null when the target does not resolve
+}
+
+type linksJSON struct {
+	Links     []linkJSON `json:"links"`
+	Backlinks []noteJSON `json:"backlinks"`
+}
+
+type syncJSON struct {
+	Pulled    int        `json:"pulled"`
+	Pushed    bool       `json:"pushed"`
+	Conflicts []noteJSON `json:"conflicts"`
+}
+
+type importJSON struct {
+	Imported []noteJSON    `json:"imported"`
+	Problems []problemJSON `json:"problems"`
+}
+
+type problemJSON struct {
+	Item   string `json:"item"`
+	Reason string `json:"reason"`
+}
+
+type hitJSON struct {
+	noteJSON
+	Score   float64 `json:"score"`
+	Snippet string  `json:"snippet"`
+	Line    int     `json:"line"`
+}
+
+func toJSON(n fs.Note) noteJSON {
+	out := noteJSON{
+		ID:        n.ID,
+		Title:     n.Title,
+		Section:   n.Section,
+		Path:      n.Path,
+		UpdatedAt: n.UpdatedAt,
+		Tags:      n.Tags,
+		Aliases:   n.Aliases,
+		Pinned:    n.Pinned,
+		Fields:    n.Fields,
+		Origin:    n.Origin,
+	}
+	if out.Tags == nil {
+		out.Tags = []string{}
+	}
+	if out.Aliases == nil {
+		out.Aliases = []string{}
+	}
+	if !n.Created.IsZero() {
+		created := n.Created
+		out.Created = &created
+	}
+	if !n.DeletedAt.IsZero() {
+		deleted := n.DeletedAt
+		out.DeletedAt = &deleted
+	}
+	return out
+}
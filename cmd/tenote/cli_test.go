@@ -0,0 +1,350 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/internet-kid/tenote/internal/config"
+	"github.com/internet-kid/tenote/internal/storage/fs"
+	"github.com/internet-kid/tenote/internal/storage/memory"
+)
+
+// setupCLI points the config at a store in a temporary directory, the way
+// a user's config would, so runCLI can be driven end to end. It returns
+// the storage directory.
+func setupCLI(t *testing.T) string {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv(passphraseEnv, "")
+	dir := filepath.Join(home, ".config", "tenote")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	root := filepath.Join(home, "notes")
+	cfg := `{"storage_dir": "` + root + `", "watch_poll": -1}`
+	if err := os.WriteFile(filepath.Join(dir, "config.json"), []byte(cfg), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return root
+}
+
+// runTest runs a subcommand with stdin as its input and returns the exit
+// code and what it printed.
+func runTest(t *testing.T, stdin string, args ...string) (code int, stdout, stderr string) {
+	t.Helper()
+	in, err := os.CreateTemp(t.TempDir(), "stdin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer in.Close()
+	if _, err := in.WriteString(stdin); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := in.Seek(0, 0); err != nil {
+		t.Fatal(err)
+	}
+	var out, errOut bytes.Buffer
+	code = runCLI(args, in, &out, &errOut)
+	return code, out.String(), errOut.String()
+}
+
+// mustNew creates a note and returns its ID.
+func mustNew(t *testing.T, title string) string {
+	t.Helper()
+	code, out, errOut := runTest(t, "", "new", title)
+	if code != exitOK {
+		t.Fatalf("new %q: exit %d: %s", title, code, errOut)
+	}
+	return strings.TrimSpace(out)
+}
+
+func TestCLIExitCodes(t *testing.T) {
+	setupCLI(t)
+	a := mustNew(t, "Alpha")
+	b := mustNew(t, "Beta")
+
+	// The longest prefix the two IDs share matches both notes.
+	n := 0
+	for n < len(a) && a[n] == b[n] {
+		n++
+	}
+	if n == 0 {
+		t.Fatalf("IDs %s and %s share no prefix", a, b)
+	}
+
+	tests := []struct {
+		name string
+		args []string
+		want int
+	}{
+		{"ok", []string{"show", a}, exitOK},
+		{"help", []string{"help"}, exitOK},
+		{"unknown command", []string{"frobnicate"}, exitUsage},
+		{"bad flag", []string{"list", "--frob"}, exitUsage},
+		{"missing argument", []string{"show"}, exitUsage},
+		{"not found", []string{"show", "ZZZZZZZZ"}, exitNotFound},
+		{"ambiguous", []string{"show", a[:n]}, exitAmbiguous},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code, _, errOut := runTest(t, "", tt.args...); code != tt.want {
+				t.Errorf("tenote %s: exit %d, want %d; stderr: %s", strings.Join(tt.args, " "), code, tt.want, errOut)
+			}
+		})
+	}
+}
+
+func TestCLIFindByFileName(t *testing.T) {
+	root := setupCLI(t)
+	notes := filepath.Join(root, "notes")
+	write := func(rel, body string) {
+		t.Helper()
+		path := filepath.Join(notes, rel)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Notes made by other tools keep their file names.
+	write("todo.md", "# Todo\n")
+	write("work/Plan-Q3.md", "# Plan\n")
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"show", "todo"}, "# Todo\n"},
+		{[]string{"show", "TODO"}, "# Todo\n"},
+		{[]string{"show", "plan-q"}, "# Plan\n"},
+	}
+	for _, tt := range tests {
+		code, out, errOut := runTest(t, "", tt.args...)
+		if code != exitOK || out != tt.want {
+			t.Errorf("tenote %s: exit %d, %q, want %q; stderr: %s", strings.Join(tt.args, " "), code, out, tt.want, errOut)
+		}
+	}
+
+	paths, err := config.ResolvePathsFrom(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &cli{store: fs.NewStore(paths, fs.WithoutWatcher())}
+	if n, err := c.get("Todo"); err != nil || n.ID != "todo" {
+		t.Fatalf("get(Todo) = %+v, %v", n, err)
+	}
+	if _, err := c.get("plan-q"); !errors.Is(err, errNotFound) {
+		t.Fatalf("get of a prefix = %v, want not found", err)
+	}
+
+	// The same ID in two notebooks names neither.
+	write("work/TODO.md", "# Other todo\n")
+	if code, _, errOut := runTest(t, "", "trash", "todo"); code != exitAmbiguous {
+		t.Fatalf("trash of an ID in two notebooks: exit %d, want %d; stderr: %s", code, exitAmbiguous, errOut)
+	}
+	if _, err := c.get("todo"); !errors.Is(err, errAmbiguous) {
+		t.Fatalf("get of an ID in two notebooks = %v, want ambiguous", err)
+	}
+}
+
+func TestCLINewFromTemplate(t *testing.T) {
+	root := setupCLI(t)
+	paths, err := config.ResolvePathsFrom(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(paths.Templates, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	tmpl := "---\ntags: [meeting]\n---\nAttendees: {{who:team}}\n"
+	if err := os.WriteFile(filepath.Join(paths.Templates, "meeting.md"), []byte(tmpl), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	code, out, errOut := runTest(t, "", "new", "--template", "meeting", "--json", "Standup")
+	if code != exitOK {
+		t.Fatalf("new: exit %d: %s", code, errOut)
+	}
+	var n noteJSON
+	if err := json.Unmarshal([]byte(out), &n); err != nil {
+		t.Fatalf("new --json: %v\n%s", err, out)
+	}
+	if n.Title != "Standup" || len(n.Tags) != 1 || n.Tags[0] != "meeting" {
+		t.Errorf("note from a template without {{title}} = %+v, want its title and the template's tags", n)
+	}
+	body, err := os.ReadFile(n.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "---\ntags: [meeting]\n---\n# Standup\n\nAttendees: team\n"; string(body) != want {
+		t.Errorf("body = %q, want %q", body, want)
+	}
+}
+
+func TestCLIJSON(t *testing.T) {
+	setupCLI(t)
+
+	code, out, errOut := runTest(t, "piped text\n", "new", "--json", "Piped")
+	if code != exitOK {
+		t.Fatalf("new: exit %d: %s", code, errOut)
+	}
+	var created noteJSON
+	if err := json.Unmarshal([]byte(out), &created); err != nil {
+		t.Fatalf("new --json: %v\n%s", err, out)
+	}
+	if created.Title != "Piped" || created.Section != fs.SectionNotes {
+		t.Errorf("new --json = %+v", created)
+	}
+
+	code, out, errOut = runTest(t, "", "list", "--json")
+	if code != exitOK {
+		t.Fatalf("list: exit %d: %s", code, errOut)
+	}
+	var list []map[string]any
+	if err := json.Unmarshal([]byte(out), &list); err != nil {
+		t.Fatalf("list --json: %v\n%s", err, out)
+	}
+	if len(list) != 1 {
+		t.Fatalf("list --json has %d notes, want 1", len(list))
+	}
+	for _, key := range []string{"id", "title", "section", "path", "updated_at", "tags", "aliases", "pinned"} {
+		if _, ok := list[0][key]; !ok {
+			t.Errorf("list --json note has no %q: %v", key, list[0])
+		}
+	}
+	if _, ok := list[0]["body"]; ok {
+		t.Errorf("list --json note has a body: %v", list[0])
+	}
+	if tags, ok := list[0]["tags"].([]any); !ok || len(tags) != 0 {
+		t.Errorf("tags = %#v, want an empty array", list[0]["tags"])
+	}
+
+	code, out, errOut = runTest(t, "", "show", "--json", created.ID)
+	if code != exitOK {
+		t.Fatalf("show: exit %d: %s", code, errOut)
+	}
+	var shown noteJSON
+	if err := json.Unmarshal([]byte(out), &shown); err != nil {
+		t.Fatalf("show --json: %v\n%s", err, out)
+	}
+	if shown.ID != created.ID || shown.Body == nil {
+		t.Fatalf("show --json = %+v", shown)
+	}
+	if want := "# Piped\n\npiped text\n"; *shown.Body != want {
+		t.Errorf("body = %q, want %q", *shown.Body, want)
+	}
+}
+
+func TestCLIEdit(t *testing.T) {
+	setupCLI(t)
+	id := mustNew(t, "Edited")
+
+	script := filepath.Join(t.TempDir(), "fake editor")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\necho 'added line' >> \"$1\"\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("VISUAL", script)
+
+	if code, _, errOut := runTest(t, "", "edit", id); code != exitOK {
+		t.Fatalf("edit: exit %d: %s", code, errOut)
+	}
+	_, out, _ := runTest(t, "", "show", id)
+	if want := "# Edited\n\nadded line\n"; out != want {
+		t.Errorf("body after edit = %q, want %q", out, want)
+	}
+}
+
+func TestCLIPurge(t *testing.T) {
+	root := setupCLI(t)
+	recent := mustNew(t, "Recent")
+	if code, _, errOut := runTest(t, "", "trash", recent); code != exitOK {
+		t.Fatalf("trash: exit %d: %s", code, errOut)
+	}
+	// A note another tool put in the trash long ago.
+	old := filepath.Join(root, "trash", "01OLD.md")
+	if err := os.WriteFile(old, []byte("# Old\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	then := time.Now().Add(-90 * 24 * time.Hour)
+	if err := os.Chtimes(old, then, then); err != nil {
+		t.Fatal(err)
+	}
+
+	purged := func(args ...string) []string {
+		t.Helper()
+		code, out, errOut := runTest(t, "", append([]string{"purge", "--json"}, args...)...)
+		if code != exitOK {
+			t.Fatalf("purge: exit %d: %s", code, errOut)
+		}
+		var notes []noteJSON
+		if err := json.Unmarshal([]byte(out), &notes); err != nil {
+			t.Fatalf("purge --json: %v\n%s", err, out)
+		}
+		var ids []string
+		for _, n := range notes {
+			ids = append(ids, n.ID)
+		}
+		return ids
+	}
+	if ids := purged(); len(ids) != 1 || ids[0] != "01OLD" {
+		t.Fatalf("purge deleted %v, want only the expired note", ids)
+	}
+	if ids := purged(); len(ids) != 0 {
+		t.Fatalf("second purge deleted %v, want nothing", ids)
+	}
+	if ids := purged("--all"); len(ids) != 1 || ids[0] != recent {
+		t.Fatalf("purge --all deleted %v, want %s", ids, recent)
+	}
+	if _, out, _ := runTest(t, "", "list", "--section", "trash", "--json"); strings.TrimSpace(out) != "[]" {
+		t.Fatalf("trash after purge --all = %s", out)
+	}
+}
+
+// failingStore refuses every write.
+type failingStore struct {
+	*memory.Store
+}
+
+var errWrite = errors.New("disk full")
+
+func (failingStore) WriteBody(string, string) error { return errWrite }
+
+func TestCLINewDiscardsUnwrittenNote(t *testing.T) {
+	c := newTestCLI(t)
+	c.store = failingStore{memory.NewStore()}
+
+	if err := c.cmdNew([]string{"Doomed"}); !errors.Is(err, errWrite) {
+		t.Fatalf("new = %v, want %v", err, errWrite)
+	}
+	for _, sec := range []fs.Section{fs.SectionNotes, fs.SectionTrash} {
+		notes, err := c.store.List(sec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(notes) != 0 {
+			t.Errorf("%s has %d notes after a failed new, want 0", sec, len(notes))
+		}
+	}
+}
+
+func TestStdinIsPiped(t *testing.T) {
+	if stdinIsPiped(strings.NewReader("text")) {
+		t.Error("a reader that is not a file counts as piped")
+	}
+	f, err := os.CreateTemp(t.TempDir(), "stdin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if !stdinIsPiped(f) {
+		t.Error("a redirected file does not count as piped")
+	}
+}
@@ -6,11 +6,16 @@ import (
 
 	tea "github.com/charmbracelet/bubbletea"
 
+	"github.com/internet-kid/tenote/internal/storage/vault"
 	"github.com/internet-kid/tenote/internal/ui/app"
 	"github.com/internet-kid/tenote/internal/ui/menu"
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		os.Exit(runCLI(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+	}
+
 	if err := run(); err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
@@ -19,7 +24,13 @@ func main() {
 
 func run() error {
 	p := tea.NewProgram(newRoot(), tea.WithAltScreen())
-	if _, err := p.Run(); err != nil {
+	final, err := p.Run()
+	// The app closes itself when it quits, but not when the program is
+	// killed.
+	if r, ok := final.(root); ok {
+		r.app.Close()
+	}
+	if err != nil {
 		return fmt.Errorf("run: %w", err)
 	}
 	return nil
@@ -41,6 +52,7 @@ type root struct {
 	state  rootState
 	menu   menu.Model
 	app    app.Model
+	key    *vault.Key // unlocks an encrypted store while the app is open
 	width  int
 	height int
 }
@@ -61,17 +73,31 @@ func (r root) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	}
 
 	// Switch from menu → app when the user picks "Open Notes".
-	if _, ok := msg.(menu.OpenNotesMsg); ok {
-		appModel, err := app.NewModel()
+	if open, ok := msg.(menu.OpenNotesMsg); ok {
+		appModel, err := app.NewModel(open.Key)
 		if err != nil {
 			// Stay on the menu if the app fails to initialise.
+			open.Key.Wipe()
 			return r, nil
 		}
 		r.state = atApp
 		r.app = appModel
+		r.key = open.Key
 		// Seed the app with the current terminal dimensions.
 		next, cmd := r.app.Update(tea.WindowSizeMsg{Width: r.width, Height: r.height})
 		r.app = next.(app.Model)
+		return r, tea.Batch(cmd, r.app.Init())
+	}
+
+	// Back to the unlock prompt when an encrypted store locks itself.
+	if _, ok := msg.(app.LockedMsg); ok {
+		r.app.Close()
+		r.key.Wipe()
+		r.key = nil
+		r.app = app.Model{}
+		r.state = atMenu
+		var cmd tea.Cmd
+		r.menu, cmd = r.menu.Locked()
 		return r, cmd
 	}
 
@@ -0,0 +1,621 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"runtime/debug"
+	"slices"
+	"strings"
+
+	"github.com/internet-kid/tenote/internal/storage"
+	"github.com/internet-kid/tenote/internal/storage/fs"
+	"github.com/internet-kid/tenote/internal/storage/links"
+	"github.com/internet-kid/tenote/internal/storage/search"
+	"github.com/internet-kid/tenote/internal/storage/tags"
+)
+
+// mcpVersions are the MCP protocol versions tenote speaks, newest first.
+var mcpVersions = []string{"2025-06-18", "2025-03-26", "2024-11-05"}
+
+// noteURI prefixes the note ID in the URI of a note resource.
+const noteURI = "tenote://notes/"
+
+const mcpInstructions = `The notes are Markdown. The first line of a note, or the title in its YAML front matter, is its title. [[Title]] links to another note by title, alias or ID. Tags are the #tags in the text and the tags in the front matter.`
+
+// JSON-RPC error codes.
+const (
+	rpcParseError     = -32700
+	rpcInvalidRequest = -32600
+	rpcNoMethod       = -32601
+	rpcInvalidParams  = -32602
+	rpcInternalError  = -32603
+	rpcNoResource     = -32002
+)
+
+// writeRetries is how many times a write that lost a race with another
+// change to the note is tried again.
+const writeRetries = 3
+
+func (c *cli) cmdMCP(args []string) error {
+	fset := c.flags("mcp")
+	if err := parse(fset, args, 0, 0); err != nil {
+		return err
+	}
+	m := &mcpServer{c: c, readOnly: c.cfg.MCPReadOnly}
+	for _, s := range c.cfg.MCPSections {
+		sec, err := parseSection(s)
+		if err != nil {
+			return fmt.Errorf("mcp_sections: %w", err)
+		}
+		m.allowed = append(m.allowed, sec)
+	}
+
+	var err error
+	if m.index, err = c.index(); err != nil {
+		return err
+	}
+	if m.links, err = c.links(); err != nil {
+		return err
+	}
+	stopWatch, err := c.watch()
+	if err != nil {
+		return err
+	}
+	defer stopWatch()
+	return m.serve(c.stdin, c.stdout)
+}
+
+// mcpServer answers Model Context Protocol requests, one JSON-RPC message
+// per line, with the notes as resources and tools to search, read and
+// change them.
+type mcpServer struct {
+	c        *cli
+	index    *search.Index
+	links    *links.Graph
+	readOnly bool
+	allowed  []fs.Section // mcp_sections; empty for every notebook
+}
+
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"` // absent for notifications
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params"`
+}
+
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string { return e.Message }
+
+func (m *mcpServer) serve(in io.Reader, out io.Writer) error {
+	r := bufio.NewReader(in)
+	enc := json.NewEncoder(out)
+	for {
+		line, err := r.ReadBytes('\n')
+		if len(strings.TrimSpace(string(line))) > 0 {
+			if resp, ok := m.handle(line); ok {
+				if err := enc.Encode(resp); err != nil {
+					return fmt.Errorf("write mcp response: %w", err)
+				}
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("read mcp request: %w", err)
+		}
+	}
+}
+
+// handle answers one message. Notifications get no answer.
+func (m *mcpServer) handle(line []byte) (rpcResponse, bool) {
+	resp := rpcResponse{JSONRPC: "2.0", ID: json.RawMessage("null")}
+	var req rpcRequest
+	if err := json.Unmarshal(line, &req); err != nil {
+		resp.Error = &rpcError{Code: rpcParseError, Message: err.Error()}
+		return resp, true
+	}
+	if req.ID == nil {
+		return resp, false
+	}
+	resp.ID = req.ID
+	if req.JSONRPC != "2.0" || req.Method == "" {
+		resp.Error = &rpcError{Code: rpcInvalidRequest, Message: "not a JSON-RPC 2.0 request"}
+		return resp, true
+	}
+
+	result, err := m.call(req.Method, req.Params)
+	var re *rpcError
+	switch {
+	case errors.As(err, &re):
+		resp.Error = re
+	case err != nil:
+		resp.Error = &rpcError{Code: rpcInternalError, Message: err.Error()}
+	default:
+		resp.Result = result
+	}
+	return resp, true
+}
+
+func (m *mcpServer) call(method string, params json.RawMessage) (any, error) {
+	switch method {
+	case "initialize":
+		var p struct {
+			ProtocolVersion string `json:"protocolVersion"`
+		}
+		if err := unmarshalParams(params, &p); err != nil {
+			return nil, err
+		}
+		version := mcpVersions[0]
+		if slices.Contains(mcpVersions, p.ProtocolVersion) {
+			version = p.ProtocolVersion
+		}
+		return map[string]any{
+			"protocolVersion": version,
+			"capabilities": map[string]any{
+				"tools":     map[string]any{},
+				"resources": map[string]any{},
+			},
+			"serverInfo":   map[string]any{"name": "tenote", "version": buildVersion()},
+			"instructions": mcpInstructions,
+		}, nil
+	case "ping":
+		return map[string]any{}, nil
+	case "tools/list":
+		var list []mcpTool
+		for _, t := range mcpTools {
+			if !m.readOnly || t.Annotations.ReadOnly {
+				list = append(list, t)
+			}
+		}
+		return map[string]any{"tools": list}, nil
+	case "tools/call":
+		var p struct {
+			Name      string          `json:"name"`
+			Arguments json.RawMessage `json:"arguments"`
+		}
+		if err := unmarshalParams(params, &p); err != nil {
+			return nil, err
+		}
+		return m.callTool(p.Name, p.Arguments)
+	case "resources/list":
+		return m.listResources()
+	case "resources/templates/list":
+		return map[string]any{"resourceTemplates": []map[string]any{{
+			"uriTemplate": noteURI + "{id}",
+			"name":        "note",
+			"description": "A note by its ID",
+			"mimeType":    "text/markdown",
+		}}}, nil
+	case "resources/read":
+		var p struct {
+			URI string `json:"uri"`
+		}
+		if err := unmarshalParams(params, &p); err != nil {
+			return nil, err
+		}
+		return m.readResource(p.URI)
+	}
+	return nil, &rpcError{Code: rpcNoMethod, Message: "unknown method " + method}
+}
+
+func unmarshalParams(params json.RawMessage, v any) error {
+	if len(params) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(params, v); err != nil {
+		return &rpcError{Code: rpcInvalidParams, Message: "invalid params: " + err.Error()}
+	}
+	return nil
+}
+
+// buildVersion is the module version tenote was built from.
+func buildVersion() string {
+	if info, ok := debug.ReadBuildInfo(); ok {
+		return info.Main.Version
+	}
+	return "(devel)"
+}
+
+// ---------------------------------------------------------------------------
+// resources
+// ---------------------------------------------------------------------------
+
+func (m *mcpServer) listResources() (any, error) {
+	sections, err := m.sections()
+	if err != nil {
+		return nil, err
+	}
+	resources := []map[string]any{}
+	for _, sec := range sections {
+		notes, err := m.c.store.List(sec)
+		if err != nil {
+			return nil, err
+		}
+		for _, n := range notes {
+			resources = append(resources, map[string]any{
+				"uri":         noteURI + n.ID,
+				"name":        n.Title,
+				"description": fmt.Sprintf("In %s, updated %s", n.Section, n.UpdatedAt.Format("2006-01-02 15:04")),
+				"mimeType":    "text/markdown",
+			})
+		}
+	}
+	return map[string]any{"resources": resources}, nil
+}
+
+func (m *mcpServer) readResource(uri string) (any, error) {
+	id, ok := strings.CutPrefix(uri, noteURI)
+	if !ok {
+		return nil, &rpcError{Code: rpcNoResource, Message: "resource not found: " + uri}
+	}
+	n, err := m.note(id)
+	if errors.Is(err, errNotFound) {
+		return nil, &rpcError{Code: rpcNoResource, Message: "resource not found: " + uri}
+	}
+	if err != nil {
+		return nil, err
+	}
+	body, err := m.c.store.ReadBody(n.Path)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]any{"contents": []map[string]any{{
+		"uri":      uri,
+		"mimeType": "text/markdown",
+		"text":     body,
+	}}}, nil
+}
+
+// ---------------------------------------------------------------------------
+// tools
+// ---------------------------------------------------------------------------
+
+type mcpTool struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	InputSchema map[string]any `json:"inputSchema"`
+	Annotations mcpAnnotations `json:"annotations"`
+
+	run func(m *mcpServer, args json.RawMessage) (any, error)
+}
+
+type mcpAnnotations struct {
+	ReadOnly    bool `json:"readOnlyHint"`
+	Destructive bool `json:"destructiveHint"`
+}
+
+// schema is the JSON schema of an object with the given properties.
+func schema(required []string, props map[string]any) map[string]any {
+	s := map[string]any{"type": "object", "properties": props, "additionalProperties": false}
+	if len(required) > 0 {
+		s["required"] = required
+	}
+	return s
+}
+
+func prop(typ, desc string) map[string]any {
+	return map[string]any{"type": typ, "description": desc}
+}
+
+var tagList = map[string]any{"type": "array", "items": map[string]any{"type": "string"}}
+
+var mcpTools = []mcpTool{
+	{
+		Name:        "search_notes",
+		Description: `Full-text search. Words must all appear; "quoted phrases", prefix*, OR, -excluded and (grouping) work as well. Returns the best matches first with a snippet of each.`,
+		InputSchema: schema([]string{"query"}, map[string]any{
+			"query": prop("string", "the search query"),
+			"limit": prop("integer", "maximum number of results, 20 by default"),
+		}),
+		Annotations: mcpAnnotations{ReadOnly: true},
+		run:         (*mcpServer).searchTool,
+	},
+	{
+		Name:        "read_note",
+		Description: "Read a note by its ID, with its metadata and Markdown body.",
+		InputSchema: schema([]string{"id"}, map[string]any{
+			"id": prop("string", "the note ID"),
+		}),
+		Annotations: mcpAnnotations{ReadOnly: true},
+		run:         (*mcpServer).readTool,
+	},
+	{
+		Name:        "create_note",
+		Description: "Create a note. The title becomes its first line, above the body.",
+		InputSchema: schema(nil, map[string]any{
+			"title":   prop("string", "the title"),
+			"body":    prop("string", "the Markdown body"),
+			"section": prop("string", `the notebook, e.g. "work/ideas"; the root notebook by default`),
+		}),
+		run: (*mcpServer).createTool,
+	},
+	{
+		Name:        "append_to_note",
+		Description: "Add text on a new line at the end of a note.",
+		InputSchema: schema([]string{"id", "text"}, map[string]any{
+			"id":   prop("string", "the note ID"),
+			"text": prop("string", "the Markdown to append"),
+		}),
+		run: (*mcpServer).appendTool,
+	},
+	{
+		Name:        "tag_note",
+		Description: "Add tags to and remove tags from the front matter of a note. Inline #tags in the text are not removed.",
+		InputSchema: schema([]string{"id"}, map[string]any{
+			"id":     prop("string", "the note ID"),
+			"add":    tagList,
+			"remove": tagList,
+		}),
+		run: (*mcpServer).tagTool,
+	},
+}
+
+// callTool runs a tool. Failures are reported in the result, for the
+// model to see, rather than as protocol errors.
+func (m *mcpServer) callTool(name string, args json.RawMessage) (any, error) {
+	i := slices.IndexFunc(mcpTools, func(t mcpTool) bool { return t.Name == name })
+	if i < 0 {
+		return nil, &rpcError{Code: rpcInvalidParams, Message: "unknown tool " + name}
+	}
+	t := mcpTools[i]
+
+	var out any
+	err := errors.New("notes are read-only: mcp_read_only is set")
+	if !m.readOnly || t.Annotations.ReadOnly {
+		if len(args) == 0 {
+			args = json.RawMessage("{}")
+		}
+		out, err = t.run(m, args)
+	}
+	if err != nil {
+		return map[string]any{
+			"content": []map[string]any{{"type": "text", "text": err.Error()}},
+			"isError": true,
+		}, nil
+	}
+	text, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return map[string]any{"content": []map[string]any{{"type": "text", "text": string(text)}}}, nil
+}
+
+// toolArgs decodes the arguments of a tool call, refusing unknown ones.
+func toolArgs(args json.RawMessage, v any) error {
+	dec := json.NewDecoder(strings.NewReader(string(args)))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return fmt.Errorf("invalid arguments: %w", err)
+	}
+	return nil
+}
+
+func (m *mcpServer) searchTool(args json.RawMessage) (any, error) {
+	var in struct {
+		Query string `json:"query"`
+		Limit *int   `json:"limit"`
+	}
+	if err := toolArgs(args, &in); err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(in.Query) == "" {
+		return nil, errors.New("query is empty")
+	}
+	opts := search.Options{Limit: 20}
+	if in.Limit != nil {
+		opts.Limit = max(*in.Limit, 0)
+	}
+	var err error
+	if opts.Sections, err = m.sections(); err != nil {
+		return nil, err
+	}
+	if err := m.index.Sync(m.c.store); err != nil {
+		return nil, err
+	}
+	hits, err := m.index.Search(m.c.store, in.Query, opts)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]hitJSON, 0, len(hits))
+	for _, h := range hits {
+		out = append(out, hitJSON{noteJSON: toJSON(h.Note), Score: h.Score, Snippet: h.Snippet, Line: h.Line})
+	}
+	return out, nil
+}
+
+func (m *mcpServer) readTool(args json.RawMessage) (any, error) {
+	var in struct {
+		ID string `json:"id"`
+	}
+	if err := toolArgs(args, &in); err != nil {
+		return nil, err
+	}
+	n, err := m.note(in.ID)
+	if err != nil {
+		return nil, err
+	}
+	body, err := m.c.store.ReadBody(n.Path)
+	if err != nil {
+		return nil, err
+	}
+	out := toJSON(n)
+	out.Body = &body
+	return out, nil
+}
+
+func (m *mcpServer) createTool(args json.RawMessage) (any, error) {
+	var in struct {
+		Title   string `json:"title"`
+		Body    string `json:"body"`
+		Section string `json:"section"`
+	}
+	if err := toolArgs(args, &in); err != nil {
+		return nil, err
+	}
+	sec := fs.SectionNotes
+	if in.Section != "" {
+		var err error
+		if sec, err = parseNotebook(in.Section); err != nil {
+			return nil, err
+		}
+	}
+	sections, err := m.sections()
+	if err != nil {
+		return nil, err
+	}
+	if !slices.Contains(sections, sec) {
+		if in.Section == "" {
+			return nil, errors.New("the root notebook is not shown; give a section")
+		}
+		return nil, fmt.Errorf("notebook %s does not exist", sec)
+	}
+
+	var body string
+	if title := strings.TrimSpace(in.Title); title != "" {
+		body = "# " + title + "\n\n"
+	}
+	body += in.Body
+
+	n, err := m.c.store.Create(sec)
+	if err != nil {
+		return nil, err
+	}
+	if body != "" {
+		if err := m.c.store.WriteBody(n.Path, body); err != nil {
+			return nil, err
+		}
+	}
+	if n, err = m.note(n.ID); err != nil {
+		return nil, err
+	}
+	return toJSON(n), nil
+}
+
+func (m *mcpServer) appendTool(args json.RawMessage) (any, error) {
+	var in struct {
+		ID   string `json:"id"`
+		Text string `json:"text"`
+	}
+	if err := toolArgs(args, &in); err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(in.Text) == "" {
+		return nil, errors.New("text is empty")
+	}
+	return m.edit(in.ID, func(body string) (string, error) {
+		if body != "" && !strings.HasSuffix(body, "\n") {
+			body += "\n"
+		}
+		body += in.Text
+		if !strings.HasSuffix(body, "\n") {
+			body += "\n"
+		}
+		return body, nil
+	})
+}
+
+func (m *mcpServer) tagTool(args json.RawMessage) (any, error) {
+	var in struct {
+		ID     string   `json:"id"`
+		Add    []string `json:"add"`
+		Remove []string `json:"remove"`
+	}
+	if err := toolArgs(args, &in); err != nil {
+		return nil, err
+	}
+	if len(in.Add)+len(in.Remove) == 0 {
+		return nil, errors.New("no tags to add or remove")
+	}
+	return m.edit(in.ID, func(body string) (string, error) {
+		return tags.Edit(body, in.Add, in.Remove)
+	})
+}
+
+// edit changes a note with change and returns it. A change made to the
+// note by someone else in the meantime is kept: change is applied again
+// on top of it.
+func (m *mcpServer) edit(id string, change func(body string) (string, error)) (any, error) {
+	n, err := m.note(id)
+	if err != nil {
+		return nil, err
+	}
+	if n.Section == fs.SectionTrash {
+		return nil, fmt.Errorf("note %s is in the trash", n.ID)
+	}
+	if err := m.links.Sync(m.c.store); err != nil {
+		return nil, err
+	}
+	for try := 1; ; try++ {
+		body, base, err := storage.ReadVersion(m.c.store, n.Path)
+		if err != nil {
+			return nil, err
+		}
+		out, err := change(body)
+		if err != nil {
+			return nil, err
+		}
+		if out == body {
+			break
+		}
+		_, err = m.links.Write(storage.Guard(m.c.store, n.Path, base), n, out)
+		if errors.Is(err, fs.ErrConflict) && try < writeRetries {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		break
+	}
+	if n, err = m.note(n.ID); err != nil {
+		return nil, err
+	}
+	return toJSON(n), nil
+}
+
+// note looks up a note the client may see by its full ID.
+func (m *mcpServer) note(id string) (fs.Note, error) {
+	n, err := m.c.get(id)
+	if err != nil {
+		return fs.Note{}, err
+	}
+	sections, err := m.sections()
+	if err != nil {
+		return fs.Note{}, err
+	}
+	if !slices.Contains(sections, n.Section) {
+		return fs.Note{}, fmt.Errorf("%w: %s", errNotFound, id)
+	}
+	return n, nil
+}
+
+// sections returns the sections the client may see: those in
+// mcp_sections with the notebooks inside them, or every notebook.
+func (m *mcpServer) sections() ([]fs.Section, error) {
+	all, err := m.c.store.Sections()
+	if err != nil {
+		return nil, err
+	}
+	var out []fs.Section
+	for _, s := range all {
+		if len(m.allowed) == 0 && s != fs.SectionTrash ||
+			slices.ContainsFunc(m.allowed, func(sec fs.Section) bool { return inSection(s, sec) }) {
+			out = append(out, s)
+		}
+	}
+	return out, nil
+}
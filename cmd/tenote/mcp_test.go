@@ -0,0 +1,230 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/internet-kid/tenote/internal/storage/fs"
+)
+
+// newTestMCP returns an MCP server over a store holding a note in the
+// root notebook and one in the journal, and their IDs.
+func newTestMCP(t *testing.T) (m *mcpServer, note, entry string) {
+	t.Helper()
+	c := newTestCLI(t)
+	for sec, body := range map[fs.Section]string{
+		fs.SectionNotes:   "# Plan\nthe shared word\n",
+		fs.SectionJournal: "# Diary\nthe shared word, privately\n",
+	} {
+		n, err := c.store.Create(sec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := c.store.WriteBody(n.Path, body); err != nil {
+			t.Fatal(err)
+		}
+		if sec == fs.SectionNotes {
+			note = n.ID
+		} else {
+			entry = n.ID
+		}
+	}
+
+	m = &mcpServer{c: c}
+	var err error
+	if m.index, err = c.index(); err != nil {
+		t.Fatal(err)
+	}
+	if m.links, err = c.links(); err != nil {
+		t.Fatal(err)
+	}
+	return m, note, entry
+}
+
+type testResponse struct {
+	ID     json.RawMessage `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  *rpcError       `json:"error"`
+}
+
+// exchange sends messages to m, one per line, and returns its answers.
+func exchange(t *testing.T, m *mcpServer, messages ...string) []testResponse {
+	t.Helper()
+	var out bytes.Buffer
+	if err := m.serve(strings.NewReader(strings.Join(messages, "\n")), &out); err != nil {
+		t.Fatalf("serve: %v", err)
+	}
+	var resps []testResponse
+	dec := json.NewDecoder(&out)
+	for dec.More() {
+		var r testResponse
+		if err := dec.Decode(&r); err != nil {
+			t.Fatalf("decode answer: %v", err)
+		}
+		resps = append(resps, r)
+	}
+	return resps
+}
+
+// callResult is the result of a tools/call.
+type callResult struct {
+	Content []struct {
+		Text string `json:"text"`
+	} `json:"content"`
+	IsError bool `json:"isError"`
+}
+
+// callTool calls a tool and returns the text of its result.
+func callTool(t *testing.T, m *mcpServer, name, args string) (string, bool) {
+	t.Helper()
+	resps := exchange(t, m, `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"`+name+`","arguments":`+args+`}}`)
+	if len(resps) != 1 || resps[0].Error != nil {
+		t.Fatalf("tools/call %s = %+v", name, resps)
+	}
+	var res callResult
+	if err := json.Unmarshal(resps[0].Result, &res); err != nil || len(res.Content) != 1 {
+		t.Fatalf("tools/call %s result %s: %v", name, resps[0].Result, err)
+	}
+	return res.Content[0].Text, res.IsError
+}
+
+func TestMCPInitialize(t *testing.T) {
+	m, _, _ := newTestMCP(t)
+	tests := []struct {
+		asked, want string
+	}{
+		{mcpVersions[0], mcpVersions[0]},
+		{"2024-11-05", "2024-11-05"},
+		// A version tenote does not speak gets its newest, for the client
+		// to decide.
+		{"2099-01-01", mcpVersions[0]},
+		{"", mcpVersions[0]},
+	}
+	for _, tt := range tests {
+		resps := exchange(t, m, `{"jsonrpc":"2.0","id":"a","method":"initialize","params":{"protocolVersion":"`+tt.asked+`","capabilities":{}}}`)
+		var res struct {
+			ProtocolVersion string         `json:"protocolVersion"`
+			Capabilities    map[string]any `json:"capabilities"`
+			ServerInfo      struct {
+				Name string `json:"name"`
+			} `json:"serverInfo"`
+		}
+		if len(resps) != 1 || string(resps[0].ID) != `"a"` || json.Unmarshal(resps[0].Result, &res) != nil {
+			t.Fatalf("initialize %q = %+v", tt.asked, resps)
+		}
+		if res.ProtocolVersion != tt.want || res.ServerInfo.Name != "tenote" || res.Capabilities["tools"] == nil {
+			t.Fatalf("initialize %q = %+v, want version %s", tt.asked, res, tt.want)
+		}
+	}
+}
+
+func TestMCPNotifications(t *testing.T) {
+	m, _, _ := newTestMCP(t)
+	resps := exchange(t, m,
+		`{"jsonrpc":"2.0","method":"notifications/initialized"}`,
+		`{"jsonrpc":"2.0","method":"notifications/cancelled","params":{"requestId":1}}`,
+		`{"jsonrpc":"2.0","method":"ping"}`,
+		``,
+		`{"jsonrpc":"2.0","id":7,"method":"ping"}`,
+	)
+	if len(resps) != 1 || string(resps[0].ID) != "7" || resps[0].Error != nil {
+		t.Fatalf("answers = %+v, want only the ping with an ID", resps)
+	}
+}
+
+func TestMCPErrors(t *testing.T) {
+	m, _, _ := newTestMCP(t)
+	resps := exchange(t, m,
+		`{not json`,
+		`{"jsonrpc":"1.0","id":1,"method":"ping"}`,
+		`{"jsonrpc":"2.0","id":2,"method":"no/such"}`,
+		`{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"no_such_tool"}}`,
+		`{"jsonrpc":"2.0","id":4,"method":"resources/read","params":{"uri":"tenote://notes/01NOPE"}}`,
+	)
+	want := []int{rpcParseError, rpcInvalidRequest, rpcNoMethod, rpcInvalidParams, rpcNoResource}
+	if len(resps) != len(want) {
+		t.Fatalf("answers = %+v", resps)
+	}
+	for i, r := range resps {
+		if r.Error == nil || r.Error.Code != want[i] {
+			t.Errorf("answer %d = %+v, want error %d", i, r, want[i])
+		}
+	}
+}
+
+func TestMCPReadOnly(t *testing.T) {
+	m, note, _ := newTestMCP(t)
+	m.readOnly = true
+
+	resps := exchange(t, m, `{"jsonrpc":"2.0","id":1,"method":"tools/list"}`)
+	var list struct {
+		Tools []mcpTool `json:"tools"`
+	}
+	if err := json.Unmarshal(resps[0].Result, &list); err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, tool := range list.Tools {
+		names = append(names, tool.Name)
+	}
+	if strings.Join(names, ",") != "search_notes,read_note" {
+		t.Fatalf("read-only tools = %v", names)
+	}
+
+	for name, args := range map[string]string{
+		"create_note":    `{"title":"New"}`,
+		"append_to_note": `{"id":"` + note + `","text":"more"}`,
+		"tag_note":       `{"id":"` + note + `","add":["x"]}`,
+	} {
+		text, isError := callTool(t, m, name, args)
+		if !isError || !strings.Contains(text, "mcp_read_only") {
+			t.Errorf("%s = %q, %v; want refused", name, text, isError)
+		}
+	}
+	if notes, _ := m.c.store.List(fs.SectionNotes); len(notes) != 1 {
+		t.Fatalf("read-only server created notes: %+v", notes)
+	}
+	n, _ := m.c.get(note)
+	if body, _ := m.c.store.ReadBody(n.Path); body != "# Plan\nthe shared word\n" {
+		t.Fatalf("read-only server changed the note to %q", body)
+	}
+	if text, isError := callTool(t, m, "read_note", `{"id":"`+note+`"}`); isError || !strings.Contains(text, "shared word") {
+		t.Fatalf("read_note = %q, %v", text, isError)
+	}
+}
+
+func TestMCPSections(t *testing.T) {
+	m, note, entry := newTestMCP(t)
+	m.allowed = []fs.Section{fs.SectionNotes}
+
+	resps := exchange(t, m, `{"jsonrpc":"2.0","id":1,"method":"resources/list"}`)
+	if res := string(resps[0].Result); !strings.Contains(res, note) || strings.Contains(res, entry) {
+		t.Fatalf("resources/list = %s", res)
+	}
+	resps = exchange(t, m, `{"jsonrpc":"2.0","id":1,"method":"resources/read","params":{"uri":"`+noteURI+entry+`"}}`)
+	if resps[0].Error == nil || resps[0].Error.Code != rpcNoResource {
+		t.Fatalf("resources/read of a hidden note = %+v", resps[0])
+	}
+
+	text, isError := callTool(t, m, "search_notes", `{"query":"shared"}`)
+	if isError || !strings.Contains(text, note) || strings.Contains(text, entry) {
+		t.Fatalf("search_notes = %q", text)
+	}
+	for name, args := range map[string]string{
+		"read_note":      `{"id":"` + entry + `"}`,
+		"append_to_note": `{"id":"` + entry + `","text":"more"}`,
+		"create_note":    `{"title":"New","section":"journal"}`,
+	} {
+		if text, isError := callTool(t, m, name, args); !isError {
+			t.Errorf("%s on a hidden notebook = %q, want an error", name, text)
+		}
+	}
+
+	// Without mcp_sections everything but the trash is shown.
+	m.allowed = nil
+	if text, isError := callTool(t, m, "read_note", `{"id":"`+entry+`"}`); isError || !strings.Contains(text, "Diary") {
+		t.Fatalf("read_note without mcp_sections = %q", text)
+	}
+}
@@ -0,0 +1,667 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/internet-kid/tenote/internal/exporter"
+	"github.com/internet-kid/tenote/internal/storage"
+	"github.com/internet-kid/tenote/internal/storage/fs"
+	"github.com/internet-kid/tenote/internal/storage/links"
+	"github.com/internet-kid/tenote/internal/storage/search"
+)
+
+const (
+	defaultServeAddr = "127.0.0.1:7373"
+
+	// siteTitle names the web UI.
+	siteTitle = "tenote"
+
+	// tokenCookie keeps a browser signed in to the web UI once it has
+	// opened a page with ?token=.
+	tokenCookie = "tenote_token"
+
+	maxRequestBody  = 10 << 20
+	shutdownTimeout = 5 * time.Second
+)
+
+func (c *cli) cmdServe(args []string) error {
+	fset := c.flags("serve")
+	addr := fset.String("addr", defaultServeAddr, "address to listen on")
+	if err := parse(fset, args, 0, 0); err != nil {
+		return err
+	}
+	if c.cfg.ServeToken == "" {
+		return errors.New("set serve_token in the config file; clients must send it to use the server")
+	}
+
+	idx, err := c.index()
+	if err != nil {
+		return err
+	}
+	graph, err := c.links()
+	if err != nil {
+		return err
+	}
+	s := &server{c: c, index: idx, links: graph, token: c.cfg.ServeToken}
+	stopWatch, err := c.watch()
+	if err != nil {
+		return err
+	}
+	defer stopWatch()
+
+	ln, err := net.Listen("tcp", *addr)
+	if err != nil {
+		return err
+	}
+	srv := &http.Server{Handler: s.routes(), ReadHeaderTimeout: 10 * time.Second}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		srv.Shutdown(shutdown)
+	}()
+
+	fmt.Fprintf(c.stderr, "serving on http://%s\n", ln.Addr())
+	if err := srv.Serve(ln); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// server answers the JSON API under /api/ and the read-only web UI, over
+// the store opened by the CLI.
+type server struct {
+	c     *cli
+	index *search.Index
+	links *links.Graph
+	token string
+}
+
+// httpError is an error answered with its own status.
+type httpError struct {
+	status int
+	msg    string
+}
+
+func (e httpError) Error() string { return e.msg }
+
+// handler is an HTTP handler that leaves answering its error to the caller.
+type handler func(w http.ResponseWriter, r *http.Request) error
+
+func (s *server) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("GET /api/notes", s.api(s.listNotes))
+	mux.Handle("POST /api/notes", s.api(s.createNote))
+	mux.Handle("GET /api/notes/{id}", s.api(s.getNote))
+	mux.Handle("PUT /api/notes/{id}", s.api(s.updateNote))
+	mux.Handle("DELETE /api/notes/{id}", s.api(s.deleteNote))
+	mux.Handle("POST /api/notes/{id}/trash", s.api(s.trashNote))
+	mux.Handle("POST /api/notes/{id}/restore", s.api(s.restoreNote))
+	mux.Handle("GET /api/search", s.api(s.searchNotes))
+	mux.Handle("GET /{$}", s.ui(s.page))
+	mux.Handle("GET /{page}", s.ui(s.page))
+	return mux
+}
+
+// api wraps an API handler: it requires the token as a bearer token and
+// answers errors as JSON.
+func (s *server) api(h handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.authorized(r, false) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="tenote"`)
+			writeJSONStatus(w, http.StatusUnauthorized, errorJSON{Error: "missing or wrong token"})
+			return
+		}
+		if err := h(w, r); err != nil {
+			status := s.status(r, err)
+			writeJSONStatus(w, status, errorJSON{Error: err.Error()})
+		}
+	})
+}
+
+// ui wraps a web UI handler. A page opened with ?token= sets a cookie
+// that lets the browser in from then on.
+func (s *server) ui(h handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'self' 'unsafe-inline'; img-src * data:; form-action 'self'")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.Header().Set("Referrer-Policy", "no-referrer")
+
+		q := r.URL.Query()
+		if t := q.Get("token"); t != "" && s.validToken(t) {
+			http.SetCookie(w, &http.Cookie{Name: tokenCookie, Value: t, Path: "/", HttpOnly: true, SameSite: http.SameSiteStrictMode})
+			q.Del("token")
+			u := *r.URL
+			u.RawQuery = q.Encode()
+			http.Redirect(w, r, u.RequestURI(), http.StatusSeeOther)
+			return
+		}
+		if !s.authorized(r, true) {
+			http.Error(w, "Open this page with ?token=<serve_token> added to the address to sign in.", http.StatusUnauthorized)
+			return
+		}
+		if err := h(w, r); err != nil {
+			http.Error(w, err.Error(), s.status(r, err))
+		}
+	})
+}
+
+// authorized reports whether r carries the token as a bearer token or,
+// with cookie, in the cookie the web UI sets.
+func (s *server) authorized(r *http.Request, cookie bool) bool {
+	if t, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return s.validToken(t)
+	}
+	if ck, err := r.Cookie(tokenCookie); cookie && err == nil {
+		return s.validToken(ck.Value)
+	}
+	return false
+}
+
+func (s *server) validToken(t string) bool {
+	return subtle.ConstantTimeCompare([]byte(t), []byte(s.token)) == 1
+}
+
+// status is the HTTP status err is answered with. Unexpected errors are
+// logged.
+func (s *server) status(r *http.Request, err error) int {
+	var he httpError
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &he):
+		return he.status
+	case errors.As(err, new(usageError)):
+		return http.StatusBadRequest
+	case errors.Is(err, errNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, fs.ErrConflict):
+		return http.StatusPreconditionFailed
+	case errors.As(err, &tooLarge):
+		return http.StatusRequestEntityTooLarge
+	}
+	fmt.Fprintf(s.c.stderr, "tenote serve: %s %s: %v\n", r.Method, r.URL.Path, err)
+	return http.StatusInternalServerError
+}
+
+// ---------------------------------------------------------------------------
+// API
+// ---------------------------------------------------------------------------
+
+func (s *server) listNotes(w http.ResponseWriter, r *http.Request) error {
+	sections, err := s.sections(r.URL.Query().Get("section"))
+	if err != nil {
+		return err
+	}
+	out := []noteJSON{}
+	for _, sec := range sections {
+		notes, err := s.c.store.List(sec)
+		if err != nil {
+			return err
+		}
+		for _, n := range notes {
+			out = append(out, toJSON(n))
+		}
+	}
+	writeJSONStatus(w, http.StatusOK, out)
+	return nil
+}
+
+func (s *server) getNote(w http.ResponseWriter, r *http.Request) error {
+	n, err := s.c.get(r.PathValue("id"))
+	if err != nil {
+		return err
+	}
+	body, err := s.c.store.ReadBody(n.Path)
+	if err != nil {
+		return err
+	}
+	if etagMatch(r.Header.Get("If-None-Match"), etag(body), true) {
+		w.Header().Set("ETag", etag(body))
+		w.WriteHeader(http.StatusNotModified)
+		return nil
+	}
+	writeNote(w, http.StatusOK, n, body)
+	return nil
+}
+
+func (s *server) createNote(w http.ResponseWriter, r *http.Request) error {
+	var in noteInput
+	if err := decode(w, r, &in); err != nil {
+		return err
+	}
+	sec := fs.SectionNotes
+	if in.Section != "" {
+		var err error
+		if sec, err = parseSection(in.Section); err != nil {
+			return err
+		}
+	}
+	switch sec {
+	case fs.SectionTrash:
+		return usagef("cannot create notes in the trash")
+	case fs.SectionJournal:
+		return usagef("journal notes are made with tenote today")
+	}
+	if err := s.exists(sec); err != nil {
+		return err
+	}
+
+	var body string
+	if title := strings.TrimSpace(in.Title); title != "" {
+		body = "# " + title + "\n\n"
+	}
+	if in.Body != nil {
+		body += *in.Body
+	}
+
+	n, err := s.c.store.Create(sec)
+	if err != nil {
+		return err
+	}
+	if body != "" {
+		if err := s.c.store.WriteBody(n.Path, body); err != nil {
+			return err
+		}
+	}
+	if n, body, err = s.reload(n.ID); err != nil {
+		return err
+	}
+	w.Header().Set("Location", "/api/notes/"+n.ID)
+	writeNote(w, http.StatusCreated, n, body)
+	return nil
+}
+
+// updateNote replaces the body of a note. With If-Match it only does so
+// while the note still has that ETag.
+func (s *server) updateNote(w http.ResponseWriter, r *http.Request) error {
+	n, err := s.c.get(r.PathValue("id"))
+	if err != nil {
+		return err
+	}
+	var in noteInput
+	if err := decode(w, r, &in); err != nil {
+		return err
+	}
+	if in.Body == nil {
+		return usagef("body is required")
+	}
+	if in.Section != "" || in.Title != "" {
+		return usagef("only the body of a note can be updated")
+	}
+	if n.Section == fs.SectionTrash {
+		return httpError{http.StatusConflict, fmt.Sprintf("note %s is in the trash", n.ID)}
+	}
+	base, err := s.precondition(r, n)
+	if err != nil {
+		return err
+	}
+
+	if err := s.links.Sync(s.c.store); err != nil {
+		return err
+	}
+	if _, err := s.links.Write(storage.Guard(s.c.store, n.Path, base), n, *in.Body); err != nil {
+		return err
+	}
+	n, body, err := s.reload(n.ID)
+	if err != nil {
+		return err
+	}
+	writeNote(w, http.StatusOK, n, body)
+	return nil
+}
+
+// deleteNote deletes a note in the trash for good.
+func (s *server) deleteNote(w http.ResponseWriter, r *http.Request) error {
+	n, err := s.c.get(r.PathValue("id"))
+	if err != nil {
+		return err
+	}
+	if n.Section != fs.SectionTrash {
+		return httpError{http.StatusConflict, fmt.Sprintf("note %s is not in the trash", n.ID)}
+	}
+	if _, err := s.precondition(r, n); err != nil {
+		return err
+	}
+	if err := s.c.store.DeleteFromTrash(n); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func (s *server) trashNote(w http.ResponseWriter, r *http.Request) error {
+	n, err := s.c.get(r.PathValue("id"))
+	if err != nil {
+		return err
+	}
+	if n.Section == fs.SectionTrash {
+		return httpError{http.StatusConflict, fmt.Sprintf("note %s is already in the trash", n.ID)}
+	}
+	if n, err = s.c.store.MoveToTrash(n); err != nil {
+		return err
+	}
+	writeJSONStatus(w, http.StatusOK, toJSON(n))
+	return nil
+}
+
+func (s *server) restoreNote(w http.ResponseWriter, r *http.Request) error {
+	n, err := s.c.get(r.PathValue("id"))
+	if err != nil {
+		return err
+	}
+	var in restoreInput
+	if err := decode(w, r, &in); err != nil {
+		return err
+	}
+	if n.Section != fs.SectionTrash {
+		return httpError{http.StatusConflict, fmt.Sprintf("note %s is not in the trash", n.ID)}
+	}
+	var target fs.Section
+	if in.To != "" {
+		if target, err = parseNotebook(in.To); err != nil {
+			return err
+		}
+		if err := s.exists(target); err != nil {
+			return err
+		}
+	}
+	if n, err = s.c.store.RestoreFromTrash(n, target); err != nil {
+		return err
+	}
+	writeJSONStatus(w, http.StatusOK, toJSON(n))
+	return nil
+}
+
+func (s *server) searchNotes(w http.ResponseWriter, r *http.Request) error {
+	q := r.URL.Query()
+	query := q.Get("q")
+	if strings.TrimSpace(query) == "" {
+		return usagef("q is required")
+	}
+	opts := search.Options{Limit: 20}
+	if l := q.Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n < 0 {
+			return usagef("invalid limit %q", l)
+		}
+		opts.Limit = n
+	}
+	if sec := q.Get("section"); sec != "" {
+		sections, err := s.sections(sec)
+		if err != nil {
+			return err
+		}
+		opts.Sections = sections
+	}
+
+	hits, err := s.search(query, opts)
+	if err != nil {
+		return err
+	}
+	out := make([]hitJSON, 0, len(hits))
+	for _, h := range hits {
+		out = append(out, hitJSON{noteJSON: toJSON(h.Note), Score: h.Score, Snippet: h.Snippet, Line: h.Line})
+	}
+	writeJSONStatus(w, http.StatusOK, out)
+	return nil
+}
+
+// reload reads a note that was just written, with its body.
+func (s *server) reload(id string) (fs.Note, string, error) {
+	n, err := s.c.get(id)
+	if err != nil {
+		return fs.Note{}, "", err
+	}
+	body, err := s.c.store.ReadBody(n.Path)
+	return n, body, err
+}
+
+// precondition checks the If-Match header of r against n and returns the
+// version a write must still find, or a zero one without the header.
+func (s *server) precondition(r *http.Request, n fs.Note) (fs.Version, error) {
+	want := r.Header.Get("If-Match")
+	if want == "" {
+		return fs.Version{}, nil
+	}
+	body, err := s.c.store.ReadBody(n.Path)
+	if err != nil {
+		return fs.Version{}, err
+	}
+	if !etagMatch(want, etag(body), false) {
+		return fs.Version{}, fmt.Errorf("note %s: %w", n.ID, fs.ErrConflict)
+	}
+	return fs.VersionOf(body, time.Time{}), nil
+}
+
+// sections returns section, which must exist, or without one every
+// section but the trash.
+func (s *server) sections(section string) ([]fs.Section, error) {
+	if section != "" {
+		sec, err := parseSection(section)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.exists(sec); err != nil {
+			return nil, err
+		}
+		return []fs.Section{sec}, nil
+	}
+	all, err := s.c.store.Sections()
+	if err != nil {
+		return nil, err
+	}
+	var out []fs.Section
+	for _, sec := range all {
+		if sec != fs.SectionTrash {
+			out = append(out, sec)
+		}
+	}
+	return out, nil
+}
+
+func (s *server) exists(sec fs.Section) error {
+	all, err := s.c.store.Sections()
+	if err != nil {
+		return err
+	}
+	for _, have := range all {
+		if have == sec {
+			return nil
+		}
+	}
+	return httpError{http.StatusNotFound, fmt.Sprintf("notebook %s does not exist", sec)}
+}
+
+// search runs query against the index, brought up to date first.
+func (s *server) search(query string, opts search.Options) ([]search.Hit, error) {
+	if err := s.index.Sync(s.c.store); err != nil {
+		return nil, err
+	}
+	hits, err := s.index.Search(s.c.store, query, opts)
+	if err != nil {
+		return nil, usageError{msg: err.Error()}
+	}
+	return hits, nil
+}
+
+// etag is the ETag of a note body: its hash, as in fs.Version.
+func etag(body string) string {
+	return `"` + fs.VersionOf(body, time.Time{}).Hash + `"`
+}
+
+// etagMatch reports whether the If-Match or If-None-Match header h lists
+// tag. Weak tags only match weakly, as If-None-Match compares.
+func etagMatch(h, tag string, weak bool) bool {
+	for _, t := range strings.Split(h, ",") {
+		t = strings.TrimSpace(t)
+		if weak {
+			t = strings.TrimPrefix(t, "W/")
+		}
+		if t == "*" || t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// decode reads a JSON request body into v. An empty body leaves v as it
+// is.
+func decode(w http.ResponseWriter, r *http.Request, v any) error {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBody))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil && !errors.Is(err, io.EOF) {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return err
+		}
+		return usagef("invalid request body: %v", err)
+	}
+	return nil
+}
+
+func writeNote(w http.ResponseWriter, status int, n fs.Note, body string) {
+	out := toJSON(n)
+	out.Body = &body
+	w.Header().Set("ETag", etag(body))
+	writeJSONStatus(w, status, out)
+}
+
+func writeJSONStatus(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// noteInput is the request body of creating and updating a note.
+type noteInput struct {
+	Section string  `json:"section"`
+	Title   string  `json:"title"`
+	Body    *string `json:"body"`
+}
+
+type restoreInput struct {
+	To string `json:"to"`
+}
+
+type errorJSON struct {
+	Error string `json:"error"`
+}
+
+// ---------------------------------------------------------------------------
+// web UI
+// ---------------------------------------------------------------------------
+
+// page serves the pages of the static site export renders, for the notes
+// outside the trash as they are now, plus search results.
+func (s *server) page(w http.ResponseWriter, r *http.Request) error {
+	name := r.PathValue("page")
+	if name == exporter.StyleFile {
+		w.Header().Set("Content-Type", "text/css; charset=utf-8")
+		_, err := io.WriteString(w, exporter.CSS)
+		return err
+	}
+
+	notes, err := s.siteNotes()
+	if err != nil {
+		return err
+	}
+	p := exporter.NewPages(notes, siteTitle)
+	p.Search = true
+
+	var buf bytes.Buffer
+	switch name {
+	case "", exporter.IndexFile:
+		err = p.Index(&buf)
+	case exporter.TagsFile:
+		err = p.Tags(&buf)
+	case exporter.SearchFile:
+		err = s.results(&buf, p, r.URL.Query().Get("q"))
+	default:
+		err = s.notePage(&buf, p, notes, name)
+	}
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+// siteNotes lists the notes outside the trash with their tags, sorted by
+// title within each notebook, but without their content.
+func (s *server) siteNotes() ([]exporter.Note, error) {
+	if err := s.index.Sync(s.c.store); err != nil {
+		return nil, err
+	}
+	selected, err := s.c.exportNotes(nil, "", "")
+	if err != nil {
+		return nil, err
+	}
+	notes := make([]exporter.Note, 0, len(selected))
+	for _, n := range selected {
+		notes = append(notes, exporter.Note{Note: n, AllTags: s.index.NoteTags(n.ID)})
+	}
+	return notes, nil
+}
+
+func (s *server) results(w io.Writer, p *exporter.Pages, query string) error {
+	var found []exporter.Note
+	if strings.TrimSpace(query) != "" {
+		sections, err := s.sections("")
+		if err != nil {
+			return err
+		}
+		hits, err := s.search(query, search.Options{Sections: sections})
+		if err != nil {
+			return err
+		}
+		for _, h := range hits {
+			found = append(found, exporter.Note{Note: h.Note})
+		}
+	}
+	return p.Results(w, query, found)
+}
+
+// notePage renders the page of a note or tag by its name.
+func (s *server) notePage(w io.Writer, p *exporter.Pages, notes []exporter.Note, name string) error {
+	for _, t := range p.TagNames() {
+		if exporter.TagPage(t) == name {
+			return p.Tag(w, t)
+		}
+	}
+	for _, n := range notes {
+		if exporter.PageName(n) != name {
+			continue
+		}
+		loaded, err := exporter.Load(s.c.store, []fs.Note{n.Note})
+		if err != nil {
+			return err
+		}
+		if err := s.links.Sync(s.c.store); err != nil {
+			return err
+		}
+		var backlinks []exporter.Note
+		for _, b := range s.links.Backlinks(n.ID) {
+			backlinks = append(backlinks, exporter.Note{Note: b})
+		}
+		return p.Note(w, loaded[0], backlinks)
+	}
+	return httpError{http.StatusNotFound, "page not found"}
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/internet-kid/tenote/internal/config"
+	"github.com/internet-kid/tenote/internal/storage"
+	"github.com/internet-kid/tenote/internal/storage/memory"
+)
+
+const testToken = "s3cret"
+
+// newTestCLI returns a CLI over an empty store in memory.
+func newTestCLI(t *testing.T) *cli {
+	t.Helper()
+	return &cli{
+		stdin:  strings.NewReader(""),
+		stdout: io.Discard,
+		stderr: io.Discard,
+		cfg:    config.AppConfig{Backend: storage.BackendMemory, StorageDir: t.TempDir(), ServeToken: testToken},
+		store:  memory.NewStore(),
+	}
+}
+
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	c := newTestCLI(t)
+	idx, err := c.index()
+	if err != nil {
+		t.Fatal(err)
+	}
+	graph, err := c.links()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer((&server{c: c, index: idx, links: graph, token: testToken}).routes())
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+// do sends a request, with the token unless header sets Authorization,
+// and returns the response with its body read.
+func do(t *testing.T, ts *httptest.Server, method, path, body string, header http.Header) (*http.Response, string) {
+	t.Helper()
+	req, err := http.NewRequest(method, ts.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+testToken)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp, string(b)
+}
+
+func TestServeAPIAuth(t *testing.T) {
+	ts := newTestServer(t)
+	tests := []struct {
+		name   string
+		header http.Header
+		want   int
+	}{
+		{"token", nil, http.StatusOK},
+		{"no token", http.Header{"Authorization": {""}}, http.StatusUnauthorized},
+		{"wrong token", http.Header{"Authorization": {"Bearer nope"}}, http.StatusUnauthorized},
+		{"not a bearer token", http.Header{"Authorization": {"Basic " + testToken}}, http.StatusUnauthorized},
+		// The cookie is the web UI's; the API only takes the header.
+		{"cookie", http.Header{"Authorization": {""}, "Cookie": {tokenCookie + "=" + testToken}}, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, body := do(t, ts, "GET", "/api/notes", "", tt.header)
+			if resp.StatusCode != tt.want {
+				t.Fatalf("status = %d, want %d: %s", resp.StatusCode, tt.want, body)
+			}
+			if tt.want == http.StatusUnauthorized && resp.Header.Get("WWW-Authenticate") == "" {
+				t.Fatal("401 without WWW-Authenticate")
+			}
+		})
+	}
+}
+
+func TestServeUIAuth(t *testing.T) {
+	ts := newTestServer(t)
+	anonymous := http.Header{"Authorization": {""}}
+
+	if resp, _ := do(t, ts, "GET", "/", "", anonymous); resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("page without a token = %d, want 401", resp.StatusCode)
+	}
+	resp, _ := do(t, ts, "GET", "/tags.html?token=nope", "", anonymous)
+	if resp.StatusCode != http.StatusUnauthorized || len(resp.Cookies()) != 0 {
+		t.Fatalf("page with a wrong token = %d, cookies %v", resp.StatusCode, resp.Cookies())
+	}
+
+	// The token in the address signs the browser in and is taken out of
+	// the address.
+	resp, _ = do(t, ts, "GET", "/tags.html?token="+testToken+"&x=1", "", anonymous)
+	if resp.StatusCode != http.StatusSeeOther || resp.Header.Get("Location") != "/tags.html?x=1" {
+		t.Fatalf("sign in = %d to %q", resp.StatusCode, resp.Header.Get("Location"))
+	}
+	cookies := resp.Cookies()
+	if len(cookies) != 1 || cookies[0].Name != tokenCookie || !cookies[0].HttpOnly || cookies[0].SameSite != http.SameSiteStrictMode {
+		t.Fatalf("sign in cookies = %+v", cookies)
+	}
+
+	signedIn := http.Header{"Authorization": {""}, "Cookie": {cookies[0].String()}}
+	resp, body := do(t, ts, "GET", "/tags.html", "", signedIn)
+	if resp.StatusCode != http.StatusOK || !strings.Contains(body, "<h1>Tags</h1>") {
+		t.Fatalf("page with the cookie = %d: %s", resp.StatusCode, body)
+	}
+	if resp.Header.Get("Content-Security-Policy") == "" {
+		t.Fatal("page without a Content-Security-Policy")
+	}
+	stale := http.Header{"Authorization": {""}, "Cookie": {tokenCookie + "=old"}}
+	if resp, _ := do(t, ts, "GET", "/", "", stale); resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("page with a stale cookie = %d, want 401", resp.StatusCode)
+	}
+	if resp, _ := do(t, ts, "GET", "/", "", nil); resp.StatusCode != http.StatusOK {
+		t.Fatalf("page with a bearer token = %d, want 200", resp.StatusCode)
+	}
+}
+
+func TestServeConditionalRequests(t *testing.T) {
+	ts := newTestServer(t)
+	resp, body := do(t, ts, "POST", "/api/notes", `{"title":"Plan","body":"first\n"}`, nil)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("create = %d: %s", resp.StatusCode, body)
+	}
+	var created noteJSON
+	if err := json.Unmarshal([]byte(body), &created); err != nil {
+		t.Fatal(err)
+	}
+	path := "/api/notes/" + created.ID
+	tag := resp.Header.Get("ETag")
+	if tag == "" || resp.Header.Get("Location") != path {
+		t.Fatalf("create headers = %v", resp.Header)
+	}
+
+	// If-None-Match: a client with the current body gets 304.
+	for _, h := range []string{tag, "W/" + tag, `"other", ` + tag, "*"} {
+		resp, body := do(t, ts, "GET", path, "", http.Header{"If-None-Match": {h}})
+		if resp.StatusCode != http.StatusNotModified || body != "" || resp.Header.Get("ETag") != tag {
+			t.Fatalf("GET If-None-Match %s = %d %q", h, resp.StatusCode, body)
+		}
+	}
+	if resp, _ := do(t, ts, "GET", path, "", http.Header{"If-None-Match": {`"other"`}}); resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET with an old ETag = %d, want 200", resp.StatusCode)
+	}
+
+	// If-Match: a write based on an old body is refused.
+	resp, body = do(t, ts, "PUT", path, `{"body":"# Plan\n\nsecond\n"}`, http.Header{"If-Match": {tag}})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("PUT If-Match current = %d: %s", resp.StatusCode, body)
+	}
+	newTag := resp.Header.Get("ETag")
+	if newTag == tag {
+		t.Fatal("ETag unchanged by a new body")
+	}
+	resp, body = do(t, ts, "PUT", path, `{"body":"lost\n"}`, http.Header{"If-Match": {tag}})
+	if resp.StatusCode != http.StatusPreconditionFailed || !strings.Contains(body, `"error"`) {
+		t.Fatalf("PUT If-Match stale = %d: %s", resp.StatusCode, body)
+	}
+	_, body = do(t, ts, "GET", path, "", nil)
+	if !strings.Contains(body, "second") || strings.Contains(body, "lost") {
+		t.Fatalf("note after a refused PUT: %s", body)
+	}
+	if resp, _ := do(t, ts, "PUT", path, `{"body":"forced\n"}`, nil); resp.StatusCode != http.StatusOK {
+		t.Fatalf("PUT without If-Match = %d, want 200", resp.StatusCode)
+	}
+
+	// Deleting for good takes the same precondition.
+	if resp, body := do(t, ts, "POST", path+"/trash", "", nil); resp.StatusCode != http.StatusOK {
+		t.Fatalf("trash = %d: %s", resp.StatusCode, body)
+	}
+	if resp, _ := do(t, ts, "DELETE", path, "", http.Header{"If-Match": {newTag}}); resp.StatusCode != http.StatusPreconditionFailed {
+		t.Fatalf("DELETE If-Match stale = %d, want 412", resp.StatusCode)
+	}
+	if resp, _ := do(t, ts, "DELETE", path, "", nil); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("DELETE = %d, want 204", resp.StatusCode)
+	}
+	if resp, _ := do(t, ts, "GET", path, "", nil); resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("GET after DELETE = %d, want 404", resp.StatusCode)
+	}
+}
+
+func TestServeRequestErrors(t *testing.T) {
+	ts := newTestServer(t)
+	tests := []struct {
+		method, path, body string
+		want               int
+	}{
+		{"POST", "/api/notes", `{"tilte":"typo"}`, http.StatusBadRequest},
+		{"POST", "/api/notes", `{"section":"trash"}`, http.StatusBadRequest},
+		{"POST", "/api/notes", `{"section":"nowhere"}`, http.StatusNotFound},
+		{"GET", "/api/notes/01NOPE", "", http.StatusNotFound},
+		{"PUT", "/api/notes/01NOPE", `{"body":""}`, http.StatusNotFound},
+		{"POST", "/api/notes", `{"body":"` + strings.Repeat("x", maxRequestBody) + `"}`, http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		resp, body := do(t, ts, tt.method, tt.path, tt.body, nil)
+		if resp.StatusCode != tt.want {
+			t.Errorf("%s %s = %d, want %d: %.200s", tt.method, tt.path, resp.StatusCode, tt.want, body)
+		}
+		var e errorJSON
+		if err := json.NewDecoder(bytes.NewReader([]byte(body))).Decode(&e); err != nil || e.Error == "" {
+			t.Errorf("%s %s answered %.200q, want a JSON error", tt.method, tt.path, body)
+		}
+	}
+}
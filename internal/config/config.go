@@ -12,6 +12,56 @@ const configPerm = 0o644
 // AppConfig holds user-configurable settings persisted to disk.
 type AppConfig struct {
 	StorageDir string `json:"storage_dir"`
+	// Backend selects the note store: "fs" (default), "git" or "memory".
+	Backend string `json:"backend,omitempty"`
+	// Editor is the external editor command, e.g. "hx" or "code --wait".
+	// Empty falls back to $VISUAL, then $EDITOR.
+	Editor string `json:"editor,omitempty"`
+	// HistoryLimit is the number of revisions kept per note. Zero means
+	// the default of 50; a negative value turns history off.
+	HistoryLimit int `json:"history_limit,omitempty"`
+	// AutoLock is the number of idle minutes after which the UI locks an
+	// encrypted store. Zero means the default of 10; a negative value
+	// never locks.
+	AutoLock int `json:"auto_lock,omitempty"`
+	// GitRemote is the URL or path the git backend syncs with. Empty uses
+	// the repository's existing origin.
+	GitRemote string `json:"git_remote,omitempty"`
+	// WatchPoll makes the UI poll for changes made outside it every so
+	// many seconds instead of relying on file notifications, which some
+	// network filesystems never deliver. Zero uses notifications; a
+	// negative value turns watching off.
+	WatchPoll int `json:"watch_poll,omitempty"`
+	// TrashRetention is the number of days notes stay in the trash before
+	// they are purged. Zero means the default of 30; a negative value
+	// keeps them until deleted by hand.
+	TrashRetention int `json:"trash_retention,omitempty"`
+	// DraftInterval is the number of seconds between autosaves of the
+	// note being edited to a draft, which is offered for recovery after a
+	// crash. Zero means the default of 5; a negative value turns drafts
+	// off.
+	DraftInterval int `json:"draft_interval,omitempty"`
+	// ServeToken is the secret that clients of tenote serve present to be
+	// let in. The server does not start without one.
+	ServeToken string `json:"serve_token,omitempty"`
+	// MCPReadOnly leaves tenote mcp with the tools that only read notes.
+	MCPReadOnly bool `json:"mcp_read_only,omitempty"`
+	// MCPSections are the notebooks tenote mcp shows, each with the
+	// notebooks inside it. Empty means every notebook; the trash is only
+	// shown when listed.
+	MCPSections []string `json:"mcp_sections,omitempty"`
+	// Sort maps sections to the order their notes are listed in:
+	// "updated" (the default), "created", "title" or "size". Sections are
+	// named as on the command line, e.g. "notes", "notes/work" or "trash";
+	// "tags" is the tag browser. The app updates it as orders are picked.
+	Sort map[string]string `json:"sort,omitempty"`
+	// GroupNotes shows notes listed by date under Today, Yesterday, This
+	// week and Older headings in the app.
+	GroupNotes bool `json:"group_notes,omitempty"`
+	// JournalTemplate names the template in the templates directory that
+	// journal notes are made from. Empty means "journal", or a heading
+	// with the date when there is no such template.
+	JournalTemplate string `json:"journal_template,omitempty"`
 }
 
 func configFilePath() (string, error) {
@@ -51,7 +101,11 @@ func LoadConfig() (AppConfig, error) {
 	}
 
 	if cfg.StorageDir == "" {
-		return defaultConfig()
+		def, err := defaultConfig()
+		if err != nil {
+			return AppConfig{}, err
+		}
+		cfg.StorageDir = def.StorageDir
 	}
 	return cfg, nil
 }
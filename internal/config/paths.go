@@ -14,6 +14,13 @@ type Paths struct {
 	Root  string
 	Notes string
 	Trash string
+	// Journal holds the daily journal notes.
+	Journal string
+	// Meta holds tenote's own state (indexes and the like), never notes.
+	Meta string
+	// Templates holds note templates. Unlike the others it is not created;
+	// without it notes start blank.
+	Templates string
 }
 
 // ResolvePaths resolves and creates Tenote data directories using the saved config.
@@ -31,9 +38,13 @@ func ResolvePathsFrom(root string) (Paths, error) {
 		Root:  root,
 		Notes: filepath.Join(root, "notes"),
 		Trash: filepath.Join(root, "trash"),
+		Meta:  filepath.Join(root, ".tenote"),
+
+		Journal:   filepath.Join(root, "journal"),
+		Templates: filepath.Join(root, "templates"),
 	}
 
-	for _, dir := range []string{p.Root, p.Notes, p.Trash} {
+	for _, dir := range []string{p.Root, p.Notes, p.Journal, p.Trash, p.Meta} {
 		if err := os.MkdirAll(dir, dirPerm); err != nil {
 			return Paths{}, fmt.Errorf("create data dir %q: %w", dir, err)
 		}
@@ -0,0 +1,212 @@
+// Package diff computes line-based differences between note bodies.
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Op is the kind of an Edit.
+type Op int
+
+const (
+	Equal Op = iota
+	Insert
+	Delete
+)
+
+// Edit is one line of an edit script turning a into b.
+type Edit struct {
+	Op   Op
+	Line string
+}
+
+// SplitLines splits s into lines, keeping a final line without a newline.
+func SplitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// Lines returns a shortest edit script from a to b using Myers' algorithm.
+func Lines(a, b []string) []Edit {
+	n, m := len(a), len(b)
+	maxD := n + m
+	if maxD == 0 {
+		return nil
+	}
+
+	offset := maxD
+	v := make([]int, 2*maxD+2)
+	var trace [][]int
+
+	for d := 0; d <= maxD; d++ {
+		snapshot := make([]int, len(v))
+		copy(snapshot, v)
+		trace = append(trace, snapshot)
+
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				return backtrack(trace, a, b, offset)
+			}
+		}
+	}
+	return nil
+}
+
+func backtrack(trace [][]int, a, b []string, offset int) []Edit {
+	x, y := len(a), len(b)
+	var edits []Edit
+
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		k := x - y
+
+		var prevK int
+		if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[offset+prevK]
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			x--
+			y--
+			edits = append(edits, Edit{Op: Equal, Line: a[x]})
+		}
+		if d == 0 {
+			break
+		}
+		if x == prevX {
+			y--
+			edits = append(edits, Edit{Op: Insert, Line: b[y]})
+		} else {
+			x--
+			edits = append(edits, Edit{Op: Delete, Line: a[x]})
+		}
+	}
+
+	for i, j := 0, len(edits)-1; i < j; i, j = i+1, j-1 {
+		edits[i], edits[j] = edits[j], edits[i]
+	}
+	return edits
+}
+
+// Unified renders the difference between a and b as a unified diff with
+// the given number of context lines. Equal inputs produce "".
+func Unified(nameA, nameB, a, b string, context int) string {
+	edits := Lines(SplitLines(a), SplitLines(b))
+
+	changed := false
+	for _, e := range edits {
+		if e.Op != Equal {
+			changed = true
+			break
+		}
+	}
+	if !changed {
+		return ""
+	}
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", nameA, nameB)
+
+	for _, h := range hunks(edits, context) {
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n", hunkRange(h.startA, h.lenA), hunkRange(h.startB, h.lenB))
+		for _, e := range h.edits {
+			prefix := " "
+			switch e.Op {
+			case Insert:
+				prefix = "+"
+			case Delete:
+				prefix = "-"
+			}
+			out.WriteString(prefix + e.Line)
+			if !strings.HasSuffix(e.Line, "\n") {
+				out.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+	}
+	return out.String()
+}
+
+type hunk struct {
+	startA, lenA int
+	startB, lenB int
+	edits        []Edit
+}
+
+// hunks groups changes closer than 2*context equal lines together and
+// surrounds each group with up to context lines of unchanged text.
+func hunks(edits []Edit, context int) []hunk {
+	// posA[i] and posB[i] are the line numbers edits[i] starts at.
+	posA := make([]int, len(edits)+1)
+	posB := make([]int, len(edits)+1)
+	for i, e := range edits {
+		posA[i+1], posB[i+1] = posA[i], posB[i]
+		if e.Op != Insert {
+			posA[i+1]++
+		}
+		if e.Op != Delete {
+			posB[i+1]++
+		}
+	}
+
+	var out []hunk
+	start, last := -1, -1
+	flush := func() {
+		from := max(0, start-context)
+		to := min(len(edits), last+1+context)
+		h := hunk{startA: posA[from], startB: posB[from], edits: edits[from:to]}
+		h.lenA = posA[to] - posA[from]
+		h.lenB = posB[to] - posB[from]
+		out = append(out, h)
+	}
+
+	for i, e := range edits {
+		if e.Op == Equal {
+			continue
+		}
+		if start >= 0 && i-last > 2*context {
+			flush()
+			start = -1
+		}
+		if start < 0 {
+			start = i
+		}
+		last = i
+	}
+	if start >= 0 {
+		flush()
+	}
+	return out
+}
+
+func hunkRange(start, length int) string {
+	if length == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if length == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, length)
+}
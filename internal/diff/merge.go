@@ -0,0 +1,125 @@
+package diff
+
+import "strings"
+
+// change replaces base lines [start, end) with lines.
+type change struct {
+	start, end int
+	lines      []string
+}
+
+// changes turns an edit script from base into the changed regions of base.
+func changes(edits []Edit) []change {
+	var out []change
+	pos := 0
+	var cur *change
+	for _, e := range edits {
+		if e.Op == Equal {
+			if cur != nil {
+				out = append(out, *cur)
+				cur = nil
+			}
+			pos++
+			continue
+		}
+		if cur == nil {
+			cur = &change{start: pos, end: pos}
+		}
+		if e.Op == Delete {
+			pos++
+			cur.end = pos
+		} else {
+			cur.lines = append(cur.lines, e.Line)
+		}
+	}
+	if cur != nil {
+		out = append(out, *cur)
+	}
+	return out
+}
+
+// apply returns base lines [start, end) with cs applied.
+func apply(base []string, cs []change, start, end int) []string {
+	var out []string
+	pos := start
+	for _, c := range cs {
+		out = append(out, base[pos:c.start]...)
+		out = append(out, c.lines...)
+		pos = c.end
+	}
+	return append(out, base[pos:end]...)
+}
+
+// Merge combines the changes ours and theirs made to base, line by line.
+// Regions both changed differently, including changes that touch, are
+// kept side by side between git-style conflict markers labelled nameOurs
+// and nameTheirs. It returns the merged text and the number of conflicts.
+func Merge(base, ours, theirs, nameOurs, nameTheirs string) (string, int) {
+	b := SplitLines(base)
+	a := changes(Lines(b, SplitLines(ours)))
+	t := changes(Lines(b, SplitLines(theirs)))
+
+	var out strings.Builder
+	write := func(lines []string) {
+		for _, l := range lines {
+			out.WriteString(l)
+		}
+	}
+	// block writes lines as a conflict side, which must end in a newline
+	// for the next marker to start on its own line.
+	block := func(lines []string) {
+		write(lines)
+		if n := len(lines); n > 0 && !strings.HasSuffix(lines[n-1], "\n") {
+			out.WriteString("\n")
+		}
+	}
+
+	conflicts := 0
+	pos := 0
+	for len(a) > 0 || len(t) > 0 {
+		// Start a region at the earliest change and grow it while a change
+		// from either side overlaps or touches it.
+		var ra, rt []change
+		var start int
+		if len(a) > 0 && (len(t) == 0 || a[0].start <= t[0].start) {
+			start = a[0].start
+		} else {
+			start = t[0].start
+		}
+		end := start
+		for grown := true; grown; {
+			grown = false
+			if len(a) > 0 && a[0].start <= end {
+				end = max(end, a[0].end)
+				ra, a = append(ra, a[0]), a[1:]
+				grown = true
+			}
+			if len(t) > 0 && t[0].start <= end {
+				end = max(end, t[0].end)
+				rt, t = append(rt, t[0]), t[1:]
+				grown = true
+			}
+		}
+		write(b[pos:start])
+		pos = end
+
+		mine, other := apply(b, ra, start, end), apply(b, rt, start, end)
+		switch {
+		case len(rt) == 0:
+			write(mine)
+		case len(ra) == 0:
+			write(other)
+		case strings.Join(mine, "") == strings.Join(other, ""):
+			write(mine)
+		default:
+			conflicts++
+			out.WriteString("<<<<<<< " + nameOurs + "\n")
+			block(mine)
+			out.WriteString("=======\n")
+			block(other)
+			out.WriteString(">>>>>>> " + nameTheirs + "\n")
+		}
+	}
+	write(b[pos:])
+	return out.String(), conflicts
+}
@@ -0,0 +1,82 @@
+package diff
+
+import "testing"
+
+func TestMerge(t *testing.T) {
+	tests := []struct {
+		name              string
+		base, ours, their string
+		want              string
+		conflicts         int
+	}{
+		{
+			name:  "separate changes",
+			base:  "a\nb\nc\nd\ne\n",
+			ours:  "a\nB\nc\nd\ne\n",
+			their: "a\nb\nc\nD\ne\n",
+			want:  "a\nB\nc\nD\ne\n",
+		},
+		{
+			name:  "one side only",
+			base:  "a\n",
+			ours:  "a\n",
+			their: "a\nb\n",
+			want:  "a\nb\n",
+		},
+		{
+			name:  "same change on both sides",
+			base:  "a\nb\nc\n",
+			ours:  "a\nX\nc\n",
+			their: "a\nX\nc\n",
+			want:  "a\nX\nc\n",
+		},
+		{
+			name:  "deletion and edit",
+			base:  "a\nb\nc\n",
+			ours:  "b\nc\n",
+			their: "a\nb\nC\n",
+			want:  "b\nC\n",
+		},
+		{
+			name:      "conflicting hunk",
+			base:      "a\nb\nc\n",
+			ours:      "a\nB1\nc\n",
+			their:     "a\nB2\nc\n",
+			want:      "a\n<<<<<<< mine\nB1\n=======\nB2\n>>>>>>> disk\nc\n",
+			conflicts: 1,
+		},
+		{
+			name:      "touching changes",
+			base:      "a\nb\nc\n",
+			ours:      "A\nb\nc\n",
+			their:     "a\nB\nc\n",
+			want:      "<<<<<<< mine\nA\nb\n=======\na\nB\n>>>>>>> disk\nc\n",
+			conflicts: 1,
+		},
+		{
+			name:      "two conflicts",
+			base:      "a\nb\nc\nd\ne\n",
+			ours:      "A1\nb\nc\nd\nE1\n",
+			their:     "A2\nb\nc\nd\nE2\n",
+			want:      "<<<<<<< mine\nA1\n=======\nA2\n>>>>>>> disk\nb\nc\nd\n<<<<<<< mine\nE1\n=======\nE2\n>>>>>>> disk\n",
+			conflicts: 2,
+		},
+		{
+			name:      "no final newline",
+			base:      "a\nb",
+			ours:      "a\nx",
+			their:     "a\ny",
+			want:      "a\n<<<<<<< mine\nx\n=======\ny\n>>>>>>> disk\n",
+			conflicts: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, n := Merge(tt.base, tt.ours, tt.their, "mine", "disk")
+			if got != tt.want || n != tt.conflicts {
+				t.Fatalf("Merge = %q, %d conflicts; want %q, %d", got, n, tt.want, tt.conflicts)
+			}
+		})
+	}
+}
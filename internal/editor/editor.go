@@ -0,0 +1,85 @@
+// Package editor launches the user's external text editor.
+package editor
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+const fallback = "vi"
+
+// Command returns a command that opens path in the configured editor, or
+// $VISUAL, $EDITOR or vi, whichever is set first. The editor string is run
+// by the shell, like git does, so it may carry arguments, e.g.
+// "code --wait", and quoted paths with spaces. A string naming an existing
+// file is run as is, spaces and all.
+func Command(configured, path string) *exec.Cmd {
+	name := fallback
+	for _, v := range []string{configured, os.Getenv("VISUAL"), os.Getenv("EDITOR")} {
+		if v = strings.TrimSpace(v); v != "" {
+			name = v
+			break
+		}
+	}
+
+	if info, err := os.Stat(name); err == nil && !info.IsDir() {
+		return exec.Command(name, path)
+	}
+	return exec.Command("sh", "-c", name+` "$@"`, name, path)
+}
+
+// Session edits a note body through a temporary file rather than the
+// note's own file, so any backend, encrypted ones included, can be edited
+// externally and the result is saved through the store like any other
+// edit: recorded in history, checked for conflicting changes and with
+// links to a changed title rewritten.
+type Session struct {
+	path     string
+	original string
+}
+
+// NewSession writes body to a temporary file named after the note, in dir
+// or the system's temporary directory when dir is empty. Only the user can
+// read the file.
+func NewSession(dir, noteID, body string) (*Session, error) {
+	f, err := os.CreateTemp(dir, "tenote-"+noteID+"-*.md")
+	if err != nil {
+		return nil, fmt.Errorf("create temp file: %w", err)
+	}
+	if _, err := f.WriteString(body); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, fmt.Errorf("write temp file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return nil, fmt.Errorf("write temp file: %w", err)
+	}
+	return &Session{path: f.Name(), original: body}, nil
+}
+
+// Command returns the editor command for the session's file.
+func (s *Session) Command(configured string) *exec.Cmd {
+	return Command(configured, s.path)
+}
+
+// Discard removes the temporary file of a session that is not used.
+func (s *Session) Discard() {
+	os.Remove(s.path)
+}
+
+// Finish reads the edited body back and removes the temporary file.
+// changed reports whether the body differs from the one the session
+// started with.
+func (s *Session) Finish() (body string, changed bool, err error) {
+	defer os.Remove(s.path)
+
+	b, err := os.ReadFile(s.path)
+	if err != nil {
+		return "", false, fmt.Errorf("read temp file: %w", err)
+	}
+	body = string(b)
+	return body, body != s.original, nil
+}
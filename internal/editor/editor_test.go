@@ -0,0 +1,70 @@
+package editor
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// fakeEditor writes a script, in a directory with a space in its name,
+// that appends a line to the file it is given last: its other argument if
+// it has one, or "edited".
+func fakeEditor(t *testing.T) string {
+	t.Helper()
+	dir := filepath.Join(t.TempDir(), "my editor")
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	script := filepath.Join(dir, "edit")
+	src := "#!/bin/sh\nline=edited\nif [ $# -gt 1 ]; then line=$1; shift; fi\necho \"$line\" >> \"$1\"\n"
+	if err := os.WriteFile(script, []byte(src), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return script
+}
+
+func TestSession(t *testing.T) {
+	script := fakeEditor(t)
+	tests := []struct {
+		name   string
+		editor string
+		want   string
+	}{
+		{"path with spaces", script, "body\nedited\n"},
+		{"quoted path with arguments", `"` + script + `" --wait`, "body\n--wait\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := NewSession(t.TempDir(), "01NOTE", "body\n")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if out, err := s.Command(tt.editor).CombinedOutput(); err != nil {
+				t.Fatalf("run editor: %v\n%s", err, out)
+			}
+			body, changed, err := s.Finish()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if body != tt.want || !changed {
+				t.Errorf("Finish = %q, %v; want %q, true", body, changed, tt.want)
+			}
+			if _, err := os.Stat(s.path); !os.IsNotExist(err) {
+				t.Errorf("temp file left behind: %v", err)
+			}
+		})
+	}
+}
+
+func TestSessionUnchanged(t *testing.T) {
+	s, err := NewSession(t.TempDir(), "01NOTE", "body\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Command("true").Run(); err != nil {
+		t.Fatal(err)
+	}
+	if body, changed, err := s.Finish(); err != nil || changed || body != "body\n" {
+		t.Errorf("Finish = %q, %v, %v; want the body unchanged", body, changed, err)
+	}
+}
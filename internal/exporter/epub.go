@@ -0,0 +1,186 @@
+package exporter
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"text/template"
+	"time"
+)
+
+const epubMimetype = "application/epub+zip"
+
+var epubTemplates = template.Must(template.New("container").Parse(`<?xml version="1.0" encoding="UTF-8"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles>
+    <rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/>
+  </rootfiles>
+</container>
+{{define "opf"}}<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="book-id">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:identifier id="book-id">{{.ID}}</dc:identifier>
+    <dc:title>{{html .Title}}</dc:title>
+    <dc:language>en</dc:language>
+    <meta property="dcterms:modified">{{.Modified}}</meta>
+  </metadata>
+  <manifest>
+    <item id="nav" href="nav.xhtml" media-type="application/xhtml+xml" properties="nav"/>
+    <item id="style" href="style.css" media-type="text/css"/>
+{{- range .Chapters}}
+    <item id="n{{.ID}}" href="{{.ID}}.xhtml" media-type="application/xhtml+xml"/>
+{{- end}}
+  </manifest>
+  <spine>
+{{- range .Chapters}}
+    <itemref idref="n{{.ID}}"/>
+{{- end}}
+  </spine>
+</package>
+{{end}}
+{{define "nav"}}<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops" xml:lang="en" lang="en">
+<head>
+<title>{{html .Title}}</title>
+<link rel="stylesheet" type="text/css" href="style.css"/>
+</head>
+<body>
+<nav epub:type="toc" id="toc">
+<h1>{{html .Title}}</h1>
+<ol>
+{{- range .Chapters}}
+<li><a href="{{.ID}}.xhtml">{{html .Title}}</a></li>
+{{- end}}
+</ol>
+</nav>
+</body>
+</html>
+{{end}}
+{{define "chapter"}}<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xml:lang="en" lang="en">
+<head>
+<title>{{html .Title}}</title>
+<link rel="stylesheet" type="text/css" href="style.css"/>
+</head>
+<body>
+<p class="meta">{{html .Section}} · updated {{.Updated}}{{range .Tags}} <span class="tag">#{{html .}}</span>{{end}}</p>
+{{.Content}}
+</body>
+</html>
+{{end}}`))
+
+type epubBook struct {
+	ID       string
+	Title    string
+	Modified string
+	Chapters []epubChapter
+}
+
+type epubChapter struct {
+	ID      string
+	Title   string
+	Section string
+	Updated string
+	Tags    []string
+	Content string // XHTML
+}
+
+// EPUB writes notes to w as an EPUB 3 book titled title, a chapter per
+// note in the order given. Links between the notes lead from chapter to
+// chapter.
+func EPUB(w io.Writer, notes []Note, title string) error {
+	s := newSet(notes)
+	book := epubBook{Title: title}
+
+	// The same notes make the same book, so readers keep their place when
+	// it is exported again.
+	h := sha256.New()
+	var latest time.Time
+	for _, n := range notes {
+		content, err := render(xhtmlMarkdown, s.linkify(withTitle(n), func(to Note) string { return to.ID + ".xhtml" }))
+		if err != nil {
+			return fmt.Errorf("render note %s: %w", n.ID, err)
+		}
+		book.Chapters = append(book.Chapters, epubChapter{
+			ID:      n.ID,
+			Title:   n.Title,
+			Section: sectionName(n.Section),
+			Updated: n.UpdatedAt.Format(dateLayout),
+			Tags:    n.AllTags,
+			Content: content,
+		})
+		h.Write([]byte(n.ID))
+		if n.UpdatedAt.After(latest) {
+			latest = n.UpdatedAt
+		}
+	}
+	book.ID = "urn:tenote:" + hex.EncodeToString(h.Sum(nil))[:32]
+	if latest.IsZero() {
+		latest = time.Now()
+	}
+	book.Modified = latest.UTC().Format(time.RFC3339)
+
+	zw := zip.NewWriter(w)
+	// The mimetype comes first, uncompressed and without extra fields or
+	// a data descriptor, so tools can recognize the file by its first
+	// bytes.
+	mw, err := zw.CreateRaw(&zip.FileHeader{
+		Name:               "mimetype",
+		Method:             zip.Store,
+		CRC32:              crc32.ChecksumIEEE([]byte(epubMimetype)),
+		CompressedSize64:   uint64(len(epubMimetype)),
+		UncompressedSize64: uint64(len(epubMimetype)),
+	})
+	if err != nil {
+		return fmt.Errorf("write epub: %w", err)
+	}
+	if _, err := io.WriteString(mw, epubMimetype); err != nil {
+		return fmt.Errorf("write epub: %w", err)
+	}
+
+	add := func(name, tmpl string, data any) error {
+		var buf bytes.Buffer
+		if err := epubTemplates.ExecuteTemplate(&buf, tmpl, data); err != nil {
+			return fmt.Errorf("render %s: %w", name, err)
+		}
+		return epubFile(zw, name, latest, buf.Bytes())
+	}
+	if err := add("META-INF/container.xml", "container", nil); err != nil {
+		return err
+	}
+	if err := add("OEBPS/content.opf", "opf", book); err != nil {
+		return err
+	}
+	if err := add("OEBPS/nav.xhtml", "nav", book); err != nil {
+		return err
+	}
+	if err := epubFile(zw, "OEBPS/style.css", latest, []byte(CSS)); err != nil {
+		return err
+	}
+	for _, ch := range book.Chapters {
+		if err := add("OEBPS/"+ch.ID+".xhtml", "chapter", ch); err != nil {
+			return err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("write epub: %w", err)
+	}
+	return nil
+}
+
+func epubFile(zw *zip.Writer, name string, modified time.Time, data []byte) error {
+	f, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: modified})
+	if err != nil {
+		return fmt.Errorf("write epub: %w", err)
+	}
+	if _, err := f.Write(data); err != nil {
+		return fmt.Errorf("write epub: %w", err)
+	}
+	return nil
+}
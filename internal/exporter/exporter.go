@@ -0,0 +1,182 @@
+// Package exporter writes notes out of the store in formats other apps
+// read: a standalone HTML page per note, a static site, a single Markdown
+// document and an EPUB book.
+//
+// [[Links]] between exported notes are resolved the way the link graph
+// resolves them, by ID, then title, then alias, and point at the exported
+// copy of their target. Links to notes left out of the export are written
+// as plain text.
+package exporter
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/renderer/html"
+
+	"github.com/internet-kid/tenote/internal/storage/fs"
+	"github.com/internet-kid/tenote/internal/storage/links"
+	"github.com/internet-kid/tenote/internal/storage/tags"
+)
+
+// Formats are the export formats, for the CLI.
+var Formats = []string{"html", "site", "markdown", "epub"}
+
+// Reader is the part of a note store Load reads from. storage.NoteStore
+// satisfies it.
+type Reader interface {
+	ReadBody(path string) (string, error)
+}
+
+// Note is a note being exported.
+type Note struct {
+	fs.Note
+	Content string   // the body below the front matter
+	AllTags []string // front matter and inline tags, normalized
+}
+
+// Load reads the notes to export from store, in the given order.
+func Load(store Reader, notes []fs.Note) ([]Note, error) {
+	out := make([]Note, 0, len(notes))
+	for _, n := range notes {
+		body, err := store.ReadBody(n.Path)
+		if err != nil {
+			return nil, err
+		}
+		_, content := fs.ParseFrontMatter(body)
+		out = append(out, Note{Note: n, Content: content, AllTags: tags.Of(n, body)})
+	}
+	return out, nil
+}
+
+// set is the notes of one export, for resolving links between them.
+type set struct {
+	notes []Note
+	byID  map[string]int
+}
+
+func newSet(notes []Note) set {
+	s := set{notes: notes, byID: make(map[string]int, len(notes))}
+	for i, n := range notes {
+		s.byID[n.ID] = i
+	}
+	return s
+}
+
+// resolve returns the exported note target names. Ties between titles and
+// aliases go to the oldest note, as in the link graph.
+func (s set) resolve(target string) (Note, bool) {
+	target = strings.TrimSpace(target)
+	if i, ok := s.byID[strings.ToUpper(target)]; ok {
+		return s.notes[i], true
+	}
+	best := func(match func(n Note) bool) (Note, bool) {
+		found, ok := Note{}, false
+		for _, n := range s.notes {
+			if match(n) && (!ok || n.ID < found.ID) {
+				found, ok = n, true
+			}
+		}
+		return found, ok
+	}
+	if n, ok := best(func(n Note) bool { return strings.EqualFold(n.Title, target) }); ok {
+		return n, true
+	}
+	return best(func(n Note) bool {
+		for _, a := range n.Aliases {
+			if strings.EqualFold(a, target) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// linkify turns the [[links]] in content into Markdown links to href of
+// their target, or into their text when the target was not exported.
+func (s set) linkify(content string, href func(Note) string) string {
+	var b strings.Builder
+	last := 0
+	for _, l := range links.Parse(content) {
+		b.WriteString(content[last:l.Start])
+		last = l.End
+		n, ok := s.resolve(l.Target)
+		if !ok {
+			b.WriteString(l.Text())
+			continue
+		}
+		text := l.Alias
+		if text == "" {
+			text = n.Title
+		}
+		b.WriteString("[" + escapeText(text) + "](" + href(n) + ")")
+	}
+	b.WriteString(content[last:])
+	return b.String()
+}
+
+// backlinks returns the exported notes linking to each note, by ID.
+func (s set) backlinks() map[string][]Note {
+	out := make(map[string][]Note)
+	for _, from := range s.notes {
+		seen := make(map[string]bool)
+		for _, l := range links.Parse(from.Content) {
+			to, ok := s.resolve(l.Target)
+			if !ok || to.ID == from.ID || seen[to.ID] {
+				continue
+			}
+			seen[to.ID] = true
+			out[to.ID] = append(out[to.ID], from)
+		}
+	}
+	for _, notes := range out {
+		sortByTitle(notes)
+	}
+	return out
+}
+
+// withTitle returns the content of n with its title as a heading when the
+// content does not start with it, e.g. when it comes from front matter.
+func withTitle(n Note) string {
+	if fs.TitleFromBody(n.Content) == n.Title {
+		return n.Content
+	}
+	return "# " + n.Title + "\n\n" + n.Content
+}
+
+var (
+	htmlMarkdown  = newMarkdown()
+	xhtmlMarkdown = newMarkdown(html.WithXHTML())
+)
+
+// newMarkdown returns the Markdown renderer: GitHub flavoured, with IDs
+// on headings. Raw HTML in notes is left out.
+func newMarkdown(opts ...renderer.Option) goldmark.Markdown {
+	return goldmark.New(
+		goldmark.WithExtensions(extension.GFM),
+		goldmark.WithParserOptions(parser.WithAutoHeadingID()),
+		goldmark.WithRendererOptions(opts...),
+	)
+}
+
+// render converts Markdown to HTML with md.
+func render(md goldmark.Markdown, src string) (string, error) {
+	var buf bytes.Buffer
+	if err := md.Convert([]byte(src), &buf); err != nil {
+		return "", fmt.Errorf("render markdown: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// sortByTitle orders notes by title, ignoring case.
+func sortByTitle(notes []Note) {
+	sort.SliceStable(notes, func(i, j int) bool {
+		return strings.ToLower(notes[i].Title) < strings.ToLower(notes[j].Title)
+	})
+}
@@ -0,0 +1,178 @@
+package exporter
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/internet-kid/tenote/internal/storage/fs"
+)
+
+func note(id, title, content string, aliases ...string) Note {
+	return Note{
+		Note: fs.Note{
+			ID:        id,
+			Title:     title,
+			Aliases:   aliases,
+			Section:   fs.SectionNotes,
+			UpdatedAt: time.Date(2026, 10, 17, 9, 0, 0, 0, time.UTC),
+		},
+		Content: content,
+	}
+}
+
+func TestHTMLEscaping(t *testing.T) {
+	n := note("01A", `Tom & "Jerry" <script>alert(1)</script>`,
+		"Body with <img src=x onerror=alert(1)> and <b>bold</b> & more\n")
+	n.AllTags = []string{`x"><script>`}
+
+	var buf bytes.Buffer
+	if err := HTMLPage(&buf, n, []Note{n}); err != nil {
+		t.Fatalf("HTMLPage: %v", err)
+	}
+	page := buf.String()
+	for _, bad := range []string{"<script>", "onerror", "<b>", `"><`} {
+		if strings.Contains(page, bad) {
+			t.Errorf("page contains %q:\n%s", bad, page)
+		}
+	}
+	for _, want := range []string{
+		"<title>Tom &amp; &#34;Jerry&#34; &lt;script&gt;alert(1)&lt;/script&gt;</title>",
+		"&amp; more",
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("page lacks %q:\n%s", want, page)
+		}
+	}
+}
+
+func TestSiteLinks(t *testing.T) {
+	alpha := note("01A", "Alpha", "# Alpha\n\nSee [[Beta]], [[01b|the second]], [[Second]] and [[Missing|gone]].\n")
+	alpha.AllTags = []string{"work"}
+	beta := note("01B", "Beta", "# Beta\n\nBack to [[alpha]].\n", "Second")
+	loose := note("01C", "Loose [brackets]", "Links to [[Beta]].\n")
+	dir := t.TempDir()
+	if err := Site([]Note{alpha, beta, loose}, dir, "My notes"); err != nil {
+		t.Fatalf("Site: %v", err)
+	}
+	read := func(name string) string {
+		t.Helper()
+		b, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(b)
+	}
+
+	page := read("01A.html")
+	for _, want := range []string{
+		// An alias reads as the title of the note it names.
+		`See <a href="01B.html">Beta</a>, <a href="01B.html">the second</a>, <a href="01B.html">Beta</a> and gone.`,
+		`<a class="tag" href="` + TagPage("work") + `">#work</a>`,
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("01A.html lacks %q:\n%s", want, page)
+		}
+	}
+	if strings.Contains(page, "[[") {
+		t.Errorf("01A.html has an unresolved link:\n%s", page)
+	}
+
+	// Backlinks list every note linking to the page once, by title.
+	page = read("01B.html")
+	backlinks := page[strings.Index(page, "Linked from"):]
+	if strings.Count(backlinks, "01A.html") != 1 || !strings.Contains(backlinks, `<a href="01C.html">Loose [brackets]</a>`) {
+		t.Errorf("01B.html backlinks:\n%s", backlinks)
+	}
+
+	index := read(IndexFile)
+	for _, id := range []string{"01A", "01B", "01C"} {
+		if !strings.Contains(index, `href="`+id+`.html"`) {
+			t.Errorf("index does not link %s:\n%s", id, index)
+		}
+	}
+	if !strings.Contains(read(TagPage("work")), `href="01A.html"`) {
+		t.Error("tag page does not list the tagged note")
+	}
+	if read(StyleFile) != CSS {
+		t.Error("site stylesheet differs from CSS")
+	}
+}
+
+func TestEPUB(t *testing.T) {
+	alpha := note("01A", "Alpha & Co", "# Alpha & Co\n\nSee [[Beta]].\n")
+	beta := note("01B", "Beta", "Plain <i>text</i>\n")
+	var buf bytes.Buffer
+	if err := EPUB(&buf, []Note{alpha, beta}, "Book <1>"); err != nil {
+		t.Fatalf("EPUB: %v", err)
+	}
+	data := buf.Bytes()
+
+	// Readers recognize the book by the mimetype stored right after the
+	// first local file header, uncompressed and without extra fields or a
+	// data descriptor.
+	const header = 30
+	if !bytes.HasPrefix(data, []byte("PK\x03\x04")) || data[6]&0x08 != 0 ||
+		string(data[header:header+len("mimetype")]) != "mimetype" ||
+		string(data[header+len("mimetype"):header+len("mimetype")+len(epubMimetype)]) != epubMimetype {
+		t.Fatalf("book does not start with its mimetype: %q", data[:80])
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("zip: %v", err)
+	}
+	first := zr.File[0]
+	if first.Name != "mimetype" || first.Method != zip.Store {
+		t.Fatalf("first entry = %s, method %d", first.Name, first.Method)
+	}
+	files := make(map[string]string)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatalf("read %s: %v", f.Name, err)
+		}
+		files[f.Name] = string(b)
+	}
+
+	for _, name := range []string{"META-INF/container.xml", "OEBPS/content.opf", "OEBPS/nav.xhtml", "OEBPS/style.css", "OEBPS/01A.xhtml", "OEBPS/01B.xhtml"} {
+		if _, ok := files[name]; !ok {
+			t.Fatalf("book lacks %s", name)
+		}
+	}
+	if !strings.Contains(files["META-INF/container.xml"], `full-path="OEBPS/content.opf"`) {
+		t.Error("container does not point at the package")
+	}
+	opf := files["OEBPS/content.opf"]
+	if !strings.Contains(opf, "<dc:title>Book &lt;1&gt;</dc:title>") || strings.Index(opf, `idref="n01A"`) > strings.Index(opf, `idref="n01B"`) {
+		t.Errorf("content.opf:\n%s", opf)
+	}
+	if !strings.Contains(files["OEBPS/nav.xhtml"], `<a href="01A.xhtml">Alpha &amp; Co</a>`) {
+		t.Errorf("nav.xhtml:\n%s", files["OEBPS/nav.xhtml"])
+	}
+	if !strings.Contains(files["OEBPS/01A.xhtml"], `<a href="01B.xhtml">Beta</a>`) {
+		t.Errorf("chapter does not link the other:\n%s", files["OEBPS/01A.xhtml"])
+	}
+	if strings.Contains(files["OEBPS/01B.xhtml"], "<i>") {
+		t.Errorf("chapter kept raw HTML:\n%s", files["OEBPS/01B.xhtml"])
+	}
+
+	// The same notes make the same book.
+	var again bytes.Buffer
+	if err := EPUB(&again, []Note{alpha, beta}, "Book <1>"); err != nil {
+		t.Fatalf("EPUB: %v", err)
+	}
+	if !bytes.Equal(again.Bytes(), data) {
+		t.Error("exporting the same notes twice made different books")
+	}
+}
@@ -0,0 +1,358 @@
+package exporter
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/internet-kid/tenote/internal/storage/fs"
+)
+
+const (
+	filePerm = 0o644
+	dirPerm  = 0o755
+
+	dateLayout = "2006-01-02"
+)
+
+// The fixed pages of a site. Note pages are named by PageName and tag
+// pages by TagPage.
+const (
+	StyleFile  = "style.css"
+	IndexFile  = "index.html"
+	TagsFile   = "tags.html"
+	SearchFile = "search.html"
+)
+
+// CSS is the stylesheet of the pages, inlined in standalone ones and the
+// StyleFile of a site.
+const CSS = `body { margin: 0; font: 16px/1.6 -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; color: #24292f; background: #fff; }
+nav, main { max-width: 46em; margin: 0 auto; padding: 0 1em; }
+nav { padding-top: 1em; font-size: .9em; }
+nav form { display: inline; margin-left: .5em; }
+nav a, .meta a, a.tag { color: #57606a; }
+a { color: #0969da; }
+.meta, .date { color: #57606a; font-size: .9em; }
+.tag { margin-right: .4em; }
+pre, code { font: .9em ui-monospace, Menlo, Consolas, monospace; background: #f6f8fa; }
+pre { padding: 1em; overflow: auto; }
+pre code { background: none; }
+blockquote { margin: 0; padding: 0 1em; color: #57606a; border-left: .25em solid #d0d7de; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #d0d7de; padding: .3em .8em; }
+img { max-width: 100%; }
+.backlinks { margin-top: 3em; border-top: 1px solid #d0d7de; }
+@media (prefers-color-scheme: dark) {
+  body { color: #c9d1d9; background: #0d1117; }
+  a { color: #58a6ff; }
+  nav a, .meta a, a.tag, .meta, .date, blockquote { color: #8b949e; }
+  pre, code { background: #161b22; }
+  th, td, .backlinks { border-color: #30363d; }
+}
+`
+
+var templates = template.Must(template.New("page").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+{{if .Site}}<link rel="stylesheet" href="` + StyleFile + `">{{else}}<style>{{.CSS}}</style>{{end}}
+</head>
+<body>
+{{if .Site}}<nav><a href="` + IndexFile + `">{{.Site}}</a> · <a href="` + TagsFile + `">Tags</a>
+{{- if .Search}}<form action="` + SearchFile + `"><input type="search" name="q" value="{{.Query}}" placeholder="Search"></form>{{end}}</nav>
+{{end}}<main>
+{{.Body}}
+</main>
+</body>
+</html>
+{{define "note"}}<p class="meta">{{.Section}} · updated {{.Updated}}{{range .Tags}} {{if .Href}}<a class="tag" href="{{.Href}}">#{{.Name}}</a>{{else}}<span class="tag">#{{.Name}}</span>{{end}}{{end}}</p>
+{{.Content}}
+{{- if .Backlinks}}
+<section class="backlinks">
+<h2>Linked from</h2>
+<ul>
+{{range .Backlinks}}<li><a href="{{.Href}}">{{.Title}}</a></li>
+{{end}}</ul>
+</section>
+{{- end}}
+{{end}}
+{{define "list"}}<h1>{{.Heading}}</h1>
+{{range .Groups}}{{if .Name}}<h2>{{.Name}}</h2>
+{{end}}<ul>
+{{range .Links}}<li><a href="{{.Href}}">{{.Title}}</a>{{if .Aside}} <span class="date">{{.Aside}}</span>{{end}}</li>
+{{end}}</ul>
+{{end}}{{end}}
+`))
+
+type pageData struct {
+	Title  string
+	Site   string // the site title; empty for standalone pages
+	Search bool
+	Query  string
+	CSS    template.CSS
+	Body   template.HTML
+}
+
+type noteData struct {
+	Section   string
+	Updated   string
+	Tags      []tagLink
+	Content   template.HTML
+	Backlinks []link
+}
+
+type tagLink struct {
+	Name string
+	Href string // empty for standalone pages
+}
+
+type link struct {
+	Title string
+	Href  string
+	Aside string // shown after the link, e.g. a date
+}
+
+type group struct {
+	Name  string
+	Links []link
+}
+
+type listData struct {
+	Heading string
+	Groups  []group
+}
+
+// HTML writes every note to dir as a standalone page named after its ID.
+// Pages link to each other where their notes do.
+func HTML(notes []Note, dir string) error {
+	if err := os.MkdirAll(dir, dirPerm); err != nil {
+		return fmt.Errorf("create export dir: %w", err)
+	}
+	p := NewPages(notes, "")
+	for _, n := range notes {
+		if err := writePage(filepath.Join(dir, PageName(n)), func(w io.Writer) error { return p.Note(w, n, nil) }); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// HTMLPage writes n as a standalone page to w. Links to the other notes
+// point at the pages HTML would write for them.
+func HTMLPage(w io.Writer, n Note, notes []Note) error {
+	return NewPages(notes, "").Note(w, n, nil)
+}
+
+// Site writes a static site to dir: a page per note with its backlinks,
+// an index of the notes by notebook, and a page per tag.
+func Site(notes []Note, dir, title string) error {
+	if err := os.MkdirAll(dir, dirPerm); err != nil {
+		return fmt.Errorf("create export dir: %w", err)
+	}
+	if err := writeFile(filepath.Join(dir, StyleFile), []byte(CSS)); err != nil {
+		return err
+	}
+	p := NewPages(notes, title)
+	backlinks := p.set.backlinks()
+	for _, n := range notes {
+		if err := writePage(filepath.Join(dir, PageName(n)), func(w io.Writer) error { return p.Note(w, n, backlinks[n.ID]) }); err != nil {
+			return err
+		}
+	}
+	if err := writePage(filepath.Join(dir, IndexFile), p.Index); err != nil {
+		return err
+	}
+	for _, t := range p.TagNames() {
+		if err := writePage(filepath.Join(dir, TagPage(t)), func(w io.Writer) error { return p.Tag(w, t) }); err != nil {
+			return err
+		}
+	}
+	return writePage(filepath.Join(dir, TagsFile), p.Tags)
+}
+
+// Pages renders the pages of a site one at a time. Site writes them all;
+// tenote serve renders each when it is asked for. Only the notes whose
+// own page is rendered need their Content; the others are linked to.
+type Pages struct {
+	// Search adds a search box to every page, which asks SearchFile.
+	Search bool
+
+	title  string
+	set    set
+	tagged map[string][]link // links to the notes with each tag
+}
+
+// NewPages returns the pages of the site titled title over notes. Without
+// a title the note pages are standalone.
+func NewPages(notes []Note, title string) *Pages {
+	p := &Pages{title: title, set: newSet(notes), tagged: make(map[string][]link)}
+	for _, n := range notes {
+		for _, t := range n.AllTags {
+			p.tagged[t] = append(p.tagged[t], noteLink(n))
+		}
+	}
+	for _, links := range p.tagged {
+		sortLinks(links)
+	}
+	return p
+}
+
+// Note writes the page of n, listing backlinks below it.
+func (p *Pages) Note(w io.Writer, n Note, backlinks []Note) error {
+	content, err := render(htmlMarkdown, p.set.linkify(withTitle(n), PageName))
+	if err != nil {
+		return fmt.Errorf("render note %s: %w", n.ID, err)
+	}
+
+	data := noteData{
+		Section: sectionName(n.Section),
+		Updated: n.UpdatedAt.Format(dateLayout),
+		Content: template.HTML(content),
+	}
+	for _, t := range n.AllTags {
+		tl := tagLink{Name: t}
+		if p.title != "" {
+			tl.Href = TagPage(t)
+		}
+		data.Tags = append(data.Tags, tl)
+	}
+	for _, from := range backlinks {
+		data.Backlinks = append(data.Backlinks, noteLink(from))
+	}
+
+	var body bytes.Buffer
+	if err := templates.ExecuteTemplate(&body, "note", data); err != nil {
+		return fmt.Errorf("render note %s: %w", n.ID, err)
+	}
+	return p.execute(w, pageData{Title: n.Title, CSS: CSS, Body: template.HTML(body.String())})
+}
+
+// Index writes the index of the notes, grouped by notebook in the order
+// the notebooks first appear.
+func (p *Pages) Index(w io.Writer) error {
+	var groups []group
+	bySection := make(map[fs.Section]int)
+	for _, n := range p.set.notes {
+		i, ok := bySection[n.Section]
+		if !ok {
+			i = len(groups)
+			bySection[n.Section] = i
+			groups = append(groups, group{Name: sectionName(n.Section)})
+		}
+		groups[i].Links = append(groups[i].Links, noteLink(n))
+	}
+	for _, g := range groups {
+		sortLinks(g.Links)
+	}
+	return p.list(w, p.title, "", listData{Heading: p.title, Groups: groups})
+}
+
+// Tags writes the list of tags with their note counts.
+func (p *Pages) Tags(w io.Writer) error {
+	all := group{}
+	for _, t := range p.TagNames() {
+		all.Links = append(all.Links, link{Title: "#" + t, Href: TagPage(t), Aside: countNotes(len(p.tagged[t]))})
+	}
+	return p.list(w, "Tags", "", listData{Heading: "Tags", Groups: []group{all}})
+}
+
+// Tag writes the list of notes tagged tag.
+func (p *Pages) Tag(w io.Writer, tag string) error {
+	return p.list(w, "#"+tag, "", listData{Heading: "#" + tag, Groups: []group{{Links: p.tagged[tag]}}})
+}
+
+// Results writes the notes found searching for query, in the order given.
+func (p *Pages) Results(w io.Writer, query string, notes []Note) error {
+	found := group{}
+	for _, n := range notes {
+		found.Links = append(found.Links, noteLink(n))
+	}
+	heading := countNotes(len(notes)) + " found"
+	return p.list(w, "Search: "+query, query, listData{Heading: heading, Groups: []group{found}})
+}
+
+// TagNames returns the tags of the notes, sorted.
+func (p *Pages) TagNames() []string {
+	names := make([]string, 0, len(p.tagged))
+	for t := range p.tagged {
+		names = append(names, t)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (p *Pages) list(w io.Writer, title, query string, data listData) error {
+	var body bytes.Buffer
+	if err := templates.ExecuteTemplate(&body, "list", data); err != nil {
+		return fmt.Errorf("render %s: %w", title, err)
+	}
+	return p.execute(w, pageData{Title: title, Query: query, Body: template.HTML(body.String())})
+}
+
+func (p *Pages) execute(w io.Writer, data pageData) error {
+	data.Site, data.Search = p.title, p.Search && p.title != ""
+	if err := templates.Execute(w, data); err != nil {
+		return fmt.Errorf("render %s: %w", data.Title, err)
+	}
+	return nil
+}
+
+// writePage writes the page that page renders to path.
+func writePage(path string, page func(io.Writer) error) error {
+	var buf bytes.Buffer
+	if err := page(&buf); err != nil {
+		return err
+	}
+	return writeFile(path, buf.Bytes())
+}
+
+func writeFile(path string, data []byte) error {
+	if err := os.WriteFile(path, data, filePerm); err != nil {
+		return fmt.Errorf("write %q: %w", path, err)
+	}
+	return nil
+}
+
+// PageName is the page of a note.
+func PageName(n Note) string { return n.ID + ".html" }
+
+// TagPage is the page of a tag. Nested tags keep their slashes as tildes,
+// which tags cannot contain.
+func TagPage(tag string) string {
+	return "tag-" + strings.ReplaceAll(tag, "/", "~") + ".html"
+}
+
+func noteLink(n Note) link {
+	return link{Title: n.Title, Href: PageName(n), Aside: n.UpdatedAt.Format(dateLayout)}
+}
+
+func countNotes(n int) string {
+	if n == 1 {
+		return "1 note"
+	}
+	return fmt.Sprintf("%d notes", n)
+}
+
+func sortLinks(links []link) {
+	sort.SliceStable(links, func(i, j int) bool {
+		return strings.ToLower(links[i].Title) < strings.ToLower(links[j].Title)
+	})
+}
+
+// sectionName is how exports name the notebook a note is in.
+func sectionName(sec fs.Section) string {
+	switch {
+	case sec == fs.SectionNotes:
+		return "Notes"
+	case sec == fs.SectionTrash:
+		return "Trash"
+	}
+	return sec.Rel()
+}
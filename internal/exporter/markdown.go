@@ -0,0 +1,31 @@
+package exporter
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Markdown writes notes to w as one Markdown document: a list of contents,
+// then each note below a rule and an anchor named after its ID, which the
+// links between the notes point at.
+func Markdown(w io.Writer, notes []Note) error {
+	s := newSet(notes)
+	var b strings.Builder
+	for _, n := range notes {
+		fmt.Fprintf(&b, "- [%s](#%s)\n", escapeText(n.Title), n.ID)
+	}
+	for _, n := range notes {
+		content := s.linkify(withTitle(n), func(to Note) string { return "#" + to.ID })
+		fmt.Fprintf(&b, "\n---\n\n<a id=\"%s\"></a>\n\n%s\n", n.ID, strings.Trim(content, "\n"))
+	}
+	if _, err := io.WriteString(w, b.String()); err != nil {
+		return fmt.Errorf("write markdown: %w", err)
+	}
+	return nil
+}
+
+// escapeText escapes the characters that would end link text early.
+func escapeText(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "[", `\[`, "]", `\]`).Replace(s)
+}
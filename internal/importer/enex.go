@@ -0,0 +1,126 @@
+package importer
+
+import (
+	"bufio"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// enexTime is the layout of the times in an ENEX file.
+const enexTime = "20060102T150405Z"
+
+type enexNote struct {
+	Title     string   `xml:"title"`
+	Content   string   `xml:"content"`
+	Created   string   `xml:"created"`
+	Updated   string   `xml:"updated"`
+	Tags      []string `xml:"tag"`
+	Resources []struct {
+		Mime     string `xml:"mime"`
+		FileName string `xml:"resource-attributes>file-name"`
+	} `xml:"resource"`
+}
+
+// ENEX reads a notebook exported from Evernote as an .enex file. Note
+// content is converted from Evernote's HTML to Markdown. Links to other
+// notes are matched to the notes of the file by their text, since the
+// file does not say which note they point at. Attachments and encrypted
+// text are not imported.
+func ENEX(path string) (Source, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Source{}, fmt.Errorf("open enex file: %w", err)
+	}
+	defer f.Close()
+
+	var notes []enexNote
+	d := xml.NewDecoder(bufio.NewReader(f))
+	for {
+		tok, err := d.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return Source{}, fmt.Errorf("read enex file %q: %w", path, err)
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok || start.Name.Local != "note" {
+			continue
+		}
+		var n enexNote
+		if err := d.DecodeElement(&n, &start); err != nil {
+			return Source{}, fmt.Errorf("read enex file %q: %w", path, err)
+		}
+		notes = append(notes, n)
+	}
+
+	titles := make(map[string]string, len(notes))
+	for i, n := range notes {
+		title := strings.ToLower(strings.TrimSpace(n.Title))
+		if _, dup := titles[title]; !dup {
+			titles[title] = strconv.Itoa(i)
+		}
+	}
+
+	var src Source
+	for i, en := range notes {
+		n := Note{
+			Key:     strconv.Itoa(i),
+			Title:   strings.TrimSpace(en.Title),
+			Tags:    en.Tags,
+			Created: enexParseTime(en.Created),
+			Updated: enexParseTime(en.Updated),
+		}
+		if n.Title == "" {
+			n.Title = "Untitled"
+		}
+		problem := func(reason string) {
+			src.Problems = append(src.Problems, Problem{Item: n.Title, Reason: reason})
+		}
+
+		var problems []string
+		n.Body, problems = htmlToMarkdown(en.Content, func(href, text string) (string, bool) {
+			if !isEvernoteLink(href) {
+				return "", false
+			}
+			if key, ok := titles[strings.ToLower(text)]; ok {
+				return wikiLink(key, text), true
+			}
+			problem(fmt.Sprintf("link %q to another Evernote note kept as plain text", text))
+			return text, true
+		})
+		for _, reason := range problems {
+			problem(reason)
+		}
+		for _, r := range en.Resources {
+			name := r.FileName
+			if name == "" {
+				name = r.Mime
+			}
+			problem(fmt.Sprintf("attachment %q not imported", name))
+		}
+		src.Notes = append(src.Notes, n)
+	}
+	return src, nil
+}
+
+func enexParseTime(s string) time.Time {
+	t, err := time.Parse(enexTime, strings.TrimSpace(s))
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// isEvernoteLink reports whether href is a note link, either the app's own
+// or a web one.
+func isEvernoteLink(href string) bool {
+	return strings.HasPrefix(href, "evernote:///view/") ||
+		strings.Contains(href, "evernote.com/shard/") && strings.Contains(href, "/nl/")
+}
@@ -0,0 +1,410 @@
+package importer
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// node is an element or, with an empty name, a run of text in an HTML
+// document.
+type node struct {
+	name  string
+	attrs map[string]string
+	text  string
+	kids  []*node
+}
+
+func (n *node) attr(name string) string { return n.attrs[name] }
+
+// parseHTML parses the XHTML of Evernote notes and the looser HTML of
+// Joplin's HTML notes. What parses before an error is returned with it.
+func parseHTML(src string) (*node, error) {
+	d := xml.NewDecoder(strings.NewReader(src))
+	d.Strict = false
+	d.AutoClose = xml.HTMLAutoClose
+	d.Entity = xml.HTMLEntity
+
+	root := &node{name: "#root"}
+	stack := []*node{root}
+	for {
+		tok, err := d.Token()
+		if errors.Is(err, io.EOF) {
+			return root, nil
+		}
+		if err != nil {
+			return root, err
+		}
+		top := stack[len(stack)-1]
+		switch t := tok.(type) {
+		case xml.StartElement:
+			n := &node{name: strings.ToLower(t.Name.Local), attrs: make(map[string]string, len(t.Attr))}
+			for _, a := range t.Attr {
+				n.attrs[strings.ToLower(a.Name.Local)] = a.Value
+			}
+			top.kids = append(top.kids, n)
+			stack = append(stack, n)
+		case xml.EndElement:
+			if len(stack) > 1 {
+				stack = stack[:len(stack)-1]
+			}
+		case xml.CharData:
+			top.kids = append(top.kids, &node{text: string(t)})
+		}
+	}
+}
+
+// htmlToMarkdown converts an HTML note body to Markdown. link is asked
+// about every link and returns what to write instead, or false to keep it
+// as a Markdown link. It returns what could not be converted alongside.
+func htmlToMarkdown(src string, link func(href, text string) (string, bool)) (string, []string) {
+	root, err := parseHTML(src)
+	c := converter{link: link}
+	if err != nil {
+		c.problem(fmt.Sprintf("the note is not well-formed (%v); what follows the error is missing", err))
+	}
+	if body := find(root, "body"); body != nil {
+		root = body
+	}
+	return strings.Join(c.blocks(root.kids), "\n\n") + "\n", c.problems
+}
+
+// find returns the first element called name in the tree below n.
+func find(n *node, name string) *node {
+	for _, k := range n.kids {
+		if k.name == name {
+			return k
+		}
+		if f := find(k, name); f != nil {
+			return f
+		}
+	}
+	return nil
+}
+
+type converter struct {
+	link     func(href, text string) (string, bool)
+	problems []string
+	seen     map[string]bool
+}
+
+// problem records reason once per note.
+func (c *converter) problem(reason string) {
+	if c.seen[reason] {
+		return
+	}
+	if c.seen == nil {
+		c.seen = make(map[string]bool)
+	}
+	c.seen[reason] = true
+	c.problems = append(c.problems, reason)
+}
+
+// skipped are elements whose content is not part of the note.
+var skipped = map[string]bool{"head": true, "title": true, "script": true, "style": true}
+
+// inlineElems are rendered within the surrounding paragraph; everything
+// else starts a block of its own.
+var inlineElems = map[string]bool{
+	"": true, "a": true, "b": true, "strong": true, "i": true, "em": true,
+	"u": true, "s": true, "strike": true, "del": true, "code": true,
+	"span": true, "font": true, "sub": true, "sup": true, "small": true,
+	"big": true, "mark": true, "abbr": true, "cite": true, "q": true,
+	"br": true, "img": true, "en-todo": true, "en-media": true,
+}
+
+// blocks renders nodes as Markdown blocks, leaving out empty ones.
+func (c *converter) blocks(nodes []*node) []string {
+	var out []string
+	add := func(b string) {
+		switch {
+		case strings.TrimSpace(b) == "":
+			return
+		case len(out) > 0 && isTodo(strings.TrimPrefix(b, "- ")) && isTodo(strings.TrimPrefix(out[len(out)-1], "- ")):
+			// Evernote writes a checklist as one block per item.
+			out[len(out)-1] += "\n" + b
+			return
+		}
+		out = append(out, b)
+	}
+
+	var para strings.Builder
+	flush := func() {
+		p := tidy(para.String())
+		if isTodo(p) {
+			p = "- " + p
+		}
+		add(p)
+		para.Reset()
+	}
+	for _, n := range nodes {
+		if inlineElems[n.name] {
+			para.WriteString(c.inline(n))
+			continue
+		}
+		flush()
+		add(c.block(n))
+	}
+	flush()
+	return out
+}
+
+func (c *converter) block(n *node) string {
+	switch n.name {
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		level, _ := strconv.Atoi(n.name[1:])
+		return strings.Repeat("#", level) + " " + strings.ReplaceAll(tidy(c.inlines(n.kids)), "\n", " ")
+	case "p":
+		return tidy(c.inlines(n.kids))
+	case "hr":
+		return "---"
+	case "pre":
+		return fence(text(n))
+	case "blockquote":
+		return prefixLines(strings.Join(c.blocks(n.kids), "\n\n"), "> ", "> ")
+	case "ul", "ol":
+		return c.list(n)
+	case "table":
+		return c.table(n)
+	case "en-crypt":
+		c.problem("encrypted text not imported")
+		return ""
+	}
+	if skipped[n.name] {
+		return ""
+	}
+	if strings.Contains(strings.ReplaceAll(n.attr("style"), " ", ""), "-en-codeblock:true") {
+		return fence(text(n))
+	}
+	// div, en-note and anything unknown: the blocks inside.
+	return strings.Join(c.blocks(n.kids), "\n\n")
+}
+
+func (c *converter) inlines(nodes []*node) string {
+	var b strings.Builder
+	for _, n := range nodes {
+		b.WriteString(c.inline(n))
+	}
+	return b.String()
+}
+
+func (c *converter) inline(n *node) string {
+	switch n.name {
+	case "":
+		return collapse(n.text)
+	case "br":
+		return "\n"
+	case "b", "strong":
+		return wrap(c.inlines(n.kids), "**")
+	case "i", "em":
+		return wrap(c.inlines(n.kids), "*")
+	case "s", "strike", "del":
+		return wrap(c.inlines(n.kids), "~~")
+	case "code":
+		return wrap(text(n), "`")
+	case "a":
+		return c.anchor(n)
+	case "img":
+		src := n.attr("src")
+		if strings.HasPrefix(src, "http://") || strings.HasPrefix(src, "https://") {
+			return "![" + n.attr("alt") + "](" + src + ")"
+		}
+		c.problem("embedded image not imported")
+		return ""
+	case "en-todo":
+		if n.attr("checked") == "true" {
+			return "[x] "
+		}
+		return "[ ] "
+	case "en-media":
+		// Readers report attachments by name.
+		return ""
+	}
+	if inlineElems[n.name] {
+		return c.inlines(n.kids)
+	}
+	// A block inside an inline element: keep its text on its own lines.
+	return "\n" + strings.Join(c.blocks(n.kids), "\n\n") + "\n"
+}
+
+func (c *converter) anchor(n *node) string {
+	label := strings.TrimSpace(c.inlines(n.kids))
+	href := strings.TrimSpace(n.attr("href"))
+	if href == "" {
+		return label
+	}
+	if c.link != nil {
+		if out, ok := c.link(href, label); ok {
+			return out
+		}
+	}
+	if label == "" || label == href {
+		return "<" + href + ">"
+	}
+	return "[" + label + "](" + href + ")"
+}
+
+func (c *converter) list(n *node) string {
+	todo := strings.Contains(strings.ReplaceAll(n.attr("style"), " ", ""), "--en-todo:true")
+	var items []string
+	num := 1
+	if start, err := strconv.Atoi(n.attr("start")); err == nil {
+		num = start
+	}
+	for _, li := range n.kids {
+		if li.name != "li" {
+			continue
+		}
+		marker := "- "
+		if n.name == "ol" {
+			marker = strconv.Itoa(num) + ". "
+			num++
+		}
+		if todo {
+			if strings.Contains(strings.ReplaceAll(li.attr("style"), " ", ""), "--en-checked:true") {
+				marker += "[x] "
+			} else {
+				marker += "[ ] "
+			}
+		}
+		body := strings.Join(c.blocks(li.kids), "\n")
+		if b := strings.TrimPrefix(body, "- "); isTodo(b) {
+			// A checkbox of its own in the item.
+			body = b
+		}
+		items = append(items, prefixLines(body, marker, strings.Repeat(" ", len(marker))))
+	}
+	return strings.Join(items, "\n")
+}
+
+// isTodo reports whether a paragraph starts with the checkbox of an
+// en-todo.
+func isTodo(p string) bool {
+	return strings.HasPrefix(p, "[ ] ") || strings.HasPrefix(p, "[x] ")
+}
+
+// table renders a table as a Markdown table with its first row as the
+// header. Cells are flattened to a single line.
+func (c *converter) table(n *node) string {
+	var rows [][]string
+	var walk func(*node)
+	walk = func(n *node) {
+		for _, k := range n.kids {
+			switch k.name {
+			case "tr":
+				var row []string
+				for _, cell := range k.kids {
+					if cell.name == "td" || cell.name == "th" {
+						s := strings.Join(c.blocks(cell.kids), " ")
+						s = strings.ReplaceAll(strings.ReplaceAll(s, "\n", " "), "|", `\|`)
+						row = append(row, s)
+					}
+				}
+				rows = append(rows, row)
+			case "thead", "tbody", "tfoot":
+				walk(k)
+			}
+		}
+	}
+	walk(n)
+	if len(rows) == 0 {
+		return ""
+	}
+
+	width := 0
+	for _, r := range rows {
+		width = max(width, len(r))
+	}
+	var b strings.Builder
+	for i, r := range rows {
+		for len(r) < width {
+			r = append(r, "")
+		}
+		b.WriteString("| " + strings.Join(r, " | ") + " |\n")
+		if i == 0 {
+			b.WriteString("|" + strings.Repeat(" --- |", width) + "\n")
+		}
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// text returns the text below n as it is, for code.
+func text(n *node) string {
+	if n.name == "" {
+		return n.text
+	}
+	if n.name == "br" {
+		return "\n"
+	}
+	var b strings.Builder
+	for _, k := range n.kids {
+		b.WriteString(text(k))
+		if k.name == "div" || k.name == "p" {
+			b.WriteString("\n")
+		}
+	}
+	return b.String()
+}
+
+func fence(code string) string {
+	return "```\n" + strings.Trim(code, "\n") + "\n```"
+}
+
+// wrap puts s between marks, keeping surrounding spaces outside them.
+func wrap(s, mark string) string {
+	trimmed := strings.TrimSpace(s)
+	if trimmed == "" {
+		return s
+	}
+	i := strings.Index(s, trimmed)
+	return s[:i] + mark + trimmed + mark + s[i+len(trimmed):]
+}
+
+// collapse turns runs of white space into single spaces, as browsers do.
+func collapse(s string) string {
+	var b strings.Builder
+	space := false
+	for _, r := range s {
+		if unicode.IsSpace(r) {
+			if !space {
+				b.WriteByte(' ')
+			}
+			space = true
+			continue
+		}
+		space = false
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// tidy trims every line of a paragraph and drops blank ones at the ends.
+func tidy(s string) string {
+	lines := strings.Split(s, "\n")
+	for i, l := range lines {
+		lines[i] = strings.TrimSpace(l)
+	}
+	return strings.Trim(strings.Join(lines, "\n"), "\n")
+}
+
+// prefixLines puts first before the first line of s and rest before the
+// others.
+func prefixLines(s, first, rest string) string {
+	lines := strings.Split(s, "\n")
+	for i, l := range lines {
+		p := rest
+		if i == 0 {
+			p = first
+		}
+		if l == "" {
+			lines[i] = strings.TrimRight(p, " ")
+			continue
+		}
+		lines[i] = p + l
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,392 @@
+// Package importer converts notes exported from other note apps into
+// tenote notes.
+//
+// A reader per format turns an export into a Source: notes in Markdown
+// with their notebook, tags and times, plus a Problem for everything that
+// could not be converted, such as attachments. Links between notes of the
+// export are written as [[key|text]], where key identifies the target
+// within the export; Write creates the notes in a store and points those
+// links at the new note IDs.
+package importer
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/internet-kid/tenote/internal/storage"
+	"github.com/internet-kid/tenote/internal/storage/fs"
+	"github.com/internet-kid/tenote/internal/storage/links"
+	"github.com/internet-kid/tenote/internal/storage/tags"
+)
+
+// Source is an export read by one of the readers.
+type Source struct {
+	Notes    []Note
+	Problems []Problem
+}
+
+// Note is a note of an export, ready to be written to a store.
+type Note struct {
+	Key      string   // identifies the note within the export
+	Title    string   // empty when the body starts with it
+	Body     string   // Markdown; links to other notes are [[key|text]]
+	Notebook []string // notebook names from the outermost in, nil for the root
+	Tags     []string
+	Created  time.Time
+	Updated  time.Time
+	Pinned   bool
+	Trashed  bool
+}
+
+// name is how problems with the note refer to it.
+func (n Note) name() string {
+	if n.Title != "" {
+		return n.Title
+	}
+	_, content := fs.ParseFrontMatter(n.Body)
+	return fs.TitleFromBody(content)
+}
+
+// Problem is something in an export that was skipped or only partly
+// converted.
+type Problem struct {
+	Item   string // the note or file concerned
+	Reason string
+}
+
+func (p Problem) String() string { return p.Item + ": " + p.Reason }
+
+// Report is the outcome of Write.
+type Report struct {
+	Notes    []fs.Note // the imported notes, in export order
+	Problems []Problem
+}
+
+// Formats are the export formats Read understands.
+var Formats = []string{"obsidian", "joplin", "enex", "simplenote"}
+
+// Read reads the export at path in format, one of Formats.
+func Read(format, path string) (Source, error) {
+	switch format {
+	case "obsidian":
+		return Obsidian(path)
+	case "joplin":
+		return Joplin(path)
+	case "enex":
+		return ENEX(path)
+	case "simplenote":
+		return Simplenote(path)
+	}
+	return Source{}, fmt.Errorf("unknown import format %q", format)
+}
+
+// Write creates the notes of src in store, inside the notebook into and
+// the notebooks below it that the export had. Notes the export had
+// trashed go to the trash. When Write fails part way, the notes not yet
+// written are deleted again and the report lists the notes imported.
+func Write(store storage.NoteStore, src Source, into fs.Section) (Report, error) {
+	w := writer{store: store, into: into, report: Report{Problems: slices.Clone(src.Problems)}}
+	if err := w.loadSections(); err != nil {
+		return w.report, err
+	}
+
+	// Every note is created before any is written, so links can point at
+	// notes further down the export.
+	created := make([]fs.Note, 0, len(src.Notes))
+	ids := make(map[string]fs.Note, len(src.Notes))
+	for _, in := range src.Notes {
+		sec, err := w.notebook(in)
+		if err != nil {
+			return w.report, w.discard(created, err)
+		}
+		n, err := store.Create(sec)
+		if err != nil {
+			return w.report, w.discard(created, err)
+		}
+		created = append(created, n)
+		if in.Key != "" {
+			ids[in.Key] = n
+		}
+	}
+
+	for i, in := range src.Notes {
+		n := created[i]
+		body := w.body(in, ids)
+		if err := store.WriteBody(n.Path, body); err != nil {
+			return w.report, w.discard(created[i:], err)
+		}
+		meta, content := fs.ParseFrontMatter(body)
+		n.ApplyMeta(meta, content)
+		w.report.Notes = append(w.report.Notes, n)
+		imported := &w.report.Notes[len(w.report.Notes)-1]
+
+		if in.Trashed {
+			var err error
+			if n, err = store.MoveToTrash(n); err != nil {
+				return w.report, w.discard(created[i+1:], err)
+			}
+			*imported = n
+		}
+		if b, ok := store.(storage.Backdater); ok && !in.Updated.IsZero() {
+			if err := b.SetUpdatedAt(n.Path, in.Updated); err != nil {
+				return w.report, w.discard(created[i+1:], err)
+			}
+			imported.UpdatedAt = in.Updated
+		}
+	}
+	return w.report, nil
+}
+
+// discard deletes the notes Write created but did not write, so a failed
+// import leaves no empty notes behind, and returns cause. Notes that
+// cannot be deleted are reported as problems.
+func (w *writer) discard(notes []fs.Note, cause error) error {
+	for _, n := range notes {
+		trashed, err := w.store.MoveToTrash(n)
+		if err == nil {
+			err = w.store.DeleteFromTrash(trashed)
+		}
+		if err != nil {
+			w.problem(n.ID, "empty note left by the failed import could not be deleted: "+err.Error())
+		}
+	}
+	return cause
+}
+
+type writer struct {
+	store    storage.NoteStore
+	into     fs.Section
+	report   Report
+	sections map[fs.Section]bool
+	flat     map[string]bool // notebooks reported as not created
+}
+
+// loadSections finds the existing notebooks and creates the one notes are
+// imported into when it is missing.
+func (w *writer) loadSections() error {
+	secs, err := w.store.Sections()
+	if err != nil {
+		return err
+	}
+	w.sections = make(map[fs.Section]bool, len(secs))
+	for _, sec := range secs {
+		w.sections[sec] = true
+	}
+	if w.sections[w.into] {
+		return nil
+	}
+	if _, ok := w.store.(storage.Notebooks); !ok {
+		return fmt.Errorf("notebook %s does not exist", w.into)
+	}
+	_, err = w.mkdirs(fs.SectionNotes, strings.Split(w.into.Rel(), "/"))
+	return err
+}
+
+// notebook returns the section for in, creating its notebooks as needed.
+// Backends without notebooks get every note in the target notebook.
+func (w *writer) notebook(in Note) (fs.Section, error) {
+	if len(in.Notebook) == 0 {
+		return w.into, nil
+	}
+	if _, ok := w.store.(storage.Notebooks); !ok {
+		path := strings.Join(in.Notebook, "/")
+		if !w.flat[path] {
+			if w.flat == nil {
+				w.flat = make(map[string]bool)
+			}
+			w.flat[path] = true
+			w.problem(path, "notebooks are not supported by this storage backend; its notes were imported into "+string(w.into))
+		}
+		return w.into, nil
+	}
+
+	names := make([]string, len(in.Notebook))
+	for i, name := range in.Notebook {
+		names[i] = notebookName(name)
+	}
+	return w.mkdirs(w.into, names)
+}
+
+// mkdirs creates the notebooks names below parent that do not exist yet
+// and returns the innermost.
+func (w *writer) mkdirs(parent fs.Section, names []string) (fs.Section, error) {
+	nbs := w.store.(storage.Notebooks)
+	sec := parent
+	for _, name := range names {
+		child := fs.Notebook(sec.Rel() + "/" + name)
+		if !w.sections[child] {
+			if _, err := nbs.CreateNotebook(sec, name); err != nil {
+				return "", err
+			}
+			w.sections[child] = true
+		}
+		sec = child
+	}
+	return sec, nil
+}
+
+// notebookName turns a notebook name from another app into a valid one.
+func notebookName(name string) string {
+	name = strings.NewReplacer("/", "-", `\`, "-").Replace(name)
+	name = strings.TrimLeft(strings.TrimSpace(name), ".")
+	if name = strings.TrimSpace(name); name == "" {
+		return "Untitled"
+	}
+	return name
+}
+
+// body returns the body in is saved with: its links pointed at the
+// created notes, its title as a heading unless it already starts with it,
+// and its tags, creation time and pin in the front matter.
+func (w *writer) body(in Note, ids map[string]fs.Note) string {
+	body := resolveLinks(in.Body, ids)
+
+	meta, content := fs.ParseFrontMatter(body)
+	block := ""
+	if err := fs.ValidateFrontMatter(body); err != nil {
+		w.problem(in.name(), "front matter is not valid YAML and was kept as text")
+	} else {
+		_, rest, _ := fs.SplitFrontMatter(body)
+		block = strings.TrimSuffix(body, rest)
+	}
+
+	if in.Title != "" && meta.Title == "" && !strings.EqualFold(fs.TitleFromBody(content), in.Title) {
+		content = "# " + in.Title + "\n\n" + strings.TrimLeft(content, "\n")
+	}
+
+	changed := false
+	for _, t := range in.Tags {
+		name := tagName(t)
+		if name == "" {
+			w.problem(in.name(), fmt.Sprintf("tag %q has no usable characters and was dropped", t))
+			continue
+		}
+		if !slices.ContainsFunc(meta.Tags, func(have string) bool { return tags.Normalize(have) == name }) {
+			meta.Tags = append(meta.Tags, name)
+			changed = true
+		}
+	}
+	if meta.Created.IsZero() && !in.Created.IsZero() {
+		meta.Created = in.Created.Local()
+		changed = true
+	}
+	if in.Pinned && !meta.Pinned {
+		meta.Pinned = true
+		changed = true
+	}
+	if changed {
+		block = fs.FormatFrontMatter(meta)
+	}
+	return block + content
+}
+
+// tagName turns a tag from another app into a tenote tag: lower case,
+// with spaces as dashes and characters tags cannot have dropped.
+func tagName(t string) string {
+	t = strings.Join(strings.Fields(t), "-")
+	t = strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("_-/", r) {
+			return r
+		}
+		return -1
+	}, t)
+	return tags.Normalize(t)
+}
+
+// resolveLinks points the [[key|text]] links in body at the notes created
+// for their keys. Links to anything else are left alone.
+func resolveLinks(body string, ids map[string]fs.Note) string {
+	var b strings.Builder
+	last := 0
+	for _, l := range links.Parse(body) {
+		n, ok := ids[l.Target]
+		if !ok {
+			continue
+		}
+		text := l.Alias
+		if text == "" {
+			text = l.Target
+		}
+		b.WriteString(body[last:l.Start])
+		b.WriteString("[[" + n.ID + "|" + text + "]]")
+		last = l.End
+	}
+	if last == 0 {
+		return body
+	}
+	b.WriteString(body[last:])
+	return b.String()
+}
+
+func (w *writer) problem(item, reason string) {
+	w.report.Problems = append(w.report.Problems, Problem{Item: item, Reason: reason})
+}
+
+// ---------------------------------------------------------------------------
+// helpers shared by the readers
+// ---------------------------------------------------------------------------
+
+// wikiLink writes a link to the note with key for Write to resolve.
+func wikiLink(key, text string) string {
+	text = strings.NewReplacer("[", "", "]", "", "|", "/").Replace(text)
+	if text == "" {
+		return "[[" + key + "]]"
+	}
+	return "[[" + key + "|" + text + "]]"
+}
+
+// mdLink matches a Markdown link or image: [text](dest "title").
+var mdLink = regexp.MustCompile(`(!?)\[([^\]]*)\]\(\s*(<[^>]*>|[^)\s]*)(?:\s+"[^"]*")?\s*\)`)
+
+// rewriteLinks calls fn for every Markdown link and image in body outside
+// code blocks with its text and destination, and replaces it with what fn
+// returns when ok.
+func rewriteLinks(body string, fn func(image bool, text, dest string) (string, bool)) string {
+	return mapLines(body, func(line string) string {
+		return mdLink.ReplaceAllStringFunc(line, func(m string) string {
+			sub := mdLink.FindStringSubmatch(m)
+			dest := strings.TrimSuffix(strings.TrimPrefix(sub[3], "<"), ">")
+			if out, ok := fn(sub[1] == "!", sub[2], dest); ok {
+				return out
+			}
+			return m
+		})
+	})
+}
+
+// mapLines replaces every line of body outside fenced code blocks with
+// what fn returns for it.
+func mapLines(body string, fn func(line string) string) string {
+	lines := strings.Split(body, "\n")
+	fence := ""
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case fence != "":
+			if strings.HasPrefix(trimmed, fence) {
+				fence = ""
+			}
+		case strings.HasPrefix(trimmed, "```"):
+			fence = "```"
+		case strings.HasPrefix(trimmed, "~~~"):
+			fence = "~~~"
+		default:
+			lines[i] = fn(line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// parseTime parses the RFC 3339 times most exports use, returning the zero
+// time for empty values and for the Unix epoch some use as "never".
+func parseTime(s string) time.Time {
+	t, err := time.Parse(time.RFC3339, strings.TrimSpace(s))
+	if err != nil || t.Unix() <= 0 {
+		return time.Time{}
+	}
+	return t
+}
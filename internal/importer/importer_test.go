@@ -0,0 +1,80 @@
+package importer
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/internet-kid/tenote/internal/storage/fs"
+	"github.com/internet-kid/tenote/internal/storage/memory"
+)
+
+var errFull = errors.New("disk full")
+
+// failingStore fails to write a body containing fail.
+type failingStore struct {
+	*memory.Store
+	fail string
+}
+
+func (s failingStore) WriteBody(path, body string) error {
+	if strings.Contains(body, s.fail) {
+		return errFull
+	}
+	return s.Store.WriteBody(path, body)
+}
+
+func TestWrite(t *testing.T) {
+	mem := memory.NewStore()
+	src := Source{Notes: []Note{
+		{Key: "a", Title: "Alpha", Body: "see [[b|Beta]]\n", Tags: []string{"Work Stuff"}},
+		{Key: "b", Title: "Beta", Body: "# Beta\nback to [[a|Alpha]]\n", Trashed: true},
+	}}
+	report, err := Write(mem, src, fs.SectionNotes)
+	if err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if len(report.Notes) != 2 || len(report.Problems) != 0 {
+		t.Fatalf("report = %+v", report)
+	}
+	alpha, beta := report.Notes[0], report.Notes[1]
+	if beta.Section != fs.SectionTrash {
+		t.Fatalf("trashed note imported into %s", beta.Section)
+	}
+	body, _ := mem.ReadBody(alpha.Path)
+	want := "---\ntags:\n    - work-stuff\n---\n# Alpha\n\nsee [[" + beta.ID + "|Beta]]\n"
+	if body != want {
+		t.Fatalf("body = %q, want %q", body, want)
+	}
+}
+
+func TestWriteFailureLeavesNoPlaceholders(t *testing.T) {
+	mem := memory.NewStore()
+	store := failingStore{Store: mem, fail: "boom"}
+	src := Source{Notes: []Note{
+		{Key: "a", Title: "First", Body: "fine\n"},
+		{Key: "b", Title: "Second", Body: "boom\n"},
+		{Key: "c", Title: "Third", Body: "never written\n"},
+	}}
+
+	report, err := Write(store, src, fs.SectionNotes)
+	if !errors.Is(err, errFull) {
+		t.Fatalf("Write = %v, want the write error", err)
+	}
+	if len(report.Notes) != 1 || report.Notes[0].Title != "First" {
+		t.Fatalf("report lists %+v, want only the first note", report.Notes)
+	}
+	for _, sec := range []fs.Section{fs.SectionNotes, fs.SectionTrash} {
+		notes, err := mem.List(sec)
+		if err != nil {
+			t.Fatalf("List: %v", err)
+		}
+		want := 0
+		if sec == fs.SectionNotes {
+			want = 1
+		}
+		if len(notes) != want {
+			t.Fatalf("%s holds %+v, want %d notes", sec, notes, want)
+		}
+	}
+}
@@ -0,0 +1,249 @@
+package importer
+
+import (
+	"archive/tar"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Joplin item types, from the type_ property.
+const (
+	joplinNote     = "1"
+	joplinFolder   = "2"
+	joplinResource = "4"
+	joplinTag      = "5"
+	joplinNoteTag  = "6"
+)
+
+// joplinHTML is the markup_language of notes written in HTML.
+const joplinHTML = "2"
+
+// joplinItem is one file of a Joplin export: a title line, a body, and
+// properties as "key: value" lines at the end.
+type joplinItem struct {
+	title string
+	body  string
+	props map[string]string
+}
+
+// Joplin reads a Joplin export, either a RAW export directory or a .jex
+// archive. Folders become notebooks and deleted notes go to the trash.
+// HTML notes are converted to Markdown. Attachments are not imported.
+func Joplin(path string) (Source, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return Source{}, fmt.Errorf("open joplin export: %w", err)
+	}
+	var items []joplinItem
+	if info.IsDir() {
+		items, err = joplinDir(path)
+	} else {
+		items, err = joplinJEX(path)
+	}
+	if err != nil {
+		return Source{}, err
+	}
+	return joplinSource(items), nil
+}
+
+// joplinDir reads the items of a RAW export. Attachments are in a
+// resources directory next to them.
+func joplinDir(dir string) ([]joplinItem, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("read joplin export: %w", err)
+	}
+	var items []joplinItem
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != ".md" {
+			continue
+		}
+		b, err := os.ReadFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("read joplin item: %w", err)
+		}
+		items = append(items, parseJoplinItem(string(b)))
+	}
+	return items, nil
+}
+
+// joplinJEX reads the items of a .jex archive, a tar file holding a RAW
+// export.
+func joplinJEX(file string) ([]joplinItem, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("open jex file: %w", err)
+	}
+	defer f.Close()
+
+	var items []joplinItem
+	tr := tar.NewReader(f)
+	for {
+		h, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return items, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read jex file %q: %w", file, err)
+		}
+		name := strings.TrimPrefix(path.Clean(h.Name), "./")
+		if h.Typeflag != tar.TypeReg || strings.Contains(name, "/") || path.Ext(name) != ".md" {
+			continue
+		}
+		b, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf("read jex file %q: %w", file, err)
+		}
+		items = append(items, parseJoplinItem(string(b)))
+	}
+}
+
+// parseJoplinItem splits an item into its title, body and properties.
+// The properties are the lines after the last blank line; items without a
+// title, like note tags, are properties only.
+func parseJoplinItem(s string) joplinItem {
+	lines := strings.Split(strings.TrimRight(strings.ReplaceAll(s, "\r\n", "\n"), "\n"), "\n")
+	i := len(lines)
+	for i > 0 && lines[i-1] != "" {
+		i--
+	}
+
+	item := joplinItem{props: make(map[string]string)}
+	for _, l := range lines[i:] {
+		if k, v, ok := strings.Cut(l, ":"); ok {
+			item.props[k] = strings.TrimSpace(v)
+		}
+	}
+	if head := lines[:max(i-1, 0)]; len(head) > 0 {
+		item.title = head[0]
+		if len(head) > 2 {
+			item.body = strings.Join(head[2:], "\n") + "\n"
+		}
+	}
+	return item
+}
+
+// joplinLink matches the destination of a link to a Joplin item.
+var joplinLink = regexp.MustCompile(`^:/([0-9a-fA-F]{32})(#.*)?$`)
+
+func joplinSource(items []joplinItem) Source {
+	byID := make(map[string]joplinItem)
+	tagsOf := make(map[string][]string)
+	for _, it := range items {
+		byID[it.props["id"]] = it
+	}
+	for _, it := range items {
+		if it.props["type_"] != joplinNoteTag {
+			continue
+		}
+		if tag, ok := byID[it.props["tag_id"]]; ok && tag.props["type_"] == joplinTag {
+			id := it.props["note_id"]
+			tagsOf[id] = append(tagsOf[id], tag.title)
+		}
+	}
+
+	var src Source
+	var notes []joplinItem
+	for _, it := range items {
+		if it.props["type_"] == joplinNote {
+			notes = append(notes, it)
+		}
+	}
+	// Exports are in no particular order; import oldest first.
+	sort.SliceStable(notes, func(i, j int) bool {
+		return joplinTime(notes[i], "created_time").Before(joplinTime(notes[j], "created_time"))
+	})
+
+	for _, it := range notes {
+		id := it.props["id"]
+		n := Note{
+			Key:      id,
+			Title:    strings.TrimSpace(it.title),
+			Notebook: joplinFolders(byID, it.props["parent_id"]),
+			Tags:     tagsOf[id],
+			Created:  joplinTime(it, "created_time"),
+			Updated:  joplinTime(it, "updated_time"),
+			Trashed:  !parseTime(it.props["deleted_time"]).IsZero(),
+		}
+		if n.Title == "" {
+			n.Title = "Untitled"
+		}
+		problem := func(reason string) {
+			src.Problems = append(src.Problems, Problem{Item: n.Title, Reason: reason})
+		}
+
+		body := it.body
+		if it.props["markup_language"] == joplinHTML {
+			var problems []string
+			body, problems = htmlToMarkdown(body, func(href, text string) (string, bool) {
+				return joplinResolve(byID, href, text, problem)
+			})
+			for _, reason := range problems {
+				problem(reason)
+			}
+		}
+		n.Body = rewriteLinks(body, func(image bool, text, dest string) (string, bool) {
+			return joplinResolve(byID, dest, text, problem)
+		})
+		src.Notes = append(src.Notes, n)
+	}
+	return src
+}
+
+// joplinResolve turns a link to another note into one Write resolves.
+// Links to attachments are reported and kept.
+func joplinResolve(byID map[string]joplinItem, dest, text string, problem func(string)) (string, bool) {
+	m := joplinLink.FindStringSubmatch(dest)
+	if m == nil {
+		return "", false
+	}
+	id := strings.ToLower(m[1])
+	target, ok := byID[id]
+	switch {
+	case !ok:
+		problem(fmt.Sprintf("link to %s, which is not in the export, kept as it was", dest))
+		return "", false
+	case target.props["type_"] == joplinResource:
+		problem(fmt.Sprintf("attachment %q not imported", target.title))
+		return "", false
+	case target.props["type_"] != joplinNote:
+		return "", false
+	}
+	if m[2] != "" {
+		problem(fmt.Sprintf("link to a heading of %q now points at the whole note", target.title))
+	}
+	return wikiLink(id, text), true
+}
+
+// joplinFolders returns the path of folder names from the outermost to
+// the folder with id.
+func joplinFolders(byID map[string]joplinItem, id string) []string {
+	var names []string
+	for seen := map[string]bool{}; id != "" && !seen[id]; {
+		seen[id] = true
+		folder, ok := byID[id]
+		if !ok || folder.props["type_"] != joplinFolder {
+			break
+		}
+		names = append([]string{folder.title}, names...)
+		id = folder.props["parent_id"]
+	}
+	return names
+}
+
+// joplinTime returns the time the user sees for a note, which imports and
+// syncs keep, falling back to the one Joplin recorded itself.
+func joplinTime(it joplinItem, key string) time.Time {
+	if t := parseTime(it.props["user_"+key]); !t.IsZero() {
+		return t
+	}
+	return parseTime(it.props[key])
+}
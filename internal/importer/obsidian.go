@@ -0,0 +1,197 @@
+package importer
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/internet-kid/tenote/internal/storage/links"
+)
+
+// obsidianTrash is the folder Obsidian moves deleted files to when set to
+// use the vault's own trash.
+const obsidianTrash = ".trash"
+
+// Obsidian reads an Obsidian vault. Every Markdown file below dir is a
+// note: its folder becomes its notebook, its file name its title and its
+// modification time when it was last changed. Notes in the vault's .trash
+// folder go to the trash. Attachments and Obsidian's settings are not
+// imported.
+func Obsidian(dir string) (Source, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return Source{}, fmt.Errorf("open vault: %w", err)
+	}
+	if !info.IsDir() {
+		return Source{}, fmt.Errorf("open vault: %s is not a directory", dir)
+	}
+
+	var src Source
+	vault := obsidianVault{byPath: make(map[string]string), byName: make(map[string][]string)}
+	var files []string
+	err = filepath.WalkDir(dir, func(p string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil || rel == "." {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		switch {
+		case d.IsDir():
+			if strings.HasPrefix(d.Name(), ".") && rel != obsidianTrash {
+				return filepath.SkipDir
+			}
+		case strings.HasPrefix(d.Name(), "."):
+		case !strings.EqualFold(path.Ext(rel), ".md"):
+			src.Problems = append(src.Problems, Problem{Item: rel, Reason: "attachment not imported"})
+		default:
+			files = append(files, rel)
+			vault.add(strings.TrimSuffix(rel, path.Ext(rel)))
+		}
+		return nil
+	})
+	if err != nil {
+		return Source{}, fmt.Errorf("read vault: %w", err)
+	}
+
+	for _, rel := range files {
+		p := filepath.Join(dir, filepath.FromSlash(rel))
+		b, err := os.ReadFile(p)
+		if err != nil {
+			return Source{}, fmt.Errorf("read note %q: %w", rel, err)
+		}
+		info, err := os.Stat(p)
+		if err != nil {
+			return Source{}, fmt.Errorf("stat note %q: %w", rel, err)
+		}
+
+		key := strings.TrimSuffix(rel, path.Ext(rel))
+		folder := path.Dir(key)
+		n := Note{
+			Key:     key,
+			Title:   path.Base(key),
+			Updated: info.ModTime(),
+		}
+		if folder == obsidianTrash || strings.HasPrefix(folder, obsidianTrash+"/") {
+			n.Trashed = true
+			folder = strings.TrimPrefix(strings.TrimPrefix(folder, obsidianTrash), "/")
+		}
+		if folder != "." && folder != "" {
+			n.Notebook = strings.Split(folder, "/")
+		}
+
+		body, problems := vault.convert(string(b), key)
+		n.Body = body
+		for _, reason := range problems {
+			src.Problems = append(src.Problems, Problem{Item: rel, Reason: reason})
+		}
+		src.Notes = append(src.Notes, n)
+	}
+	return src, nil
+}
+
+// obsidianVault resolves links the way Obsidian does: by path from the
+// vault root, by path from the linking note, or by file name alone.
+type obsidianVault struct {
+	byPath map[string]string   // lower-cased key to key
+	byName map[string][]string // lower-cased file name to keys
+}
+
+func (v obsidianVault) add(key string) {
+	v.byPath[strings.ToLower(key)] = key
+	name := strings.ToLower(path.Base(key))
+	v.byName[name] = append(v.byName[name], key)
+}
+
+// resolve returns the key of the note target names, as seen from the
+// note with key from. Of several notes with the name, the one closest to
+// the vault root wins.
+func (v obsidianVault) resolve(target, from string) (string, bool) {
+	target = strings.TrimSpace(target)
+	if strings.EqualFold(path.Ext(target), ".md") {
+		target = strings.TrimSuffix(target, path.Ext(target))
+	}
+	if target == "" {
+		return "", false
+	}
+	for _, p := range []string{path.Join(path.Dir(from), target), strings.TrimPrefix(path.Clean(target), "/")} {
+		if key, ok := v.byPath[strings.ToLower(p)]; ok {
+			return key, true
+		}
+	}
+	keys := v.byName[strings.ToLower(target)]
+	if len(keys) == 0 {
+		return "", false
+	}
+	return slices.MinFunc(keys, func(a, b string) int {
+		return strings.Count(a, "/") - strings.Count(b, "/")
+	}), true
+}
+
+// convert rewrites the links in the body of the note with key into links
+// Write can resolve, returning what it could not carry over.
+func (v obsidianVault) convert(body, key string) (string, []string) {
+	var problems []string
+
+	var b strings.Builder
+	last := 0
+	for _, l := range links.Parse(body) {
+		name, anchor := l.Target, ""
+		if i := strings.IndexAny(name, "#^"); i >= 0 {
+			name, anchor = name[:i], name[i:]
+		}
+		target, ok := v.resolve(name, key)
+		if !ok {
+			// A link to a note not written yet, or an embedded attachment.
+			continue
+		}
+		start := l.Start
+		if start > 0 && body[start-1] == '!' {
+			start--
+			problems = append(problems, fmt.Sprintf("embedded note %q became a link", name))
+		}
+		if anchor != "" {
+			problems = append(problems, fmt.Sprintf("link to %q now points at the whole note", l.Target))
+		}
+		text := l.Alias
+		if text == "" {
+			text = l.Target
+		}
+		b.WriteString(body[last:start])
+		b.WriteString(wikiLink(target, text))
+		last = l.End
+	}
+	if last > 0 {
+		b.WriteString(body[last:])
+		body = b.String()
+	}
+
+	body = rewriteLinks(body, func(image bool, text, dest string) (string, bool) {
+		if image || strings.Contains(dest, ":") {
+			return "", false
+		}
+		dest, anchor, _ := strings.Cut(dest, "#")
+		if unescaped, err := url.PathUnescape(dest); err == nil {
+			dest = unescaped
+		}
+		if !strings.EqualFold(path.Ext(dest), ".md") {
+			return "", false
+		}
+		target, ok := v.resolve(dest, key)
+		if !ok {
+			problems = append(problems, fmt.Sprintf("link to missing note %q kept as it was", dest))
+			return "", false
+		}
+		if anchor != "" {
+			problems = append(problems, fmt.Sprintf("link to %q now points at the whole note", dest+"#"+anchor))
+		}
+		return wikiLink(target, text), true
+	})
+	return body, problems
+}
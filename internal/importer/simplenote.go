@@ -0,0 +1,119 @@
+package importer
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// simplenoteJSON is the file in a Simplenote export zip that holds the
+// notes; the rest are the same notes as text files.
+const simplenoteJSON = "notes.json"
+
+type simplenoteExport struct {
+	ActiveNotes  []simplenoteNote `json:"activeNotes"`
+	TrashedNotes []simplenoteNote `json:"trashedNotes"`
+}
+
+type simplenoteNote struct {
+	ID           string   `json:"id"`
+	Content      string   `json:"content"`
+	CreationDate string   `json:"creationDate"`
+	LastModified string   `json:"lastModified"`
+	Tags         []string `json:"tags"`
+	Pinned       bool     `json:"pinned"`
+	SystemTags   []string `json:"systemTags"` // older exports: "pinned", "markdown"
+}
+
+// simplenoteLink matches the destination of a link to another note.
+var simplenoteLink = regexp.MustCompile(`^simplenote://note/([0-9A-Za-z_-]+)$`)
+
+// Simplenote reads a Simplenote export, either its notes.json or the zip
+// file holding it. The first line of a note is its title, as in tenote.
+func Simplenote(file string) (Source, error) {
+	b, err := simplenoteRead(file)
+	if err != nil {
+		return Source{}, err
+	}
+	var export simplenoteExport
+	if err := json.Unmarshal(b, &export); err != nil {
+		return Source{}, fmt.Errorf("parse simplenote export %q: %w", file, err)
+	}
+
+	ids := make(map[string]bool)
+	for _, n := range append(slices.Clone(export.ActiveNotes), export.TrashedNotes...) {
+		ids[n.ID] = true
+	}
+
+	var src Source
+	add := func(sn simplenoteNote, trashed bool) {
+		n := Note{
+			Key:     sn.ID,
+			Body:    strings.ReplaceAll(sn.Content, "\r\n", "\n"),
+			Tags:    sn.Tags,
+			Created: parseTime(sn.CreationDate),
+			Updated: parseTime(sn.LastModified),
+			Pinned:  sn.Pinned || slices.Contains(sn.SystemTags, "pinned"),
+			Trashed: trashed,
+		}
+		n.Body = rewriteLinks(n.Body, func(image bool, text, dest string) (string, bool) {
+			m := simplenoteLink.FindStringSubmatch(dest)
+			if m == nil {
+				return "", false
+			}
+			if !ids[m[1]] {
+				src.Problems = append(src.Problems, Problem{Item: n.name(), Reason: fmt.Sprintf("link to %s, which is not in the export, kept as it was", dest)})
+				return "", false
+			}
+			return wikiLink(m[1], text), true
+		})
+		if !strings.HasSuffix(n.Body, "\n") {
+			n.Body += "\n"
+		}
+		src.Notes = append(src.Notes, n)
+	}
+	for _, sn := range export.ActiveNotes {
+		add(sn, false)
+	}
+	for _, sn := range export.TrashedNotes {
+		add(sn, true)
+	}
+	return src, nil
+}
+
+// simplenoteRead returns the notes.json of an export, reading it from the
+// zip file when given one.
+func simplenoteRead(file string) ([]byte, error) {
+	zr, err := zip.OpenReader(file)
+	if err != nil {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("open simplenote export: %w", err)
+		}
+		return b, nil
+	}
+	defer zr.Close()
+
+	for _, f := range zr.File {
+		if path.Base(f.Name) != simplenoteJSON {
+			continue
+		}
+		r, err := f.Open()
+		if err != nil {
+			return nil, fmt.Errorf("read simplenote export %q: %w", file, err)
+		}
+		defer r.Close()
+		b, err := io.ReadAll(r)
+		if err != nil {
+			return nil, fmt.Errorf("read simplenote export %q: %w", file, err)
+		}
+		return b, nil
+	}
+	return nil, fmt.Errorf("simplenote export %q has no %s", file, simplenoteJSON)
+}
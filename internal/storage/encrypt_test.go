@@ -0,0 +1,159 @@
+package storage
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/internet-kid/tenote/internal/config"
+	"github.com/internet-kid/tenote/internal/storage/fs"
+	"github.com/internet-kid/tenote/internal/storage/vault"
+)
+
+const passphrase = "correct horse"
+
+func TestEncryptDecrypt(t *testing.T) {
+	cfg := config.AppConfig{StorageDir: t.TempDir()}
+	store, err := Open(cfg)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	kept := writeNote(t, store, "# Kept\nsecret plans\n")
+	trashed := writeNote(t, store, "# Trashed\nsecret past\n")
+	if trashed, err = store.MoveToTrash(trashed); err != nil {
+		t.Fatalf("MoveToTrash: %v", err)
+	}
+	paths, _ := config.ResolvePathsFrom(cfg.StorageDir)
+	if err := os.WriteFile(filepath.Join(paths.Meta, indexFile), []byte("secret"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := Encrypt(cfg, passphrase)
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+	if n < 2 {
+		t.Fatalf("Encrypt rewrote %d files, want the notes and their revisions", n)
+	}
+	assertNoPlaintext(t, cfg.StorageDir, "secret")
+	if _, err := Open(cfg); !errors.Is(err, vault.ErrLocked) {
+		t.Fatalf("Open without the key = %v, want ErrLocked", err)
+	}
+	if _, err := Unlock(cfg, "battery staple"); !errors.Is(err, vault.ErrPassphrase) {
+		t.Fatalf("Unlock with a wrong passphrase = %v, want ErrPassphrase", err)
+	}
+
+	key, err := Unlock(cfg, passphrase)
+	if err != nil {
+		t.Fatalf("Unlock: %v", err)
+	}
+	defer key.Wipe()
+	sealed, err := OpenWithKey(cfg, key)
+	if err != nil {
+		t.Fatalf("OpenWithKey: %v", err)
+	}
+	assertBody(t, sealed, kept.Path, "# Kept\nsecret plans\n")
+	assertBody(t, sealed, trashed.Path, "# Trashed\nsecret past\n")
+	revs, err := sealed.(Historian).Revisions(kept)
+	if err != nil || len(revs) == 0 {
+		t.Fatalf("Revisions = %v, %v", revs, err)
+	}
+
+	if _, err := Decrypt(cfg, key); err != nil {
+		t.Fatalf("Decrypt: %v", err)
+	}
+	if ok, _ := Encrypted(cfg); ok {
+		t.Fatal("store is still encrypted after Decrypt")
+	}
+	plain, err := Open(cfg)
+	if err != nil {
+		t.Fatalf("Open after Decrypt: %v", err)
+	}
+	assertBody(t, plain, kept.Path, "# Kept\nsecret plans\n")
+	raw, _ := os.ReadFile(kept.Path)
+	if string(raw) != "# Kept\nsecret plans\n" {
+		t.Fatalf("note file after Decrypt = %q", raw)
+	}
+}
+
+func TestEncryptResumes(t *testing.T) {
+	cfg := config.AppConfig{StorageDir: t.TempDir()}
+	store, err := Open(cfg)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	first := writeNote(t, store, "# First\nsecret one\n")
+	if _, err := Encrypt(cfg, passphrase); err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+
+	// A note left in plain text, as by an interrupted run, is sealed by
+	// running it again; the notes already sealed stay readable.
+	paths, _ := config.ResolvePathsFrom(cfg.StorageDir)
+	late := filepath.Join(paths.Notes, "late.md")
+	if err := os.WriteFile(late, []byte("# Late\nsecret two\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Encrypt(cfg, "battery staple"); !errors.Is(err, vault.ErrPassphrase) {
+		t.Fatalf("Encrypt with another passphrase = %v, want ErrPassphrase", err)
+	}
+	if _, err := Encrypt(cfg, passphrase); err != nil {
+		t.Fatalf("Encrypt again: %v", err)
+	}
+	assertNoPlaintext(t, cfg.StorageDir, "secret")
+
+	key, err := Unlock(cfg, passphrase)
+	if err != nil {
+		t.Fatalf("Unlock: %v", err)
+	}
+	defer key.Wipe()
+	sealed, err := OpenWithKey(cfg, key)
+	if err != nil {
+		t.Fatalf("OpenWithKey: %v", err)
+	}
+	assertBody(t, sealed, first.Path, "# First\nsecret one\n")
+	assertBody(t, sealed, late, "# Late\nsecret two\n")
+}
+
+func writeNote(t *testing.T, store NoteStore, body string) fs.Note {
+	t.Helper()
+	n, err := store.Create(fs.SectionNotes)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if err := store.WriteBody(n.Path, body); err != nil {
+		t.Fatalf("WriteBody: %v", err)
+	}
+	return n
+}
+
+func assertBody(t *testing.T, store NoteStore, path, want string) {
+	t.Helper()
+	got, err := store.ReadBody(path)
+	if err != nil || got != want {
+		t.Fatalf("ReadBody(%s) = %q, %v; want %q", filepath.Base(path), got, err, want)
+	}
+}
+
+// assertNoPlaintext fails if any file under root contains text.
+func assertNoPlaintext(t *testing.T, root, text string) {
+	t.Helper()
+	err := filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if strings.Contains(string(data), text) {
+			t.Errorf("%s is in plain text", strings.TrimPrefix(path, root))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,186 @@
+package fs
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+const (
+	// Temporary files are named ".<note>.<random>.tmp" next to the note,
+	// hidden so that listing and watching skip them.
+	tempSuffix = ".tmp"
+
+	// recoverAge is how old a temporary file must be before Recover takes
+	// it for a leftover of a crash rather than another process's write in
+	// progress.
+	recoverAge = time.Minute
+)
+
+// FS is the part of the filesystem note writes go through. The store uses
+// the real one; tests substitute one that fails on demand to check that an
+// interrupted write never leaves a truncated note behind.
+type FS interface {
+	CreateTemp(dir, pattern string) (File, error)
+	Rename(oldpath, newpath string) error
+	Remove(name string) error
+	// SyncDir makes renames in dir durable. It returns nil where
+	// directories cannot be synced.
+	SyncDir(dir string) error
+}
+
+// File is a temporary file being written.
+type File interface {
+	io.Writer
+	Name() string
+	Chmod(mode os.FileMode) error
+	Sync() error
+	Close() error
+}
+
+// WithFS makes the store write notes through fsys.
+func WithFS(fsys FS) Option {
+	return func(s *Store) { s.fsys = fsys }
+}
+
+type osFS struct{}
+
+func (osFS) CreateTemp(dir, pattern string) (File, error) { return os.CreateTemp(dir, pattern) }
+func (osFS) Rename(oldpath, newpath string) error         { return os.Rename(oldpath, newpath) }
+func (osFS) Remove(name string) error                     { return os.Remove(name) }
+
+func (osFS) SyncDir(dir string) error {
+	// Windows cannot open a directory for syncing; renames there are
+	// durable once they return.
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+// writeAtomic replaces path with data so that a crash or a full disk at
+// any point leaves either the old or the new content, never a mix: data
+// goes to a temporary file in the same directory, which is synced and
+// then renamed over path.
+func (s *Store) writeAtomic(path string, data []byte, perm os.FileMode) (err error) {
+	dir, name := filepath.Split(path)
+	f, err := s.fsys.CreateTemp(dir, "."+name+".*"+tempSuffix)
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	defer func() {
+		if err != nil {
+			_ = s.fsys.Remove(tmp)
+		}
+	}()
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Chmod(perm); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := s.fsys.Rename(tmp, path); err != nil {
+		return err
+	}
+	if err := s.fsys.SyncDir(dir); err != nil {
+		return fmt.Errorf("sync directory %q: %w", dir, err)
+	}
+	return nil
+}
+
+// Recover deals with temporary files that writes interrupted by a crash
+// left in the note directories. One identical to its note is removed. Any
+// other may hold the only copy of an edit, so it is moved to the recovered
+// directory under the meta directory; Recover returns the new paths.
+// Files still encrypted stay encrypted.
+func (s *Store) Recover() ([]string, error) {
+	var kept []string
+	for _, root := range []string{s.paths.Notes, s.paths.Journal, s.paths.Trash} {
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				if errors.Is(err, fs.ErrNotExist) {
+					return nil
+				}
+				return err
+			}
+			target := tempTarget(d.Name())
+			if d.IsDir() || target == "" {
+				return nil
+			}
+			info, err := d.Info()
+			if err != nil || time.Since(info.ModTime()) < recoverAge {
+				return err
+			}
+
+			tmp, tmpErr := s.readFile(path)
+			cur, curErr := s.readFile(filepath.Join(filepath.Dir(path), target))
+			if tmpErr == nil && curErr == nil && bytes.Equal(tmp, cur) {
+				if err := os.Remove(path); err != nil {
+					return fmt.Errorf("remove leftover %q: %w", path, err)
+				}
+				return nil
+			}
+
+			dst, err := s.keepLeftover(path, target, info.ModTime())
+			if err != nil {
+				return err
+			}
+			kept = append(kept, dst)
+			return nil
+		})
+		if err != nil {
+			return kept, err
+		}
+	}
+	return kept, nil
+}
+
+// tempTarget returns the note a temporary file was written for, or "" for
+// files that are not temporary note files.
+func tempTarget(name string) string {
+	if !strings.HasSuffix(name, tempSuffix) {
+		return ""
+	}
+	name = strings.TrimPrefix(name, ".")
+	i := strings.Index(name, noteExt+".")
+	if i < 0 {
+		return ""
+	}
+	return name[:i+len(noteExt)]
+}
+
+func (s *Store) keepLeftover(path, target string, mod time.Time) (string, error) {
+	dir := filepath.Join(s.paths.Meta, "recovered")
+	if err := os.MkdirAll(dir, dirPerm); err != nil {
+		return "", fmt.Errorf("create recovered dir: %w", err)
+	}
+	id := strings.TrimSuffix(target, noteExt)
+	dst := filepath.Join(dir, id+"-"+mod.Format("20060102T150405")+noteExt)
+	if err := os.Rename(path, dst); err != nil {
+		return "", fmt.Errorf("keep leftover %q: %w", path, err)
+	}
+	return dst, nil
+}
@@ -0,0 +1,232 @@
+package fs
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/internet-kid/tenote/internal/config"
+)
+
+var errInjected = errors.New("injected failure")
+
+// failingFS is the real filesystem, except that the step named fail fails
+// once armed.
+type failingFS struct {
+	osFS
+	fail  string
+	armed bool
+}
+
+func (f *failingFS) fails(step string) bool { return f.armed && f.fail == step }
+
+func (f *failingFS) CreateTemp(dir, pattern string) (File, error) {
+	if f.fails("create") {
+		return nil, errInjected
+	}
+	file, err := f.osFS.CreateTemp(dir, pattern)
+	if err != nil {
+		return nil, err
+	}
+	return &failingFile{File: file, fs: f}, nil
+}
+
+func (f *failingFS) Rename(oldpath, newpath string) error {
+	if f.fails("rename") {
+		return errInjected
+	}
+	return f.osFS.Rename(oldpath, newpath)
+}
+
+type failingFile struct {
+	File
+	fs *failingFS
+}
+
+func (f *failingFile) Write(p []byte) (int, error) {
+	if f.fs.fails("write") {
+		// A full disk takes part of the data.
+		n, _ := f.File.Write(p[:len(p)/2])
+		return n, errInjected
+	}
+	return f.File.Write(p)
+}
+
+func (f *failingFile) Sync() error {
+	if f.fs.fails("sync") {
+		return errInjected
+	}
+	return f.File.Sync()
+}
+
+func newTestStore(t *testing.T, opts ...Option) (*Store, config.Paths) {
+	t.Helper()
+	paths, err := config.ResolvePathsFrom(t.TempDir())
+	if err != nil {
+		t.Fatalf("ResolvePathsFrom: %v", err)
+	}
+	return NewStore(paths, opts...), paths
+}
+
+// tempFiles returns the temporary files left in dir.
+func tempFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out []string
+	for _, e := range entries {
+		if strings.HasSuffix(e.Name(), tempSuffix) {
+			out = append(out, e.Name())
+		}
+	}
+	return out
+}
+
+func TestWriteAtomicFailure(t *testing.T) {
+	for _, step := range []string{"create", "write", "sync", "rename"} {
+		t.Run(step, func(t *testing.T) {
+			fsys := &failingFS{fail: step}
+			s, paths := newTestStore(t, WithFS(fsys))
+			n, err := s.Create(SectionNotes)
+			if err != nil {
+				t.Fatalf("Create: %v", err)
+			}
+			if err := s.WriteBody(n.Path, "# Old\nkept\n"); err != nil {
+				t.Fatalf("WriteBody: %v", err)
+			}
+
+			fsys.armed = true
+			if err := s.WriteBody(n.Path, "# New\n"+strings.Repeat("lost\n", 1000)); !errors.Is(err, errInjected) {
+				t.Fatalf("WriteBody = %v, want the injected failure", err)
+			}
+			fsys.armed = false
+
+			if body, err := s.ReadBody(n.Path); err != nil || body != "# Old\nkept\n" {
+				t.Fatalf("note after a failed write = %q, %v; want the old content", body, err)
+			}
+			if left := tempFiles(t, paths.Notes); len(left) > 0 {
+				t.Fatalf("failed write left %v", left)
+			}
+			revs, err := s.Revisions(n)
+			if err != nil {
+				t.Fatalf("Revisions: %v", err)
+			}
+			for _, r := range revs {
+				if body, _ := s.ReadRevision(n, r.Hash); strings.Contains(body, "lost") {
+					t.Fatalf("history holds the body of a failed write")
+				}
+			}
+		})
+	}
+}
+
+func TestRecover(t *testing.T) {
+	s, paths := newTestStore(t)
+	n, err := s.Create(SectionNotes)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if err := s.WriteBody(n.Path, "# Note\n"); err != nil {
+		t.Fatalf("WriteBody: %v", err)
+	}
+	name := filepath.Base(n.Path)
+	old := time.Now().Add(-2 * recoverAge)
+	seed := func(tmp, body string, mod time.Time) string {
+		t.Helper()
+		path := filepath.Join(paths.Notes, tmp)
+		if err := os.WriteFile(path, []byte(body), filePerm); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, mod, mod); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	same := seed("."+name+".111"+tempSuffix, "# Note\n", old)
+	edit := seed("."+name+".222"+tempSuffix, "# Note\nunsaved edit\n", old)
+	fresh := seed("."+name+".333"+tempSuffix, "# Note\nbeing written\n", time.Now())
+	other := seed("notes.txt"+tempSuffix, "not a note", old)
+
+	kept, err := s.Recover()
+	if err != nil {
+		t.Fatalf("Recover: %v", err)
+	}
+	if len(kept) != 1 {
+		t.Fatalf("Recover kept %v, want the differing file only", kept)
+	}
+	if b, err := os.ReadFile(kept[0]); err != nil || string(b) != "# Note\nunsaved edit\n" {
+		t.Fatalf("kept file = %q, %v", b, err)
+	}
+	if !strings.HasPrefix(kept[0], filepath.Join(paths.Meta, "recovered")) {
+		t.Fatalf("kept %s outside the recovered directory", kept[0])
+	}
+
+	for path, want := range map[string]bool{same: false, edit: false, fresh: true, other: true} {
+		_, err := os.Stat(path)
+		if exists := err == nil; exists != want {
+			t.Fatalf("%s exists = %v, want %v", filepath.Base(path), exists, want)
+		}
+	}
+	if body, _ := s.ReadBody(n.Path); body != "# Note\n" {
+		t.Fatalf("Recover changed the note to %q", body)
+	}
+	if notes, _ := s.List(SectionNotes); !slices.ContainsFunc(notes, func(m Note) bool { return m.ID == n.ID }) || len(notes) != 1 {
+		t.Fatalf("List after Recover = %+v", notes)
+	}
+}
+
+// hookFS is the real filesystem, except that the first rename once armed
+// calls hook before it happens.
+type hookFS struct {
+	osFS
+	hook func()
+}
+
+func (f *hookFS) Rename(oldpath, newpath string) error {
+	if hook := f.hook; hook != nil {
+		f.hook = nil
+		hook()
+	}
+	return f.osFS.Rename(oldpath, newpath)
+}
+
+func TestWriteBodyWaitsForWriteBodyIf(t *testing.T) {
+	fsys := &hookFS{}
+	s, _ := newTestStore(t, WithFS(fsys))
+	n, err := s.Create(SectionNotes)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	_, base, err := s.ReadVersion(n.Path)
+	if err != nil {
+		t.Fatalf("ReadVersion: %v", err)
+	}
+
+	// An unconditional save starts after WriteBodyIf checked the version
+	// but before it wrote the note.
+	done := make(chan error, 1)
+	fsys.hook = func() {
+		go func() { done <- s.WriteBody(n.Path, "# Theirs\n") }()
+		select {
+		case err := <-done:
+			t.Errorf("WriteBody finished inside WriteBodyIf: %v", err)
+			done <- err
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+	if err := s.WriteBodyIf(n.Path, "# Mine\n", base); err != nil {
+		t.Fatalf("WriteBodyIf: %v", err)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("WriteBody: %v", err)
+	}
+	if body, _ := s.ReadBody(n.Path); body != "# Theirs\n" {
+		t.Fatalf("note = %q, want the later save", body)
+	}
+}
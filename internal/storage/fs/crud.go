@@ -2,20 +2,25 @@ package fs
 
 import (
 	"bufio"
+	"bytes"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
-	"sort"
 	"strings"
+	"time"
 
 	"github.com/oklog/ulid/v2"
 )
 
 func (s *Store) Create(section Section) (Note, error) {
 	id := ulid.Make().String()
-	path := s.notePath(section, id)
+	path, err := s.notePath(section, id)
+	if err != nil {
+		return Note{}, err
+	}
 
-	if err := os.WriteFile(path, []byte(noteTemplate), filePerm); err != nil {
+	if err := s.writeFile(path, []byte(noteTemplate)); err != nil {
 		return Note{}, fmt.Errorf("create note %q: %w", path, err)
 	}
 
@@ -23,24 +28,38 @@ func (s *Store) Create(section Section) (Note, error) {
 	if err != nil {
 		return Note{}, fmt.Errorf("stat new note %q: %w", path, err)
 	}
+	s.list.put(path, newListEntry(info, Meta{}, noteTemplate))
 
 	return Note{
 		ID:        id,
-		Title:     defaultNoteName,
+		Title:     DefaultNoteTitle,
 		Path:      path,
 		Section:   section,
 		UpdatedAt: info.ModTime(),
+		Size:      info.Size(),
 	}, nil
 }
 
 func (s *Store) List(section Section) ([]Note, error) {
-	dir := s.dirFor(section)
+	dir, err := s.dirFor(section)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.list.isSynced(dir) {
+		notes := s.listCached(dir, section)
+		s.applyPins(notes)
+		SortNotes(notes, SortUpdated)
+		return notes, nil
+	}
 
 	entries, err := os.ReadDir(dir)
 	if err != nil {
 		return nil, fmt.Errorf("read section dir %q: %w", dir, err)
 	}
 
+	cached := s.list.entries(dir)
+	fresh := make(map[string]listEntry)
 	var notes []Note
 	for _, e := range entries {
 		if e.IsDir() {
@@ -58,70 +77,201 @@ func (s *Store) List(section Section) ([]Note, error) {
 			return nil, fmt.Errorf("read file info %q: %w", path, err)
 		}
 
-		title, err := readFirstNonEmptyLine(path)
-		if err != nil {
-			return nil, err
-		}
-		if title == "" {
-			title = defaultNoteName
-		}
-
 		id := strings.TrimSuffix(name, noteExt)
+		entry, ok := cached[name]
+		delete(cached, name)
+		if !ok || !entry.fresh(info) {
+			meta, content, err := s.readHeader(path)
+			if err != nil {
+				return nil, err
+			}
+			entry = newListEntry(info, meta, content)
+			if section == SectionTrash {
+				if info, err := s.readTrashInfo(id); err == nil {
+					entry.Origin, entry.DeletedAt = info.Origin, info.DeletedAt
+				}
+			}
+			fresh[name] = entry
+		}
 
-		notes = append(notes, Note{
+		n := Note{
 			ID:        id,
-			Title:     title,
 			Path:      path,
 			Section:   section,
 			UpdatedAt: info.ModTime(),
-		})
+			Size:      info.Size(),
+		}
+		entry.apply(&n)
+		notes = append(notes, n)
 	}
 
-	sort.Slice(notes, func(i, j int) bool {
-		return notes[i].UpdatedAt.After(notes[j].UpdatedAt)
-	})
+	// What is left in cached belongs to files that are gone.
+	gone := make([]string, 0, len(cached))
+	for name := range cached {
+		gone = append(gone, name)
+	}
+	s.list.update(dir, fresh, gone)
+	s.list.reconciled(dir)
 
+	s.applyPins(notes)
+	SortNotes(notes, SortUpdated)
 	return notes, nil
 }
 
+// listCached lists the notes of dir from the list cache alone.
+func (s *Store) listCached(dir string, section Section) []Note {
+	entries := s.list.entries(dir)
+	notes := make([]Note, 0, len(entries))
+	for name, e := range entries {
+		n := Note{
+			ID:        strings.TrimSuffix(name, noteExt),
+			Path:      filepath.Join(dir, name),
+			Section:   section,
+			UpdatedAt: e.ModTime,
+			Size:      e.Size,
+		}
+		e.apply(&n)
+		notes = append(notes, n)
+	}
+	return notes
+}
+
 func (s *Store) ReadBody(path string) (string, error) {
-	b, err := os.ReadFile(path)
+	b, err := s.readFile(path)
 	if err != nil {
 		return "", fmt.Errorf("read note %q: %w", path, err)
 	}
 	return string(b), nil
 }
 
+// WriteBody saves body to the note at path. The new body is recorded in
+// the note's history only once it is written, so history never holds a
+// save that did not happen.
 func (s *Store) WriteBody(path, body string) error {
-	if err := os.WriteFile(path, []byte(body), filePerm); err != nil {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.writeBody(path, body)
+}
+
+// writeBody is WriteBody for callers that hold s.mu.
+func (s *Store) writeBody(path, body string) error {
+	if err := s.recordCurrent(path); err != nil {
+		return fmt.Errorf("record revision: %w", err)
+	}
+	if err := s.writeFile(path, []byte(body)); err != nil {
 		return fmt.Errorf("write note %q: %w", path, err)
 	}
+	s.remember(path, []byte(body))
+	if err := s.history.Record(noteID(path), body); err != nil {
+		return fmt.Errorf("record revision: %w", err)
+	}
 	return nil
 }
 
-func readFirstNonEmptyLine(path string) (string, error) {
+// remember records the list entry of the note just written to path with
+// body, so the next List does not have to read it back.
+func (s *Store) remember(path string, body []byte) {
+	info, err := os.Stat(path)
+	if err != nil {
+		s.list.forget(path)
+		return
+	}
+	meta, content, err := scanHeader(bytes.NewReader(body), path)
+	if err != nil {
+		s.list.forget(path)
+		return
+	}
+	s.list.put(path, newListEntry(info, meta, content))
+}
+
+// SetUpdatedAt sets the modification time of the note at path, which is
+// what List reports as its UpdatedAt.
+func (s *Store) SetUpdatedAt(path string, t time.Time) error {
+	if err := os.Chtimes(path, t, t); err != nil {
+		return fmt.Errorf("set time of note %q: %w", path, err)
+	}
+	s.list.touch(path)
+	return nil
+}
+
+// TitleFromBody derives a note title from its first non-empty line, the same
+// way List does for notes on disk.
+func TitleFromBody(body string) string {
+	for _, line := range strings.Split(body, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if title := titleFromLine(line); title != "" {
+			return title
+		}
+		break
+	}
+	return DefaultNoteTitle
+}
+
+// titleFromLine strips the markdown heading prefix from a trimmed line.
+func titleFromLine(line string) string {
+	if strings.HasPrefix(line, "#") {
+		line = strings.TrimSpace(strings.TrimLeft(line, "#"))
+	}
+	return line
+}
+
+// readHeader reads just enough of a note to build its list entry: the front
+// matter block, if any, and the first non-empty line after it. Encrypted
+// notes have to be read whole.
+func (s *Store) readHeader(path string) (Meta, string, error) {
+	if s.cipher != nil {
+		b, err := s.readFile(path)
+		if err != nil {
+			return Meta{}, "", fmt.Errorf("read note %q: %w", path, err)
+		}
+		return scanHeader(bytes.NewReader(b), path)
+	}
+
 	f, err := os.Open(path)
 	if err != nil {
-		return "", fmt.Errorf("open note %q: %w", path, err)
+		return Meta{}, "", fmt.Errorf("open note %q: %w", path, err)
 	}
 	defer f.Close()
+	return scanHeader(f, path)
+}
+
+func scanHeader(r io.Reader, path string) (Meta, string, error) {
+	var head strings.Builder
+	inBlock := false
+	lineNo := 0
 
-	sc := bufio.NewScanner(f)
+	sc := bufio.NewScanner(r)
 	for sc.Scan() {
-		line := strings.TrimSpace(sc.Text())
-		if line == "" {
+		raw := sc.Text()
+		lineNo++
+		head.WriteString(raw)
+		head.WriteString("\n")
+
+		line := strings.TrimSpace(raw)
+		switch {
+		case lineNo == 1 && line == frontMatterDelim:
+			inBlock = true
+			continue
+		case inBlock:
+			if line == frontMatterDelim {
+				inBlock = false
+			}
+			continue
+		case line == "":
 			continue
 		}
-		// strip markdown heading prefix
-		if strings.HasPrefix(line, "#") {
-			line = strings.TrimSpace(strings.TrimLeft(line, "#"))
-		}
-		return line, nil
+
+		meta, content := ParseFrontMatter(head.String())
+		return meta, content, nil
 	}
 
 	if err := sc.Err(); err != nil {
-		return "", fmt.Errorf("scan note %q: %w", path, err)
+		return Meta{}, "", fmt.Errorf("scan note %q: %w", path, err)
 	}
 
-	return "", nil
+	meta, content := ParseFrontMatter(head.String())
+	return meta, content, nil
 }
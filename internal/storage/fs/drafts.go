@@ -0,0 +1,158 @@
+package fs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const draftExt = ".json"
+
+// Draft is the unsaved text of a note being edited. Editors save one now
+// and then and delete it once the edit is saved or given up, so a draft
+// found on startup is an edit a crash interrupted.
+type Draft struct {
+	Note  Note // the edited note; Path is empty when it no longer exists
+	Body  string
+	Saved time.Time
+}
+
+type draftFile struct {
+	ID      string    `json:"id"`
+	Path    string    `json:"path"`
+	Section Section   `json:"section"`
+	Title   string    `json:"title"`
+	Body    string    `json:"body"`
+	Saved   time.Time `json:"saved"`
+}
+
+func (s *Store) draftDir() string { return filepath.Join(s.paths.Meta, "drafts") }
+
+func (s *Store) draftPath(id string) string {
+	return filepath.Join(s.draftDir(), id+draftExt)
+}
+
+// SaveDraft records body as the unsaved text of n, replacing its previous
+// draft. Drafts are encrypted like notes.
+func (s *Store) SaveDraft(n Note, body string) error {
+	if err := os.MkdirAll(s.draftDir(), dirPerm); err != nil {
+		return fmt.Errorf("create drafts dir: %w", err)
+	}
+	b, err := json.Marshal(draftFile{
+		ID:      n.ID,
+		Path:    n.Path,
+		Section: n.Section,
+		Title:   n.Title,
+		Body:    body,
+		Saved:   time.Now(),
+	})
+	if err != nil {
+		return fmt.Errorf("marshal draft: %w", err)
+	}
+	if err := s.writeFile(s.draftPath(n.ID), b); err != nil {
+		return fmt.Errorf("write draft of %q: %w", n.Path, err)
+	}
+	return nil
+}
+
+// DeleteDraft removes the draft of n, if there is one.
+func (s *Store) DeleteDraft(n Note) error {
+	err := os.Remove(s.draftPath(n.ID))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("delete draft of %q: %w", n.Path, err)
+	}
+	return nil
+}
+
+// Drafts returns the drafts on disk, oldest first. Their notes are looked
+// up again, since they may have been moved or trashed in the meantime.
+func (s *Store) Drafts() ([]Draft, error) {
+	entries, err := os.ReadDir(s.draftDir())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read drafts dir: %w", err)
+	}
+
+	var drafts []Draft
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), draftExt) {
+			continue
+		}
+		path := filepath.Join(s.draftDir(), e.Name())
+		b, err := s.readFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("read draft %q: %w", path, err)
+		}
+		var f draftFile
+		if err := json.Unmarshal(b, &f); err != nil {
+			return nil, fmt.Errorf("parse draft %q: %w", path, err)
+		}
+
+		n, ok := s.findNote(f.ID, f.Section)
+		if !ok {
+			n = Note{ID: f.ID, Title: f.Title, Section: f.Section, UpdatedAt: f.Saved}
+		}
+		drafts = append(drafts, Draft{Note: n, Body: f.Body, Saved: f.Saved})
+	}
+	sort.Slice(drafts, func(i, j int) bool {
+		return drafts[i].Saved.Before(drafts[j].Saved)
+	})
+	return drafts, nil
+}
+
+// findNote looks for the note with id in section first, then everywhere.
+func (s *Store) findNote(id string, section Section) (Note, bool) {
+	sections, err := s.Sections()
+	if err != nil {
+		return Note{}, false
+	}
+	for _, sec := range append([]Section{section}, sections...) {
+		if _, err := s.dirFor(sec); err != nil {
+			continue
+		}
+		notes, err := s.List(sec)
+		if err != nil {
+			continue
+		}
+		for _, n := range notes {
+			if n.ID == id {
+				return n, true
+			}
+		}
+	}
+	return Note{}, false
+}
+
+// recryptDrafts is Recrypt for the drafts.
+func (s *Store) recryptDrafts(to Cipher) (int, error) {
+	entries, err := os.ReadDir(s.draftDir())
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("read drafts dir: %w", err)
+	}
+	n := 0
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), draftExt) {
+			continue
+		}
+		path := filepath.Join(s.draftDir(), e.Name())
+		plain, err := s.readFile(path)
+		if err != nil {
+			return n, fmt.Errorf("read draft %q: %w", path, err)
+		}
+		if err := s.writeSealed(to, path, plain); err != nil {
+			return n, fmt.Errorf("write draft %q: %w", path, err)
+		}
+		n++
+	}
+	return n, nil
+}
@@ -0,0 +1,243 @@
+package fs
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const frontMatterDelim = "---"
+
+// createdLayouts are the formats accepted for the created field when it is
+// written as a plain string rather than a YAML timestamp.
+var createdLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// Meta is the optional YAML front matter block at the top of a note.
+// Known keys are decoded into typed fields; everything else is kept in
+// Fields so it survives a round trip through FormatFrontMatter.
+type Meta struct {
+	Title   string
+	Tags    []string
+	Aliases []string
+	Created time.Time
+	Pinned  bool // set by hand or on import; see Store.SetPinned
+	Fields  map[string]any
+}
+
+// IsZero reports whether m carries no metadata at all.
+func (m Meta) IsZero() bool {
+	return m.Title == "" && len(m.Tags) == 0 && len(m.Aliases) == 0 &&
+		m.Created.IsZero() && !m.Pinned && len(m.Fields) == 0
+}
+
+// SplitFrontMatter separates a leading front matter block from the rest of
+// body. ok is false when body has no well-formed block, in which case rest
+// is body unchanged.
+func SplitFrontMatter(body string) (block, rest string, ok bool) {
+	first, after, found := strings.Cut(body, "\n")
+	if !found || strings.TrimRight(first, " \t\r") != frontMatterDelim {
+		return "", body, false
+	}
+
+	offset := 0
+	for offset <= len(after) {
+		line, next, more := strings.Cut(after[offset:], "\n")
+		if strings.TrimRight(line, " \t\r") == frontMatterDelim {
+			block = after[:offset]
+			if more {
+				rest = next
+			}
+			return block, rest, true
+		}
+		if !more {
+			break
+		}
+		offset += len(line) + 1
+	}
+
+	return "", body, false
+}
+
+// ParseFrontMatter decodes the front matter of body and returns it together
+// with the remaining content. A missing or malformed block yields a zero
+// Meta and the whole body, so a typo never hides a note.
+func ParseFrontMatter(body string) (Meta, string) {
+	block, rest, ok := SplitFrontMatter(body)
+	if !ok {
+		return Meta{}, body
+	}
+
+	var raw map[string]any
+	if err := yaml.Unmarshal([]byte(block), &raw); err != nil {
+		return Meta{}, body
+	}
+
+	var m Meta
+	for k, v := range raw {
+		switch strings.ToLower(k) {
+		case "title":
+			m.Title = strings.TrimSpace(toString(v))
+		case "tags":
+			m.Tags = toStrings(v)
+		case "aliases":
+			m.Aliases = toStrings(v)
+		case "created":
+			m.Created = toTime(v)
+		case "pinned":
+			m.Pinned, _ = v.(bool)
+		default:
+			if m.Fields == nil {
+				m.Fields = make(map[string]any)
+			}
+			m.Fields[k] = v
+		}
+	}
+
+	return m, rest
+}
+
+// ValidateFrontMatter reports a YAML error in body's front matter block.
+// Bodies without a block are always valid.
+func ValidateFrontMatter(body string) error {
+	block, _, ok := SplitFrontMatter(body)
+	if !ok {
+		return nil
+	}
+	var raw map[string]any
+	if err := yaml.Unmarshal([]byte(block), &raw); err != nil {
+		return fmt.Errorf("front matter: %w", err)
+	}
+	return nil
+}
+
+// FormatFrontMatter renders m as a front matter block including both
+// delimiters and a trailing newline. A zero Meta renders as "".
+func FormatFrontMatter(m Meta) string {
+	if m.IsZero() {
+		return ""
+	}
+
+	doc := &yaml.Node{Kind: yaml.MappingNode}
+	add := func(k string, v any) {
+		var val yaml.Node
+		if err := val.Encode(v); err != nil {
+			return
+		}
+		doc.Content = append(doc.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: k}, &val)
+	}
+
+	if m.Title != "" {
+		add("title", m.Title)
+	}
+	if len(m.Tags) > 0 {
+		add("tags", m.Tags)
+	}
+	if len(m.Aliases) > 0 {
+		add("aliases", m.Aliases)
+	}
+	if !m.Created.IsZero() {
+		add("created", m.Created.Format(time.RFC3339))
+	}
+	if m.Pinned {
+		add("pinned", true)
+	}
+
+	keys := make([]string, 0, len(m.Fields))
+	for k := range m.Fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		add(k, m.Fields[k])
+	}
+
+	out, err := yaml.Marshal(doc)
+	if err != nil {
+		return ""
+	}
+	return frontMatterDelim + "\n" + string(out) + frontMatterDelim + "\n"
+}
+
+// WithFrontMatter returns body with its front matter replaced by m. The
+// content below the block is left untouched.
+func WithFrontMatter(body string, m Meta) string {
+	_, rest, _ := SplitFrontMatter(body)
+	return FormatFrontMatter(m) + rest
+}
+
+// ApplyMeta copies metadata onto n. The title is taken from m when set and
+// from the first content line otherwise.
+func (n *Note) ApplyMeta(m Meta, content string) {
+	n.Title = m.Title
+	if n.Title == "" {
+		n.Title = TitleFromBody(content)
+	}
+	n.Tags = m.Tags
+	n.Aliases = m.Aliases
+	n.Created = m.Created
+	n.Pinned = m.Pinned
+	n.Fields = m.Fields
+}
+
+func toString(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	default:
+		out, err := yaml.Marshal(v)
+		if err != nil {
+			return ""
+		}
+		return strings.TrimSpace(string(out))
+	}
+}
+
+// toStrings accepts a YAML list or a comma separated string.
+func toStrings(v any) []string {
+	var parts []string
+	switch v := v.(type) {
+	case []any:
+		for _, item := range v {
+			parts = append(parts, toString(item))
+		}
+	case string:
+		parts = strings.Split(v, ",")
+	default:
+		parts = []string{toString(v)}
+	}
+
+	out := parts[:0]
+	for _, p := range parts {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
+
+func toTime(v any) time.Time {
+	switch v := v.(type) {
+	case time.Time:
+		return v
+	case string:
+		for _, layout := range createdLayouts {
+			if t, err := time.ParseInLocation(layout, strings.TrimSpace(v), time.Local); err == nil {
+				return t
+			}
+		}
+	}
+	return time.Time{}
+}
@@ -0,0 +1,177 @@
+package fs
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseFrontMatter(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		want     Meta
+		wantRest string
+	}{
+		{
+			name:     "no block",
+			body:     "# Groceries\n\n- milk\n",
+			wantRest: "# Groceries\n\n- milk\n",
+		},
+		{
+			name:     "only a heading rule",
+			body:     "---\n# Not front matter\n",
+			wantRest: "---\n# Not front matter\n",
+		},
+		{
+			name: "known keys",
+			body: "---\ntitle: Plan\ntags: [work, release]\naliases: roadmap, plan b\ncreated: 2024-03-01\n---\n# Heading\n",
+			want: Meta{
+				Title:   "Plan",
+				Tags:    []string{"work", "release"},
+				Aliases: []string{"roadmap", "plan b"},
+				Created: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+			},
+			wantRest: "# Heading\n",
+		},
+		{
+			name: "unknown keys",
+			body: "---\nTags: [a]\nstatus: draft\npriority: 2\n---\nbody\n",
+			want: Meta{
+				Tags:   []string{"a"},
+				Fields: map[string]any{"status": "draft", "priority": 2},
+			},
+			wantRest: "body\n",
+		},
+		{
+			name:     "malformed yaml",
+			body:     "---\ntags: [unclosed\n---\n# Kept\n",
+			wantRest: "---\ntags: [unclosed\n---\n# Kept\n",
+		},
+		{
+			name:     "empty block",
+			body:     "---\n---\n# Empty\n",
+			wantRest: "# Empty\n",
+		},
+		{
+			name:     "crlf delimiters",
+			body:     "---\r\ntitle: Windows\r\n---\r\nrest",
+			want:     Meta{Title: "Windows"},
+			wantRest: "rest",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, rest := ParseFrontMatter(tt.body)
+			if !got.Created.Equal(tt.want.Created) {
+				t.Fatalf("Created = %v, want %v", got.Created, tt.want.Created)
+			}
+			got.Created, tt.want.Created = time.Time{}, time.Time{}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("Meta = %+v, want %+v", got, tt.want)
+			}
+			if rest != tt.wantRest {
+				t.Fatalf("rest = %q, want %q", rest, tt.wantRest)
+			}
+		})
+	}
+}
+
+func TestValidateFrontMatter(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		wantErr bool
+	}{
+		{"no block", "# Title\n", false},
+		{"valid", "---\ntags: [a, b]\n---\n", false},
+		{"unclosed list", "---\ntags: [a\n---\n", true},
+		{"bad indentation", "---\ntitle: a\n  b: c\n---\n", true},
+		{"not a mapping", "---\n- a\n- b\n---\n", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateFrontMatter(tt.body)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ValidateFrontMatter = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestWithFrontMatterRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		edit func(*Meta)
+		want string
+	}{
+		{
+			name: "adds a block",
+			body: "# Groceries\n",
+			edit: func(m *Meta) { m.Tags = []string{"home"} },
+			want: "---\ntags:\n    - home\n---\n# Groceries\n",
+		},
+		{
+			name: "keeps unknown keys",
+			body: "---\nstatus: draft\ntags: [a]\n---\nbody\n",
+			edit: func(m *Meta) { m.Tags = append(m.Tags, "b") },
+			want: "---\ntags:\n    - a\n    - b\nstatus: draft\n---\nbody\n",
+		},
+		{
+			name: "drops an emptied block",
+			body: "---\ntags: [a]\n---\nbody\n",
+			edit: func(m *Meta) { m.Tags = nil },
+			want: "body\n",
+		},
+		{
+			name: "leaves the content alone",
+			body: "---\ntitle: T\n---\n---\nnot: meta\n---\n",
+			edit: func(m *Meta) { m.Title = "U" },
+			want: "---\ntitle: U\n---\n---\nnot: meta\n---\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, _ := ParseFrontMatter(tt.body)
+			tt.edit(&m)
+			got := WithFrontMatter(tt.body, m)
+			if got != tt.want {
+				t.Fatalf("WithFrontMatter = %q, want %q", got, tt.want)
+			}
+
+			// What was written parses back to the same metadata.
+			back, _ := ParseFrontMatter(got)
+			if !reflect.DeepEqual(back, m) && !(back.IsZero() && m.IsZero()) {
+				t.Fatalf("round trip = %+v, want %+v", back, m)
+			}
+		})
+	}
+}
+
+func TestWithFrontMatterMalformed(t *testing.T) {
+	// A block that does not parse reads as no metadata; saving metadata
+	// replaces it rather than stacking a second block on top.
+	body := "---\ntags: [unclosed\n---\n# Kept\n"
+	m, _ := ParseFrontMatter(body)
+	m.Title = "New"
+	got := WithFrontMatter(body, m)
+	if !strings.HasPrefix(got, "---\ntitle: New\n---\n") || !strings.HasSuffix(got, "# Kept\n") {
+		t.Fatalf("WithFrontMatter = %q", got)
+	}
+	if strings.Count(got, "---\n") != 2 {
+		t.Fatalf("WithFrontMatter left the malformed block behind: %q", got)
+	}
+}
+
+func TestCreatedRoundTrip(t *testing.T) {
+	created := time.Date(2026, 10, 17, 8, 30, 0, 0, time.UTC)
+	body := WithFrontMatter("# Day\n", Meta{Created: created})
+	m, rest := ParseFrontMatter(body)
+	if !m.Created.Equal(created) || rest != "# Day\n" {
+		t.Fatalf("ParseFrontMatter(%q) = %+v, %q", body, m, rest)
+	}
+}
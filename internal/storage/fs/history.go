@@ -0,0 +1,39 @@
+package fs
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/internet-kid/tenote/internal/storage/history"
+)
+
+// recordCurrent snapshots the note at path before it is overwritten, so
+// edits made outside tenote, or before history existed, are not lost. A
+// note that does not exist yet has nothing to record.
+func (s *Store) recordCurrent(path string) error {
+	current, err := s.readFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read note %q: %w", path, err)
+	}
+	return s.history.Record(noteID(path), string(current))
+}
+
+// Revisions returns the recorded revisions of n, newest first.
+func (s *Store) Revisions(n Note) ([]history.Revision, error) {
+	return s.history.List(n.ID)
+}
+
+// ReadRevision returns the body of one revision of n.
+func (s *Store) ReadRevision(n Note, hash string) (string, error) {
+	return s.history.Read(n.ID, hash)
+}
+
+func noteID(path string) string {
+	return strings.TrimSuffix(filepath.Base(path), noteExt)
+}
@@ -0,0 +1,330 @@
+package fs
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// listCacheTimeout bounds how long a new store waits for another tenote
+// process holding the database. The cache is an optimization, so giving up
+// only costs reading the notes again.
+const listCacheTimeout = 100 * time.Millisecond
+
+// WithListCache keeps what List reads from each note in a database at
+// path, so listing a notebook after a restart does not have to open every
+// note again. Without it the cache lives only as long as the store. The
+// database stays open until the store is closed.
+func WithListCache(path string) Option {
+	return func(s *Store) { s.list.path = path }
+}
+
+// WithoutWatcher tells the store that nothing watches its notes and calls
+// Refresh, so every List checks the directory for notes added, changed or
+// removed outside tenote instead of answering from the cache alone.
+func WithoutWatcher() Option {
+	return func(s *Store) { s.list.unwatched = true }
+}
+
+// listCache remembers the list entry of each note file, so List can answer
+// without reading the notes, or even their directory.
+//
+// Entries are grouped by directory. A directory is loaded from the database
+// and reconciled against the files the first time it is listed: an entry
+// is kept while its file keeps the modification time and size it had when
+// it was read; any other change, including one made outside tenote, reads
+// the file again. After that the directory is listed from its entries,
+// which the store's own changes write through, until Refresh says that
+// files in it changed some other way; without a watcher to say so, it is
+// reconciled on every List. Failing to open the database is never
+// an error: the entries are then kept in memory only.
+type listCache struct {
+	path string   // the database; empty keeps entries in memory only
+	db   *bolt.DB // nil when the database could not be opened
+
+	// unwatched is set when no watcher calls refresh; see WithoutWatcher.
+	unwatched bool
+
+	mu     sync.Mutex
+	dirs   map[string]map[string]listEntry // by directory, then file name
+	synced map[string]bool                 // directories reconciled since the last refresh
+}
+
+// listEntry is what List shows of a note file.
+type listEntry struct {
+	ModTime time.Time `json:"mod_time"`
+	Size    int64     `json:"size"`
+	Title   string    `json:"title"`
+	Tags    []string  `json:"tags,omitempty"`
+	Aliases []string  `json:"aliases,omitempty"`
+	Created time.Time `json:"created,omitzero"`
+	Pinned  bool      `json:"pinned,omitempty"`
+	Fields  string    `json:"fields,omitempty"` // other front matter fields, as front matter
+
+	// The trash info of a trashed note.
+	Origin    Section   `json:"origin,omitempty"`
+	DeletedAt time.Time `json:"deleted_at,omitzero"`
+
+	fields map[string]any // Fields parsed, shared by the notes listed from e
+}
+
+func newListEntry(info os.FileInfo, m Meta, content string) listEntry {
+	var n Note
+	n.ApplyMeta(m, content)
+	e := listEntry{
+		ModTime: info.ModTime(),
+		Size:    info.Size(),
+		Title:   n.Title,
+		Tags:    m.Tags,
+		Aliases: m.Aliases,
+		Created: m.Created,
+		Pinned:  m.Pinned,
+	}
+	if len(m.Fields) > 0 {
+		e.Fields = FormatFrontMatter(Meta{Fields: m.Fields})
+		e.fields = m.Fields
+	}
+	return e
+}
+
+// fresh reports whether e still describes the file with info.
+func (e listEntry) fresh(info os.FileInfo) bool {
+	return e.ModTime.Equal(info.ModTime()) && e.Size == info.Size()
+}
+
+// apply fills in the parts of n that come from the note file's content.
+func (e listEntry) apply(n *Note) {
+	n.Title = e.Title
+	n.Tags = e.Tags
+	n.Aliases = e.Aliases
+	n.Created = e.Created
+	n.Pinned = e.Pinned
+	n.Fields = e.fields
+	n.Origin = e.Origin
+	n.DeletedAt = e.DeletedAt
+}
+
+// open opens the database, if the cache has one.
+func (c *listCache) open() {
+	if c.path == "" {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), dirPerm); err != nil {
+		return
+	}
+	db, err := bolt.Open(c.path, filePerm, &bolt.Options{Timeout: listCacheTimeout})
+	if err != nil {
+		return
+	}
+	c.db = db
+}
+
+// close closes the database. The entries stay in memory.
+func (c *listCache) close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.db == nil {
+		return nil
+	}
+	err := c.db.Close()
+	c.db = nil
+	return err
+}
+
+// isSynced reports whether dir was reconciled since it last changed
+// outside the store. Without a watcher that is never known.
+func (c *listCache) isSynced(dir string) bool {
+	if c.unwatched {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.synced[dir]
+}
+
+// reconciled records that the entries of dir match its files.
+func (c *listCache) reconciled(dir string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.synced == nil {
+		c.synced = make(map[string]bool)
+	}
+	c.synced[dir] = true
+}
+
+// refresh makes the next List of the directories of paths, or of every
+// directory when paths is empty, reconcile them again. A path may be a
+// note file or a directory.
+func (c *listCache) refresh(paths []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(paths) == 0 {
+		clear(c.synced)
+		return
+	}
+	for _, p := range paths {
+		p = filepath.Clean(p)
+		delete(c.synced, p)
+		delete(c.synced, filepath.Dir(p))
+	}
+}
+
+// entries returns the cached entries of dir, which the caller may keep.
+func (c *listCache) entries(dir string) map[string]listEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cached := c.load(dir)
+	out := make(map[string]listEntry, len(cached))
+	for name, e := range cached {
+		out[name] = e
+	}
+	return out
+}
+
+// update stores put and forgets drop in dir.
+func (c *listCache) update(dir string, put map[string]listEntry, drop []string) {
+	if len(put) == 0 && len(drop) == 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cached := c.load(dir)
+	for name, e := range put {
+		cached[name] = e
+	}
+	for _, name := range drop {
+		delete(cached, name)
+	}
+	c.write(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte(dir))
+		if err != nil {
+			return err
+		}
+		for name, e := range put {
+			v, err := json.Marshal(e)
+			if err != nil {
+				return err
+			}
+			if err := b.Put([]byte(name), v); err != nil {
+				return err
+			}
+		}
+		for _, name := range drop {
+			if err := b.Delete([]byte(name)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// put records the entry of the note file at path.
+func (c *listCache) put(path string, e listEntry) {
+	c.update(filepath.Dir(path), map[string]listEntry{filepath.Base(path): e}, nil)
+}
+
+// forget drops the entry of the note file at path.
+func (c *listCache) forget(path string) {
+	c.update(filepath.Dir(path), nil, []string{filepath.Base(path)})
+}
+
+// move carries the entry of a note file renamed from one path to another,
+// with the trash info origin and deletedAt. Renaming keeps the file's
+// modification time and size, so the entry stays valid.
+func (c *listCache) move(from, to string, origin Section, deletedAt time.Time) {
+	e, ok := c.entries(filepath.Dir(from))[filepath.Base(from)]
+	c.forget(from)
+	if ok {
+		e.Origin, e.DeletedAt = origin, deletedAt
+		c.put(to, e)
+	}
+}
+
+// stamp records the trash info of the trashed note file at path.
+func (c *listCache) stamp(path string, origin Section, deletedAt time.Time) {
+	e, ok := c.entries(filepath.Dir(path))[filepath.Base(path)]
+	if ok {
+		e.Origin, e.DeletedAt = origin, deletedAt
+		c.put(path, e)
+	}
+}
+
+// touch records a new modification time of the note file at path, which
+// otherwise did not change.
+func (c *listCache) touch(path string) {
+	e, ok := c.entries(filepath.Dir(path))[filepath.Base(path)]
+	if !ok {
+		return
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		c.forget(path)
+		return
+	}
+	e.ModTime = info.ModTime()
+	c.put(path, e)
+}
+
+// drop forgets everything about dir.
+func (c *listCache) drop(dir string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.dirs, dir)
+	delete(c.synced, dir)
+	c.write(func(tx *bolt.Tx) error {
+		if tx.Bucket([]byte(dir)) == nil {
+			return nil
+		}
+		return tx.DeleteBucket([]byte(dir))
+	})
+}
+
+// load returns the entries of dir, reading them from the database the
+// first time. c.mu must be held.
+func (c *listCache) load(dir string) map[string]listEntry {
+	if cached, ok := c.dirs[dir]; ok {
+		return cached
+	}
+	if c.dirs == nil {
+		c.dirs = make(map[string]map[string]listEntry)
+	}
+	cached := make(map[string]listEntry)
+	c.dirs[dir] = cached
+	if c.db == nil {
+		return cached
+	}
+	_ = c.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(dir))
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(k, v []byte) error {
+			var e listEntry
+			if json.Unmarshal(v, &e) != nil {
+				return nil
+			}
+			if e.Fields != "" {
+				m, _ := ParseFrontMatter(e.Fields)
+				e.fields = m.Fields
+			}
+			cached[string(k)] = e
+			return nil
+		})
+	})
+	return cached
+}
+
+// write runs fn in a read-write transaction on the database, if the store
+// has one. c.mu must be held. Failures leave the database behind the
+// in-memory entries, which the next process reconciles against the notes
+// when it lists them.
+func (c *listCache) write(fn func(tx *bolt.Tx) error) {
+	if c.db == nil {
+		return
+	}
+	_ = c.db.Update(fn)
+}
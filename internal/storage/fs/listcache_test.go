@@ -0,0 +1,130 @@
+package fs_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/internet-kid/tenote/internal/config"
+	"github.com/internet-kid/tenote/internal/storage"
+	"github.com/internet-kid/tenote/internal/storage/fs"
+	"github.com/internet-kid/tenote/internal/storage/storetest"
+)
+
+func TestConformanceListCache(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) storage.NoteStore {
+		paths := testPaths(t)
+		s := fs.NewStore(paths, fs.WithListCache(filepath.Join(paths.Meta, "list.db")))
+		t.Cleanup(func() { s.Close() })
+		return s
+	})
+}
+
+func TestListCacheRefresh(t *testing.T) {
+	s := fs.NewStore(testPaths(t))
+	n, err := s.Create(fs.SectionNotes)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if err := s.WriteBody(n.Path, "# Mine\n"); err != nil {
+		t.Fatalf("WriteBody: %v", err)
+	}
+	if _, err := s.List(fs.SectionNotes); err != nil {
+		t.Fatalf("List: %v", err)
+	}
+
+	// Another program changes the note and adds one.
+	if err := os.WriteFile(n.Path, []byte("# Theirs\nlonger than before\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	other := filepath.Join(filepath.Dir(n.Path), "01OTHER.md")
+	if err := os.WriteFile(other, []byte("# Other\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	notes, err := s.List(fs.SectionNotes)
+	if err != nil || len(notes) != 1 || notes[0].Title != "Mine" {
+		t.Fatalf("List before Refresh = %+v, %v; want what the store wrote", notes, err)
+	}
+
+	s.Refresh([]string{other})
+	notes, err = s.List(fs.SectionNotes)
+	if err != nil || len(notes) != 2 {
+		t.Fatalf("List after Refresh = %+v, %v", notes, err)
+	}
+	titles := map[string]bool{notes[0].Title: true, notes[1].Title: true}
+	if !titles["Theirs"] || !titles["Other"] {
+		t.Fatalf("List after Refresh = %+v", notes)
+	}
+}
+
+func TestListCacheReopen(t *testing.T) {
+	paths := testPaths(t)
+	db := filepath.Join(paths.Meta, "list.db")
+	s := fs.NewStore(paths, fs.WithListCache(db))
+	n, err := s.Create(fs.SectionJournal)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if err := s.WriteBody(n.Path, "# Entry\n"); err != nil {
+		t.Fatalf("WriteBody: %v", err)
+	}
+	trashed, err := s.MoveToTrash(n)
+	if err != nil {
+		t.Fatalf("MoveToTrash: %v", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	// The database is free for the next store, which lists the trash with
+	// where its notes came from.
+	s = fs.NewStore(paths, fs.WithListCache(db))
+	defer s.Close()
+	notes, err := s.List(fs.SectionTrash)
+	if err != nil || len(notes) != 1 {
+		t.Fatalf("List = %+v, %v", notes, err)
+	}
+	got := notes[0]
+	if got.Title != "Entry" || got.Origin != fs.SectionJournal || !got.DeletedAt.Equal(trashed.DeletedAt) {
+		t.Fatalf("trashed note = %+v, want title Entry from the journal deleted at %v", got, trashed.DeletedAt)
+	}
+}
+
+func TestListCacheWithoutWatcher(t *testing.T) {
+	// With watching turned off nothing calls Refresh.
+	cfg := config.AppConfig{StorageDir: t.TempDir(), WatchPoll: -1}
+	s, err := storage.Open(cfg)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer storage.Close(s)
+	if w, err := storage.Watch(cfg); w != nil || err != nil {
+		t.Fatalf("Watch = %v, %v; want no watcher", w, err)
+	}
+	n, err := s.Create(fs.SectionNotes)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if err := s.WriteBody(n.Path, "# Mine\n"); err != nil {
+		t.Fatalf("WriteBody: %v", err)
+	}
+	if _, err := s.List(fs.SectionNotes); err != nil {
+		t.Fatalf("List: %v", err)
+	}
+
+	other := filepath.Join(filepath.Dir(n.Path), "01OTHER.md")
+	if err := os.WriteFile(other, []byte("# Other\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	notes, err := s.List(fs.SectionNotes)
+	if err != nil || len(notes) != 2 {
+		t.Fatalf("List = %+v, %v; want the note added outside tenote too", notes, err)
+	}
+
+	if err := os.Remove(other); err != nil {
+		t.Fatal(err)
+	}
+	if notes, err = s.List(fs.SectionNotes); err != nil || len(notes) != 1 || notes[0].Title != "Mine" {
+		t.Fatalf("List = %+v, %v; want the removed note gone", notes, err)
+	}
+}
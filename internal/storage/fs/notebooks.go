@@ -0,0 +1,194 @@
+package fs
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Sections returns every section notes can be listed from: the root
+// notebook, each nested notebook in depth-first name order, then the
+// journal and the trash.
+func (s *Store) Sections() ([]Section, error) {
+	out := []Section{SectionNotes}
+
+	var walk func(dir string, parent Section) error
+	walk = func(dir string, parent Section) error {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return fmt.Errorf("read notebook dir %q: %w", dir, err)
+		}
+		for _, e := range entries {
+			if !e.IsDir() || ValidNotebookName(e.Name()) != nil {
+				continue
+			}
+			child := Notebook(parent.Rel() + "/" + e.Name())
+			out = append(out, child)
+			if err := walk(filepath.Join(dir, e.Name()), child); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := walk(s.paths.Notes, SectionNotes); err != nil {
+		return nil, err
+	}
+
+	return append(out, SectionJournal, SectionTrash), nil
+}
+
+// CreateNotebook creates a notebook called name inside parent.
+func (s *Store) CreateNotebook(parent Section, name string) (Section, error) {
+	name = strings.TrimSpace(name)
+	if err := ValidNotebookName(name); err != nil {
+		return "", err
+	}
+	if !parent.IsNotebook() {
+		return "", fmt.Errorf("notebooks can only be created inside notes, not %q", parent)
+	}
+
+	nb := Notebook(parent.Rel() + "/" + name)
+	dir, err := s.dirFor(nb)
+	if err != nil {
+		return "", err
+	}
+	if err := os.Mkdir(dir, dirPerm); err != nil {
+		return "", fmt.Errorf("create notebook %q: %w", nb.Rel(), err)
+	}
+	return nb, nil
+}
+
+// DeleteNotebook removes an empty notebook.
+func (s *Store) DeleteNotebook(nb Section) error {
+	if !nb.IsNotebook() || nb == SectionNotes {
+		return fmt.Errorf("%q is not a removable notebook", nb)
+	}
+	dir, err := s.dirFor(nb)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(dir); err != nil {
+		return fmt.Errorf("delete notebook %q (it must be empty): %w", nb.Rel(), err)
+	}
+	s.list.drop(dir)
+	return nil
+}
+
+// Move puts a note into another notebook.
+func (s *Store) Move(n Note, target Section) (Note, error) {
+	if !target.IsNotebook() {
+		return Note{}, fmt.Errorf("move target must be a notebook, got %q", target)
+	}
+	if n.Section == SectionTrash {
+		return Note{}, fmt.Errorf("restore note %s before moving it", n.ID)
+	}
+	if n.Section == target {
+		return n, nil
+	}
+
+	dst, err := s.notePath(target, n.ID)
+	if err != nil {
+		return Note{}, err
+	}
+	if err := moveFile(n.Path, dst); err != nil {
+		return Note{}, fmt.Errorf("move note %q to %q: %w", n.Path, target.Rel(), err)
+	}
+	s.list.move(n.Path, dst, "", time.Time{})
+	n.Path = dst
+	n.Section = target
+	n.UpdatedAt = time.Now()
+	return n, nil
+}
+
+// moveFile moves the note file from to to, failing with an error that
+// wraps os.ErrExist when a file is already there: notes in different
+// notebooks may have the same ID, and a rename would silently replace one
+// with the other. Where hard links are not supported it checks first.
+func moveFile(from, to string) error {
+	exists := fmt.Errorf("a note named %s is already there: %w", filepath.Base(to), os.ErrExist)
+	err := os.Link(from, to)
+	switch {
+	case err == nil:
+		if err := os.Remove(from); err != nil {
+			_ = os.Remove(to)
+			return err
+		}
+		return nil
+	case errors.Is(err, os.ErrExist):
+		return exists
+	}
+	if _, err := os.Lstat(to); err == nil {
+		return exists
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return os.Rename(from, to)
+}
+
+// ValidNotebookName rejects names that would escape the notes directory or
+// clash with tenote's own hidden directories. Backends without directories
+// apply the same rules so notebooks stay portable between them.
+func ValidNotebookName(name string) error {
+	switch {
+	case name == "":
+		return errors.New("notebook name is empty")
+	case name == "." || name == "..":
+		return fmt.Errorf("invalid notebook name %q", name)
+	case strings.HasPrefix(name, "."):
+		return fmt.Errorf("notebook name %q must not start with a dot", name)
+	case strings.ContainsAny(name, `/\`):
+		return fmt.Errorf("notebook name %q must not contain slashes", name)
+	}
+	return nil
+}
+
+// ValidSection checks that s is the trash, the journal or a notebook whose
+// every path element is a valid notebook name.
+func ValidSection(s Section) error {
+	switch {
+	case s == SectionTrash || s == SectionJournal || s == SectionNotes:
+		return nil
+	case !s.IsNotebook():
+		return fmt.Errorf("unknown section %q", s)
+	}
+	for _, part := range strings.Split(s.Rel(), "/") {
+		if err := ValidNotebookName(part); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SortSections orders sections the way Sections returns them: notebooks
+// depth-first by name, then the journal, then the trash.
+func SortSections(secs []Section) {
+	sort.SliceStable(secs, func(i, j int) bool {
+		a, b := secs[i], secs[j]
+		if ra, rb := sectionRank(a), sectionRank(b); ra != rb {
+			return ra < rb
+		}
+		pa, pb := strings.Split(string(a), "/"), strings.Split(string(b), "/")
+		for k := 0; k < len(pa) && k < len(pb); k++ {
+			if pa[k] != pb[k] {
+				return pa[k] < pb[k]
+			}
+		}
+		return len(pa) < len(pb)
+	})
+}
+
+// sectionRank groups sections for SortSections: notebooks, the journal, the
+// trash.
+func sectionRank(s Section) int {
+	switch s {
+	case SectionJournal:
+		return 1
+	case SectionTrash:
+		return 2
+	}
+	return 0
+}
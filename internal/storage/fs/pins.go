@@ -0,0 +1,122 @@
+package fs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+const pinsFile = "pins.json"
+
+// pinSet is the set of pinned note IDs, kept in a file under the meta
+// directory so pinning a note does not rewrite it. The file is read again
+// whenever it changes, so pins made by another tenote process show up.
+type pinSet struct {
+	mu      sync.Mutex
+	modTime time.Time
+	size    int64
+	ids     map[string]bool
+}
+
+func (s *Store) pinsPath() string { return filepath.Join(s.paths.Meta, pinsFile) }
+
+// load returns the pinned IDs, reading the file if it changed since it
+// was last read. p.mu must be held.
+func (p *pinSet) load(path string) (map[string]bool, error) {
+	info, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		p.ids, p.modTime, p.size = nil, time.Time{}, 0
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read pins %q: %w", path, err)
+	}
+	if p.ids != nil && info.ModTime().Equal(p.modTime) && info.Size() == p.size {
+		return p.ids, nil
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read pins %q: %w", path, err)
+	}
+	var list []string
+	if err := json.Unmarshal(b, &list); err != nil {
+		return nil, fmt.Errorf("parse pins %q: %w", path, err)
+	}
+	p.ids = make(map[string]bool, len(list))
+	for _, id := range list {
+		p.ids[id] = true
+	}
+	p.modTime, p.size = info.ModTime(), info.Size()
+	return p.ids, nil
+}
+
+// applyPins marks the pinned notes among notes. Pins that cannot be read
+// are left out rather than failing the list; SetPinned reports them.
+func (s *Store) applyPins(notes []Note) {
+	s.pins.mu.Lock()
+	defer s.pins.mu.Unlock()
+	ids, err := s.pins.load(s.pinsPath())
+	if err != nil || len(ids) == 0 {
+		return
+	}
+	for i := range notes {
+		if ids[notes[i].ID] {
+			notes[i].Pinned = true
+		}
+	}
+}
+
+// SetPinned pins n to the top of its notebook, or unpins it, and reports
+// whether that changed anything. Pins are kept apart from the notes, so
+// the note itself, its modification time and its history stay as they
+// are. A note pinned through the pinned key of its front matter stays
+// pinned until the key is removed; see storage.SetPinned.
+func (s *Store) SetPinned(n Note, pinned bool) (bool, error) {
+	s.pins.mu.Lock()
+	defer s.pins.mu.Unlock()
+
+	path := s.pinsPath()
+	ids, err := s.pins.load(path)
+	if err != nil {
+		return false, err
+	}
+	if ids[n.ID] == pinned {
+		return false, nil
+	}
+	list := make([]string, 0, len(ids)+1)
+	for id := range ids {
+		if id != n.ID {
+			list = append(list, id)
+		}
+	}
+	if pinned {
+		list = append(list, n.ID)
+	}
+	slices.Sort(list)
+
+	if err := os.MkdirAll(filepath.Dir(path), dirPerm); err != nil {
+		return false, fmt.Errorf("create meta dir: %w", err)
+	}
+	b, err := json.Marshal(list)
+	if err != nil {
+		return false, fmt.Errorf("marshal pins: %w", err)
+	}
+	if err := s.writeAtomic(path, b, filePerm); err != nil {
+		return false, fmt.Errorf("write pins %q: %w", path, err)
+	}
+	// Read back on the next use; the new file may share the old one's
+	// time and size.
+	s.pins.ids = nil
+	return true, nil
+}
+
+// unpin forgets the pin of a note deleted for good. It is best effort: a
+// stale pin names a note that no longer exists.
+func (s *Store) unpin(id string) {
+	_, _ = s.SetPinned(Note{ID: id}, false)
+}
@@ -0,0 +1,100 @@
+package fs
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+)
+
+// SortOrder is an order notes are listed in. Pinned notes come before the
+// others in every order.
+type SortOrder string
+
+const (
+	SortUpdated SortOrder = "updated" // most recently changed first
+	SortCreated SortOrder = "created" // most recently created first
+	SortTitle   SortOrder = "title"   // alphabetically by title
+	SortSize    SortOrder = "size"    // largest first
+)
+
+// SortOrders lists every order, in the order the UI cycles through them.
+var SortOrders = []SortOrder{SortUpdated, SortCreated, SortTitle, SortSize}
+
+// ParseSortOrder returns the order named s. An empty name is SortUpdated,
+// the order List returns notes in.
+func ParseSortOrder(s string) (SortOrder, error) {
+	if s == "" {
+		return SortUpdated, nil
+	}
+	for _, o := range SortOrders {
+		if string(o) == s {
+			return o, nil
+		}
+	}
+	return "", fmt.Errorf("unknown sort order %q", s)
+}
+
+// DefaultSortOrder is the order the notes of section are listed in unless
+// another is picked: the journal by day, everything else most recently
+// changed first.
+func DefaultSortOrder(section Section) SortOrder {
+	if section == SectionJournal {
+		return SortCreated
+	}
+	return SortUpdated
+}
+
+// Next returns the order after o in SortOrders, wrapping around.
+func (o SortOrder) Next() SortOrder {
+	for i, s := range SortOrders {
+		if s == o {
+			return SortOrders[(i+1)%len(SortOrders)]
+		}
+	}
+	return SortUpdated
+}
+
+// SortNotes sorts notes in order o, pinned notes first. Notes that tie are
+// listed most recently changed first.
+func SortNotes(notes []Note, o SortOrder) {
+	sort.SliceStable(notes, func(i, j int) bool {
+		a, b := notes[i], notes[j]
+		if a.Pinned != b.Pinned {
+			return a.Pinned
+		}
+		switch o {
+		case SortCreated:
+			if ca, cb := a.CreatedOrUpdated(), b.CreatedOrUpdated(); !ca.Equal(cb) {
+				return ca.After(cb)
+			}
+		case SortTitle:
+			if ta, tb := strings.ToLower(a.Title), strings.ToLower(b.Title); ta != tb {
+				return ta < tb
+			}
+		case SortSize:
+			if a.Size != b.Size {
+				return a.Size > b.Size
+			}
+		}
+		return a.UpdatedAt.After(b.UpdatedAt)
+	})
+}
+
+// CreatedOrUpdated returns when n was created according to its front
+// matter, or else the time in its ID. Notes whose ID is not a ULID, and
+// imported notes dated back to their last change before the import, go
+// by when they last changed.
+func (n Note) CreatedOrUpdated() time.Time {
+	if !n.Created.IsZero() {
+		return n.Created
+	}
+	if id, err := ulid.Parse(n.ID); err == nil {
+		if t := ulid.Time(id.Time()); t.Before(n.UpdatedAt) {
+			return t
+		}
+	}
+	return n.UpdatedAt
+}
@@ -0,0 +1,62 @@
+package fs_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+
+	"github.com/internet-kid/tenote/internal/storage/fs"
+)
+
+func TestSortCreated(t *testing.T) {
+	day := time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+	idAt := func(t time.Time) string { return ulid.MustNew(ulid.Timestamp(t), nil).String() }
+
+	older := fs.Note{ID: idAt(day), Title: "Older", UpdatedAt: day.Add(48 * time.Hour)}
+	newer := fs.Note{ID: idAt(day.Add(time.Hour)), Title: "Newer", UpdatedAt: day.Add(time.Hour)}
+	dated := fs.Note{ID: idAt(day.Add(2 * time.Hour)), Title: "Dated", Created: day.Add(-time.Hour), UpdatedAt: day.Add(2 * time.Hour)}
+	imported := fs.Note{ID: idAt(day.Add(3 * time.Hour)), Title: "Imported", UpdatedAt: day.Add(30 * time.Minute)}
+	synced := fs.Note{ID: "synced", Title: "Synced", UpdatedAt: day.Add(90 * time.Minute)}
+
+	notes := []fs.Note{older, dated, imported, synced, newer}
+	fs.SortNotes(notes, fs.SortCreated)
+	var got []string
+	for _, n := range notes {
+		got = append(got, n.Title)
+	}
+	// Editing Older does not move it ahead of Newer. Dated goes by its
+	// front matter; Imported, changed before its ID was made, and Synced,
+	// without a ULID, by their last change.
+	want := []string{"Synced", "Newer", "Imported", "Older", "Dated"}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("sorted by creation = %q, want %q", got, want)
+		}
+	}
+}
+
+func TestSortCreatedAfterEdit(t *testing.T) {
+	s := fs.NewStore(testPaths(t))
+	older, err := s.Create(fs.SectionNotes)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	time.Sleep(2 * time.Millisecond) // IDs carry milliseconds
+	newer, err := s.Create(fs.SectionNotes)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if err := s.WriteBody(older.Path, "# Older, edited\n"); err != nil {
+		t.Fatalf("WriteBody: %v", err)
+	}
+
+	notes, err := s.List(fs.SectionNotes)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	fs.SortNotes(notes, fs.SortCreated)
+	if len(notes) != 2 || notes[0].ID != newer.ID || notes[1].ID != older.ID {
+		t.Fatalf("sorted by creation = %+v, want %s before %s", notes, newer.ID, older.ID)
+	}
+}
@@ -1,35 +1,94 @@
 package fs
 
 import (
+	"fmt"
 	"path/filepath"
+	"sync"
 
 	"github.com/internet-kid/tenote/internal/config"
+	"github.com/internet-kid/tenote/internal/storage/history"
 )
 
 const (
-	filePerm        = 0o644
-	noteExt         = ".md"
-	noteTemplate    = "# \n\n"
-	defaultNoteName = "(untitled)"
+	filePerm     = 0o644
+	dirPerm      = 0o755
+	noteExt      = ".md"
+	noteTemplate = "# \n\n"
 )
 
+// DefaultNoteTitle is the title of a note whose body does not provide one.
+const DefaultNoteTitle = "(untitled)"
+
 type Store struct {
-	paths config.Paths
+	// mu serializes note writes, so WriteBodyIf's check and write are
+	// atomic within the process and no WriteBody can land in between.
+	mu sync.Mutex
+
+	paths   config.Paths
+	history *history.Store
+	cipher  Cipher
+	fsys    FS
+	list    *listCache
+	pins    pinSet
+
+	historyLimit int
+}
+
+// Option configures a Store.
+type Option func(*Store)
+
+// WithHistoryLimit sets how many revisions are kept per note. Zero keeps
+// the default; a negative value turns revision history off.
+func WithHistoryLimit(n int) Option {
+	return func(s *Store) { s.historyLimit = n }
+}
+
+func NewStore(paths config.Paths, opts ...Option) *Store {
+	s := &Store{paths: paths, fsys: osFS{}, list: &listCache{}}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.history = history.New(filepath.Join(paths.Meta, "history"), s.historyLimit, history.WithCipher(s.cipher))
+	s.list.open()
+	return s
 }
 
-func NewStore(paths config.Paths) *Store {
-	return &Store{paths: paths}
+// Close releases the list cache's database. The store stays usable, with
+// the cache in memory only.
+func (s *Store) Close() error {
+	if err := s.list.close(); err != nil {
+		return fmt.Errorf("close list cache %q: %w", s.list.path, err)
+	}
+	return nil
 }
 
-func (s *Store) dirFor(section Section) string {
+// Refresh tells the store that the note files or directories at paths
+// changed other than through it, by another program or a sync. List reads
+// their directories again, instead of answering from what it remembers.
+// No paths means any file may have changed.
+func (s *Store) Refresh(paths []string) {
+	s.list.refresh(paths)
+}
+
+// dirFor maps a section to its directory. Notebook paths are confined to
+// the notes directory.
+func (s *Store) dirFor(section Section) (string, error) {
+	if err := ValidSection(section); err != nil {
+		return "", err
+	}
 	switch section {
 	case SectionTrash:
-		return s.paths.Trash
-	default:
-		return s.paths.Notes
+		return s.paths.Trash, nil
+	case SectionJournal:
+		return s.paths.Journal, nil
 	}
+	return filepath.Join(s.paths.Notes, filepath.FromSlash(section.Rel())), nil
 }
 
-func (s *Store) notePath(section Section, id string) string {
-	return filepath.Join(s.dirFor(section), id+noteExt)
+func (s *Store) notePath(section Section, id string) (string, error) {
+	dir, err := s.dirFor(section)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, id+noteExt), nil
 }
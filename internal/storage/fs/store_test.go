@@ -0,0 +1,38 @@
+package fs_test
+
+import (
+	"testing"
+
+	"github.com/internet-kid/tenote/internal/config"
+	"github.com/internet-kid/tenote/internal/storage"
+	"github.com/internet-kid/tenote/internal/storage/fs"
+	"github.com/internet-kid/tenote/internal/storage/storetest"
+	"github.com/internet-kid/tenote/internal/storage/vault"
+)
+
+func TestConformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) storage.NoteStore {
+		return fs.NewStore(testPaths(t))
+	})
+}
+
+func TestConformanceEncrypted(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) storage.NoteStore {
+		paths := testPaths(t)
+		key, err := vault.Create(paths.Meta, "correct horse")
+		if err != nil {
+			t.Fatalf("vault.Create: %v", err)
+		}
+		t.Cleanup(key.Wipe)
+		return fs.NewStore(paths, fs.WithCipher(key))
+	})
+}
+
+func testPaths(t *testing.T) config.Paths {
+	t.Helper()
+	paths, err := config.ResolvePathsFrom(t.TempDir())
+	if err != nil {
+		t.Fatalf("ResolvePathsFrom: %v", err)
+	}
+	return paths
+}
@@ -1,39 +1,76 @@
 package fs
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
 	"time"
 )
 
+// trashInfo is kept for a trashed note so it can go back where it came
+// from, and be purged once it has been in the trash long enough.
+type trashInfo struct {
+	Origin    Section   `json:"origin"`
+	DeletedAt time.Time `json:"deleted_at,omitzero"`
+}
+
 func (s *Store) MoveToTrash(n Note) (Note, error) {
 	if n.Section == SectionTrash {
 		return n, nil
 	}
-	dst := s.notePath(SectionTrash, n.ID)
-	if err := os.Rename(n.Path, dst); err != nil {
+	dst, err := s.notePath(SectionTrash, n.ID)
+	if err != nil {
+		return Note{}, err
+	}
+	// The record is written once the note is in, so a refused move
+	// leaves the record of the note already trashed under its ID alone.
+	if err := moveFile(n.Path, dst); err != nil {
 		return Note{}, fmt.Errorf("move note %q to trash: %w", n.Path, err)
 	}
+	now := time.Now()
+	if err := s.writeTrashInfo(n.ID, trashInfo{Origin: n.Section, DeletedAt: now}); err != nil {
+		if merr := moveFile(dst, n.Path); merr != nil {
+			return Note{}, errors.Join(err, merr)
+		}
+		return Note{}, err
+	}
+	s.list.move(n.Path, dst, n.Section, now)
+	n.Origin = n.Section
 	n.Path = dst
 	n.Section = SectionTrash
-	n.UpdatedAt = time.Now()
+	n.UpdatedAt = now
+	n.DeletedAt = now
 	return n, nil
 }
 
+// RestoreFromTrash moves a trashed note into target. An empty or trash
+// target restores it to the notebook or journal it was trashed from, or to
+// the root notebook when that is unknown. Missing notebooks are recreated.
 func (s *Store) RestoreFromTrash(n Note, target Section) (Note, error) {
 	if n.Section != SectionTrash {
 		return n, nil
 	}
-	if target == SectionTrash {
-		target = SectionNotes
+	if target == "" || target == SectionTrash {
+		target = s.trashOrigin(n.ID)
 	}
-	dst := s.notePath(target, n.ID)
-	if err := os.Rename(n.Path, dst); err != nil {
+	dst, err := s.notePath(target, n.ID)
+	if err != nil {
+		return Note{}, err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), dirPerm); err != nil {
+		return Note{}, fmt.Errorf("recreate notebook %q: %w", target.Rel(), err)
+	}
+	if err := moveFile(n.Path, dst); err != nil {
 		return Note{}, fmt.Errorf("restore note %q: %w", n.Path, err)
 	}
+	s.list.move(n.Path, dst, "", time.Time{})
+	s.removeTrashInfo(n.ID)
 	n.Path = dst
 	n.Section = target
 	n.UpdatedAt = time.Now()
+	n.DeletedAt, n.Origin = time.Time{}, ""
 	return n, nil
 }
 
@@ -44,5 +81,117 @@ func (s *Store) DeleteFromTrash(n Note) error {
 	if err := os.Remove(n.Path); err != nil {
 		return fmt.Errorf("delete note %q from trash: %w", n.Path, err)
 	}
+	s.list.forget(n.Path)
+	s.removeTrashInfo(n.ID)
+	s.unpin(n.ID)
+	return s.history.Remove(n.ID)
+}
+
+// PurgeTrash deletes the notes trashed before cutoff for good and returns
+// them. Notes trashed without a record of when, by another tool, are dated
+// by the last change to their file, which stays put from one run to the
+// next.
+func (s *Store) PurgeTrash(cutoff time.Time) ([]Note, error) {
+	notes, err := s.List(SectionTrash)
+	if err != nil {
+		return nil, err
+	}
+	var purged []Note
+	for _, n := range notes {
+		if n.DeletedAt.IsZero() {
+			info, _ := s.readTrashInfo(n.ID)
+			info.DeletedAt = n.UpdatedAt
+			if err := s.writeTrashInfo(n.ID, info); err != nil {
+				return purged, err
+			}
+			s.list.stamp(n.Path, info.Origin, info.DeletedAt)
+			n.DeletedAt = info.DeletedAt
+		}
+		if !n.DeletedAt.Before(cutoff) {
+			continue
+		}
+		if err := s.DeleteFromTrash(n); err != nil {
+			return purged, err
+		}
+		purged = append(purged, n)
+	}
+	return purged, nil
+}
+
+func (s *Store) trashInfoPath(id string) string {
+	return filepath.Join(s.paths.Meta, "trash", id+".json")
+}
+
+// writeTrashInfo records info for the trashed note id. It names the
+// notebook the note came from, so it is encrypted like the notes.
+func (s *Store) writeTrashInfo(id string, info trashInfo) error {
+	path := s.trashInfoPath(id)
+	if err := os.MkdirAll(filepath.Dir(path), dirPerm); err != nil {
+		return fmt.Errorf("create trash info dir: %w", err)
+	}
+	b, err := json.Marshal(info)
+	if err != nil {
+		return fmt.Errorf("marshal trash info: %w", err)
+	}
+	if err := s.writeFile(path, b); err != nil {
+		return fmt.Errorf("write trash info %q: %w", path, err)
+	}
 	return nil
 }
+
+func (s *Store) readTrashInfo(id string) (trashInfo, error) {
+	var info trashInfo
+	b, err := s.readFile(s.trashInfoPath(id))
+	if err != nil {
+		return info, err
+	}
+	if err := json.Unmarshal(b, &info); err != nil {
+		return info, fmt.Errorf("parse trash info for %s: %w", id, err)
+	}
+	return info, nil
+}
+
+// trashOrigin returns the notebook or journal a trashed note came from.
+func (s *Store) trashOrigin(id string) Section {
+	info, err := s.readTrashInfo(id)
+	if err != nil || !info.Origin.IsNotebook() && info.Origin != SectionJournal {
+		return SectionNotes
+	}
+	if _, err := s.dirFor(info.Origin); err != nil {
+		return SectionNotes
+	}
+	return info.Origin
+}
+
+// removeTrashInfo is best effort: a stale record only affects where a note
+// would be restored to, and a note with that ID can no longer be in trash.
+func (s *Store) removeTrashInfo(id string) {
+	_ = os.Remove(s.trashInfoPath(id))
+}
+
+func (s *Store) recryptTrashInfo(to Cipher) (int, error) {
+	dir := filepath.Join(s.paths.Meta, "trash")
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("read trash info dir: %w", err)
+	}
+	n := 0
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != ".json" {
+			continue
+		}
+		path := filepath.Join(dir, e.Name())
+		plain, err := s.readFile(path)
+		if err != nil {
+			return n, fmt.Errorf("read trash info %q: %w", path, err)
+		}
+		if err := s.writeSealed(to, path, plain); err != nil {
+			return n, fmt.Errorf("write trash info %q: %w", path, err)
+		}
+		n++
+	}
+	return n, nil
+}
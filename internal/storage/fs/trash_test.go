@@ -0,0 +1,106 @@
+package fs_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/internet-kid/tenote/internal/storage/fs"
+	"github.com/internet-kid/tenote/internal/storage/vault"
+)
+
+func TestTrashInfoEncrypted(t *testing.T) {
+	paths := testPaths(t)
+	key, err := vault.Create(paths.Meta, "correct horse")
+	if err != nil {
+		t.Fatalf("vault.Create: %v", err)
+	}
+	t.Cleanup(key.Wipe)
+	s := fs.NewStore(paths, fs.WithCipher(key))
+
+	nb, err := s.CreateNotebook(fs.SectionNotes, "secret-project")
+	if err != nil {
+		t.Fatalf("CreateNotebook: %v", err)
+	}
+	n, err := s.Create(nb)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	trashed, err := s.MoveToTrash(n)
+	if err != nil {
+		t.Fatalf("MoveToTrash: %v", err)
+	}
+
+	raw, err := os.ReadFile(filepath.Join(paths.Meta, "trash", n.ID+".json"))
+	if err != nil {
+		t.Fatalf("read trash info: %v", err)
+	}
+	if strings.Contains(string(raw), "secret-project") {
+		t.Fatalf("trash info names the notebook in plain text: %s", raw)
+	}
+
+	// A fresh store reads the record back.
+	s = fs.NewStore(paths, fs.WithCipher(key))
+	trash, err := s.List(fs.SectionTrash)
+	if err != nil || len(trash) != 1 {
+		t.Fatalf("List(trash) = %+v, %v", trash, err)
+	}
+	if trash[0].Origin != nb || trash[0].DeletedAt.IsZero() {
+		t.Fatalf("trashed note = %+v, want it from %s with a date", trash[0], nb)
+	}
+	restored, err := s.RestoreFromTrash(trashed, "")
+	if err != nil {
+		t.Fatalf("RestoreFromTrash: %v", err)
+	}
+	if restored.Section != nb {
+		t.Fatalf("restored to %s, want %s", restored.Section, nb)
+	}
+}
+
+func TestPurgeTrashDatesUndatedNotesByMtime(t *testing.T) {
+	paths := testPaths(t)
+	s := fs.NewStore(paths)
+
+	// Notes trashed by another tool: one long ago, one just now.
+	put := func(id string, mod time.Time) string {
+		t.Helper()
+		path := filepath.Join(paths.Trash, id+".md")
+		if err := os.WriteFile(path, []byte("# "+id+"\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, mod, mod); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	old := time.Now().Add(-60 * 24 * time.Hour)
+	recent := time.Now().Add(-24 * time.Hour).Truncate(time.Second)
+	put("01OLD", old)
+	put("01RECENT", recent)
+
+	cutoff := time.Now().Add(-30 * 24 * time.Hour)
+	purged, err := s.PurgeTrash(cutoff)
+	if err != nil {
+		t.Fatalf("PurgeTrash: %v", err)
+	}
+	if len(purged) != 1 || purged[0].ID != "01OLD" {
+		t.Fatalf("purged %+v, want only the note last changed before the cutoff", purged)
+	}
+
+	// Later runs keep the date instead of pushing it back.
+	for range 2 {
+		if _, err := s.PurgeTrash(cutoff); err != nil {
+			t.Fatalf("PurgeTrash: %v", err)
+		}
+		s.Refresh(nil)
+		trash, err := s.List(fs.SectionTrash)
+		if err != nil || len(trash) != 1 {
+			t.Fatalf("List(trash) = %+v, %v", trash, err)
+		}
+		if !trash[0].DeletedAt.Equal(recent) {
+			t.Fatalf("DeletedAt = %v, want the file's mtime %v", trash[0].DeletedAt, recent)
+		}
+	}
+}
@@ -1,18 +1,72 @@
 package fs
 
-import "time"
+import (
+	"path"
+	"strings"
+	"time"
+)
 
 // ---------------------------------------------------------------------------
 // Section
 // ---------------------------------------------------------------------------
 
+// Section is where a note lives: the trash, the journal, the root notebook
+// "notes", or a nested notebook addressed by its slash separated path below
+// the root, e.g. "notes/work/ideas".
 type Section string
 
 const (
-	SectionNotes Section = "notes"
-	SectionTrash Section = "trash"
+	SectionNotes   Section = "notes"
+	SectionJournal Section = "journal" // one note per day; see storage.OpenJournal
+	SectionTrash   Section = "trash"
 )
 
+// Notebook returns the section for a notebook path relative to the root
+// notebook, e.g. Notebook("work/ideas"). An empty path is the root.
+func Notebook(rel string) Section {
+	rel = strings.Trim(path.Clean("/"+rel), "/")
+	if rel == "" {
+		return SectionNotes
+	}
+	return SectionNotes + "/" + Section(rel)
+}
+
+// IsNotebook reports whether s is the root notebook or one nested in it.
+func (s Section) IsNotebook() bool {
+	return s == SectionNotes || strings.HasPrefix(string(s), string(SectionNotes)+"/")
+}
+
+// Rel returns the notebook path below the root notebook, "" for the root
+// and for sections that are not notebooks.
+func (s Section) Rel() string {
+	if !s.IsNotebook() {
+		return ""
+	}
+	return strings.TrimPrefix(strings.TrimPrefix(string(s), string(SectionNotes)), "/")
+}
+
+// Name returns the last path element of a notebook, or the section itself.
+func (s Section) Name() string {
+	return path.Base(string(s))
+}
+
+// Depth is 0 for the root notebook and the trash, 1 for its children, and
+// so on.
+func (s Section) Depth() int {
+	if s.Rel() == "" {
+		return 0
+	}
+	return strings.Count(s.Rel(), "/") + 1
+}
+
+// Parent returns the enclosing notebook, or s itself at the top level.
+func (s Section) Parent() Section {
+	if s.Depth() == 0 {
+		return s
+	}
+	return Notebook(path.Dir(s.Rel()))
+}
+
 // ---------------------------------------------------------------------------
 // Note
 // ---------------------------------------------------------------------------
@@ -23,4 +77,20 @@ type Note struct {
 	Path      string
 	Section   Section
 	UpdatedAt time.Time
+	Size      int64 // of the note file in bytes, as stored
+
+	// Front matter metadata; see Meta.
+	Tags    []string
+	Aliases []string
+	Created time.Time
+	Fields  map[string]any
+
+	// Pinned notes are listed first. The store keeps the pins, apart
+	// from the note; a pinned key in the front matter also pins it.
+	Pinned bool
+
+	// For notes in the trash: when they were trashed, zero if unknown, and
+	// the notebook they came from, empty if unknown.
+	DeletedAt time.Time
+	Origin    Section
 }
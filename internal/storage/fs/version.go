@@ -0,0 +1,123 @@
+package fs
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// ErrConflict is returned by WriteBodyIf when the note changed after the
+// version the caller edited was read.
+var ErrConflict = errors.New("note changed since it was read")
+
+// Version identifies the content of a note as it was read, so that a later
+// save can tell whether someone else changed the note in the meantime.
+type Version struct {
+	ModTime time.Time
+	Hash    string // SHA-256 of the plain body
+}
+
+// VersionOf returns the version of body as last modified at mod.
+func VersionOf(body string, mod time.Time) Version {
+	sum := sha256.Sum256([]byte(body))
+	return Version{ModTime: mod, Hash: hex.EncodeToString(sum[:])}
+}
+
+// IsZero reports whether v is the zero Version, which matches anything.
+func (v Version) IsZero() bool { return v.Hash == "" }
+
+// Matches reports whether the note at v still has the content it had at
+// base. Only the content counts: sync tools and editors often touch a file
+// without changing it.
+func (v Version) Matches(base Version) bool {
+	return base.IsZero() || v.Hash == base.Hash
+}
+
+// ReadVersion is ReadBody that also returns the version of the body read.
+func (s *Store) ReadVersion(path string) (string, Version, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", Version{}, fmt.Errorf("read note %q: %w", path, err)
+	}
+	body, err := s.ReadBody(path)
+	if err != nil {
+		return "", Version{}, err
+	}
+	return body, VersionOf(body, info.ModTime()), nil
+}
+
+// WriteBodyIf is WriteBody for a body edited from base. It fails with
+// ErrConflict, writing nothing, when the note on disk no longer matches
+// base. A zero base writes unconditionally.
+func (s *Store) WriteBodyIf(path, body string, base Version) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !base.IsZero() {
+		_, cur, err := s.ReadVersion(path)
+		if err != nil {
+			return err
+		}
+		if !cur.Matches(base) {
+			return fmt.Errorf("write note %q: %w", path, ErrConflict)
+		}
+	}
+	return s.writeBody(path, body)
+}
+
+// updateAttempts is how often Update applies an edit to a note that keeps
+// changing under it before giving up.
+const updateAttempts = 3
+
+// BodyStore is the part of a note store Update reads and writes through.
+// storage.NoteStore satisfies it.
+type BodyStore interface {
+	ReadBody(path string) (string, error)
+	WriteBody(path, body string) error
+}
+
+// versioned is storage.Versioned, which this package cannot import.
+type versioned interface {
+	ReadVersion(path string) (string, Version, error)
+	WriteBodyIf(path, body string, base Version) error
+}
+
+// Update rewrites the note at path with edit, which returns the new body
+// and whether it changed anything. Stores that track versions are written
+// through WriteBodyIf, so a save made since the note was read is never
+// overwritten: edit is applied again to the new body instead, and Update
+// fails with ErrConflict if the note keeps changing. Update reports whether
+// it wrote the note.
+func Update(store BodyStore, path string, edit func(body string) (string, bool)) (bool, error) {
+	v, ok := store.(versioned)
+	if !ok {
+		body, err := store.ReadBody(path)
+		if err != nil {
+			return false, err
+		}
+		out, changed := edit(body)
+		if !changed {
+			return false, nil
+		}
+		return true, store.WriteBody(path, out)
+	}
+
+	var err error
+	for range updateAttempts {
+		body, base, rerr := v.ReadVersion(path)
+		if rerr != nil {
+			return false, rerr
+		}
+		out, changed := edit(body)
+		if !changed {
+			return false, nil
+		}
+		if err = v.WriteBodyIf(path, out, base); !errors.Is(err, ErrConflict) {
+			return err == nil, err
+		}
+	}
+	return false, err
+}
@@ -0,0 +1,68 @@
+package fs_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/internet-kid/tenote/internal/storage/fs"
+)
+
+func TestWriteBodyIf(t *testing.T) {
+	s := fs.NewStore(testPaths(t))
+	n, err := s.Create(fs.SectionNotes)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if err := s.WriteBody(n.Path, "# One\n"); err != nil {
+		t.Fatalf("WriteBody: %v", err)
+	}
+	_, base, err := s.ReadVersion(n.Path)
+	if err != nil {
+		t.Fatalf("ReadVersion: %v", err)
+	}
+
+	// Someone else saves the note after it was read.
+	if err := s.WriteBody(n.Path, "# One\nfrom elsewhere\n"); err != nil {
+		t.Fatalf("WriteBody: %v", err)
+	}
+	if err := s.WriteBodyIf(n.Path, "# One\nmine\n", base); !errors.Is(err, fs.ErrConflict) {
+		t.Fatalf("WriteBodyIf with a stale version = %v, want ErrConflict", err)
+	}
+	if body, _ := s.ReadBody(n.Path); body != "# One\nfrom elsewhere\n" {
+		t.Fatalf("a refused write changed the note to %q", body)
+	}
+
+	_, cur, err := s.ReadVersion(n.Path)
+	if err != nil {
+		t.Fatalf("ReadVersion: %v", err)
+	}
+	if err := s.WriteBodyIf(n.Path, "# One\nmine\n", cur); err != nil {
+		t.Fatalf("WriteBodyIf with the current version: %v", err)
+	}
+	if err := s.WriteBodyIf(n.Path, "# Forced\n", fs.Version{}); err != nil {
+		t.Fatalf("WriteBodyIf with a zero version: %v", err)
+	}
+	if body, _ := s.ReadBody(n.Path); body != "# Forced\n" {
+		t.Fatalf("note = %q", body)
+	}
+}
+
+func TestVersionMatchesContentOnly(t *testing.T) {
+	// Touching a note without changing it is not a conflict.
+	s := fs.NewStore(testPaths(t))
+	n, err := s.Create(fs.SectionNotes)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	_, base, err := s.ReadVersion(n.Path)
+	if err != nil {
+		t.Fatalf("ReadVersion: %v", err)
+	}
+	body, _ := s.ReadBody(n.Path)
+	if err := s.WriteBody(n.Path, body); err != nil {
+		t.Fatalf("WriteBody: %v", err)
+	}
+	if err := s.WriteBodyIf(n.Path, "# Edited\n", base); err != nil {
+		t.Fatalf("WriteBodyIf after an unchanged save = %v", err)
+	}
+}
@@ -0,0 +1,78 @@
+package gitstore
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// repo runs git in the storage root.
+type repo struct {
+	dir string
+	// identity is passed to commands that commit when the user has no git
+	// identity configured, so commits never fail for lack of one.
+	identity []string
+}
+
+func (r *repo) git(args ...string) (string, error) {
+	name := args[0]
+	for i := 0; i+2 < len(args) && args[i] == "-c"; i += 2 {
+		name = args[i+2]
+	}
+
+	cmd := exec.Command("git", append([]string{"-C", r.dir}, args...)...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = strings.TrimSpace(stdout.String())
+		}
+		if msg == "" {
+			return "", fmt.Errorf("git %s: %w", name, err)
+		}
+		return "", fmt.Errorf("git %s: %s", name, msg)
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// commitArgs prefixes a committing command with the fallback identity.
+func (r *repo) commitArgs(args ...string) []string {
+	return append(append([]string{}, r.identity...), args...)
+}
+
+// lines splits command output into non-empty lines.
+func lines(out string) []string {
+	var res []string
+	for _, l := range strings.Split(out, "\n") {
+		if l = strings.TrimSpace(l); l != "" {
+			res = append(res, l)
+		}
+	}
+	return res
+}
+
+// openRepo makes sure dir is inside a git work tree, initializing a new
+// repository there if it is not.
+func openRepo(dir string) (*repo, bool, error) {
+	if _, err := exec.LookPath("git"); err != nil {
+		return nil, false, errors.New("the git backend needs git on $PATH")
+	}
+	r := &repo{dir: dir}
+
+	created := false
+	if out, err := r.git("rev-parse", "--is-inside-work-tree"); err != nil || out != "true" {
+		if _, err := r.git("init", "-q"); err != nil {
+			return nil, false, err
+		}
+		created = true
+	}
+
+	if email, _ := r.git("config", "user.email"); email == "" {
+		r.identity = []string{"-c", "user.name=tenote", "-c", "user.email=tenote@localhost"}
+	}
+	return r, created, nil
+}
@@ -0,0 +1,258 @@
+// Package gitstore keeps the filesystem store in a git repository. Every
+// change made through it is committed with a message describing it, and
+// Sync exchanges those commits with a remote.
+//
+// Only the notes and trash directories are committed; tenote's own state
+// under .tenote is ignored. The storage root may be a repository of its
+// own or a directory inside a larger one.
+package gitstore
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/internet-kid/tenote/internal/config"
+	"github.com/internet-kid/tenote/internal/storage/fs"
+)
+
+const (
+	// keepFile makes git track otherwise empty notebook directories.
+	keepFile  = ".gitkeep"
+	ignore    = ".gitignore"
+	metaEntry = ".tenote/"
+	filePerm  = 0o644
+)
+
+// Store is an fs.Store whose changes are committed to git.
+type Store struct {
+	*fs.Store
+
+	mu     sync.Mutex
+	paths  config.Paths
+	repo   *repo
+	remote string
+}
+
+// Open returns a Store for paths, initializing a repository in its root
+// when it is not inside one yet. remote is the URL or path pulled from and
+// pushed to by Sync; empty uses the repository's existing origin.
+func Open(paths config.Paths, remote string, opts ...fs.Option) (*Store, error) {
+	r, created, err := openRepo(paths.Root)
+	if err != nil {
+		return nil, err
+	}
+	s := &Store{Store: fs.NewStore(paths, opts...), paths: paths, repo: r, remote: remote}
+
+	if err := s.setup(created); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// setup ignores tenote's state directory and keeps the notes, journal and
+// trash directories in the repository even while they are empty.
+func (s *Store) setup(created bool) error {
+	path := filepath.Join(s.paths.Root, ignore)
+	b, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("read %q: %w", path, err)
+	}
+	if !hasLine(string(b), metaEntry) {
+		if len(b) > 0 && !strings.HasSuffix(string(b), "\n") {
+			b = append(b, '\n')
+		}
+		b = append(b, metaEntry+"\n"...)
+		if err := os.WriteFile(path, b, filePerm); err != nil {
+			return fmt.Errorf("write %q: %w", path, err)
+		}
+	}
+	for _, dir := range []string{s.paths.Notes, s.paths.Journal, s.paths.Trash} {
+		if err := keep(dir); err != nil {
+			return err
+		}
+	}
+
+	msg := "Set up tenote notes"
+	if created {
+		msg = "Initialize tenote notes"
+	}
+	return s.commit(msg, ignore)
+}
+
+func hasLine(text, line string) bool {
+	for _, l := range strings.Split(text, "\n") {
+		if strings.TrimSpace(l) == line || strings.TrimSpace(l) == "/"+line {
+			return true
+		}
+	}
+	return false
+}
+
+func keep(dir string) error {
+	path := filepath.Join(dir, keepFile)
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+	if err := os.WriteFile(path, nil, filePerm); err != nil {
+		return fmt.Errorf("write %q: %w", path, err)
+	}
+	return nil
+}
+
+// commit records the current state of the notes, journal and trash
+// directories, plus any extra paths relative to the root, unless nothing
+// changed. Changes staged by the user elsewhere in the repository are left
+// alone.
+func (s *Store) commit(msg string, extra ...string) error {
+	paths := append([]string{"notes", "journal", "trash"}, extra...)
+	if _, err := s.repo.git(append([]string{"add", "-A", "--"}, paths...)...); err != nil {
+		return err
+	}
+	if _, err := s.repo.git(append([]string{"diff", "--cached", "--quiet", "--"}, paths...)...); err == nil {
+		return nil
+	}
+	_, err := s.repo.git(s.repo.commitArgs(append([]string{"commit", "-q", "-m", msg, "--"}, paths...)...)...)
+	return err
+}
+
+func (s *Store) Create(section fs.Section) (fs.Note, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n, err := s.Store.Create(section)
+	if err != nil {
+		return fs.Note{}, err
+	}
+	return n, s.commit(fmt.Sprintf("Create note %s in %s", n.ID, section))
+}
+
+func (s *Store) WriteBody(path, body string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.Store.WriteBody(path, body); err != nil {
+		return err
+	}
+	return s.commitUpdate(path, body)
+}
+
+func (s *Store) WriteBodyIf(path, body string, base fs.Version) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.Store.WriteBodyIf(path, body, base); err != nil {
+		return err
+	}
+	return s.commitUpdate(path, body)
+}
+
+func (s *Store) commitUpdate(path, body string) error {
+	meta, content := fs.ParseFrontMatter(body)
+	title := meta.Title
+	if title == "" {
+		title = fs.TitleFromBody(content)
+	}
+	return s.commit(fmt.Sprintf("Update %q (%s)", title, noteID(path)))
+}
+
+func (s *Store) MoveToTrash(n fs.Note) (fs.Note, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	moved, err := s.Store.MoveToTrash(n)
+	if err != nil {
+		return fs.Note{}, err
+	}
+	return moved, s.commit(fmt.Sprintf("Move %q (%s) to trash", n.Title, n.ID))
+}
+
+func (s *Store) RestoreFromTrash(n fs.Note, target fs.Section) (fs.Note, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	restored, err := s.Store.RestoreFromTrash(n, target)
+	if err != nil {
+		return fs.Note{}, err
+	}
+	return restored, s.commit(fmt.Sprintf("Restore %q (%s) to %s", n.Title, n.ID, restored.Section))
+}
+
+func (s *Store) DeleteFromTrash(n fs.Note) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.Store.DeleteFromTrash(n); err != nil {
+		return err
+	}
+	return s.commit(fmt.Sprintf("Delete %q (%s) from trash", n.Title, n.ID))
+}
+
+func (s *Store) PurgeTrash(cutoff time.Time) ([]fs.Note, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	purged, err := s.Store.PurgeTrash(cutoff)
+	if len(purged) > 0 {
+		if cerr := s.commit(fmt.Sprintf("Purge %d note(s) from trash", len(purged))); err == nil {
+			err = cerr
+		}
+	}
+	return purged, err
+}
+
+func (s *Store) CreateNotebook(parent fs.Section, name string) (fs.Section, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	nb, err := s.Store.CreateNotebook(parent, name)
+	if err != nil {
+		return "", err
+	}
+	if err := keep(s.notebookDir(nb)); err != nil {
+		return nb, err
+	}
+	return nb, s.commit("Create notebook " + nb.Rel())
+}
+
+func (s *Store) DeleteNotebook(nb fs.Section) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := fs.ValidSection(nb); err != nil {
+		return err
+	}
+	// The keep file alone does not make a notebook non-empty.
+	kept := filepath.Join(s.notebookDir(nb), keepFile)
+	removed := os.Remove(kept) == nil
+	if err := s.Store.DeleteNotebook(nb); err != nil {
+		if removed {
+			_ = os.WriteFile(kept, nil, filePerm)
+		}
+		return err
+	}
+	return s.commit("Delete notebook " + nb.Rel())
+}
+
+func (s *Store) Move(n fs.Note, target fs.Section) (fs.Note, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	moved, err := s.Store.Move(n, target)
+	if err != nil {
+		return fs.Note{}, err
+	}
+	return moved, s.commit(fmt.Sprintf("Move %q (%s) to %s", n.Title, n.ID, target))
+}
+
+func (s *Store) notebookDir(nb fs.Section) string {
+	return filepath.Join(s.paths.Notes, filepath.FromSlash(nb.Rel()))
+}
+
+func noteID(path string) string {
+	return strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+}
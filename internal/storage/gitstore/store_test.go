@@ -0,0 +1,28 @@
+package gitstore_test
+
+import (
+	"os/exec"
+	"testing"
+
+	"github.com/internet-kid/tenote/internal/config"
+	"github.com/internet-kid/tenote/internal/storage"
+	"github.com/internet-kid/tenote/internal/storage/gitstore"
+	"github.com/internet-kid/tenote/internal/storage/storetest"
+)
+
+func TestConformance(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	storetest.Run(t, func(t *testing.T) storage.NoteStore {
+		paths, err := config.ResolvePathsFrom(t.TempDir())
+		if err != nil {
+			t.Fatalf("ResolvePathsFrom: %v", err)
+		}
+		s, err := gitstore.Open(paths, "")
+		if err != nil {
+			t.Fatalf("Open: %v", err)
+		}
+		return s
+	})
+}
@@ -0,0 +1,188 @@
+package gitstore
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/internet-kid/tenote/internal/storage/fs"
+)
+
+const remoteName = "origin"
+
+// SyncResult describes what Sync exchanged with the remote.
+type SyncResult struct {
+	Pulled int  // commits merged from the remote
+	Pushed bool // whether local commits were pushed
+	// Conflicts are notes changed on both sides. They keep git's conflict
+	// markers, both versions side by side, until they are edited.
+	Conflicts []fs.Note
+}
+
+// Sync commits pending changes, merges the remote branch and pushes the
+// result. Notes that conflict are committed with conflict markers and
+// reported in the result rather than failing the sync.
+func (s *Store) Sync() (SyncResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var res SyncResult
+	if err := s.commit("Save local changes"); err != nil {
+		return res, err
+	}
+	if err := s.ensureRemote(); err != nil {
+		return res, err
+	}
+	branch, err := s.repo.git("symbolic-ref", "--short", "HEAD")
+	if err != nil {
+		return res, err
+	}
+	if _, err := s.repo.git("fetch", "-q", remoteName); err != nil {
+		return res, err
+	}
+
+	upstream := remoteName + "/" + branch
+	if _, err := s.repo.git("rev-parse", "-q", "--verify", upstream); err == nil {
+		if res, err = s.merge(upstream); err != nil {
+			return res, err
+		}
+	}
+
+	ahead := 1
+	if _, err := s.repo.git("rev-parse", "-q", "--verify", upstream); err == nil {
+		ahead, err = s.count(upstream + "..HEAD")
+		if err != nil {
+			return res, err
+		}
+	}
+	if ahead > 0 {
+		if _, err := s.repo.git("push", "-q", "-u", remoteName, branch); err != nil {
+			return res, err
+		}
+		res.Pushed = true
+	}
+	return res, nil
+}
+
+// ensureRemote points origin at the configured remote.
+func (s *Store) ensureRemote() error {
+	current, err := s.repo.git("remote", "get-url", remoteName)
+	switch {
+	case s.remote == "" && err != nil:
+		return fmt.Errorf("no git remote: set git_remote in the config or add %q to the repository", remoteName)
+	case s.remote == "" || current == s.remote:
+		return nil
+	case err != nil:
+		_, err = s.repo.git("remote", "add", remoteName, s.remote)
+	default:
+		_, err = s.repo.git("remote", "set-url", remoteName, s.remote)
+	}
+	return err
+}
+
+func (s *Store) count(revs string) (int, error) {
+	out, err := s.repo.git("rev-list", "--count", revs)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(out)
+}
+
+// merge merges upstream into HEAD. Conflicted notes are committed as they
+// are in the work tree, markers included; a note deleted on one side and
+// changed on the other is kept.
+func (s *Store) merge(upstream string) (SyncResult, error) {
+	var res SyncResult
+	n, err := s.count("HEAD.." + upstream)
+	if err != nil || n == 0 {
+		return res, err
+	}
+	res.Pulled = n
+	// Whatever the merge brought in, or left behind when it was aborted,
+	// changed the notes without the store knowing.
+	defer s.Store.Refresh(nil)
+
+	_, mergeErr := s.repo.git(s.repo.commitArgs("merge", "-q", "--no-edit", "--allow-unrelated-histories", upstream)...)
+	if mergeErr == nil {
+		return res, nil
+	}
+
+	out, err := s.repo.git("diff", "--name-only", "--diff-filter=U")
+	conflicted := lines(out)
+	if err != nil || len(conflicted) == 0 {
+		_, _ = s.repo.git("merge", "--abort")
+		return SyncResult{}, mergeErr
+	}
+	prefix, err := s.repo.git("rev-parse", "--show-prefix")
+	if err != nil {
+		return SyncResult{}, err
+	}
+	for _, p := range conflicted {
+		rel, ok := strings.CutPrefix(p, prefix)
+		if !ok || !(strings.HasPrefix(rel, "notes/") || strings.HasPrefix(rel, "journal/") || strings.HasPrefix(rel, "trash/")) {
+			_, _ = s.repo.git("merge", "--abort")
+			return SyncResult{}, fmt.Errorf("merge %s: conflict outside the notes in %s", upstream, p)
+		}
+	}
+
+	conflicts, err := s.resolve(upstream, conflicted, prefix)
+	if err != nil {
+		_, _ = s.repo.git("merge", "--abort")
+		return SyncResult{}, err
+	}
+	res.Conflicts = conflicts
+	return res, nil
+}
+
+// resolve commits a merge of upstream that stopped on conflicted, which
+// are paths relative to the repository's top level.
+func (s *Store) resolve(upstream string, conflicted []string, prefix string) ([]fs.Note, error) {
+	var notes []fs.Note
+	for _, p := range conflicted {
+		rel := strings.TrimPrefix(p, prefix)
+		args := []string{"add", "--", rel}
+		if _, err := os.Stat(filepath.Join(s.paths.Root, filepath.FromSlash(rel))); err != nil {
+			args = []string{"rm", "-q", "--", rel}
+		} else if note, ok := s.noteAt(rel); ok {
+			notes = append(notes, note)
+		}
+		if _, err := s.repo.git(args...); err != nil {
+			return nil, err
+		}
+	}
+	msg := fmt.Sprintf("Merge %s with conflicts in %d note(s)", upstream, len(notes))
+	if _, err := s.repo.git(s.repo.commitArgs("commit", "-q", "-m", msg)...); err != nil {
+		return nil, err
+	}
+	return notes, nil
+}
+
+// noteAt finds the note stored at rel, a slash separated path below the
+// storage root.
+func (s *Store) noteAt(rel string) (fs.Note, bool) {
+	dir, file := path.Split(rel)
+	if path.Ext(file) != ".md" {
+		return fs.Note{}, false
+	}
+	section := fs.SectionTrash
+	switch dir = strings.TrimSuffix(dir, "/"); {
+	case dir == string(fs.SectionJournal):
+		section = fs.SectionJournal
+	case strings.HasPrefix(dir, "notes"):
+		section = fs.Notebook(strings.TrimPrefix(dir, "notes"))
+	}
+	notes, err := s.Store.List(section)
+	if err != nil {
+		return fs.Note{}, false
+	}
+	id := strings.TrimSuffix(file, ".md")
+	for _, n := range notes {
+		if n.ID == id {
+			return n, true
+		}
+	}
+	return fs.Note{}, false
+}
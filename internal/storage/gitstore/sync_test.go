@@ -0,0 +1,202 @@
+package gitstore_test
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/internet-kid/tenote/internal/config"
+	"github.com/internet-kid/tenote/internal/storage/fs"
+	"github.com/internet-kid/tenote/internal/storage/gitstore"
+)
+
+// git runs git in dir with a fixed identity and returns its output.
+func git(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	args = append([]string{"-C", dir, "-c", "user.name=test", "-c", "user.email=test@localhost"}, args...)
+	out, err := exec.Command("git", args...).CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s: %v\n%s", strings.Join(args[6:], " "), err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// remote is a bare repository standing in for the server that clones
+// sync through.
+type remote struct {
+	t   *testing.T
+	dir string
+}
+
+func newRemote(t *testing.T) *remote {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir := filepath.Join(t.TempDir(), "remote.git")
+	if out, err := exec.Command("git", "init", "-q", "--bare", dir).CombinedOutput(); err != nil {
+		t.Fatalf("git init --bare: %v\n%s", err, out)
+	}
+	return &remote{t: t, dir: dir}
+}
+
+// clone clones the remote and opens a store in the clone, which syncs
+// with the clone's origin.
+func (r *remote) clone() (*gitstore.Store, string) {
+	r.t.Helper()
+	dir := filepath.Join(r.t.TempDir(), "clone")
+	if out, err := exec.Command("git", "clone", "-q", r.dir, dir).CombinedOutput(); err != nil {
+		r.t.Fatalf("git clone: %v\n%s", err, out)
+	}
+	paths, err := config.ResolvePathsFrom(dir)
+	if err != nil {
+		r.t.Fatalf("ResolvePathsFrom: %v", err)
+	}
+	s, err := gitstore.Open(paths, "")
+	if err != nil {
+		r.t.Fatalf("Open: %v", err)
+	}
+	return s, dir
+}
+
+// head returns the commit the remote's branch points at.
+func (r *remote) head(branch string) string {
+	r.t.Helper()
+	return git(r.t, r.dir, "rev-parse", branch)
+}
+
+func newNote(t *testing.T, s *gitstore.Store, body string) fs.Note {
+	t.Helper()
+	n, err := s.Create(fs.SectionNotes)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if err := s.WriteBody(n.Path, body); err != nil {
+		t.Fatalf("WriteBody: %v", err)
+	}
+	return n
+}
+
+func mustSync(t *testing.T, s *gitstore.Store) gitstore.SyncResult {
+	t.Helper()
+	res, err := s.Sync()
+	if err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	return res
+}
+
+func TestSyncCleanMerge(t *testing.T) {
+	r := newRemote(t)
+	a, aDir := r.clone()
+	newNote(t, a, "# From A\n")
+	if res := mustSync(t, a); !res.Pushed || res.Pulled != 0 {
+		t.Fatalf("first sync of A = %+v, want a push and nothing pulled", res)
+	}
+
+	b, bDir := r.clone()
+	newNote(t, b, "# From B\n")
+	newNote(t, a, "# Also from A\n")
+	mustSync(t, a)
+
+	res := mustSync(t, b)
+	if res.Pulled == 0 || !res.Pushed || len(res.Conflicts) != 0 {
+		t.Fatalf("sync of B = %+v, want commits pulled and pushed without conflicts", res)
+	}
+	branch := git(t, bDir, "symbolic-ref", "--short", "HEAD")
+	if got, want := r.head(branch), git(t, bDir, "rev-parse", "HEAD"); got != want {
+		t.Fatalf("remote %s is at %s, want B's %s", branch, got, want)
+	}
+
+	if res := mustSync(t, a); res.Pulled == 0 || res.Pushed {
+		t.Fatalf("second sync of A = %+v, want B's commits pulled and nothing pushed", res)
+	}
+	if got, want := git(t, aDir, "rev-parse", "HEAD"), r.head(branch); got != want {
+		t.Fatalf("A is at %s after syncing, want the remote's %s", got, want)
+	}
+	notes, err := a.List(fs.SectionNotes)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	var titles []string
+	for _, n := range notes {
+		titles = append(titles, n.Title)
+	}
+	if len(notes) != 3 {
+		t.Fatalf("A lists %q after syncing, want all three notes", titles)
+	}
+}
+
+func TestSyncConflict(t *testing.T) {
+	r := newRemote(t)
+	a, _ := r.clone()
+	n := newNote(t, a, "# Shared\n\nfirst line\n")
+	mustSync(t, a)
+
+	b, bDir := r.clone()
+	if err := a.WriteBody(n.Path, "# Shared\n\nchanged by A\n"); err != nil {
+		t.Fatalf("WriteBody: %v", err)
+	}
+	mustSync(t, a)
+	bPath := filepath.Join(bDir, "notes", filepath.Base(n.Path))
+	if err := b.WriteBody(bPath, "# Shared\n\nchanged by B\n"); err != nil {
+		t.Fatalf("WriteBody: %v", err)
+	}
+
+	res := mustSync(t, b)
+	if len(res.Conflicts) != 1 || res.Conflicts[0].ID != n.ID {
+		t.Fatalf("conflicts = %+v, want note %s", res.Conflicts, n.ID)
+	}
+	if !res.Pushed {
+		t.Errorf("the merge with conflicts was not pushed")
+	}
+	if parents := strings.Fields(git(t, bDir, "rev-list", "--parents", "-n", "1", "HEAD")); len(parents) != 3 {
+		t.Errorf("HEAD is not a merge commit: %v", parents)
+	}
+	if status := git(t, bDir, "status", "--porcelain"); status != "" {
+		t.Errorf("work tree not clean after the merge:\n%s", status)
+	}
+
+	body, err := b.ReadBody(bPath)
+	if err != nil {
+		t.Fatalf("ReadBody: %v", err)
+	}
+	for _, want := range []string{"<<<<<<<", "changed by A", "changed by B", ">>>>>>>"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("conflicted note has no %q:\n%s", want, body)
+		}
+	}
+}
+
+func TestSyncConflictOutsideNotes(t *testing.T) {
+	r := newRemote(t)
+	a, aDir := r.clone()
+	mustSync(t, a)
+	b, bDir := r.clone()
+
+	// The notes may live in a repository that holds other files too.
+	for dir, text := range map[string]string{aDir: "from A\n", bDir: "from B\n"} {
+		if err := os.WriteFile(filepath.Join(dir, "README"), []byte(text), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		git(t, dir, "add", "README")
+		git(t, dir, "commit", "-q", "-m", "Add README")
+	}
+	mustSync(t, a)
+
+	before := git(t, bDir, "rev-parse", "HEAD")
+	if _, err := b.Sync(); err == nil || !strings.Contains(err.Error(), "conflict outside the notes") {
+		t.Fatalf("Sync = %v, want a conflict outside the notes", err)
+	}
+	if after := git(t, bDir, "rev-parse", "HEAD"); after != before {
+		t.Errorf("HEAD moved from %s to %s", before, after)
+	}
+	if _, err := os.Stat(filepath.Join(bDir, ".git", "MERGE_HEAD")); !os.IsNotExist(err) {
+		t.Errorf("the merge was not aborted: %v", err)
+	}
+	if got, _ := os.ReadFile(filepath.Join(bDir, "README")); string(got) != "from B\n" {
+		t.Errorf("README = %q, want B's own", got)
+	}
+}
@@ -0,0 +1,296 @@
+// Package history keeps content-addressed snapshots of note bodies so a
+// bad save can be inspected and rolled back.
+//
+// Each note has its own directory holding the snapshots, named by the
+// SHA-256 of their content, and a log listing revisions oldest first:
+//
+//	<dir>/<note id>/log.json
+//	<dir>/<note id>/<sha256>
+package history
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	filePerm   = 0o644
+	sealedPerm = 0o600
+	dirPerm    = 0o755
+	logName    = "log.json"
+
+	// DefaultLimit is the number of revisions kept per note when no limit
+	// is configured.
+	DefaultLimit = 50
+)
+
+// Revision is one recorded version of a note.
+type Revision struct {
+	Hash string    `json:"hash"`
+	Time time.Time `json:"time"`
+	Size int       `json:"size"`
+}
+
+// Cipher encrypts snapshots at rest; see vault.Key.
+type Cipher interface {
+	Seal(plain []byte) ([]byte, error)
+	Open(data []byte) ([]byte, error)
+}
+
+// Store records and reads revisions under a directory.
+type Store struct {
+	mu     sync.Mutex
+	dir    string
+	limit  int
+	cipher Cipher
+}
+
+// Option configures a Store.
+type Option func(*Store)
+
+// WithCipher stores snapshots encrypted with c.
+func WithCipher(c Cipher) Option {
+	return func(s *Store) { s.cipher = c }
+}
+
+// New returns a Store rooted at dir keeping at most limit revisions per
+// note. A limit of zero means DefaultLimit; a negative limit disables
+// recording.
+func New(dir string, limit int, opts ...Option) *Store {
+	if limit == 0 {
+		limit = DefaultLimit
+	}
+	s := &Store{dir: dir, limit: limit}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Hash returns the content address of body.
+func Hash(body string) string {
+	sum := sha256.Sum256([]byte(body))
+	return hex.EncodeToString(sum[:])
+}
+
+// Record adds body as the newest revision of the note unless it is already
+// the newest one, then drops revisions beyond the retention limit.
+func (s *Store) Record(noteID, body string) error {
+	if s.limit < 0 {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	revs, err := s.readLog(noteID)
+	if err != nil {
+		return err
+	}
+
+	hash := Hash(body)
+	if len(revs) > 0 && revs[len(revs)-1].Hash == hash {
+		return nil
+	}
+
+	dir := s.noteDir(noteID)
+	if err := os.MkdirAll(dir, dirPerm); err != nil {
+		return fmt.Errorf("create history dir %q: %w", dir, err)
+	}
+
+	obj := filepath.Join(dir, hash)
+	if _, err := os.Stat(obj); errors.Is(err, os.ErrNotExist) {
+		data, perm, err := s.seal([]byte(body))
+		if err != nil {
+			return fmt.Errorf("encrypt revision: %w", err)
+		}
+		if err := os.WriteFile(obj, data, perm); err != nil {
+			return fmt.Errorf("write revision %q: %w", obj, err)
+		}
+	}
+
+	revs = append(revs, Revision{Hash: hash, Time: time.Now(), Size: len(body)})
+
+	var dropped []Revision
+	if len(revs) > s.limit {
+		dropped = revs[:len(revs)-s.limit]
+		revs = revs[len(revs)-s.limit:]
+	}
+
+	if err := s.writeLog(noteID, revs); err != nil {
+		return err
+	}
+	return s.removeUnreferenced(noteID, dropped, revs)
+}
+
+// List returns the note's revisions, newest first.
+func (s *Store) List(noteID string) ([]Revision, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	revs, err := s.readLog(noteID)
+	if err != nil {
+		return nil, err
+	}
+	for i, j := 0, len(revs)-1; i < j; i, j = i+1, j-1 {
+		revs[i], revs[j] = revs[j], revs[i]
+	}
+	return revs, nil
+}
+
+// Read returns the body stored for a revision.
+func (s *Store) Read(noteID, hash string) (string, error) {
+	path := filepath.Join(s.noteDir(noteID), filepath.Base(hash))
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("read revision %q: %w", path, err)
+	}
+	if s.cipher != nil {
+		if b, err = s.cipher.Open(b); err != nil {
+			return "", fmt.Errorf("read revision %q: %w", path, err)
+		}
+	}
+	return string(b), nil
+}
+
+// Remove deletes every revision of a note.
+func (s *Store) Remove(noteID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.RemoveAll(s.noteDir(noteID)); err != nil {
+		return fmt.Errorf("remove history of %s: %w", noteID, err)
+	}
+	return nil
+}
+
+// Recrypt rewrites every snapshot encrypted with to, or in plain text when
+// to is nil, and returns how many it rewrote. Snapshots are read with the
+// Store's own cipher.
+func (s *Store) Recrypt(to Cipher) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	dirs, err := os.ReadDir(s.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("read history dir %q: %w", s.dir, err)
+	}
+
+	out := &Store{cipher: to}
+	n := 0
+	for _, d := range dirs {
+		if !d.IsDir() {
+			continue
+		}
+		entries, err := os.ReadDir(filepath.Join(s.dir, d.Name()))
+		if err != nil {
+			return n, fmt.Errorf("read history dir %q: %w", d.Name(), err)
+		}
+		for _, e := range entries {
+			if e.IsDir() || e.Name() == logName || filepath.Ext(e.Name()) != "" {
+				continue
+			}
+			path := filepath.Join(s.dir, d.Name(), e.Name())
+			b, err := os.ReadFile(path)
+			if err != nil {
+				return n, fmt.Errorf("read revision %q: %w", path, err)
+			}
+			if s.cipher != nil {
+				if b, err = s.cipher.Open(b); err != nil {
+					return n, fmt.Errorf("read revision %q: %w", path, err)
+				}
+			}
+			data, perm, err := out.seal(b)
+			if err != nil {
+				return n, fmt.Errorf("encrypt revision %q: %w", path, err)
+			}
+			tmp := path + ".tmp"
+			if err := os.WriteFile(tmp, data, perm); err != nil {
+				return n, fmt.Errorf("write revision %q: %w", tmp, err)
+			}
+			if err := os.Rename(tmp, path); err != nil {
+				return n, fmt.Errorf("replace revision %q: %w", path, err)
+			}
+			n++
+		}
+	}
+	return n, nil
+}
+
+// seal prepares a snapshot for disk. Encrypted snapshots are only readable
+// by their owner.
+func (s *Store) seal(b []byte) ([]byte, os.FileMode, error) {
+	if s.cipher == nil {
+		return b, filePerm, nil
+	}
+	data, err := s.cipher.Seal(b)
+	return data, sealedPerm, err
+}
+
+func (s *Store) noteDir(noteID string) string {
+	return filepath.Join(s.dir, filepath.Base(noteID))
+}
+
+func (s *Store) readLog(noteID string) ([]Revision, error) {
+	path := filepath.Join(s.noteDir(noteID), logName)
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read history log %q: %w", path, err)
+	}
+
+	var revs []Revision
+	if err := json.Unmarshal(b, &revs); err != nil {
+		return nil, fmt.Errorf("parse history log %q: %w", path, err)
+	}
+	return revs, nil
+}
+
+func (s *Store) writeLog(noteID string, revs []Revision) error {
+	path := filepath.Join(s.noteDir(noteID), logName)
+	b, err := json.MarshalIndent(revs, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal history log: %w", err)
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, b, filePerm); err != nil {
+		return fmt.Errorf("write history log %q: %w", tmp, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("replace history log %q: %w", path, err)
+	}
+	return nil
+}
+
+// removeUnreferenced deletes snapshots of dropped revisions that no kept
+// revision shares.
+func (s *Store) removeUnreferenced(noteID string, dropped, kept []Revision) error {
+	live := make(map[string]bool, len(kept))
+	for _, r := range kept {
+		live[r.Hash] = true
+	}
+	for _, r := range dropped {
+		if live[r.Hash] {
+			continue
+		}
+		path := filepath.Join(s.noteDir(noteID), r.Hash)
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("remove revision %q: %w", path, err)
+		}
+		live[r.Hash] = true
+	}
+	return nil
+}
@@ -0,0 +1,194 @@
+package history
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func bodies(t *testing.T, s *Store, id string) []string {
+	t.Helper()
+	revs, err := s.List(id)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	out := make([]string, 0, len(revs))
+	for _, r := range revs {
+		body, err := s.Read(id, r.Hash)
+		if err != nil {
+			t.Fatalf("Read(%s): %v", r.Hash, err)
+		}
+		out = append(out, body)
+	}
+	return out
+}
+
+func TestRecord(t *testing.T) {
+	s := New(t.TempDir(), 0)
+	for _, body := range []string{"one", "two", "two", "three"} {
+		if err := s.Record("N1", body); err != nil {
+			t.Fatalf("Record(%q): %v", body, err)
+		}
+	}
+
+	// Newest first, and saving the same body twice records it once.
+	if got, want := bodies(t, s, "N1"), []string{"three", "two", "one"}; !slices.Equal(got, want) {
+		t.Fatalf("revisions = %q, want %q", got, want)
+	}
+	revs, _ := s.List("N1")
+	if revs[0].Size != len("three") || revs[0].Hash != Hash("three") {
+		t.Fatalf("newest revision = %+v", revs[0])
+	}
+	if revs[0].Time.Before(revs[2].Time) {
+		t.Fatalf("revisions out of order: %+v", revs)
+	}
+
+	if revs, err := s.List("other"); err != nil || len(revs) != 0 {
+		t.Fatalf("List of a note without history = %v, %v", revs, err)
+	}
+}
+
+func TestRecordRevertAgain(t *testing.T) {
+	// Going back to an older body is a new revision sharing its snapshot.
+	s := New(t.TempDir(), 0)
+	for _, body := range []string{"a", "b", "a"} {
+		if err := s.Record("N1", body); err != nil {
+			t.Fatalf("Record: %v", err)
+		}
+	}
+	if got, want := bodies(t, s, "N1"), []string{"a", "b", "a"}; !slices.Equal(got, want) {
+		t.Fatalf("revisions = %q, want %q", got, want)
+	}
+}
+
+func TestRetention(t *testing.T) {
+	dir := t.TempDir()
+	s := New(dir, 3)
+	for _, body := range []string{"1", "2", "3", "1", "4", "5"} {
+		if err := s.Record("N1", body); err != nil {
+			t.Fatalf("Record(%q): %v", body, err)
+		}
+	}
+	if got, want := bodies(t, s, "N1"), []string{"5", "4", "1"}; !slices.Equal(got, want) {
+		t.Fatalf("revisions = %q, want %q", got, want)
+	}
+
+	// Dropped snapshots are removed unless a kept revision shares them.
+	for body, want := range map[string]bool{"1": true, "2": false, "3": false, "4": true, "5": true} {
+		_, err := os.Stat(filepath.Join(dir, "N1", Hash(body)))
+		if exists := err == nil; exists != want {
+			t.Fatalf("snapshot of %q exists = %v, want %v", body, exists, want)
+		}
+	}
+}
+
+func TestDisabled(t *testing.T) {
+	dir := t.TempDir()
+	s := New(dir, -1)
+	if err := s.Record("N1", "body"); err != nil {
+		t.Fatalf("Record: %v", err)
+	}
+	if revs, err := s.List("N1"); err != nil || len(revs) != 0 {
+		t.Fatalf("List with history off = %v, %v", revs, err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Fatalf("history off wrote %d entries", len(entries))
+	}
+}
+
+func TestRollback(t *testing.T) {
+	// A rollback is reading an old revision and recording it again, so it
+	// can itself be undone.
+	s := New(t.TempDir(), 0)
+	for _, body := range []string{"good", "bad"} {
+		if err := s.Record("N1", body); err != nil {
+			t.Fatalf("Record: %v", err)
+		}
+	}
+	revs, _ := s.List("N1")
+	old, err := s.Read("N1", revs[1].Hash)
+	if err != nil || old != "good" {
+		t.Fatalf("Read(oldest) = %q, %v", old, err)
+	}
+	if err := s.Record("N1", old); err != nil {
+		t.Fatalf("Record(rollback): %v", err)
+	}
+	if got, want := bodies(t, s, "N1"), []string{"good", "bad", "good"}; !slices.Equal(got, want) {
+		t.Fatalf("revisions after rollback = %q, want %q", got, want)
+	}
+}
+
+func TestRemove(t *testing.T) {
+	s := New(t.TempDir(), 0)
+	if err := s.Record("N1", "body"); err != nil {
+		t.Fatalf("Record: %v", err)
+	}
+	if err := s.Remove("N1"); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	if revs, err := s.List("N1"); err != nil || len(revs) != 0 {
+		t.Fatalf("List after Remove = %v, %v", revs, err)
+	}
+	if _, err := s.Read("N1", Hash("body")); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("Read after Remove = %v, want ErrNotExist", err)
+	}
+}
+
+func TestReadConfined(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "secret"), []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	s := New(filepath.Join(dir, "history"), 0)
+	if _, err := s.Read("N1", "../../secret"); err == nil {
+		t.Fatal("Read escaped the note's history directory")
+	}
+}
+
+// xorCipher stands in for vault.Key.
+type xorCipher struct{}
+
+func (xorCipher) Seal(plain []byte) ([]byte, error) { return xor(plain), nil }
+func (xorCipher) Open(data []byte) ([]byte, error)  { return xor(data), nil }
+
+func xor(b []byte) []byte {
+	out := make([]byte, len(b))
+	for i := range b {
+		out[i] = b[i] ^ 0x5a
+	}
+	return out
+}
+
+func TestRecrypt(t *testing.T) {
+	dir := t.TempDir()
+	plain := New(dir, 0)
+	if err := plain.Record("N1", "secret body"); err != nil {
+		t.Fatalf("Record: %v", err)
+	}
+
+	n, err := plain.Recrypt(xorCipher{})
+	if err != nil || n != 1 {
+		t.Fatalf("Recrypt = %d, %v; want 1", n, err)
+	}
+	raw, err := os.ReadFile(filepath.Join(dir, "N1", Hash("secret body")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(raw, []byte("secret")) {
+		t.Fatal("snapshot is still plain text after Recrypt")
+	}
+	sealed := New(dir, 0, WithCipher(xorCipher{}))
+	if got := bodies(t, sealed, "N1"); !slices.Equal(got, []string{"secret body"}) {
+		t.Fatalf("revisions read with the cipher = %q", got)
+	}
+
+	if n, err := sealed.Recrypt(nil); err != nil || n != 1 {
+		t.Fatalf("Recrypt back = %d, %v; want 1", n, err)
+	}
+	if got := bodies(t, New(dir, 0), "N1"); !slices.Equal(got, []string{"secret body"}) {
+		t.Fatalf("revisions after decrypting = %q", got)
+	}
+}
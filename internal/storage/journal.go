@@ -0,0 +1,111 @@
+package storage
+
+import (
+	"errors"
+	"time"
+
+	"github.com/internet-kid/tenote/internal/config"
+	"github.com/internet-kid/tenote/internal/storage/fs"
+	"github.com/internet-kid/tenote/internal/templates"
+)
+
+// DefaultJournalTemplate is the template journal notes are made from when
+// the config does not name one.
+const DefaultJournalTemplate = "journal"
+
+// JournalTitleLayout is how the default journal template titles a day.
+const JournalTitleLayout = "Monday, January 2, 2006"
+
+// defaultJournalBody is used when there is no journal template.
+const defaultJournalBody = "# {{title}}\n\n"
+
+// StartOfDay returns midnight at the start of t's day in the local time zone.
+func StartOfDay(t time.Time) time.Time {
+	t = t.In(time.Local)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local)
+}
+
+// JournalDay returns the day the journal note n is for: the day it was
+// created on according to its front matter, or else the day it last
+// changed.
+func JournalDay(n fs.Note) time.Time {
+	t := n.Created
+	if t.IsZero() {
+		t = n.UpdatedAt
+	}
+	if t.Location() == time.UTC && t.Equal(t.Truncate(24*time.Hour)) {
+		// A bare date in YAML is midnight UTC; it means that date
+		// wherever the note is read.
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local)
+	}
+	return StartOfDay(t)
+}
+
+// JournalEntry finds the journal note for day. When a day has several, the
+// most recently changed one is returned.
+func JournalEntry(store NoteStore, day time.Time) (fs.Note, bool, error) {
+	notes, err := store.List(fs.SectionJournal)
+	if err != nil {
+		return fs.Note{}, false, err
+	}
+	day = StartOfDay(day)
+	var entry fs.Note
+	found := false
+	for _, n := range notes {
+		if JournalDay(n).Equal(day) && (!found || n.UpdatedAt.After(entry.UpdatedAt)) {
+			entry, found = n, true
+		}
+	}
+	return entry, found, nil
+}
+
+// OpenJournal returns the journal note for day, creating it from the
+// journal template selected by cfg when there is none yet; created reports
+// whether it was. The template's {{title}} is the day, and any other field
+// gets its default.
+func OpenJournal(cfg config.AppConfig, store NoteStore, day time.Time) (n fs.Note, created bool, err error) {
+	if n, ok, err := JournalEntry(store, day); err != nil || ok {
+		return n, false, err
+	}
+	t, err := journalTemplate(cfg)
+	if err != nil {
+		return fs.Note{}, false, err
+	}
+
+	// The time of day is now's, for templates that use {{time}}.
+	now := time.Now()
+	day = StartOfDay(day)
+	at := time.Date(day.Year(), day.Month(), day.Day(), now.Hour(), now.Minute(), now.Second(), 0, time.Local)
+	body := t.Fill(at, map[string]string{"title": day.Format(JournalTitleLayout)})
+	meta, _ := fs.ParseFrontMatter(body)
+	meta.Created = at
+	body = fs.WithFrontMatter(body, meta)
+
+	if n, err = store.Create(fs.SectionJournal); err != nil {
+		return fs.Note{}, false, err
+	}
+	if err := store.WriteBody(n.Path, body); err != nil {
+		return fs.Note{}, false, err
+	}
+	meta, content := fs.ParseFrontMatter(body)
+	n.ApplyMeta(meta, content)
+	return n, true, nil
+}
+
+// journalTemplate reads the template journal notes are made from. A
+// missing default template is not an error; one named in the config is.
+func journalTemplate(cfg config.AppConfig) (templates.Template, error) {
+	name := cfg.JournalTemplate
+	if name == "" {
+		name = DefaultJournalTemplate
+	}
+	dir, err := TemplateDir(cfg)
+	if err != nil {
+		return templates.Template{}, err
+	}
+	t, err := templates.Find(dir, name)
+	if errors.Is(err, templates.ErrNotFound) && cfg.JournalTemplate == "" {
+		return templates.Template{Name: name, Body: defaultJournalBody}, nil
+	}
+	return t, err
+}
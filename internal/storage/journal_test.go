@@ -0,0 +1,165 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/internet-kid/tenote/internal/config"
+	"github.com/internet-kid/tenote/internal/storage/fs"
+	"github.com/internet-kid/tenote/internal/storage/memory"
+)
+
+// inZone runs the rest of the test with loc as the local time zone.
+func inZone(t *testing.T, loc *time.Location) {
+	t.Helper()
+	old := time.Local
+	time.Local = loc
+	t.Cleanup(func() { time.Local = old })
+}
+
+func TestJournalDay(t *testing.T) {
+	inZone(t, time.FixedZone("UTC-8", -8*60*60))
+	updated := time.Date(2026, 3, 2, 10, 0, 0, 0, time.Local)
+
+	tests := []struct {
+		name    string
+		created time.Time
+		want    time.Time
+	}{
+		{
+			name: "no created time",
+			want: time.Date(2026, 3, 2, 0, 0, 0, 0, time.Local),
+		},
+		{
+			name:    "created late in the evening",
+			created: time.Date(2026, 3, 1, 23, 30, 0, 0, time.Local),
+			want:    time.Date(2026, 3, 1, 0, 0, 0, 0, time.Local),
+		},
+		{
+			// 2026-03-01 in YAML; the previous evening here, but it means
+			// the first.
+			name:    "bare date",
+			created: time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC),
+			want:    time.Date(2026, 3, 1, 0, 0, 0, 0, time.Local),
+		},
+		{
+			name:    "time in UTC",
+			created: time.Date(2026, 3, 1, 6, 0, 0, 0, time.UTC),
+			want:    time.Date(2026, 2, 28, 0, 0, 0, 0, time.Local),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := JournalDay(fs.Note{Created: tt.created, UpdatedAt: updated})
+			if !got.Equal(tt.want) {
+				t.Fatalf("JournalDay = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// reversed lists notes in the opposite order, as a store sorted otherwise
+// would.
+type reversed struct{ *memory.Store }
+
+func (s reversed) List(section fs.Section) ([]fs.Note, error) {
+	notes, err := s.Store.List(section)
+	slices.Reverse(notes)
+	return notes, err
+}
+
+func TestJournalEntryLatest(t *testing.T) {
+	day := time.Date(2026, 10, 17, 0, 0, 0, 0, time.Local)
+	for _, store := range []NoteStore{memory.NewStore(), reversed{memory.NewStore()}} {
+		var latest fs.Note
+		for i, hour := range []int{9, 18, 12} {
+			n, err := store.Create(fs.SectionJournal)
+			if err != nil {
+				t.Fatalf("Create: %v", err)
+			}
+			at := day.Add(time.Duration(hour) * time.Hour)
+			if err := store.(Backdater).SetUpdatedAt(n.Path, at); err != nil {
+				t.Fatalf("SetUpdatedAt: %v", err)
+			}
+			if i == 1 {
+				latest = n
+			}
+		}
+		other, _ := store.Create(fs.SectionJournal)
+		_ = store.(Backdater).SetUpdatedAt(other.Path, day.AddDate(0, 0, 1))
+
+		n, ok, err := JournalEntry(store, day.Add(20*time.Hour))
+		if err != nil || !ok {
+			t.Fatalf("JournalEntry = %v, %v", ok, err)
+		}
+		if n.ID != latest.ID {
+			t.Fatalf("JournalEntry = %s at %v, want the latest %s", n.ID, n.UpdatedAt, latest.ID)
+		}
+		if _, ok, _ := JournalEntry(store, day.AddDate(0, 0, -1)); ok {
+			t.Fatal("JournalEntry found an entry for a day without one")
+		}
+	}
+}
+
+func TestOpenJournal(t *testing.T) {
+	day := time.Date(2026, 10, 17, 0, 0, 0, 0, time.Local)
+
+	t.Run("default body", func(t *testing.T) {
+		cfg := config.AppConfig{StorageDir: t.TempDir()}
+		store := memory.NewStore()
+		n, created, err := OpenJournal(cfg, store, day)
+		if err != nil || !created {
+			t.Fatalf("OpenJournal = %v, %v", created, err)
+		}
+		body, _ := store.ReadBody(n.Path)
+		meta, content := fs.ParseFrontMatter(body)
+		if content != "# Saturday, October 17, 2026\n\n" || !JournalDay(n).Equal(day) || !StartOfDay(meta.Created).Equal(day) {
+			t.Fatalf("entry = %+v, body %q", n, body)
+		}
+
+		again, created, err := OpenJournal(cfg, store, day.Add(23*time.Hour))
+		if err != nil || created || again.ID != n.ID {
+			t.Fatalf("OpenJournal again = %s, %v, %v; want %s", again.ID, created, err, n.ID)
+		}
+	})
+
+	t.Run("template", func(t *testing.T) {
+		cfg := config.AppConfig{StorageDir: t.TempDir()}
+		dir, _ := TemplateDir(cfg)
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		tmpl := "---\ntags: [journal]\n---\n# {{title}}\n\nMood: {{mood:fine}}\nWeek of {{date:Jan 2}}\n"
+		if err := os.WriteFile(filepath.Join(dir, DefaultJournalTemplate+".md"), []byte(tmpl), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		store := memory.NewStore()
+		n, _, err := OpenJournal(cfg, store, day)
+		if err != nil {
+			t.Fatalf("OpenJournal: %v", err)
+		}
+		body, _ := store.ReadBody(n.Path)
+		meta, content := fs.ParseFrontMatter(body)
+		if want := "# Saturday, October 17, 2026\n\nMood: fine\nWeek of Oct 17\n"; content != want {
+			t.Fatalf("content = %q, want %q", content, want)
+		}
+		if !slices.Equal(meta.Tags, []string{"journal"}) || meta.Created.IsZero() {
+			t.Fatalf("front matter = %+v", meta)
+		}
+	})
+
+	t.Run("missing named template", func(t *testing.T) {
+		cfg := config.AppConfig{StorageDir: t.TempDir(), JournalTemplate: "daily"}
+		store := memory.NewStore()
+		if _, _, err := OpenJournal(cfg, store, day); err == nil || !strings.Contains(err.Error(), "daily") {
+			t.Fatalf("OpenJournal = %v, want an error naming the template", err)
+		}
+		if notes, _ := store.List(fs.SectionJournal); len(notes) != 0 {
+			t.Fatalf("OpenJournal created %+v without its template", notes)
+		}
+	})
+}
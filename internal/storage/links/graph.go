@@ -0,0 +1,359 @@
+package links
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/internet-kid/tenote/internal/storage/fs"
+)
+
+const (
+	graphVersion = 1
+	graphPerm    = 0o644
+	graphDirPerm = 0o755
+)
+
+// Source is the part of a note store the graph reads from.
+// storage.NoteStore satisfies it.
+type Source interface {
+	Sections() ([]fs.Section, error)
+	List(section fs.Section) ([]fs.Note, error)
+	ReadBody(path string) (string, error)
+}
+
+// Store is a Source that can also save notes; see Graph.Write.
+type Store interface {
+	Source
+	WriteBody(path, body string) error
+}
+
+// node is what the graph remembers about a note. Targets are kept as
+// written and resolved on demand, so renaming one note never requires
+// touching the records of the notes linking to it.
+type node struct {
+	Title     string
+	Aliases   []string
+	Section   fs.Section
+	Path      string
+	UpdatedAt time.Time
+	Targets   []string
+}
+
+type graphFile struct {
+	Version int
+	Nodes   map[string]node
+}
+
+// Ref is an outgoing link target together with the note it resolves to.
+type Ref struct {
+	Target   string
+	Note     fs.Note
+	Resolved bool
+}
+
+// Graph is the link graph of a store. It is safe for concurrent use.
+type Graph struct {
+	mu   sync.Mutex
+	path string
+	data graphFile
+}
+
+// Open loads the graph stored at path. An empty path keeps it in memory
+// only. A missing or outdated file is not an error: the graph starts empty
+// and the next Sync rebuilds it.
+func Open(path string) *Graph {
+	g := &Graph{path: path, data: graphFile{Version: graphVersion, Nodes: make(map[string]node)}}
+	if path == "" {
+		return g
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return g
+	}
+	var data graphFile
+	if err := gob.NewDecoder(bytes.NewReader(b)).Decode(&data); err != nil || data.Version != graphVersion || data.Nodes == nil {
+		return g
+	}
+	g.data = data
+	return g
+}
+
+// Sync brings the graph up to date with src. Only notes whose modification
+// time changed since they were last seen are re-read.
+func (g *Graph) Sync(src Source) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	sections, err := src.Sections()
+	if err != nil {
+		return fmt.Errorf("list sections for link graph: %w", err)
+	}
+	seen := make(map[string]fs.Note)
+	for _, section := range sections {
+		notes, err := src.List(section)
+		if err != nil {
+			return fmt.Errorf("list %s for link graph: %w", section, err)
+		}
+		for _, n := range notes {
+			seen[n.ID] = n
+		}
+	}
+
+	changed := false
+	for id := range g.data.Nodes {
+		if _, ok := seen[id]; !ok {
+			delete(g.data.Nodes, id)
+			changed = true
+		}
+	}
+
+	for id, n := range seen {
+		old, ok := g.data.Nodes[id]
+		if ok && old.UpdatedAt.Equal(n.UpdatedAt) {
+			if old.Path != n.Path || old.Section != n.Section || old.Title != n.Title {
+				old.Path, old.Section, old.Title, old.Aliases = n.Path, n.Section, n.Title, n.Aliases
+				g.data.Nodes[id] = old
+				changed = true
+			}
+			continue
+		}
+
+		body, err := src.ReadBody(n.Path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("read note %s for link graph: %w", id, err)
+		}
+		g.update(n, body)
+		changed = true
+	}
+
+	if !changed {
+		return nil
+	}
+	return g.save()
+}
+
+// Update records the links of a note that was just saved with body.
+func (g *Graph) Update(n fs.Note, body string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.update(n, body)
+	return g.save()
+}
+
+func (g *Graph) update(n fs.Note, body string) {
+	var targets []string
+	for _, l := range Parse(body) {
+		targets = append(targets, l.Target)
+	}
+	g.data.Nodes[n.ID] = node{
+		Title:     n.Title,
+		Aliases:   n.Aliases,
+		Section:   n.Section,
+		Path:      n.Path,
+		UpdatedAt: n.UpdatedAt,
+		Targets:   targets,
+	}
+}
+
+// Write saves body to n through store and records its links. When the save
+// changes the title of a note that links resolved to by title, those links
+// are rewritten to the new title in every note that has them; see
+// fs.Update for notes saved meanwhile. Write returns the notes it rewrote.
+func (g *Graph) Write(store Store, n fs.Note, body string) ([]fs.Note, error) {
+	if err := store.WriteBody(n.Path, body); err != nil {
+		return nil, err
+	}
+
+	saved := n
+	meta, content := fs.ParseFrontMatter(body)
+	saved.ApplyMeta(meta, content)
+	// The store sets the real modification time; a zero one makes the next
+	// Sync pick it up.
+	saved.UpdatedAt = time.Time{}
+
+	g.mu.Lock()
+	renamed := !strings.EqualFold(n.Title, saved.Title) &&
+		n.Title != fs.DefaultNoteTitle &&
+		g.resolve(n.Title) == n.ID
+	g.update(saved, body)
+
+	var referrers []fs.Note
+	if renamed {
+		for id, nd := range g.data.Nodes {
+			if id != n.ID && hasTarget(nd.Targets, n.Title) {
+				referrers = append(referrers, nd.note(id))
+			}
+		}
+	}
+	err := g.save()
+	g.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	var rewritten []fs.Note
+	for _, r := range referrers {
+		var out string
+		ok, err := fs.Update(store, r.Path, func(body string) (string, bool) {
+			var changed bool
+			out, changed = Rewrite(body, n.Title, saved.Title)
+			return out, changed
+		})
+		if err != nil {
+			return rewritten, fmt.Errorf("rewrite links in %s: %w", r.ID, err)
+		}
+		if !ok {
+			continue
+		}
+		r.UpdatedAt = time.Time{}
+		if err := g.Update(r, out); err != nil {
+			return rewritten, err
+		}
+		rewritten = append(rewritten, r)
+	}
+	return rewritten, nil
+}
+
+// Resolve returns the note a link target points at. IDs win over titles,
+// titles over aliases; trashed notes are never link targets.
+func (g *Graph) Resolve(target string) (fs.Note, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	id := g.resolve(target)
+	if id == "" {
+		return fs.Note{}, false
+	}
+	return g.data.Nodes[id].note(id), true
+}
+
+func (g *Graph) resolve(target string) string {
+	target = strings.TrimSpace(target)
+	if nd, ok := g.data.Nodes[strings.ToUpper(target)]; ok && nd.Section != fs.SectionTrash {
+		return strings.ToUpper(target)
+	}
+
+	// Ties go to the lowest, i.e. oldest, ID so resolution is stable.
+	best := func(match func(nd node) bool) string {
+		found := ""
+		for id, nd := range g.data.Nodes {
+			if nd.Section != fs.SectionTrash && match(nd) && (found == "" || id < found) {
+				found = id
+			}
+		}
+		return found
+	}
+	if id := best(func(nd node) bool { return strings.EqualFold(nd.Title, target) }); id != "" {
+		return id
+	}
+	return best(func(nd node) bool { return hasTarget(nd.Aliases, target) })
+}
+
+// Outgoing returns the distinct link targets of a note in the order they
+// first appear.
+func (g *Graph) Outgoing(id string) []Ref {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	nd, ok := g.data.Nodes[id]
+	if !ok {
+		return nil
+	}
+	refs := make([]Ref, 0, len(nd.Targets))
+	seen := make(map[string]bool, len(nd.Targets))
+	for _, t := range nd.Targets {
+		if seen[strings.ToLower(t)] {
+			continue
+		}
+		seen[strings.ToLower(t)] = true
+		r := Ref{Target: t}
+		if to := g.resolve(t); to != "" {
+			r.Note, r.Resolved = g.data.Nodes[to].note(to), true
+		}
+		refs = append(refs, r)
+	}
+	return refs
+}
+
+// Backlinks returns the notes outside the trash that link to id, sorted by
+// title.
+func (g *Graph) Backlinks(id string) []fs.Note {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	var out []fs.Note
+	for from, nd := range g.data.Nodes {
+		if from == id || nd.Section == fs.SectionTrash {
+			continue
+		}
+		for _, t := range nd.Targets {
+			if g.resolve(t) == id {
+				out = append(out, nd.note(from))
+				break
+			}
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Title != out[j].Title {
+			return out[i].Title < out[j].Title
+		}
+		return out[i].ID < out[j].ID
+	})
+	return out
+}
+
+func (nd node) note(id string) fs.Note {
+	return fs.Note{
+		ID:        id,
+		Title:     nd.Title,
+		Aliases:   nd.Aliases,
+		Section:   nd.Section,
+		Path:      nd.Path,
+		UpdatedAt: nd.UpdatedAt,
+	}
+}
+
+func hasTarget(list []string, want string) bool {
+	for _, s := range list {
+		if strings.EqualFold(s, want) {
+			return true
+		}
+	}
+	return false
+}
+
+// save replaces the graph file atomically; see search.Index.
+func (g *Graph) save() error {
+	if g.path == "" {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(g.data); err != nil {
+		return fmt.Errorf("encode link graph: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(g.path), graphDirPerm); err != nil {
+		return fmt.Errorf("create link graph dir: %w", err)
+	}
+	tmp := g.path + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), graphPerm); err != nil {
+		return fmt.Errorf("write link graph %q: %w", tmp, err)
+	}
+	if err := os.Rename(tmp, g.path); err != nil {
+		return fmt.Errorf("replace link graph %q: %w", g.path, err)
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package links
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/internet-kid/tenote/internal/storage/fs"
+	"github.com/internet-kid/tenote/internal/storage/memory"
+	"github.com/internet-kid/tenote/internal/storage/memory/memtest"
+)
+
+func TestWriteRewritesReferrers(t *testing.T) {
+	mem := memory.NewStore()
+	target := memtest.NewNote(t, mem, "# Plan\n")
+	ref := memtest.NewNote(t, mem, "# Ref\nsee [[Plan]] and [[plan|the plan]]\n")
+
+	g := Open("")
+	if err := g.Sync(mem); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	rewritten, err := g.Write(mem, target, "# Roadmap\n")
+	if err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if len(rewritten) != 1 || rewritten[0].ID != ref.ID {
+		t.Fatalf("Write rewrote %+v, want %s", rewritten, ref.ID)
+	}
+	body, _ := mem.ReadBody(ref.Path)
+	if want := "# Ref\nsee [[Roadmap]] and [[Roadmap|the plan]]\n"; body != want {
+		t.Fatalf("referrer = %q, want %q", body, want)
+	}
+	if bl := g.Backlinks(target.ID); len(bl) != 1 || bl[0].ID != ref.ID {
+		t.Fatalf("Backlinks after rename = %+v", bl)
+	}
+}
+
+func TestWriteKeepsConcurrentEdit(t *testing.T) {
+	mem := memory.NewStore()
+	target := memtest.NewNote(t, mem, "# Plan\n")
+	ref := memtest.NewNote(t, mem, "# Ref\n[[Plan]]\n")
+	store := &memtest.RacingStore{Store: mem, Path: ref.Path, Races: 1}
+
+	g := Open("")
+	if err := g.Sync(store); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	if _, err := g.Write(store, target, "# Roadmap\n"); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	body, _ := mem.ReadBody(ref.Path)
+	if want := "# Ref\n[[Roadmap]]\nedited elsewhere\n"; body != want {
+		t.Fatalf("referrer = %q, want %q", body, want)
+	}
+}
+
+func TestWriteReportsBusyReferrer(t *testing.T) {
+	mem := memory.NewStore()
+	target := memtest.NewNote(t, mem, "# Plan\n")
+	ref := memtest.NewNote(t, mem, "# Ref\n[[Plan]]\n")
+	store := &memtest.RacingStore{Store: mem, Path: ref.Path, Races: 100}
+
+	g := Open("")
+	if err := g.Sync(store); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	_, err := g.Write(store, target, "# Roadmap\n")
+	if !errors.Is(err, fs.ErrConflict) || !strings.Contains(err.Error(), ref.ID) {
+		t.Fatalf("Write = %v, want a conflict naming %s", err, ref.ID)
+	}
+	body, _ := mem.ReadBody(ref.Path)
+	if strings.Contains(body, "[[Roadmap]]") || !strings.Contains(body, "edited elsewhere") {
+		t.Fatalf("referrer = %q, want the other edits and the old link", body)
+	}
+}
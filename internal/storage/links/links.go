@@ -0,0 +1,121 @@
+// Package links parses wiki-style [[links]] between notes and keeps a
+// persistent graph of them for backlinks and link following.
+//
+// A link names its target by note ID, title or alias, optionally followed
+// by the text to show instead:
+//
+//	[[Release checklist]]
+//	[[01J9Z6KX3M2Q|the checklist]]
+//
+// Links inside fenced code blocks are ignored.
+package links
+
+import (
+	"strings"
+)
+
+// Link is one [[...]] occurrence in a body.
+type Link struct {
+	Target string // what the link points at: an ID, title or alias
+	Alias  string // display text after "|", empty when absent
+
+	Start, End int // byte offsets of the whole [[...]] in the body
+	Line       int // zero-based line of the link
+}
+
+// Text returns what a reader sees for the link.
+func (l Link) Text() string {
+	if l.Alias != "" {
+		return l.Alias
+	}
+	return l.Target
+}
+
+// Parse returns the links in body in order of appearance.
+func Parse(body string) []Link {
+	var out []Link
+	forEachLine(body, func(line string, off, lineNo int) {
+		for i := 0; ; {
+			open := strings.Index(line[i:], "[[")
+			if open < 0 {
+				return
+			}
+			open += i
+			end := strings.Index(line[open+2:], "]]")
+			if end < 0 {
+				return
+			}
+			end += open + 2
+
+			inner := line[open+2 : end]
+			// "[[a [[b]]" links to b; skip to the innermost opening.
+			if j := strings.LastIndex(inner, "[["); j >= 0 {
+				open += j + 2
+				inner = line[open+2 : end]
+			}
+			if l, ok := parseInner(inner); ok {
+				l.Start, l.End, l.Line = off+open, off+end+2, lineNo
+				out = append(out, l)
+			}
+			i = end + 2
+		}
+	})
+	return out
+}
+
+func parseInner(inner string) (Link, bool) {
+	target, alias, _ := strings.Cut(inner, "|")
+	target = strings.TrimSpace(target)
+	if target == "" || strings.ContainsAny(target, "[]") {
+		return Link{}, false
+	}
+	return Link{Target: target, Alias: strings.TrimSpace(alias)}, true
+}
+
+// Rewrite points every link whose target is from, compared without regard
+// to case, at to. Aliases are kept. It reports whether body changed.
+func Rewrite(body, from, to string) (string, bool) {
+	var b strings.Builder
+	last, changed := 0, false
+	for _, l := range Parse(body) {
+		if !strings.EqualFold(l.Target, from) {
+			continue
+		}
+		changed = true
+		b.WriteString(body[last:l.Start])
+		b.WriteString("[[" + to)
+		if l.Alias != "" {
+			b.WriteString("|" + l.Alias)
+		}
+		b.WriteString("]]")
+		last = l.End
+	}
+	if !changed {
+		return body, false
+	}
+	b.WriteString(body[last:])
+	return b.String(), true
+}
+
+// forEachLine calls fn with every line of body outside fenced code blocks,
+// its byte offset and its zero-based line number.
+func forEachLine(body string, fn func(line string, off, lineNo int)) {
+	fence := ""
+	off := 0
+	for lineNo, line := range strings.Split(body, "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case fence != "":
+			if strings.HasPrefix(trimmed, fence) {
+				fence = ""
+			}
+		case strings.HasPrefix(trimmed, "```"):
+			fence = "```"
+		case strings.HasPrefix(trimmed, "~~~"):
+			fence = "~~~"
+		default:
+			fn(line, off, lineNo)
+		}
+		off += len(line) + 1
+	}
+}
@@ -0,0 +1,54 @@
+// Package memtest provides helpers for tests that run against a store in
+// memory. It is kept apart from storetest, which depends on the packages
+// whose tests use it.
+package memtest
+
+import (
+	"testing"
+
+	"github.com/internet-kid/tenote/internal/storage/fs"
+	"github.com/internet-kid/tenote/internal/storage/memory"
+)
+
+// RacingStore saves an edit to the note at Path right after each of the
+// first Races reads of it, as another tenote would.
+type RacingStore struct {
+	*memory.Store
+	Path  string
+	Races int
+}
+
+func (s *RacingStore) ReadVersion(path string) (string, fs.Version, error) {
+	body, v, err := s.Store.ReadVersion(path)
+	if err == nil && path == s.Path && s.Races > 0 {
+		s.Races--
+		if err := s.Store.WriteBody(path, body+"edited elsewhere\n"); err != nil {
+			return "", fs.Version{}, err
+		}
+	}
+	return body, v, err
+}
+
+// NewNote creates a note with body in the root notebook and returns it as
+// List does, with its title and metadata.
+func NewNote(t *testing.T, s *memory.Store, body string) fs.Note {
+	t.Helper()
+	n, err := s.Create(fs.SectionNotes)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if err := s.WriteBody(n.Path, body); err != nil {
+		t.Fatalf("WriteBody: %v", err)
+	}
+	notes, err := s.List(fs.SectionNotes)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	for _, m := range notes {
+		if m.ID == n.ID {
+			return m
+		}
+	}
+	t.Fatalf("note %s not listed", n.ID)
+	return fs.Note{}
+}
@@ -0,0 +1,363 @@
+// Package memory implements an in-process note store. Nothing is persisted;
+// it is meant for tests and for trying tenote out without touching disk.
+package memory
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+
+	"github.com/internet-kid/tenote/internal/storage/fs"
+)
+
+const (
+	pathPrefix   = "memory://"
+	noteTemplate = "# \n\n"
+)
+
+type entry struct {
+	note   fs.Note
+	body   string
+	origin fs.Section // notebook a trashed note came from
+}
+
+type Store struct {
+	mu        sync.Mutex
+	notes     map[string]*entry // keyed by path
+	notebooks map[fs.Section]bool
+	pins      map[string]bool // IDs of pinned notes
+}
+
+func NewStore() *Store {
+	return &Store{
+		notes:     make(map[string]*entry),
+		notebooks: make(map[fs.Section]bool),
+		pins:      make(map[string]bool),
+	}
+}
+
+func notePath(section fs.Section, id string) string {
+	return pathPrefix + string(section) + "/" + id
+}
+
+func (s *Store) Sections() ([]fs.Section, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	out := []fs.Section{fs.SectionNotes, fs.SectionJournal, fs.SectionTrash}
+	for nb := range s.notebooks {
+		out = append(out, nb)
+	}
+	fs.SortSections(out)
+	return out, nil
+}
+
+func (s *Store) Create(section fs.Section) (fs.Note, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.exists(section) {
+		return fs.Note{}, fmt.Errorf("create note in %q: %w", section, os.ErrNotExist)
+	}
+
+	id := ulid.Make().String()
+	n := fs.Note{
+		ID:        id,
+		Title:     fs.TitleFromBody(noteTemplate),
+		Path:      notePath(section, id),
+		Section:   section,
+		UpdatedAt: time.Now(),
+		Size:      int64(len(noteTemplate)),
+	}
+	s.notes[n.Path] = &entry{note: n, body: noteTemplate}
+	return n, nil
+}
+
+func (s *Store) List(section fs.Section) ([]fs.Note, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var notes []fs.Note
+	for _, e := range s.notes {
+		if e.note.Section == section {
+			n := e.note
+			n.Pinned = n.Pinned || s.pins[n.ID]
+			notes = append(notes, n)
+		}
+	}
+
+	fs.SortNotes(notes, fs.SortUpdated)
+	return notes, nil
+}
+
+func (s *Store) ReadBody(path string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.notes[path]
+	if !ok {
+		return "", fmt.Errorf("read note %q: %w", path, os.ErrNotExist)
+	}
+	return e.body, nil
+}
+
+func (s *Store) WriteBody(path, body string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.notes[path]
+	if !ok {
+		return fmt.Errorf("write note %q: %w", path, os.ErrNotExist)
+	}
+	e.write(body)
+	return nil
+}
+
+func (s *Store) ReadVersion(path string) (string, fs.Version, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.notes[path]
+	if !ok {
+		return "", fs.Version{}, fmt.Errorf("read note %q: %w", path, os.ErrNotExist)
+	}
+	return e.body, fs.VersionOf(e.body, e.note.UpdatedAt), nil
+}
+
+func (s *Store) WriteBodyIf(path, body string, base fs.Version) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.notes[path]
+	if !ok {
+		return fmt.Errorf("write note %q: %w", path, os.ErrNotExist)
+	}
+	if !fs.VersionOf(e.body, e.note.UpdatedAt).Matches(base) {
+		return fmt.Errorf("write note %q: %w", path, fs.ErrConflict)
+	}
+	e.write(body)
+	return nil
+}
+
+func (s *Store) SetUpdatedAt(path string, t time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.notes[path]
+	if !ok {
+		return fmt.Errorf("set time of note %q: %w", path, os.ErrNotExist)
+	}
+	e.note.UpdatedAt = t
+	return nil
+}
+
+func (e *entry) write(body string) {
+	e.body = body
+	meta, content := fs.ParseFrontMatter(body)
+	e.note.ApplyMeta(meta, content)
+	e.note.UpdatedAt = time.Now()
+	e.note.Size = int64(len(body))
+}
+
+func (s *Store) MoveToTrash(n fs.Note) (fs.Note, error) {
+	if n.Section == fs.SectionTrash {
+		return n, nil
+	}
+	moved, err := s.move(n, fs.SectionTrash)
+	if err != nil {
+		return fs.Note{}, fmt.Errorf("move note %q to trash: %w", n.Path, err)
+	}
+	return moved, nil
+}
+
+func (s *Store) RestoreFromTrash(n fs.Note, target fs.Section) (fs.Note, error) {
+	if n.Section != fs.SectionTrash {
+		return n, nil
+	}
+	if target == "" || target == fs.SectionTrash {
+		target = s.origin(n.Path)
+	}
+	if err := fs.ValidSection(target); err != nil {
+		return fs.Note{}, err
+	}
+	s.mu.Lock()
+	s.addNotebook(target)
+	s.mu.Unlock()
+
+	restored, err := s.move(n, target)
+	if err != nil {
+		return fs.Note{}, fmt.Errorf("restore note %q: %w", n.Path, err)
+	}
+	return restored, nil
+}
+
+func (s *Store) DeleteFromTrash(n fs.Note) error {
+	if n.Section != fs.SectionTrash {
+		return fmt.Errorf("delete from trash requires trash section, got %q", n.Section)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.notes[n.Path]; !ok {
+		return fmt.Errorf("delete note %q from trash: %w", n.Path, os.ErrNotExist)
+	}
+	delete(s.notes, n.Path)
+	delete(s.pins, n.ID)
+	return nil
+}
+
+// SetPinned pins n, or unpins it, and reports whether that changed
+// anything. A pinned key in the note's front matter pins it too.
+func (s *Store) SetPinned(n fs.Note, pinned bool) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.pins[n.ID] == pinned {
+		return false, nil
+	}
+	if pinned {
+		s.pins[n.ID] = true
+	} else {
+		delete(s.pins, n.ID)
+	}
+	return true, nil
+}
+
+// PurgeTrash deletes the notes trashed before cutoff and returns them.
+func (s *Store) PurgeTrash(cutoff time.Time) ([]fs.Note, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var purged []fs.Note
+	for path, e := range s.notes {
+		if e.note.Section == fs.SectionTrash && e.note.DeletedAt.Before(cutoff) {
+			delete(s.notes, path)
+			delete(s.pins, e.note.ID)
+			purged = append(purged, e.note)
+		}
+	}
+	return purged, nil
+}
+
+func (s *Store) move(n fs.Note, target fs.Section) (fs.Note, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.notes[n.Path]
+	if !ok {
+		return fs.Note{}, os.ErrNotExist
+	}
+	delete(s.notes, n.Path)
+
+	now := time.Now()
+	if target == fs.SectionTrash {
+		e.origin = e.note.Section
+		e.note.DeletedAt, e.note.Origin = now, e.note.Section
+	} else {
+		e.note.DeletedAt, e.note.Origin = time.Time{}, ""
+	}
+	e.note.Path = notePath(target, e.note.ID)
+	e.note.Section = target
+	e.note.UpdatedAt = now
+	s.notes[e.note.Path] = e
+	return e.note, nil
+}
+
+func (s *Store) CreateNotebook(parent fs.Section, name string) (fs.Section, error) {
+	if err := fs.ValidNotebookName(name); err != nil {
+		return "", err
+	}
+	if !parent.IsNotebook() {
+		return "", fmt.Errorf("notebooks can only be created inside notes, not %q", parent)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.exists(parent) {
+		return "", fmt.Errorf("create notebook in %q: %w", parent, os.ErrNotExist)
+	}
+	nb := fs.Notebook(parent.Rel() + "/" + name)
+	if s.notebooks[nb] {
+		return "", fmt.Errorf("create notebook %q: %w", nb.Rel(), os.ErrExist)
+	}
+	s.notebooks[nb] = true
+	return nb, nil
+}
+
+func (s *Store) DeleteNotebook(nb fs.Section) error {
+	if !nb.IsNotebook() || nb == fs.SectionNotes {
+		return fmt.Errorf("%q is not a removable notebook", nb)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.notebooks[nb] {
+		return fmt.Errorf("delete notebook %q: %w", nb.Rel(), os.ErrNotExist)
+	}
+	for sec := range s.notebooks {
+		if sec.Parent() == nb {
+			return fmt.Errorf("delete notebook %q: it is not empty", nb.Rel())
+		}
+	}
+	for _, e := range s.notes {
+		if e.note.Section == nb {
+			return fmt.Errorf("delete notebook %q: it is not empty", nb.Rel())
+		}
+	}
+	delete(s.notebooks, nb)
+	return nil
+}
+
+func (s *Store) Move(n fs.Note, target fs.Section) (fs.Note, error) {
+	if !target.IsNotebook() {
+		return fs.Note{}, fmt.Errorf("move target must be a notebook, got %q", target)
+	}
+	if n.Section == fs.SectionTrash {
+		return fs.Note{}, fmt.Errorf("restore note %s before moving it", n.ID)
+	}
+	if n.Section == target {
+		return n, nil
+	}
+
+	s.mu.Lock()
+	ok := s.exists(target)
+	s.mu.Unlock()
+	if !ok {
+		return fs.Note{}, fmt.Errorf("move note %q to %q: %w", n.Path, target.Rel(), os.ErrNotExist)
+	}
+
+	moved, err := s.move(n, target)
+	if err != nil {
+		return fs.Note{}, fmt.Errorf("move note %q to %q: %w", n.Path, target.Rel(), err)
+	}
+	return moved, nil
+}
+
+// exists reports whether section can hold notes. The caller holds s.mu.
+func (s *Store) exists(section fs.Section) bool {
+	return section == fs.SectionNotes || section == fs.SectionJournal || section == fs.SectionTrash || s.notebooks[section]
+}
+
+// addNotebook registers nb and its ancestors. The caller holds s.mu.
+func (s *Store) addNotebook(nb fs.Section) {
+	for nb.Depth() > 0 {
+		s.notebooks[nb] = true
+		nb = nb.Parent()
+	}
+}
+
+func (s *Store) origin(path string) fs.Section {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e, ok := s.notes[path]; ok && (e.origin.IsNotebook() || e.origin == fs.SectionJournal) {
+		return e.origin
+	}
+	return fs.SectionNotes
+}
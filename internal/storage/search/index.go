@@ -0,0 +1,238 @@
+// Package search maintains a persistent inverted index over the notes of a
+// store and answers phrase, prefix and boolean queries against it.
+package search
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/internet-kid/tenote/internal/storage/fs"
+	"github.com/internet-kid/tenote/internal/storage/tags"
+)
+
+const (
+	indexVersion = 2
+	indexPerm    = 0o644
+	indexDirPerm = 0o755
+)
+
+// Source is the part of a note store the index reads from.
+// storage.NoteStore satisfies it.
+type Source interface {
+	Sections() ([]fs.Section, error)
+	List(section fs.Section) ([]fs.Note, error)
+	ReadBody(path string) (string, error)
+}
+
+// doc is the per-note record kept in the index.
+type doc struct {
+	Section    fs.Section
+	Path       string
+	UpdatedAt  time.Time
+	Length     int
+	Terms      []string // distinct body terms, so removal need not scan every posting list
+	TitleTerms []string
+	Tags       []string // see tags.Of
+}
+
+// indexFile is the on-disk representation of an Index.
+type indexFile struct {
+	Version  int
+	Docs     map[string]doc
+	Postings map[string]map[string][]int // term -> note ID -> token positions
+}
+
+// Index is an inverted index of note bodies. It is safe for concurrent use.
+type Index struct {
+	mu   sync.Mutex
+	path string
+	data indexFile
+
+	// notes holds the listing seen by the last Sync so hits can carry the
+	// full note, including front matter fields that are not persisted.
+	notes map[string]fs.Note
+}
+
+// Open loads the index stored at path. An empty path keeps the index in
+// memory only. A missing, unreadable or outdated file is not an error: the
+// index starts empty and the next Sync rebuilds it.
+func Open(path string) *Index {
+	idx := &Index{path: path, notes: make(map[string]fs.Note)}
+	idx.reset()
+
+	if path == "" {
+		return idx
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return idx
+	}
+
+	var data indexFile
+	if err := gob.NewDecoder(bytes.NewReader(b)).Decode(&data); err != nil || data.Version != indexVersion {
+		return idx
+	}
+	if data.Docs == nil || data.Postings == nil {
+		return idx
+	}
+	idx.data = data
+	return idx
+}
+
+func (idx *Index) reset() {
+	idx.data = indexFile{
+		Version:  indexVersion,
+		Docs:     make(map[string]doc),
+		Postings: make(map[string]map[string][]int),
+	}
+}
+
+// Sync brings the index up to date with src. Only notes whose modification
+// time changed since they were last indexed are re-read; notes that no
+// longer exist are dropped. The index file is rewritten when anything
+// changed.
+func (idx *Index) Sync(src Source) error {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	sections, err := src.Sections()
+	if err != nil {
+		return fmt.Errorf("list sections for index: %w", err)
+	}
+	seen := make(map[string]fs.Note)
+	for _, section := range sections {
+		notes, err := src.List(section)
+		if err != nil {
+			return fmt.Errorf("list %s for index: %w", section, err)
+		}
+		for _, n := range notes {
+			seen[n.ID] = n
+		}
+	}
+
+	changed := false
+	for id := range idx.data.Docs {
+		if _, ok := seen[id]; !ok {
+			idx.remove(id)
+			changed = true
+		}
+	}
+
+	for id, n := range seen {
+		d, ok := idx.data.Docs[id]
+		if ok && d.UpdatedAt.Equal(n.UpdatedAt) {
+			if d.Path != n.Path || d.Section != n.Section {
+				d.Path = n.Path
+				d.Section = n.Section
+				idx.data.Docs[id] = d
+				changed = true
+			}
+			continue
+		}
+
+		body, err := src.ReadBody(n.Path)
+		if errors.Is(err, os.ErrNotExist) {
+			// Removed between List and ReadBody; the next Sync drops it.
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("index note %s: %w", id, err)
+		}
+		idx.add(n, body)
+		changed = true
+	}
+
+	idx.notes = seen
+
+	if !changed {
+		return nil
+	}
+	return idx.save()
+}
+
+// Len returns the number of indexed notes.
+func (idx *Index) Len() int {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	return len(idx.data.Docs)
+}
+
+func (idx *Index) add(n fs.Note, body string) {
+	idx.remove(n.ID)
+
+	toks := tokenize(body)
+	var terms []string
+	for pos, t := range toks {
+		docs := idx.data.Postings[t.term]
+		if docs == nil {
+			docs = make(map[string][]int)
+			idx.data.Postings[t.term] = docs
+		}
+		if _, ok := docs[n.ID]; !ok {
+			terms = append(terms, t.term)
+		}
+		docs[n.ID] = append(docs[n.ID], pos)
+	}
+
+	var titleTerms []string
+	for _, t := range tokenize(n.Title) {
+		titleTerms = append(titleTerms, t.term)
+	}
+
+	idx.data.Docs[n.ID] = doc{
+		Section:    n.Section,
+		Path:       n.Path,
+		UpdatedAt:  n.UpdatedAt,
+		Length:     len(toks),
+		Terms:      terms,
+		TitleTerms: titleTerms,
+		Tags:       tags.Of(n, body),
+	}
+}
+
+func (idx *Index) remove(id string) {
+	d, ok := idx.data.Docs[id]
+	if !ok {
+		return
+	}
+	delete(idx.data.Docs, id)
+	for _, term := range d.Terms {
+		docs := idx.data.Postings[term]
+		delete(docs, id)
+		if len(docs) == 0 {
+			delete(idx.data.Postings, term)
+		}
+	}
+}
+
+// save writes the index next to its final location and renames it into
+// place so a concurrent reader never sees a partial file.
+func (idx *Index) save() error {
+	if idx.path == "" {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(idx.data); err != nil {
+		return fmt.Errorf("encode search index: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(idx.path), indexDirPerm); err != nil {
+		return fmt.Errorf("create index dir: %w", err)
+	}
+	tmp := idx.path + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), indexPerm); err != nil {
+		return fmt.Errorf("write search index %q: %w", tmp, err)
+	}
+	if err := os.Rename(tmp, idx.path); err != nil {
+		return fmt.Errorf("replace search index %q: %w", idx.path, err)
+	}
+	return nil
+}
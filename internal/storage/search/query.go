@@ -0,0 +1,528 @@
+package search
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/internet-kid/tenote/internal/storage/fs"
+)
+
+// Query syntax:
+//
+//	word          notes containing word
+//	wor*          notes containing a word starting with "wor"
+//	"two words"   notes containing the exact phrase
+//	a b, a AND b  both
+//	a OR b        either
+//	-a, NOT a     notes without a
+//	( ... )       grouping
+//
+// Matching is case-insensitive.
+
+// Options narrows a search.
+type Options struct {
+	// Sections limits hits to the given sections. Empty means all.
+	Sections []fs.Section
+	// Limit caps the number of hits. Zero means no limit.
+	Limit int
+}
+
+// Hit is a note matching a query.
+type Hit struct {
+	Note  fs.Note
+	Score float64
+
+	// Snippet is a single-line excerpt around the first match, and
+	// Highlights are the byte ranges within it that matched.
+	Snippet    string
+	Highlights [][2]int
+
+	// Line is the zero-based line of the note body holding the first match.
+	Line int
+
+	// Terms are the indexed terms that made the note match.
+	Terms []string
+}
+
+// Search evaluates query against the index and returns hits ranked by
+// score, best first. Snippets are built by reading matching notes from src.
+func (idx *Index) Search(src Source, query string, opts Options) ([]Hit, error) {
+	expr, err := parseQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	if expr == nil {
+		return nil, nil
+	}
+
+	idx.mu.Lock()
+	res := expr.eval(idx)
+
+	var hits []Hit
+	for id, m := range res {
+		d := idx.data.Docs[id]
+		if !sectionAllowed(d.Section, opts.Sections) {
+			continue
+		}
+		n, ok := idx.notes[id]
+		if !ok {
+			n = fs.Note{ID: id, Title: id, Path: d.Path, Section: d.Section, UpdatedAt: d.UpdatedAt}
+		}
+		hits = append(hits, Hit{Note: n, Score: m.score, Terms: m.termList()})
+	}
+	idx.mu.Unlock()
+
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].Note.UpdatedAt.After(hits[j].Note.UpdatedAt)
+	})
+	if opts.Limit > 0 && len(hits) > opts.Limit {
+		hits = hits[:opts.Limit]
+	}
+
+	for i := range hits {
+		body, err := src.ReadBody(hits[i].Note.Path)
+		if err != nil {
+			continue
+		}
+		hits[i].Snippet, hits[i].Highlights, hits[i].Line = snippet(body, hits[i].Terms)
+	}
+
+	return hits, nil
+}
+
+func sectionAllowed(s fs.Section, allowed []fs.Section) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	for _, a := range allowed {
+		if a == s {
+			return true
+		}
+	}
+	return false
+}
+
+// ---------------------------------------------------------------------------
+// evaluation
+// ---------------------------------------------------------------------------
+
+type match struct {
+	score float64
+	terms map[string]struct{}
+}
+
+func (m match) termList() []string {
+	out := make([]string, 0, len(m.terms))
+	for t := range m.terms {
+		out = append(out, t)
+	}
+	sort.Strings(out)
+	return out
+}
+
+type result map[string]match
+
+type node interface {
+	eval(idx *Index) result
+}
+
+type termNode struct {
+	term   string
+	prefix bool
+}
+
+type phraseNode struct{ terms []string }
+
+type andNode struct{ left, right node }
+
+type orNode struct{ left, right node }
+
+type notNode struct{ inner node }
+
+func (n termNode) eval(idx *Index) result {
+	res := make(result)
+	terms := []string{n.term}
+	if n.prefix {
+		terms = terms[:0]
+		for t := range idx.data.Postings {
+			if strings.HasPrefix(t, n.term) {
+				terms = append(terms, t)
+			}
+		}
+	}
+
+	for _, t := range terms {
+		docs := idx.data.Postings[t]
+		idf := idx.idf(len(docs))
+		for id, pos := range docs {
+			m := res[id]
+			m.score += idx.weight(id, t, len(pos), idf)
+			m = m.with(t)
+			res[id] = m
+		}
+	}
+	return res
+}
+
+func (n phraseNode) eval(idx *Index) result {
+	res := make(result)
+	if len(n.terms) == 0 {
+		return res
+	}
+	first := idx.data.Postings[n.terms[0]]
+	for id, starts := range first {
+		count := 0
+		for _, start := range starts {
+			if idx.phraseAt(id, start, n.terms[1:]) {
+				count++
+			}
+		}
+		if count == 0 {
+			continue
+		}
+
+		// Score a phrase as the sum of its terms, weighted by how often
+		// the whole phrase occurs.
+		m := match{}
+		for _, t := range n.terms {
+			idf := idx.idf(len(idx.data.Postings[t]))
+			m.score += idx.weight(id, t, count, idf)
+			m = m.with(t)
+		}
+		res[id] = m
+	}
+	return res
+}
+
+func (idx *Index) phraseAt(id string, start int, rest []string) bool {
+	for i, t := range rest {
+		if !containsInt(idx.data.Postings[t][id], start+i+1) {
+			return false
+		}
+	}
+	return true
+}
+
+func (n andNode) eval(idx *Index) result {
+	l, r := n.left.eval(idx), n.right.eval(idx)
+	res := make(result)
+	for id, lm := range l {
+		if rm, ok := r[id]; ok {
+			res[id] = lm.merge(rm)
+		}
+	}
+	return res
+}
+
+func (n orNode) eval(idx *Index) result {
+	res := n.left.eval(idx)
+	for id, rm := range n.right.eval(idx) {
+		res[id] = res[id].merge(rm)
+	}
+	return res
+}
+
+func (n notNode) eval(idx *Index) result {
+	excluded := n.inner.eval(idx)
+	res := make(result)
+	for id := range idx.data.Docs {
+		if _, ok := excluded[id]; !ok {
+			res[id] = match{}
+		}
+	}
+	return res
+}
+
+func (m match) with(term string) match {
+	if m.terms == nil {
+		m.terms = make(map[string]struct{})
+	}
+	m.terms[term] = struct{}{}
+	return m
+}
+
+func (m match) merge(o match) match {
+	m.score += o.score
+	for t := range o.terms {
+		m = m.with(t)
+	}
+	return m
+}
+
+func (idx *Index) idf(df int) float64 {
+	return math.Log(1 + float64(len(idx.data.Docs))/float64(max(df, 1)))
+}
+
+// weight scores one term in one note: a saturating term frequency
+// normalised by note length, with a bonus when the term is in the title.
+func (idx *Index) weight(id, term string, tf int, idf float64) float64 {
+	const k1, b = 1.2, 0.75
+	d := idx.data.Docs[id]
+	avg := idx.avgLength()
+	norm := 1 - b + b*float64(d.Length)/avg
+	w := idf * float64(tf) * (k1 + 1) / (float64(tf) + k1*norm)
+	if containsString(d.TitleTerms, term) {
+		w *= 2
+	}
+	return w
+}
+
+func (idx *Index) avgLength() float64 {
+	if len(idx.data.Docs) == 0 {
+		return 1
+	}
+	total := 0
+	for _, d := range idx.data.Docs {
+		total += d.Length
+	}
+	return math.Max(1, float64(total)/float64(len(idx.data.Docs)))
+}
+
+func containsInt(xs []int, v int) bool {
+	i := sort.SearchInts(xs, v)
+	return i < len(xs) && xs[i] == v
+}
+
+func containsString(xs []string, v string) bool {
+	for _, x := range xs {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
+
+// ---------------------------------------------------------------------------
+// parsing
+// ---------------------------------------------------------------------------
+
+type tokKind int
+
+const (
+	tokWord tokKind = iota
+	tokPhrase
+	tokAnd
+	tokOr
+	tokNot
+	tokOpen
+	tokClose
+)
+
+type qtoken struct {
+	kind tokKind
+	text string
+}
+
+func lexQuery(q string) ([]qtoken, error) {
+	var toks []qtoken
+	rs := []rune(q)
+	for i := 0; i < len(rs); {
+		r := rs[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(':
+			toks = append(toks, qtoken{kind: tokOpen})
+			i++
+		case r == ')':
+			toks = append(toks, qtoken{kind: tokClose})
+			i++
+		case r == '-':
+			toks = append(toks, qtoken{kind: tokNot})
+			i++
+		case r == '"':
+			end := i + 1
+			for end < len(rs) && rs[end] != '"' {
+				end++
+			}
+			if end == len(rs) {
+				return nil, fmt.Errorf("unterminated phrase in query")
+			}
+			toks = append(toks, qtoken{kind: tokPhrase, text: string(rs[i+1 : end])})
+			i = end + 1
+		default:
+			end := i
+			for end < len(rs) && !unicode.IsSpace(rs[end]) && !strings.ContainsRune(`()"`, rs[end]) {
+				end++
+			}
+			word := string(rs[i:end])
+			switch word {
+			case "AND":
+				toks = append(toks, qtoken{kind: tokAnd})
+			case "OR":
+				toks = append(toks, qtoken{kind: tokOr})
+			case "NOT":
+				toks = append(toks, qtoken{kind: tokNot})
+			default:
+				toks = append(toks, qtoken{kind: tokWord, text: word})
+			}
+			i = end
+		}
+	}
+	return toks, nil
+}
+
+type parser struct {
+	toks []qtoken
+	pos  int
+}
+
+// parseQuery returns nil when q holds no searchable terms.
+func parseQuery(q string) (node, error) {
+	toks, err := lexQuery(q)
+	if err != nil || len(toks) == 0 {
+		return nil, err
+	}
+	p := &parser{toks: toks}
+	n, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.toks) {
+		return nil, fmt.Errorf("unexpected %q in query", p.describe(p.toks[p.pos]))
+	}
+	return n, nil
+}
+
+func (p *parser) peek() (qtoken, bool) {
+	if p.pos >= len(p.toks) {
+		return qtoken{}, false
+	}
+	return p.toks[p.pos], true
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t, ok := p.peek()
+		if !ok || t.kind != tokOr {
+			return left, nil
+		}
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = combine(left, right, func(l, r node) node { return orNode{l, r} })
+	}
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t, ok := p.peek()
+		if !ok || t.kind == tokOr || t.kind == tokClose {
+			return left, nil
+		}
+		if t.kind == tokAnd {
+			p.pos++
+		}
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = combine(left, right, func(l, r node) node { return andNode{l, r} })
+	}
+}
+
+func (p *parser) parseUnary() (node, error) {
+	t, ok := p.peek()
+	if !ok {
+		return nil, fmt.Errorf("query ends unexpectedly")
+	}
+	switch t.kind {
+	case tokNot:
+		p.pos++
+		inner, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		if inner == nil {
+			return nil, nil
+		}
+		return notNode{inner}, nil
+	case tokOpen:
+		p.pos++
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if t, ok := p.peek(); !ok || t.kind != tokClose {
+			return nil, fmt.Errorf("missing ) in query")
+		}
+		p.pos++
+		return inner, nil
+	case tokPhrase:
+		p.pos++
+		var terms []string
+		for _, tok := range tokenize(t.text) {
+			terms = append(terms, tok.term)
+		}
+		if len(terms) == 0 {
+			return nil, nil
+		}
+		if len(terms) == 1 {
+			return termNode{term: terms[0]}, nil
+		}
+		return phraseNode{terms: terms}, nil
+	case tokWord:
+		p.pos++
+		prefix := strings.HasSuffix(t.text, "*")
+		toks := tokenize(strings.TrimSuffix(t.text, "*"))
+		if len(toks) == 0 {
+			return nil, nil
+		}
+		if len(toks) > 1 {
+			// "foo-bar" or "foo.bar" index as separate words.
+			terms := make([]string, len(toks))
+			for i, tok := range toks {
+				terms[i] = tok.term
+			}
+			return phraseNode{terms: terms}, nil
+		}
+		return termNode{term: toks[0].term, prefix: prefix}, nil
+	default:
+		return nil, fmt.Errorf("unexpected %q in query", p.describe(t))
+	}
+}
+
+func (p *parser) describe(t qtoken) string {
+	switch t.kind {
+	case tokAnd:
+		return "AND"
+	case tokOr:
+		return "OR"
+	case tokNot:
+		return "NOT"
+	case tokOpen:
+		return "("
+	case tokClose:
+		return ")"
+	default:
+		return t.text
+	}
+}
+
+// combine joins two subqueries, dropping either side that had no terms.
+func combine(l, r node, join func(l, r node) node) node {
+	switch {
+	case l == nil:
+		return r
+	case r == nil:
+		return l
+	default:
+		return join(l, r)
+	}
+}
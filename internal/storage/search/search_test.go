@@ -0,0 +1,334 @@
+package search
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/internet-kid/tenote/internal/storage/fs"
+	"github.com/internet-kid/tenote/internal/storage/memory"
+)
+
+// countingSource counts the bodies an index reads.
+type countingSource struct {
+	*memory.Store
+	reads int
+}
+
+func (s *countingSource) ReadBody(path string) (string, error) {
+	s.reads++
+	return s.Store.ReadBody(path)
+}
+
+// newCorpus returns a store holding a note per body, in notes unless the
+// body is keyed by another section.
+func newCorpus(t *testing.T, bodies map[string]fs.Section) *countingSource {
+	t.Helper()
+	s := &countingSource{Store: memory.NewStore()}
+	for body, sec := range bodies {
+		if sec == "" {
+			sec = fs.SectionNotes
+		}
+		n, err := s.Create(sec)
+		if err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+		if err := s.WriteBody(n.Path, body); err != nil {
+			t.Fatalf("WriteBody: %v", err)
+		}
+	}
+	return s
+}
+
+func titles(hits []Hit) []string {
+	out := make([]string, 0, len(hits))
+	for _, h := range hits {
+		out = append(out, h.Note.Title)
+	}
+	return out
+}
+
+func TestSearchQueries(t *testing.T) {
+	src := newCorpus(t, map[string]fs.Section{
+		"# Release plan\nShip the release on Friday.\n":      "",
+		"# Groceries\nmilk, eggs and bread\n":                "",
+		"# Plan for the garden\nplant tomatoes in spring\n":  "",
+		"# Draft release notes\nplan: draft, then publish\n": "",
+		"# Old plan\nthe release plan we dropped\n":          fs.SectionTrash,
+	})
+	idx := Open("")
+	if err := idx.Sync(src); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+
+	tests := []struct {
+		query string
+		opts  Options
+		want  []string
+	}{
+		{query: "milk", want: []string{"Groceries"}},
+		{query: "MILK", want: []string{"Groceries"}},
+		{query: "missing", want: nil},
+		{query: "plan", opts: Options{Sections: []fs.Section{fs.SectionNotes}}, want: []string{"Draft release notes", "Plan for the garden", "Release plan"}},
+		{query: "plan", want: []string{"Draft release notes", "Old plan", "Plan for the garden", "Release plan"}},
+		{query: "pla*", opts: Options{Sections: []fs.Section{fs.SectionNotes}}, want: []string{"Draft release notes", "Plan for the garden", "Release plan"}},
+		{query: "tomat*", want: []string{"Plan for the garden"}},
+		{query: `"release plan"`, want: []string{"Old plan", "Release plan"}},
+		{query: `"plan release"`, want: nil},
+		{query: "release plan", want: []string{"Draft release notes", "Old plan", "Release plan"}},
+		{query: "release AND garden", want: nil},
+		{query: "milk OR tomatoes", want: []string{"Groceries", "Plan for the garden"}},
+		{query: "plan -release", want: []string{"Plan for the garden"}},
+		{query: "plan NOT draft", want: []string{"Old plan", "Plan for the garden", "Release plan"}},
+		{query: "(milk OR garden) -eggs", want: []string{"Plan for the garden"}},
+		{query: "release (friday OR publish)", want: []string{"Draft release notes", "Release plan"}},
+		{query: "-plan", want: []string{"Groceries"}},
+		// "release-notes" indexes as two words, so it reads as a phrase.
+		{query: "release-notes", want: []string{"Draft release notes"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			hits, err := idx.Search(src, tt.query, tt.opts)
+			if err != nil {
+				t.Fatalf("Search: %v", err)
+			}
+			got := titles(hits)
+			sort.Strings(got)
+			if !slices.Equal(got, tt.want) {
+				t.Fatalf("Search(%q) = %q, want %q", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseQuery(t *testing.T) {
+	tests := []struct {
+		query   string
+		want    node
+		wantErr string
+	}{
+		{query: "", want: nil},
+		{query: "   ", want: nil},
+		{query: "***", want: nil},
+		{query: "word", want: termNode{term: "word"}},
+		{query: "Wor*", want: termNode{term: "wor", prefix: true}},
+		{query: `"one two"`, want: phraseNode{terms: []string{"one", "two"}}},
+		{query: `"one"`, want: termNode{term: "one"}},
+		{query: "a b", want: andNode{termNode{term: "a"}, termNode{term: "b"}}},
+		{query: "a AND b", want: andNode{termNode{term: "a"}, termNode{term: "b"}}},
+		{query: "a OR b c", want: orNode{termNode{term: "a"}, andNode{termNode{term: "b"}, termNode{term: "c"}}}},
+		{query: "(a OR b) c", want: andNode{orNode{termNode{term: "a"}, termNode{term: "b"}}, termNode{term: "c"}}},
+		{query: "-a", want: notNode{termNode{term: "a"}}},
+		{query: "NOT a", want: notNode{termNode{term: "a"}}},
+		{query: "a -b", want: andNode{termNode{term: "a"}, notNode{termNode{term: "b"}}}},
+		{query: "or and", want: andNode{termNode{term: "or"}, termNode{term: "and"}}},
+		{query: `"unterminated`, wantErr: "unterminated phrase"},
+		{query: "(a OR b", wantErr: "missing )"},
+		{query: "a)", wantErr: `unexpected ")"`},
+		{query: "a OR", wantErr: "query ends unexpectedly"},
+		{query: "OR a", wantErr: `unexpected "OR"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			got, err := parseQuery(tt.query)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("parseQuery(%q) error = %v, want %q", tt.query, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseQuery(%q): %v", tt.query, err)
+			}
+			if !nodeEqual(got, tt.want) {
+				t.Fatalf("parseQuery(%q) = %#v, want %#v", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+// nodeEqual compares query trees; nodes hold slices, so == does not do.
+func nodeEqual(a, b node) bool {
+	switch a := a.(type) {
+	case nil:
+		return b == nil
+	case termNode:
+		b, ok := b.(termNode)
+		return ok && a == b
+	case phraseNode:
+		b, ok := b.(phraseNode)
+		return ok && slices.Equal(a.terms, b.terms)
+	case andNode:
+		b, ok := b.(andNode)
+		return ok && nodeEqual(a.left, b.left) && nodeEqual(a.right, b.right)
+	case orNode:
+		b, ok := b.(orNode)
+		return ok && nodeEqual(a.left, b.left) && nodeEqual(a.right, b.right)
+	case notNode:
+		b, ok := b.(notNode)
+		return ok && nodeEqual(a.inner, b.inner)
+	}
+	return false
+}
+
+func TestSearchScoring(t *testing.T) {
+	src := newCorpus(t, map[string]fs.Section{
+		"# Budget\nnumbers for next year\n":                              "",
+		"# Meeting\nwe talked about the budget once\n":                   "",
+		"# Notes\nbudget budget budget, the budget is over the budget\n": "",
+		"# Filler\nnothing to see here at all\n":                         "",
+	})
+	idx := Open("")
+	if err := idx.Sync(src); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+
+	hits, err := idx.Search(src, "budget", Options{})
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	// A title match outweighs repetition, and repetition outweighs a
+	// single mention.
+	want := []string{"Budget", "Notes", "Meeting"}
+	if got := titles(hits); !slices.Equal(got, want) {
+		t.Fatalf("ranking = %q, want %q", got, want)
+	}
+	for i := 1; i < len(hits); i++ {
+		if hits[i].Score > hits[i-1].Score {
+			t.Fatalf("hits not sorted by score: %v", hits)
+		}
+	}
+
+	limited, err := idx.Search(src, "budget", Options{Limit: 1})
+	if err != nil || len(limited) != 1 || limited[0].Note.Title != "Budget" {
+		t.Fatalf("Search with Limit 1 = %q, %v", titles(limited), err)
+	}
+}
+
+func TestSearchSnippet(t *testing.T) {
+	body := "# Trip\n\nFirst line.\nWe booked the Hotel near the hotel bar.\n"
+	src := newCorpus(t, map[string]fs.Section{body: ""})
+	idx := Open("")
+	if err := idx.Sync(src); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+
+	hits, err := idx.Search(src, "hotel", Options{})
+	if err != nil || len(hits) != 1 {
+		t.Fatalf("Search = %v, %v", hits, err)
+	}
+	h := hits[0]
+	if h.Line != 3 {
+		t.Fatalf("Line = %d, want 3", h.Line)
+	}
+	if strings.Contains(h.Snippet, "\n") {
+		t.Fatalf("Snippet spans lines: %q", h.Snippet)
+	}
+	if len(h.Highlights) != 2 {
+		t.Fatalf("Highlights = %v, want 2", h.Highlights)
+	}
+	for _, r := range h.Highlights {
+		if got := h.Snippet[r[0]:r[1]]; !strings.EqualFold(got, "hotel") {
+			t.Fatalf("highlight %v covers %q", r, got)
+		}
+	}
+}
+
+func TestIndexPersistence(t *testing.T) {
+	src := newCorpus(t, map[string]fs.Section{
+		"# Alpha\nfirst note\n": "",
+		"# Beta\nsecond note\n": "",
+	})
+	path := filepath.Join(t.TempDir(), "search.idx")
+
+	idx := Open(path)
+	if err := idx.Sync(src); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	if src.reads != 2 {
+		t.Fatalf("first Sync read %d notes, want 2", src.reads)
+	}
+
+	// Reopened, the index answers from the file and re-reads nothing.
+	idx = Open(path)
+	if idx.Len() != 2 {
+		t.Fatalf("reopened index holds %d notes, want 2", idx.Len())
+	}
+	hits, err := idx.Search(src, "second", Options{})
+	if err != nil || len(hits) != 1 {
+		t.Fatalf("Search before Sync = %v, %v", hits, err)
+	}
+	src.reads = 0
+	if err := idx.Sync(src); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	if src.reads != 0 {
+		t.Fatalf("Sync of unchanged notes read %d notes, want 0", src.reads)
+	}
+
+	// Only the changed note is read again; the removed one is dropped.
+	notes, err := src.List(fs.SectionNotes)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	var alpha, beta fs.Note
+	for _, n := range notes {
+		if n.Title == "Alpha" {
+			alpha = n
+		} else {
+			beta = n
+		}
+	}
+	if err := src.WriteBody(alpha.Path, "# Alpha\nrewritten\n"); err != nil {
+		t.Fatalf("WriteBody: %v", err)
+	}
+	trashed, err := src.MoveToTrash(beta)
+	if err != nil {
+		t.Fatalf("MoveToTrash: %v", err)
+	}
+	if err := src.DeleteFromTrash(trashed); err != nil {
+		t.Fatalf("DeleteFromTrash: %v", err)
+	}
+	src.reads = 0
+	if err := idx.Sync(src); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	if src.reads != 1 {
+		t.Fatalf("Sync after one change read %d notes, want 1", src.reads)
+	}
+
+	idx = Open(path)
+	if idx.Len() != 1 {
+		t.Fatalf("index holds %d notes, want 1", idx.Len())
+	}
+	for query, want := range map[string]int{"rewritten": 1, "first": 0, "second": 0} {
+		hits, err := idx.Search(src, query, Options{})
+		if err != nil || len(hits) != want {
+			t.Fatalf("Search(%q) = %d hits, %v; want %d", query, len(hits), err, want)
+		}
+	}
+}
+
+func TestOpenDiscardsBadIndex(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "search.idx")
+	if err := os.WriteFile(path, []byte("not a gob"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	idx := Open(path)
+	if idx.Len() != 0 {
+		t.Fatalf("corrupt index opened with %d notes", idx.Len())
+	}
+
+	src := newCorpus(t, map[string]fs.Section{"# One\n": ""})
+	if err := idx.Sync(src); err != nil {
+		t.Fatalf("Sync over a corrupt index: %v", err)
+	}
+	if Open(path).Len() != 1 {
+		t.Fatal("Sync did not replace the corrupt index")
+	}
+}
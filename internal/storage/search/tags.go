@@ -0,0 +1,61 @@
+package search
+
+import (
+	"sort"
+
+	"github.com/internet-kid/tenote/internal/storage/fs"
+	"github.com/internet-kid/tenote/internal/storage/tags"
+)
+
+// Tags returns every tag used by a note outside the trash with the number
+// of such notes, sorted by name.
+func (idx *Index) Tags() []tags.Count {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	counts := make(map[string]int)
+	for _, d := range idx.data.Docs {
+		if d.Section == fs.SectionTrash {
+			continue
+		}
+		for _, t := range d.Tags {
+			counts[t]++
+		}
+	}
+
+	out := make([]tags.Count, 0, len(counts))
+	for name, n := range counts {
+		out = append(out, tags.Count{Name: name, Notes: n})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// Tagged returns the notes outside the trash whose tags match want, as
+// tags.Match decides, most recently updated first. Notes are taken from
+// the listing seen by the last Sync.
+func (idx *Index) Tagged(want []string, anyOf bool) []fs.Note {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	var out []fs.Note
+	for id, d := range idx.data.Docs {
+		if d.Section == fs.SectionTrash || !tags.Match(d.Tags, want, anyOf) {
+			continue
+		}
+		n, ok := idx.notes[id]
+		if !ok {
+			continue
+		}
+		out = append(out, n)
+	}
+	fs.SortNotes(out, fs.SortUpdated)
+	return out
+}
+
+// NoteTags returns the indexed tags of a note.
+func (idx *Index) NoteTags(id string) []string {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	return idx.data.Docs[id].Tags
+}
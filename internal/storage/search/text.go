@@ -0,0 +1,118 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	snippetBefore = 30
+	snippetAfter  = 90
+)
+
+type token struct {
+	term       string
+	start, end int // byte offsets in the source text
+}
+
+// tokenize splits s into lower-cased runs of letters and digits.
+func tokenize(s string) []token {
+	var toks []token
+	start := -1
+	for i, r := range s {
+		word := unicode.IsLetter(r) || unicode.IsDigit(r)
+		switch {
+		case word && start < 0:
+			start = i
+		case !word && start >= 0:
+			toks = append(toks, token{term: strings.ToLower(s[start:i]), start: start, end: i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		toks = append(toks, token{term: strings.ToLower(s[start:]), start: start, end: len(s)})
+	}
+	return toks
+}
+
+// snippet returns a one-line excerpt of body around the first occurrence of
+// any of terms, the byte ranges of every occurrence inside the excerpt, and
+// the zero-based body line of the first occurrence.
+func snippet(body string, terms []string) (string, [][2]int, int) {
+	want := make(map[string]struct{}, len(terms))
+	for _, t := range terms {
+		want[t] = struct{}{}
+	}
+
+	toks := tokenize(body)
+	first := -1
+	for i, t := range toks {
+		if _, ok := want[t.term]; ok {
+			first = i
+			break
+		}
+	}
+	if first < 0 {
+		return "", nil, 0
+	}
+
+	hit := toks[first]
+	line := strings.Count(body[:hit.start], "\n")
+
+	from := runeBoundary(body, max(0, hit.start-snippetBefore))
+	to := runeBoundary(body, min(len(body), hit.end+snippetAfter))
+
+	var b strings.Builder
+	var highlights [][2]int
+	if from > 0 {
+		b.WriteString("…")
+	}
+	cursor := from
+	for _, t := range toks[first:] {
+		if t.end > to {
+			break
+		}
+		if _, ok := want[t.term]; !ok {
+			continue
+		}
+		b.WriteString(flatten(body[cursor:t.start]))
+		start := b.Len()
+		b.WriteString(body[t.start:t.end])
+		highlights = append(highlights, [2]int{start, b.Len()})
+		cursor = t.end
+	}
+	b.WriteString(flatten(body[cursor:to]))
+	if to < len(body) {
+		b.WriteString("…")
+	}
+
+	return b.String(), highlights, line
+}
+
+// flatten collapses whitespace runs, including newlines, to single spaces so
+// a snippet always fits on one line.
+func flatten(s string) string {
+	var b strings.Builder
+	space := false
+	for _, r := range s {
+		if unicode.IsSpace(r) {
+			if !space {
+				b.WriteByte(' ')
+			}
+			space = true
+			continue
+		}
+		space = false
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// runeBoundary moves i back to the start of the rune it points into.
+func runeBoundary(s string, i int) int {
+	for i > 0 && i < len(s) && !utf8.RuneStart(s[i]) {
+		i--
+	}
+	return i
+}
@@ -0,0 +1,522 @@
+// Package storage defines the contract shared by all note backends and
+// picks the backend configured in config.AppConfig.
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/internet-kid/tenote/internal/config"
+	"github.com/internet-kid/tenote/internal/storage/fs"
+	"github.com/internet-kid/tenote/internal/storage/gitstore"
+	"github.com/internet-kid/tenote/internal/storage/history"
+	"github.com/internet-kid/tenote/internal/storage/links"
+	"github.com/internet-kid/tenote/internal/storage/memory"
+	"github.com/internet-kid/tenote/internal/storage/search"
+	"github.com/internet-kid/tenote/internal/storage/vault"
+	"github.com/internet-kid/tenote/internal/storage/watch"
+)
+
+const (
+	BackendFS     = "fs"
+	BackendGit    = "git"
+	BackendMemory = "memory"
+)
+
+// Files under the meta directory that hold data derived from note
+// content. Encrypted stores keep them in memory instead.
+const (
+	indexFile = "search.idx"
+	linksFile = "links.idx"
+	listFile  = "list.db"
+)
+
+// External edits of an encrypted store are made in a private directory
+// under its meta directory; see EditDir.
+const (
+	editDir        = "edit"
+	privateDirPerm = 0o700
+)
+
+// NoteStore is implemented by every note backend. Notes are addressed by
+// their Path, which is opaque to callers and only meaningful to the backend
+// that produced it.
+//
+// Sections lists the root notebook first, then any nested notebooks in
+// tree order, then the trash. RestoreFromTrash with an empty or trash
+// target puts the note back in the notebook it was trashed from.
+type NoteStore interface {
+	Sections() ([]fs.Section, error)
+	Create(section fs.Section) (fs.Note, error)
+	List(section fs.Section) ([]fs.Note, error)
+	ReadBody(path string) (string, error)
+	WriteBody(path, body string) error
+	MoveToTrash(n fs.Note) (fs.Note, error)
+	RestoreFromTrash(n fs.Note, target fs.Section) (fs.Note, error)
+	DeleteFromTrash(n fs.Note) error
+}
+
+// Historian is implemented by backends that record a revision on every
+// save. Restoring a revision is a WriteBody of its content, which is
+// itself recorded, so a rollback can be undone.
+type Historian interface {
+	Revisions(n fs.Note) ([]history.Revision, error)
+	ReadRevision(n fs.Note, hash string) (string, error)
+}
+
+// Notebooks is implemented by backends that support nested notebooks.
+type Notebooks interface {
+	CreateNotebook(parent fs.Section, name string) (fs.Section, error)
+	DeleteNotebook(nb fs.Section) error
+	Move(n fs.Note, target fs.Section) (fs.Note, error)
+}
+
+// Versioned is implemented by backends that can refuse to save over a
+// change made since the note was read, by another tenote, a sync tool or
+// an editor. WriteBodyIf fails with fs.ErrConflict in that case.
+type Versioned interface {
+	ReadVersion(path string) (string, fs.Version, error)
+	WriteBodyIf(path, body string, base fs.Version) error
+}
+
+// Recoverer is implemented by backends that a crash can leave with
+// unfinished writes. Recover cleans them up when opening the store and
+// returns the files it kept because they may hold unsaved edits.
+type Recoverer interface {
+	Recover() ([]string, error)
+}
+
+// Purger is implemented by backends that record when notes were trashed.
+// PurgeTrash deletes the notes trashed before cutoff for good and returns
+// them.
+type Purger interface {
+	PurgeTrash(cutoff time.Time) ([]fs.Note, error)
+}
+
+// Drafter is implemented by backends that keep the unsaved text of notes
+// being edited, so an edit a crash interrupted can be recovered on the
+// next start.
+type Drafter interface {
+	SaveDraft(n fs.Note, body string) error
+	DeleteDraft(n fs.Note) error
+	Drafts() ([]fs.Draft, error)
+}
+
+// Backdater is implemented by backends that can set when a note was last
+// changed, so notes imported from elsewhere keep their own times.
+type Backdater interface {
+	SetUpdatedAt(path string, t time.Time) error
+}
+
+// Syncer is implemented by backends that exchange notes with a remote.
+// Notes changed on both sides are returned as conflicts, not errors.
+type Syncer interface {
+	Sync() (gitstore.SyncResult, error)
+}
+
+// Pinner is implemented by backends that keep which notes are pinned to
+// the top of their notebook apart from the notes. SetPinned reports
+// whether the pin changed.
+type Pinner interface {
+	SetPinned(n fs.Note, pinned bool) (bool, error)
+}
+
+// Refresher is implemented by backends that remember what they list.
+// Refresh tells them the note files or directories at paths changed
+// outside the store, as reported by a watch.Event; no paths means any may
+// have.
+type Refresher interface {
+	Refresh(paths []string)
+}
+
+var (
+	_ NoteStore = (*fs.Store)(nil)
+	_ NoteStore = (*gitstore.Store)(nil)
+	_ NoteStore = (*memory.Store)(nil)
+	_ Historian = (*fs.Store)(nil)
+	_ Historian = (*gitstore.Store)(nil)
+	_ Notebooks = (*fs.Store)(nil)
+	_ Notebooks = (*gitstore.Store)(nil)
+	_ Notebooks = (*memory.Store)(nil)
+	_ Versioned = (*fs.Store)(nil)
+	_ Versioned = (*gitstore.Store)(nil)
+	_ Versioned = (*memory.Store)(nil)
+	_ Recoverer = (*fs.Store)(nil)
+	_ Recoverer = (*gitstore.Store)(nil)
+	_ Purger    = (*fs.Store)(nil)
+	_ Purger    = (*gitstore.Store)(nil)
+	_ Purger    = (*memory.Store)(nil)
+	_ Drafter   = (*fs.Store)(nil)
+	_ Drafter   = (*gitstore.Store)(nil)
+	_ Backdater = (*fs.Store)(nil)
+	_ Backdater = (*gitstore.Store)(nil)
+	_ Backdater = (*memory.Store)(nil)
+	_ Syncer    = (*gitstore.Store)(nil)
+	_ Pinner    = (*fs.Store)(nil)
+	_ Pinner    = (*gitstore.Store)(nil)
+	_ Pinner    = (*memory.Store)(nil)
+	_ Refresher = (*fs.Store)(nil)
+	_ Refresher = (*gitstore.Store)(nil)
+	_ io.Closer = (*fs.Store)(nil)
+	_ io.Closer = (*gitstore.Store)(nil)
+	_ Versioned = guarded{}
+)
+
+// Refresh passes the paths of a watch.Event to store, when it remembers
+// what it lists.
+func Refresh(store NoteStore, paths []string) {
+	if r, ok := store.(Refresher); ok {
+		r.Refresh(paths)
+	}
+}
+
+// Close releases what store holds open, when it holds anything.
+func Close(store NoteStore) error {
+	if c, ok := store.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// ReadVersion reads a note together with its version, or a zero version
+// when the backend does not track them.
+func ReadVersion(store NoteStore, path string) (string, fs.Version, error) {
+	if v, ok := store.(Versioned); ok {
+		return v.ReadVersion(path)
+	}
+	body, err := store.ReadBody(path)
+	return body, fs.Version{}, err
+}
+
+// Guard returns store with saves to path made through WriteBodyIf with
+// base, so code that writes through a plain NoteStore, like the link graph,
+// refuses to clobber a concurrent change. Other notes are written as usual.
+func Guard(store NoteStore, path string, base fs.Version) NoteStore {
+	v, ok := store.(Versioned)
+	if !ok || base.IsZero() {
+		return store
+	}
+	return guarded{NoteStore: store, v: v, path: path, base: base}
+}
+
+type guarded struct {
+	NoteStore
+	v    Versioned
+	path string
+	base fs.Version
+}
+
+func (g guarded) WriteBody(path, body string) error {
+	if path != g.path {
+		return g.NoteStore.WriteBody(path, body)
+	}
+	return g.v.WriteBodyIf(path, body, g.base)
+}
+
+// ReadVersion and WriteBodyIf keep a guarded store Versioned, so notes
+// other than the guarded one can be written with a base of their own.
+func (g guarded) ReadVersion(path string) (string, fs.Version, error) {
+	return g.v.ReadVersion(path)
+}
+
+func (g guarded) WriteBodyIf(path, body string, base fs.Version) error {
+	return g.v.WriteBodyIf(path, body, base)
+}
+
+// SetPinned pins n to the top of its notebook, or unpins it, through the
+// store's pins, so the note itself does not change. It reports whether
+// anything changed. Unpinning a note whose front matter sets the pinned
+// key, by hand or on import, removes the key; a note changed since it was
+// read is not overwritten.
+func SetPinned(store NoteStore, n fs.Note, pinned bool) (bool, error) {
+	p, ok := store.(Pinner)
+	if !ok {
+		return false, errors.New("pins are not supported by this storage backend")
+	}
+	changed, err := p.SetPinned(n, pinned)
+	if err != nil || pinned {
+		return changed, err
+	}
+
+	body, version, err := ReadVersion(store, n.Path)
+	if err != nil {
+		return changed, err
+	}
+	meta, _ := fs.ParseFrontMatter(body)
+	if !meta.Pinned {
+		return changed, nil
+	}
+	meta.Pinned = false
+	if err := Guard(store, n.Path, version).WriteBody(n.Path, fs.WithFrontMatter(body, meta)); err != nil {
+		return changed, err
+	}
+	return true, nil
+}
+
+// SortOrder returns the order cfg lists the notes of section in, falling
+// back to the section's default.
+func SortOrder(cfg config.AppConfig, section fs.Section) fs.SortOrder {
+	name, ok := cfg.Sort[string(section)]
+	o, err := fs.ParseSortOrder(name)
+	if !ok || err != nil {
+		return fs.DefaultSortOrder(section)
+	}
+	return o
+}
+
+// TemplateDir returns the directory the note templates of the store
+// selected by cfg live in. It is under the storage root whatever the
+// backend, and may not exist.
+func TemplateDir(cfg config.AppConfig) (string, error) {
+	paths, err := config.ResolvePathsFrom(cfg.StorageDir)
+	if err != nil {
+		return "", err
+	}
+	return paths.Templates, nil
+}
+
+// EditDir returns the directory external edits of the store selected by
+// cfg are made in, through a temporary file; see editor.Session. It is
+// empty, for the system's temporary directory, unless the store is
+// encrypted: then it is a directory under the meta directory that only the
+// user can enter, so decrypted notes never land in a shared one.
+func EditDir(cfg config.AppConfig) (string, error) {
+	encrypted, err := Encrypted(cfg)
+	if err != nil || !encrypted {
+		return "", err
+	}
+	paths, err := config.ResolvePathsFrom(cfg.StorageDir)
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(paths.Meta, editDir)
+	if err := os.MkdirAll(dir, privateDirPerm); err != nil {
+		return "", fmt.Errorf("create edit dir %q: %w", dir, err)
+	}
+	// It may predate the store's encryption.
+	if err := os.Chmod(dir, privateDirPerm); err != nil {
+		return "", fmt.Errorf("create edit dir %q: %w", dir, err)
+	}
+	return dir, nil
+}
+
+// DefaultTrashRetention is how long notes stay in the trash when the
+// config does not say.
+const DefaultTrashRetention = 30 * 24 * time.Hour
+
+// TrashRetention returns how long notes stay in the trash before they are
+// purged, or zero when they stay until deleted by hand.
+func TrashRetention(cfg config.AppConfig) time.Duration {
+	switch {
+	case cfg.TrashRetention < 0:
+		return 0
+	case cfg.TrashRetention == 0:
+		return DefaultTrashRetention
+	}
+	return time.Duration(cfg.TrashRetention) * 24 * time.Hour
+}
+
+// PurgeExpired deletes the notes that have been in the trash for longer
+// than the retention configured in cfg, and returns them. Backends that do
+// not record when notes were trashed keep them.
+func PurgeExpired(cfg config.AppConfig, store NoteStore) ([]fs.Note, error) {
+	p, ok := store.(Purger)
+	keep := TrashRetention(cfg)
+	if !ok || keep <= 0 {
+		return nil, nil
+	}
+	return p.PurgeTrash(time.Now().Add(-keep))
+}
+
+// Open returns the backend selected by cfg.Backend. An empty backend means
+// the filesystem store rooted at cfg.StorageDir. An encrypted store fails
+// with vault.ErrLocked; see OpenWithKey.
+func Open(cfg config.AppConfig) (NoteStore, error) {
+	return OpenWithKey(cfg, nil)
+}
+
+// OpenWithKey is Open for a store that may be encrypted, using the key
+// returned by Unlock. The key is ignored for plaintext stores.
+func OpenWithKey(cfg config.AppConfig, key *vault.Key) (NoteStore, error) {
+	switch cfg.Backend {
+	case "", BackendFS:
+		paths, err := config.ResolvePathsFrom(cfg.StorageDir)
+		if err != nil {
+			return nil, err
+		}
+		opts := []fs.Option{fs.WithHistoryLimit(cfg.HistoryLimit)}
+		if vault.Exists(paths.Meta) {
+			if key == nil {
+				return nil, vault.ErrLocked
+			}
+			opts = append(opts, fs.WithCipher(key))
+		} else {
+			opts = append(opts, fs.WithListCache(filepath.Join(paths.Meta, listFile)))
+		}
+		if cfg.WatchPoll < 0 {
+			opts = append(opts, fs.WithoutWatcher())
+		}
+		return fs.NewStore(paths, opts...), nil
+	case BackendGit:
+		paths, err := config.ResolvePathsFrom(cfg.StorageDir)
+		if err != nil {
+			return nil, err
+		}
+		opts := []fs.Option{
+			fs.WithHistoryLimit(cfg.HistoryLimit),
+			fs.WithListCache(filepath.Join(paths.Meta, listFile)),
+		}
+		if cfg.WatchPoll < 0 {
+			opts = append(opts, fs.WithoutWatcher())
+		}
+		return gitstore.Open(paths, cfg.GitRemote, opts...)
+	case BackendMemory:
+		return memory.NewStore(), nil
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", cfg.Backend)
+	}
+}
+
+// OpenIndex opens the full-text index that belongs to the backend selected
+// by cfg. The filesystem and git backends keep it under the storage root; other
+// backends, and encrypted stores, get an index that lives only as long as
+// the process.
+func OpenIndex(cfg config.AppConfig) (*search.Index, error) {
+	switch cfg.Backend {
+	case "", BackendFS, BackendGit:
+		paths, err := config.ResolvePathsFrom(cfg.StorageDir)
+		if err != nil {
+			return nil, err
+		}
+		if vault.Exists(paths.Meta) {
+			return search.Open(""), nil
+		}
+		return search.Open(filepath.Join(paths.Meta, indexFile)), nil
+	default:
+		return search.Open(""), nil
+	}
+}
+
+// OpenLinks opens the wiki link graph that belongs to the backend selected
+// by cfg, following the same rules as OpenIndex.
+func OpenLinks(cfg config.AppConfig) (*links.Graph, error) {
+	switch cfg.Backend {
+	case "", BackendFS, BackendGit:
+		paths, err := config.ResolvePathsFrom(cfg.StorageDir)
+		if err != nil {
+			return nil, err
+		}
+		if vault.Exists(paths.Meta) {
+			return links.Open(""), nil
+		}
+		return links.Open(filepath.Join(paths.Meta, linksFile)), nil
+	default:
+		return links.Open(""), nil
+	}
+}
+
+// Watch watches the notes of the store selected by cfg for changes made
+// outside the process. It returns nil for backends without files on disk
+// and when cfg turns watching off.
+func Watch(cfg config.AppConfig) (*watch.Watcher, error) {
+	if cfg.WatchPoll < 0 {
+		return nil, nil
+	}
+	switch cfg.Backend {
+	case "", BackendFS, BackendGit:
+		paths, err := config.ResolvePathsFrom(cfg.StorageDir)
+		if err != nil {
+			return nil, err
+		}
+		var opts []watch.Option
+		if cfg.WatchPoll > 0 {
+			opts = append(opts, watch.WithPolling(time.Duration(cfg.WatchPoll)*time.Second))
+		}
+		return watch.New([]string{paths.Notes, paths.Journal, paths.Trash}, opts...), nil
+	default:
+		return nil, nil
+	}
+}
+
+// Encrypted reports whether the store selected by cfg is encrypted.
+func Encrypted(cfg config.AppConfig) (bool, error) {
+	if cfg.Backend != "" && cfg.Backend != BackendFS {
+		return false, nil
+	}
+	paths, err := config.ResolvePathsFrom(cfg.StorageDir)
+	if err != nil {
+		return false, err
+	}
+	return vault.Exists(paths.Meta), nil
+}
+
+// Unlock derives the key of the encrypted store selected by cfg.
+func Unlock(cfg config.AppConfig, passphrase string) (*vault.Key, error) {
+	paths, err := config.ResolvePathsFrom(cfg.StorageDir)
+	if err != nil {
+		return nil, err
+	}
+	return vault.Unlock(paths.Meta, passphrase)
+}
+
+// Encrypt migrates the plaintext filesystem store selected by cfg to an
+// encrypted one. For a store that is already encrypted it finishes an
+// interrupted migration, and passphrase must match. Indexes derived from
+// note content are deleted; encrypted stores rebuild them in memory.
+// It returns the number of files encrypted.
+func Encrypt(cfg config.AppConfig, passphrase string) (int, error) {
+	if cfg.Backend != "" && cfg.Backend != BackendFS {
+		return 0, fmt.Errorf("encryption is not supported by the %q backend", cfg.Backend)
+	}
+	paths, err := config.ResolvePathsFrom(cfg.StorageDir)
+	if err != nil {
+		return 0, err
+	}
+
+	var key *vault.Key
+	if vault.Exists(paths.Meta) {
+		key, err = vault.Unlock(paths.Meta, passphrase)
+	} else {
+		key, err = vault.Create(paths.Meta, passphrase)
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer key.Wipe()
+
+	store := fs.NewStore(paths, fs.WithCipher(key))
+	n, err := store.Recrypt(key)
+	if err != nil {
+		return n, fmt.Errorf("encrypt store: %w", err)
+	}
+	for _, name := range []string{indexFile, linksFile, listFile} {
+		path := filepath.Join(paths.Meta, name)
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return n, fmt.Errorf("remove plaintext index: %w", err)
+		}
+	}
+	return n, nil
+}
+
+// Decrypt migrates the encrypted store selected by cfg back to plain
+// files and removes its vault. It returns the number of files decrypted.
+func Decrypt(cfg config.AppConfig, key *vault.Key) (int, error) {
+	paths, err := config.ResolvePathsFrom(cfg.StorageDir)
+	if err != nil {
+		return 0, err
+	}
+	if !vault.Exists(paths.Meta) {
+		return 0, errors.New("note store is not encrypted")
+	}
+
+	store := fs.NewStore(paths, fs.WithCipher(key))
+	n, err := store.Recrypt(nil)
+	if err != nil {
+		return n, fmt.Errorf("decrypt store: %w", err)
+	}
+	return n, vault.Remove(paths.Meta)
+}
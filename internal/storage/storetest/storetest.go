@@ -0,0 +1,668 @@
+// Package storetest provides a conformance suite that every
+// storage.NoteStore implementation is expected to pass. Backends call Run
+// from their own tests:
+//
+//	func TestConformance(t *testing.T) {
+//		storetest.Run(t, func(t *testing.T) storage.NoteStore {
+//			return memory.NewStore()
+//		})
+//	}
+package storetest
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/internet-kid/tenote/internal/storage"
+	"github.com/internet-kid/tenote/internal/storage/fs"
+)
+
+// Factory returns a new, empty store. It is called once per subtest.
+type Factory func(t *testing.T) storage.NoteStore
+
+// Run exercises the NoteStore contract against stores produced by newStore.
+func Run(t *testing.T, newStore Factory) {
+	t.Helper()
+
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, s storage.NoteStore)
+	}{
+		{"CreateAndList", testCreateAndList},
+		{"ReadWriteBody", testReadWriteBody},
+		{"TitleFromBody", testTitleFromBody},
+		{"ListSortedByUpdatedAt", testListSorted},
+		{"ListPinnedFirst", testListPinnedFirst},
+		{"FrontMatterPin", testFrontMatterPin},
+		{"MoveToTrash", testMoveToTrash},
+		{"RestoreFromTrash", testRestoreFromTrash},
+		{"DeleteFromTrash", testDeleteFromTrash},
+		{"DeleteOutsideTrash", testDeleteOutsideTrash},
+		{"ReadMissing", testReadMissing},
+		{"Sections", testSections},
+		{"Notebooks", testNotebooks},
+		{"RestoreToOrigin", testRestoreToOrigin},
+		{"SameIDs", testSameIDs},
+		{"WriteBodyIf", testWriteBodyIf},
+		{"PurgeTrash", testPurgeTrash},
+		{"Drafts", testDrafts},
+		{"Revisions", testRevisions},
+		{"SetUpdatedAt", testSetUpdatedAt},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, newStore(t))
+		})
+	}
+}
+
+func testCreateAndList(t *testing.T, s storage.NoteStore) {
+	n := mustCreate(t, s, fs.SectionNotes)
+	if n.ID == "" || n.Path == "" {
+		t.Fatalf("Create returned note without ID or Path: %+v", n)
+	}
+	if n.Section != fs.SectionNotes {
+		t.Fatalf("Create section = %q, want %q", n.Section, fs.SectionNotes)
+	}
+
+	notes := mustList(t, s, fs.SectionNotes)
+	if len(notes) != 1 || notes[0].ID != n.ID {
+		t.Fatalf("List(notes) = %+v, want only %s", notes, n.ID)
+	}
+	if trash := mustList(t, s, fs.SectionTrash); len(trash) != 0 {
+		t.Fatalf("List(trash) = %+v, want empty", trash)
+	}
+}
+
+func testReadWriteBody(t *testing.T, s storage.NoteStore) {
+	n := mustCreate(t, s, fs.SectionNotes)
+
+	const body = "# Groceries\n\n- milk\n"
+	if err := s.WriteBody(n.Path, body); err != nil {
+		t.Fatalf("WriteBody: %v", err)
+	}
+	got, err := s.ReadBody(n.Path)
+	if err != nil {
+		t.Fatalf("ReadBody: %v", err)
+	}
+	if got != body {
+		t.Fatalf("ReadBody = %q, want %q", got, body)
+	}
+}
+
+func testTitleFromBody(t *testing.T, s storage.NoteStore) {
+	n := mustCreate(t, s, fs.SectionNotes)
+	if err := s.WriteBody(n.Path, "\n## Meeting notes\nbody\n"); err != nil {
+		t.Fatalf("WriteBody: %v", err)
+	}
+
+	notes := mustList(t, s, fs.SectionNotes)
+	if len(notes) != 1 {
+		t.Fatalf("List returned %d notes, want 1", len(notes))
+	}
+	if notes[0].Title != "Meeting notes" {
+		t.Fatalf("Title = %q, want %q", notes[0].Title, "Meeting notes")
+	}
+}
+
+func testListSorted(t *testing.T, s storage.NoteStore) {
+	for range 3 {
+		mustCreate(t, s, fs.SectionNotes)
+	}
+
+	notes := mustList(t, s, fs.SectionNotes)
+	for i := 1; i < len(notes); i++ {
+		if notes[i].UpdatedAt.After(notes[i-1].UpdatedAt) {
+			t.Fatalf("List not sorted by UpdatedAt desc at %d: %v after %v",
+				i, notes[i].UpdatedAt, notes[i-1].UpdatedAt)
+		}
+	}
+}
+
+func testListPinnedFirst(t *testing.T, s storage.NoteStore) {
+	pinned := mustCreate(t, s, fs.SectionNotes)
+	if err := s.WriteBody(pinned.Path, "# Pinned\n"); err != nil {
+		t.Fatalf("WriteBody: %v", err)
+	}
+	for range 2 {
+		n := mustCreate(t, s, fs.SectionNotes)
+		if err := s.WriteBody(n.Path, "# Later\n"); err != nil {
+			t.Fatalf("WriteBody: %v", err)
+		}
+	}
+	var before fs.Note
+	for _, n := range mustList(t, s, fs.SectionNotes) {
+		if n.ID == pinned.ID {
+			before = n
+		}
+	}
+
+	if changed, err := storage.SetPinned(s, pinned, true); err != nil || !changed {
+		t.Fatalf("SetPinned = %v, %v; want a change", changed, err)
+	}
+	notes := mustList(t, s, fs.SectionNotes)
+	if len(notes) != 3 || notes[0].ID != pinned.ID || !notes[0].Pinned {
+		t.Fatalf("List = %+v, want pinned note %s first", notes, pinned.ID)
+	}
+	// Pinning leaves the note alone.
+	if !notes[0].UpdatedAt.Equal(before.UpdatedAt) || notes[0].Size != before.Size {
+		t.Fatalf("pinned note = %+v, was %+v", notes[0], before)
+	}
+	if body, _ := s.ReadBody(pinned.Path); body != "# Pinned\n" {
+		t.Fatalf("pinning rewrote the note to %q", body)
+	}
+	if changed, err := storage.SetPinned(s, pinned, true); err != nil || changed {
+		t.Fatalf("SetPinned again = %v, %v; want no change", changed, err)
+	}
+
+	if changed, err := storage.SetPinned(s, pinned, false); err != nil || !changed {
+		t.Fatalf("SetPinned(false) = %v, %v; want a change", changed, err)
+	}
+	for _, n := range mustList(t, s, fs.SectionNotes) {
+		if n.Pinned {
+			t.Fatalf("%s still pinned after unpinning", n.ID)
+		}
+	}
+}
+
+// testFrontMatterPin checks notes pinned by a pinned key in their front
+// matter, as users and imports may set it.
+func testFrontMatterPin(t *testing.T, s storage.NoteStore) {
+	n := mustCreate(t, s, fs.SectionNotes)
+	if err := s.WriteBody(n.Path, "---\npinned: true\ntags: [a]\n---\n# Old\n"); err != nil {
+		t.Fatalf("WriteBody: %v", err)
+	}
+	mustCreate(t, s, fs.SectionNotes)
+	if notes := mustList(t, s, fs.SectionNotes); notes[0].ID != n.ID || !notes[0].Pinned {
+		t.Fatalf("List = %+v, want %s pinned first", notes, n.ID)
+	}
+
+	if changed, err := storage.SetPinned(s, n, false); err != nil || !changed {
+		t.Fatalf("SetPinned(false) = %v, %v; want a change", changed, err)
+	}
+	if body, _ := s.ReadBody(n.Path); body != "---\ntags:\n    - a\n---\n# Old\n" {
+		t.Fatalf("unpinned note = %q, want the pinned key gone", body)
+	}
+	for _, got := range mustList(t, s, fs.SectionNotes) {
+		if got.Pinned {
+			t.Fatalf("%s still pinned", got.ID)
+		}
+	}
+}
+
+func testMoveToTrash(t *testing.T, s storage.NoteStore) {
+	n := mustCreate(t, s, fs.SectionNotes)
+	if err := s.WriteBody(n.Path, "# Old\n"); err != nil {
+		t.Fatalf("WriteBody: %v", err)
+	}
+
+	trashed, err := s.MoveToTrash(n)
+	if err != nil {
+		t.Fatalf("MoveToTrash: %v", err)
+	}
+	if trashed.Section != fs.SectionTrash || trashed.ID != n.ID {
+		t.Fatalf("MoveToTrash = %+v, want same ID in trash", trashed)
+	}
+	if got := mustList(t, s, fs.SectionNotes); len(got) != 0 {
+		t.Fatalf("List(notes) after trash = %+v, want empty", got)
+	}
+	if got := mustList(t, s, fs.SectionTrash); len(got) != 1 || got[0].ID != n.ID {
+		t.Fatalf("List(trash) = %+v, want only %s", got, n.ID)
+	}
+
+	body, err := s.ReadBody(trashed.Path)
+	if err != nil {
+		t.Fatalf("ReadBody(trashed): %v", err)
+	}
+	if body != "# Old\n" {
+		t.Fatalf("trashed body = %q, want %q", body, "# Old\n")
+	}
+
+	again, err := s.MoveToTrash(trashed)
+	if err != nil {
+		t.Fatalf("MoveToTrash on trashed note: %v", err)
+	}
+	if again.Path != trashed.Path {
+		t.Fatalf("MoveToTrash on trashed note moved it to %q", again.Path)
+	}
+}
+
+func testRestoreFromTrash(t *testing.T, s storage.NoteStore) {
+	n := mustCreate(t, s, fs.SectionNotes)
+	trashed, err := s.MoveToTrash(n)
+	if err != nil {
+		t.Fatalf("MoveToTrash: %v", err)
+	}
+
+	restored, err := s.RestoreFromTrash(trashed, fs.SectionTrash)
+	if err != nil {
+		t.Fatalf("RestoreFromTrash: %v", err)
+	}
+	if restored.Section != fs.SectionNotes {
+		t.Fatalf("restore into trash section should fall back to notes, got %q", restored.Section)
+	}
+	if got := mustList(t, s, fs.SectionNotes); len(got) != 1 || got[0].ID != n.ID {
+		t.Fatalf("List(notes) = %+v, want only %s", got, n.ID)
+	}
+	if got := mustList(t, s, fs.SectionTrash); len(got) != 0 {
+		t.Fatalf("List(trash) = %+v, want empty", got)
+	}
+}
+
+func testDeleteFromTrash(t *testing.T, s storage.NoteStore) {
+	n := mustCreate(t, s, fs.SectionNotes)
+	trashed, err := s.MoveToTrash(n)
+	if err != nil {
+		t.Fatalf("MoveToTrash: %v", err)
+	}
+	if err := s.DeleteFromTrash(trashed); err != nil {
+		t.Fatalf("DeleteFromTrash: %v", err)
+	}
+	if got := mustList(t, s, fs.SectionTrash); len(got) != 0 {
+		t.Fatalf("List(trash) after delete = %+v, want empty", got)
+	}
+	if _, err := s.ReadBody(trashed.Path); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("ReadBody after delete: err = %v, want ErrNotExist", err)
+	}
+}
+
+func testDeleteOutsideTrash(t *testing.T, s storage.NoteStore) {
+	n := mustCreate(t, s, fs.SectionNotes)
+	if err := s.DeleteFromTrash(n); err == nil {
+		t.Fatal("DeleteFromTrash outside trash succeeded, want error")
+	}
+	if got := mustList(t, s, fs.SectionNotes); len(got) != 1 {
+		t.Fatalf("note was removed by a rejected delete: %+v", got)
+	}
+}
+
+func testReadMissing(t *testing.T, s storage.NoteStore) {
+	n := mustCreate(t, s, fs.SectionNotes)
+	trashed, err := s.MoveToTrash(n)
+	if err != nil {
+		t.Fatalf("MoveToTrash: %v", err)
+	}
+	if _, err := s.ReadBody(n.Path); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("ReadBody(old path): err = %v, want ErrNotExist", err)
+	}
+	if err := s.DeleteFromTrash(trashed); err != nil {
+		t.Fatalf("DeleteFromTrash: %v", err)
+	}
+}
+
+func testSections(t *testing.T, s storage.NoteStore) {
+	secs, err := s.Sections()
+	if err != nil {
+		t.Fatalf("Sections: %v", err)
+	}
+	if len(secs) < 2 || secs[0] != fs.SectionNotes || secs[len(secs)-1] != fs.SectionTrash {
+		t.Fatalf("Sections = %v, want notes first and trash last", secs)
+	}
+}
+
+func testNotebooks(t *testing.T, s storage.NoteStore) {
+	nbs, ok := s.(storage.Notebooks)
+	if !ok {
+		t.Skip("backend does not support notebooks")
+	}
+
+	work, err := nbs.CreateNotebook(fs.SectionNotes, "work")
+	if err != nil {
+		t.Fatalf("CreateNotebook(work): %v", err)
+	}
+	ideas, err := nbs.CreateNotebook(work, "ideas")
+	if err != nil {
+		t.Fatalf("CreateNotebook(ideas): %v", err)
+	}
+	if ideas != fs.Notebook("work/ideas") {
+		t.Fatalf("nested notebook = %q, want %q", ideas, fs.Notebook("work/ideas"))
+	}
+	if _, err := nbs.CreateNotebook(fs.SectionNotes, "../escape"); err == nil {
+		t.Fatal("CreateNotebook with a path succeeded, want error")
+	}
+
+	secs, err := s.Sections()
+	if err != nil {
+		t.Fatalf("Sections: %v", err)
+	}
+	want := []fs.Section{fs.SectionNotes, work, ideas, fs.SectionJournal, fs.SectionTrash}
+	if len(secs) != len(want) {
+		t.Fatalf("Sections = %v, want %v", secs, want)
+	}
+	for i := range want {
+		if secs[i] != want[i] {
+			t.Fatalf("Sections = %v, want %v", secs, want)
+		}
+	}
+
+	n := mustCreate(t, s, fs.SectionNotes)
+	moved, err := nbs.Move(n, ideas)
+	if err != nil {
+		t.Fatalf("Move: %v", err)
+	}
+	if moved.Section != ideas {
+		t.Fatalf("Move section = %q, want %q", moved.Section, ideas)
+	}
+	if got := mustList(t, s, ideas); len(got) != 1 || got[0].ID != n.ID {
+		t.Fatalf("List(%s) = %+v, want only %s", ideas, got, n.ID)
+	}
+	if got := mustList(t, s, fs.SectionNotes); len(got) != 0 {
+		t.Fatalf("List(notes) after move = %+v, want empty", got)
+	}
+
+	if err := nbs.DeleteNotebook(ideas); err == nil {
+		t.Fatal("DeleteNotebook on a non-empty notebook succeeded, want error")
+	}
+	if _, err := nbs.Move(moved, fs.SectionNotes); err != nil {
+		t.Fatalf("Move back: %v", err)
+	}
+	if err := nbs.DeleteNotebook(ideas); err != nil {
+		t.Fatalf("DeleteNotebook: %v", err)
+	}
+}
+
+func testRestoreToOrigin(t *testing.T, s storage.NoteStore) {
+	nbs, ok := s.(storage.Notebooks)
+	if !ok {
+		t.Skip("backend does not support notebooks")
+	}
+	work, err := nbs.CreateNotebook(fs.SectionNotes, "work")
+	if err != nil {
+		t.Fatalf("CreateNotebook: %v", err)
+	}
+
+	n := mustCreate(t, s, work)
+	trashed, err := s.MoveToTrash(n)
+	if err != nil {
+		t.Fatalf("MoveToTrash: %v", err)
+	}
+	restored, err := s.RestoreFromTrash(trashed, "")
+	if err != nil {
+		t.Fatalf("RestoreFromTrash: %v", err)
+	}
+	if restored.Section != work {
+		t.Fatalf("restored to %q, want origin %q", restored.Section, work)
+	}
+
+	trashed, err = s.MoveToTrash(restored)
+	if err != nil {
+		t.Fatalf("MoveToTrash: %v", err)
+	}
+	if err := nbs.DeleteNotebook(work); err != nil {
+		t.Fatalf("DeleteNotebook: %v", err)
+	}
+	restored, err = s.RestoreFromTrash(trashed, "")
+	if err != nil {
+		t.Fatalf("RestoreFromTrash into deleted notebook: %v", err)
+	}
+	if restored.Section != work {
+		t.Fatalf("restored to %q, want recreated %q", restored.Section, work)
+	}
+	if got := mustList(t, s, work); len(got) != 1 || got[0].ID != n.ID {
+		t.Fatalf("List(%s) = %+v, want only %s", work, got, n.ID)
+	}
+}
+
+// testSameIDs checks that notes with the same ID in different notebooks,
+// as other tools and syncs can leave them, never replace each other.
+func testSameIDs(t *testing.T, s storage.NoteStore) {
+	nbs, ok := s.(storage.Notebooks)
+	if !ok {
+		t.Skip("backend does not support notebooks")
+	}
+	a, err := nbs.CreateNotebook(fs.SectionNotes, "a")
+	if err != nil {
+		t.Fatalf("CreateNotebook: %v", err)
+	}
+	b, err := nbs.CreateNotebook(fs.SectionNotes, "b")
+	if err != nil {
+		t.Fatalf("CreateNotebook: %v", err)
+	}
+	na := mustCreate(t, s, a)
+	if err := s.WriteBody(na.Path, "# From A\n"); err != nil {
+		t.Fatalf("WriteBody: %v", err)
+	}
+	if _, err := os.Stat(na.Path); err != nil {
+		t.Skip("backend does not keep notes in files")
+	}
+	// Give a note in b the same file name, the way another tool would.
+	nb := mustCreate(t, s, b)
+	if err := s.WriteBody(nb.Path, "# From B\n"); err != nil {
+		t.Fatalf("WriteBody: %v", err)
+	}
+	same := filepath.Join(filepath.Dir(nb.Path), filepath.Base(na.Path))
+	if err := os.Rename(nb.Path, same); err != nil {
+		t.Fatal(err)
+	}
+	if r, ok := s.(storage.Refresher); ok {
+		r.Refresh(nil)
+	}
+	list := mustList(t, s, b)
+	if len(list) != 1 || list[0].ID != na.ID {
+		t.Fatalf("List(%s) = %+v, want a note with ID %s", b, list, na.ID)
+	}
+	nb = list[0]
+
+	body := func(n fs.Note, want string) {
+		t.Helper()
+		if got, err := s.ReadBody(n.Path); err != nil || got != want {
+			t.Fatalf("note in %s = %q, %v; want %q", n.Section, got, err, want)
+		}
+	}
+
+	trashed, err := s.MoveToTrash(na)
+	if err != nil {
+		t.Fatalf("MoveToTrash: %v", err)
+	}
+	if _, err := s.MoveToTrash(nb); !errors.Is(err, os.ErrExist) {
+		t.Fatalf("MoveToTrash onto a trashed note = %v, want ErrExist", err)
+	}
+	body(trashed, "# From A\n")
+	body(nb, "# From B\n")
+	if got := mustList(t, s, fs.SectionTrash); len(got) != 1 || got[0].Origin != a {
+		t.Fatalf("List(trash) = %+v, want the note from %s", got, a)
+	}
+
+	// With b's note moved into a, the trashed note cannot go back there.
+	nb, err = nbs.Move(nb, a)
+	if err != nil {
+		t.Fatalf("Move: %v", err)
+	}
+	if _, err := s.RestoreFromTrash(trashed, ""); !errors.Is(err, os.ErrExist) {
+		t.Fatalf("RestoreFromTrash onto a note = %v, want ErrExist", err)
+	}
+	body(trashed, "# From A\n")
+	body(nb, "# From B\n")
+
+	restored, err := s.RestoreFromTrash(trashed, b)
+	if err != nil {
+		t.Fatalf("RestoreFromTrash(%s): %v", b, err)
+	}
+	if _, err := nbs.Move(nb, b); !errors.Is(err, os.ErrExist) {
+		t.Fatalf("Move onto a note = %v, want ErrExist", err)
+	}
+	body(restored, "# From A\n")
+	body(nb, "# From B\n")
+}
+
+func testWriteBodyIf(t *testing.T, s storage.NoteStore) {
+	vs, ok := s.(storage.Versioned)
+	if !ok {
+		t.Skip("backend does not track versions")
+	}
+	n := mustCreate(t, s, fs.SectionNotes)
+
+	_, base, err := vs.ReadVersion(n.Path)
+	if err != nil {
+		t.Fatalf("ReadVersion: %v", err)
+	}
+	if err := vs.WriteBodyIf(n.Path, "# Mine\n", base); err != nil {
+		t.Fatalf("WriteBodyIf on an unchanged note: %v", err)
+	}
+
+	// base no longer matches: the note now holds "# Mine".
+	if err := vs.WriteBodyIf(n.Path, "# Stale\n", base); !errors.Is(err, fs.ErrConflict) {
+		t.Fatalf("WriteBodyIf on a changed note = %v, want fs.ErrConflict", err)
+	}
+	if got, _ := s.ReadBody(n.Path); got != "# Mine\n" {
+		t.Fatalf("conflicting WriteBodyIf wrote %q", got)
+	}
+
+	if err := vs.WriteBodyIf(n.Path, "# Forced\n", fs.Version{}); err != nil {
+		t.Fatalf("WriteBodyIf with a zero base: %v", err)
+	}
+}
+
+func testPurgeTrash(t *testing.T, s storage.NoteStore) {
+	p, ok := s.(storage.Purger)
+	if !ok {
+		t.Skip("backend does not purge the trash")
+	}
+	kept := mustCreate(t, s, fs.SectionNotes)
+	n := mustCreate(t, s, fs.SectionNotes)
+
+	before := time.Now()
+	trashed, err := s.MoveToTrash(n)
+	if err != nil {
+		t.Fatalf("MoveToTrash: %v", err)
+	}
+	listed := mustList(t, s, fs.SectionTrash)
+	if len(listed) != 1 || listed[0].Origin != fs.SectionNotes || listed[0].DeletedAt.Before(before.Add(-time.Second)) {
+		t.Fatalf("trashed note = %+v, want origin %q and deleted at about %v", listed, fs.SectionNotes, before)
+	}
+
+	purged, err := p.PurgeTrash(trashed.DeletedAt.Add(-time.Hour))
+	if err != nil || len(purged) != 0 {
+		t.Fatalf("PurgeTrash before the note was trashed = %v, %v; want nothing purged", purged, err)
+	}
+	purged, err = p.PurgeTrash(time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("PurgeTrash: %v", err)
+	}
+	if len(purged) != 1 || purged[0].ID != n.ID {
+		t.Fatalf("PurgeTrash purged %+v, want %s", purged, n.ID)
+	}
+	if got := mustList(t, s, fs.SectionTrash); len(got) != 0 {
+		t.Fatalf("trash after purge = %+v, want empty", got)
+	}
+	if got := mustList(t, s, fs.SectionNotes); len(got) != 1 || got[0].ID != kept.ID {
+		t.Fatalf("notes after purge = %+v, want only %s", got, kept.ID)
+	}
+}
+
+func testDrafts(t *testing.T, s storage.NoteStore) {
+	d, ok := s.(storage.Drafter)
+	if !ok {
+		t.Skip("backend does not keep drafts")
+	}
+	n := mustCreate(t, s, fs.SectionNotes)
+	if err := d.SaveDraft(n, "# First\n"); err != nil {
+		t.Fatalf("SaveDraft: %v", err)
+	}
+	if err := d.SaveDraft(n, "# Second\n"); err != nil {
+		t.Fatalf("SaveDraft again: %v", err)
+	}
+
+	drafts, err := d.Drafts()
+	if err != nil {
+		t.Fatalf("Drafts: %v", err)
+	}
+	if len(drafts) != 1 || drafts[0].Note.ID != n.ID || drafts[0].Body != "# Second\n" {
+		t.Fatalf("Drafts = %+v, want the second draft of %s", drafts, n.ID)
+	}
+	if drafts[0].Note.Path != n.Path {
+		t.Fatalf("draft note path = %q, want %q", drafts[0].Note.Path, n.Path)
+	}
+
+	// The draft follows its note into the trash.
+	trashed, err := s.MoveToTrash(n)
+	if err != nil {
+		t.Fatalf("MoveToTrash: %v", err)
+	}
+	drafts, err = d.Drafts()
+	if err != nil {
+		t.Fatalf("Drafts: %v", err)
+	}
+	if len(drafts) != 1 || drafts[0].Note.Path != trashed.Path {
+		t.Fatalf("Drafts after trash = %+v, want note at %q", drafts, trashed.Path)
+	}
+
+	if err := d.DeleteDraft(n); err != nil {
+		t.Fatalf("DeleteDraft: %v", err)
+	}
+	if err := d.DeleteDraft(n); err != nil {
+		t.Fatalf("DeleteDraft without a draft: %v", err)
+	}
+	if drafts, err := d.Drafts(); err != nil || len(drafts) != 0 {
+		t.Fatalf("Drafts after delete = %+v, %v; want none", drafts, err)
+	}
+}
+
+func testRevisions(t *testing.T, s storage.NoteStore) {
+	h, ok := s.(storage.Historian)
+	if !ok {
+		t.Skip("backend does not record revisions")
+	}
+	n := mustCreate(t, s, fs.SectionNotes)
+	for _, body := range []string{"# One\n", "# Two\n", "# Two\n"} {
+		if err := s.WriteBody(n.Path, body); err != nil {
+			t.Fatalf("WriteBody: %v", err)
+		}
+	}
+
+	revs, err := h.Revisions(n)
+	if err != nil {
+		t.Fatalf("Revisions: %v", err)
+	}
+	// The blank note Create made, then the two distinct saves.
+	if len(revs) != 3 {
+		t.Fatalf("Revisions = %+v, want 3", revs)
+	}
+	for i, want := range []string{"# Two\n", "# One\n"} {
+		got, err := h.ReadRevision(n, revs[i].Hash)
+		if err != nil {
+			t.Fatalf("ReadRevision: %v", err)
+		}
+		if got != want {
+			t.Fatalf("revision %d = %q, want %q", i, got, want)
+		}
+	}
+}
+
+func testSetUpdatedAt(t *testing.T, s storage.NoteStore) {
+	b, ok := s.(storage.Backdater)
+	if !ok {
+		t.Skip("backend cannot backdate notes")
+	}
+	n := mustCreate(t, s, fs.SectionNotes)
+	when := time.Date(2020, 5, 17, 9, 30, 0, 0, time.UTC)
+	if err := b.SetUpdatedAt(n.Path, when); err != nil {
+		t.Fatalf("SetUpdatedAt: %v", err)
+	}
+	notes := mustList(t, s, fs.SectionNotes)
+	if len(notes) != 1 || !notes[0].UpdatedAt.Equal(when) {
+		t.Fatalf("List = %+v, want updated at %v", notes, when)
+	}
+}
+
+func mustCreate(t *testing.T, s storage.NoteStore, section fs.Section) fs.Note {
+	t.Helper()
+	n, err := s.Create(section)
+	if err != nil {
+		t.Fatalf("Create(%s): %v", section, err)
+	}
+	return n
+}
+
+func mustList(t *testing.T, s storage.NoteStore, section fs.Section) []fs.Note {
+	t.Helper()
+	notes, err := s.List(section)
+	if err != nil {
+		t.Fatalf("List(%s): %v", section, err)
+	}
+	return notes
+}
@@ -0,0 +1,305 @@
+// Package tags extracts note tags and rewrites them across notes.
+//
+// A note's tags are the union of the tags listed in its front matter and
+// the inline #tags in its content. Tags compare without regard to case and
+// may be nested with slashes, e.g. #work/clients; renaming a tag renames
+// its nested tags with it.
+package tags
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/internet-kid/tenote/internal/storage/fs"
+)
+
+// Count is a tag together with the number of notes carrying it.
+type Count struct {
+	Name  string
+	Notes int
+}
+
+// Normalize returns the canonical form of a tag: without a leading '#',
+// lower-cased and trimmed. It returns "" for strings that are not tags.
+func Normalize(t string) string {
+	t = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(t), "#")))
+	t = strings.Trim(t, "/")
+	if t == "" || strings.IndexFunc(t, func(r rune) bool { return !isTagRune(r) }) >= 0 {
+		return ""
+	}
+	return t
+}
+
+// Of returns the sorted, normalized tags of a note: its front matter tags
+// and the inline tags of body.
+func Of(n fs.Note, body string) []string {
+	_, content := fs.ParseFrontMatter(body)
+	var all []string
+	all = append(all, n.Tags...)
+	for _, t := range inline(content) {
+		all = append(all, t.name)
+	}
+	return unique(all)
+}
+
+// Match reports whether have satisfies want: every wanted tag when anyOf
+// is false, at least one otherwise. A wanted tag also matches its nested
+// tags.
+func Match(have, want []string, anyOf bool) bool {
+	if len(want) == 0 {
+		return true
+	}
+	for _, w := range want {
+		w = Normalize(w)
+		found := false
+		for _, h := range have {
+			if within(h, w) {
+				found = true
+				break
+			}
+		}
+		if found && anyOf {
+			return true
+		}
+		if !found && !anyOf {
+			return false
+		}
+	}
+	return !anyOf
+}
+
+// Rewrite renames every tag in from, and the tags nested below them, to to
+// in both the front matter and the inline tags of body. Renaming several
+// tags to the same name merges them. It reports whether body changed.
+func Rewrite(body string, from []string, to string) (string, bool) {
+	to = Normalize(to)
+	if to == "" {
+		return body, false
+	}
+	rename := func(t string) (string, bool) {
+		nt := Normalize(t)
+		for _, f := range from {
+			if f = Normalize(f); f != "" && within(nt, f) {
+				return to + nt[len(f):], true
+			}
+		}
+		return t, false
+	}
+
+	changed := false
+	meta, content := fs.ParseFrontMatter(body)
+	block := body[:len(body)-len(content)]
+
+	if len(meta.Tags) > 0 {
+		renamed := make([]string, len(meta.Tags))
+		metaChanged := false
+		for i, t := range meta.Tags {
+			var ok bool
+			renamed[i], ok = rename(t)
+			metaChanged = metaChanged || ok
+		}
+		if metaChanged {
+			meta.Tags = unique(renamed)
+			block = fs.FormatFrontMatter(meta)
+			changed = true
+		}
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, t := range inline(content) {
+		nt, ok := rename(t.name)
+		if !ok {
+			continue
+		}
+		b.WriteString(content[last:t.start])
+		b.WriteString("#" + nt)
+		last = t.end
+		changed = true
+	}
+	b.WriteString(content[last:])
+
+	if !changed {
+		return body, false
+	}
+	return block + b.String(), true
+}
+
+// Edit adds the tags in add to the front matter of body and removes those
+// in remove from it. Inline tags are part of the text and are left alone,
+// so a note keeps a removed tag its content mentions. Body is returned
+// unchanged when its front matter already has the tags asked for.
+func Edit(body string, add, remove []string) (string, error) {
+	for _, t := range append(slices.Clone(add), remove...) {
+		if Normalize(t) == "" {
+			return "", fmt.Errorf("invalid tag %q", t)
+		}
+	}
+	if err := fs.ValidateFrontMatter(body); err != nil {
+		return "", err
+	}
+
+	meta, _ := fs.ParseFrontMatter(body)
+	drop := unique(remove)
+	var kept []string
+	for _, t := range meta.Tags {
+		if !slices.Contains(drop, Normalize(t)) {
+			kept = append(kept, t)
+		}
+	}
+	tags := unique(append(kept, add...))
+	if slices.Equal(tags, meta.Tags) {
+		return body, nil
+	}
+	meta.Tags = tags
+	return fs.WithFrontMatter(body, meta), nil
+}
+
+// Store is the part of a note store Retag reads and writes through.
+// storage.NoteStore satisfies it.
+type Store interface {
+	Sections() ([]fs.Section, error)
+	List(section fs.Section) ([]fs.Note, error)
+	ReadBody(path string) (string, error)
+	WriteBody(path, body string) error
+}
+
+// Retag applies Rewrite to every note in store, the trash included, and
+// returns the notes it changed. A note saved meanwhile is retagged again
+// rather than overwritten; see fs.Update. Notes that keep changing are
+// skipped and named in an error wrapping fs.ErrConflict once the others
+// are done.
+func Retag(store Store, from []string, to string) ([]fs.Note, error) {
+	if Normalize(to) == "" {
+		return nil, fmt.Errorf("invalid tag %q", to)
+	}
+	for _, f := range from {
+		if Normalize(f) == "" {
+			return nil, fmt.Errorf("invalid tag %q", f)
+		}
+	}
+
+	sections, err := store.Sections()
+	if err != nil {
+		return nil, err
+	}
+	var changed []fs.Note
+	var busy []string
+	for _, sec := range sections {
+		notes, err := store.List(sec)
+		if err != nil {
+			return changed, err
+		}
+		for _, n := range notes {
+			ok, err := fs.Update(store, n.Path, func(body string) (string, bool) {
+				return Rewrite(body, from, to)
+			})
+			if errors.Is(err, fs.ErrConflict) {
+				busy = append(busy, n.ID)
+				continue
+			}
+			if err != nil {
+				return changed, fmt.Errorf("retag note %s: %w", n.ID, err)
+			}
+			if ok {
+				changed = append(changed, n)
+			}
+		}
+	}
+	if len(busy) > 0 {
+		return changed, fmt.Errorf("retag notes %s: %w", strings.Join(busy, ", "), fs.ErrConflict)
+	}
+	return changed, nil
+}
+
+// within reports whether tag is parent or nested below it.
+func within(tag, parent string) bool {
+	return tag == parent || strings.HasPrefix(tag, parent+"/")
+}
+
+func unique(in []string) []string {
+	seen := make(map[string]bool, len(in))
+	var out []string
+	for _, t := range in {
+		if t = Normalize(t); t != "" && !seen[t] {
+			seen[t] = true
+			out = append(out, t)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// inlineTag is one #tag in content; start and end span the '#' and the name.
+type inlineTag struct {
+	name       string
+	start, end int
+}
+
+// inline finds #tags in content outside code. A tag must follow the start
+// of a line, whitespace or an opening bracket, so headings ("# Title"),
+// URL fragments and issue numbers ("#12") are not tags.
+func inline(content string) []inlineTag {
+	var out []inlineTag
+	fence := ""
+	off := 0
+	for _, line := range strings.Split(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case fence != "":
+			if strings.HasPrefix(trimmed, fence) {
+				fence = ""
+			}
+		case strings.HasPrefix(trimmed, "```"):
+			fence = "```"
+		case strings.HasPrefix(trimmed, "~~~"):
+			fence = "~~~"
+		default:
+			out = append(out, inlineInLine(line, off)...)
+		}
+		off += len(line) + 1
+	}
+	return out
+}
+
+func inlineInLine(line string, off int) []inlineTag {
+	var out []inlineTag
+	inCode := false
+	prev := ' '
+	for i := 0; i < len(line); {
+		r, size := utf8.DecodeRuneInString(line[i:])
+		switch {
+		case r == '`':
+			inCode = !inCode
+		case r == '#' && !inCode && (unicode.IsSpace(prev) || strings.ContainsRune("([{", prev)):
+			end := i + 1
+			for end < len(line) {
+				r2, s2 := utf8.DecodeRuneInString(line[end:])
+				if !isTagRune(r2) {
+					break
+				}
+				end += s2
+			}
+			name := strings.TrimRight(line[i+1:end], "/")
+			end = i + 1 + len(name)
+			if strings.IndexFunc(name, func(r rune) bool { return !unicode.IsDigit(r) && r != '/' }) >= 0 {
+				out = append(out, inlineTag{name: strings.ToLower(name), start: off + i, end: off + end})
+				prev = 'x'
+				i = end
+				continue
+			}
+		}
+		prev = r
+		i += size
+	}
+	return out
+}
+
+func isTagRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '-' || r == '/'
+}
@@ -0,0 +1,76 @@
+package tags
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/internet-kid/tenote/internal/storage/fs"
+	"github.com/internet-kid/tenote/internal/storage/memory"
+	"github.com/internet-kid/tenote/internal/storage/memory/memtest"
+)
+
+func TestRetag(t *testing.T) {
+	mem := memory.NewStore()
+	a := memtest.NewNote(t, mem, "---\ntags: [work]\n---\n# A\n")
+	b := memtest.NewNote(t, mem, "# B\nabout #work/clients\n")
+	c := memtest.NewNote(t, mem, "# C\nnothing here\n")
+
+	changed, err := Retag(mem, []string{"work"}, "job")
+	if err != nil {
+		t.Fatalf("Retag: %v", err)
+	}
+	if len(changed) != 2 {
+		t.Fatalf("Retag changed %+v, want 2 notes", changed)
+	}
+	for path, want := range map[string]string{
+		a.Path: "---\ntags:\n    - job\n---\n# A\n",
+		b.Path: "# B\nabout #job/clients\n",
+		c.Path: "# C\nnothing here\n",
+	} {
+		if got, _ := mem.ReadBody(path); got != want {
+			t.Fatalf("note = %q, want %q", got, want)
+		}
+	}
+}
+
+func TestRetagKeepsConcurrentEdit(t *testing.T) {
+	mem := memory.NewStore()
+	n := memtest.NewNote(t, mem, "# A\n#work\n")
+	store := &memtest.RacingStore{Store: mem, Path: n.Path, Races: 1}
+
+	changed, err := Retag(store, []string{"work"}, "job")
+	if err != nil || len(changed) != 1 {
+		t.Fatalf("Retag = %+v, %v", changed, err)
+	}
+	if got, want := mustRead(t, mem, n.Path), "# A\n#job\nedited elsewhere\n"; got != want {
+		t.Fatalf("note = %q, want %q", got, want)
+	}
+}
+
+func TestRetagReportsBusyNote(t *testing.T) {
+	mem := memory.NewStore()
+	busy := memtest.NewNote(t, mem, "# Busy\n#work\n")
+	other := memtest.NewNote(t, mem, "# Other\n#work\n")
+	store := &memtest.RacingStore{Store: mem, Path: busy.Path, Races: 100}
+
+	changed, err := Retag(store, []string{"work"}, "job")
+	if !errors.Is(err, fs.ErrConflict) || !strings.Contains(err.Error(), busy.ID) {
+		t.Fatalf("Retag error = %v, want a conflict naming %s", err, busy.ID)
+	}
+	if len(changed) != 1 || changed[0].ID != other.ID {
+		t.Fatalf("Retag changed %+v, want only %s", changed, other.ID)
+	}
+	if got := mustRead(t, mem, busy.Path); strings.Contains(got, "#job") || !strings.Contains(got, "edited elsewhere") {
+		t.Fatalf("busy note = %q, want the other edits and the old tag", got)
+	}
+}
+
+func mustRead(t *testing.T, s *memory.Store, path string) string {
+	t.Helper()
+	body, err := s.ReadBody(path)
+	if err != nil {
+		t.Fatalf("ReadBody: %v", err)
+	}
+	return body
+}
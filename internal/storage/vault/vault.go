@@ -0,0 +1,227 @@
+// Package vault encrypts note data at rest with a key derived from a
+// passphrase.
+//
+// A vault is a small header file in the store's meta directory recording
+// the key derivation parameters (Argon2id) and a check value that tells a
+// wrong passphrase from a right one. Data is sealed with AES-256-GCM:
+//
+//	tenote-vault-v1\n | 12-byte nonce | ciphertext and tag
+//
+// Data without that header is passed through by Key.Open, so a store can
+// be read while it is being migrated in either direction.
+package vault
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"golang.org/x/crypto/argon2"
+)
+
+const (
+	fileName = "vault.json"
+	filePerm = 0o600
+	version  = 1
+
+	magic    = "tenote-vault-v1\n"
+	keyLen   = 32
+	saltLen  = 16
+	checkMsg = "tenote vault check"
+
+	// Argon2id parameters for new vaults; existing vaults keep the ones
+	// they were created with.
+	argonTime    = 3
+	argonMemory  = 64 * 1024 // KiB
+	argonThreads = 4
+)
+
+var (
+	// ErrLocked is returned when an encrypted store is opened without its
+	// key, or a key is used after Wipe.
+	ErrLocked = errors.New("note store is encrypted and locked")
+	// ErrPassphrase is returned by Unlock for a wrong passphrase.
+	ErrPassphrase = errors.New("wrong passphrase")
+)
+
+// header is the vault file.
+type header struct {
+	Version int    `json:"version"`
+	KDF     string `json:"kdf"`
+	Salt    []byte `json:"salt"`
+	Time    uint32 `json:"time"`
+	Memory  uint32 `json:"memory"`
+	Threads uint8  `json:"threads"`
+	Check   []byte `json:"check"`
+}
+
+// Key seals and opens note data. It is safe for concurrent use.
+type Key struct {
+	mu  sync.Mutex
+	key []byte
+}
+
+// Exists reports whether dir holds a vault, i.e. whether the store it
+// belongs to is encrypted.
+func Exists(dir string) bool {
+	_, err := os.Stat(filepath.Join(dir, fileName))
+	return err == nil
+}
+
+// Create sets up a new vault in dir and returns its key.
+func Create(dir, passphrase string) (*Key, error) {
+	if passphrase == "" {
+		return nil, errors.New("passphrase must not be empty")
+	}
+	if Exists(dir) {
+		return nil, fmt.Errorf("vault already exists in %q", dir)
+	}
+
+	h := header{
+		Version: version,
+		KDF:     "argon2id",
+		Salt:    make([]byte, saltLen),
+		Time:    argonTime,
+		Memory:  argonMemory,
+		Threads: argonThreads,
+	}
+	if _, err := rand.Read(h.Salt); err != nil {
+		return nil, fmt.Errorf("generate salt: %w", err)
+	}
+	k := &Key{key: h.derive(passphrase)}
+	check, err := k.Seal([]byte(checkMsg))
+	if err != nil {
+		return nil, err
+	}
+	h.Check = check
+
+	b, err := json.MarshalIndent(h, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("marshal vault: %w", err)
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("create vault dir: %w", err)
+	}
+	path := filepath.Join(dir, fileName)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, b, filePerm); err != nil {
+		return nil, fmt.Errorf("write vault %q: %w", tmp, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return nil, fmt.Errorf("replace vault %q: %w", path, err)
+	}
+	return k, nil
+}
+
+// Unlock derives the key of the vault in dir from passphrase.
+func Unlock(dir, passphrase string) (*Key, error) {
+	path := filepath.Join(dir, fileName)
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read vault %q: %w", path, err)
+	}
+	var h header
+	if err := json.Unmarshal(b, &h); err != nil {
+		return nil, fmt.Errorf("parse vault %q: %w", path, err)
+	}
+	if h.Version != version || h.KDF != "argon2id" {
+		return nil, fmt.Errorf("unsupported vault %q: version %d, kdf %q", path, h.Version, h.KDF)
+	}
+
+	k := &Key{key: h.derive(passphrase)}
+	check, err := k.Open(h.Check)
+	if err != nil || !IsSealed(h.Check) || subtle.ConstantTimeCompare(check, []byte(checkMsg)) != 1 {
+		k.Wipe()
+		return nil, ErrPassphrase
+	}
+	return k, nil
+}
+
+// Remove deletes the vault in dir. The store's files must have been
+// decrypted first.
+func Remove(dir string) error {
+	path := filepath.Join(dir, fileName)
+	if err := os.Remove(path); err != nil {
+		return fmt.Errorf("remove vault %q: %w", path, err)
+	}
+	return nil
+}
+
+func (h header) derive(passphrase string) []byte {
+	return argon2.IDKey([]byte(passphrase), h.Salt, h.Time, h.Memory, h.Threads, keyLen)
+}
+
+// IsSealed reports whether data was produced by Key.Seal.
+func IsSealed(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(magic))
+}
+
+// Seal encrypts plain.
+func (k *Key) Seal(plain []byte) ([]byte, error) {
+	aead, err := k.aead()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, len(magic)+aead.NonceSize(), len(magic)+aead.NonceSize()+len(plain)+aead.Overhead())
+	copy(out, magic)
+	nonce := out[len(magic):]
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("generate nonce: %w", err)
+	}
+	return aead.Seal(out, nonce, plain, []byte(magic)), nil
+}
+
+// Open decrypts data sealed with k. Data that is not sealed is returned
+// unchanged.
+func (k *Key) Open(data []byte) ([]byte, error) {
+	if !IsSealed(data) {
+		return data, nil
+	}
+	aead, err := k.aead()
+	if err != nil {
+		return nil, err
+	}
+	rest := data[len(magic):]
+	if len(rest) < aead.NonceSize() {
+		return nil, errors.New("decrypt: data is truncated")
+	}
+	plain, err := aead.Open(nil, rest[:aead.NonceSize()], rest[aead.NonceSize():], []byte(magic))
+	if err != nil {
+		return nil, fmt.Errorf("decrypt: %w", err)
+	}
+	return plain, nil
+}
+
+// Wipe zeroes the key. Seal and Open fail with ErrLocked afterwards.
+func (k *Key) Wipe() {
+	if k == nil {
+		return
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	clear(k.key)
+	k.key = nil
+}
+
+// aead builds the cipher for each call so no expanded copy of the key
+// outlives Wipe.
+func (k *Key) aead() (cipher.AEAD, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.key == nil {
+		return nil, ErrLocked
+	}
+	block, err := aes.NewCipher(k.key)
+	if err != nil {
+		return nil, fmt.Errorf("init cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,88 @@
+package vault
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestSealOpen(t *testing.T) {
+	dir := t.TempDir()
+	key, err := Create(dir, "correct horse")
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	defer key.Wipe()
+
+	plain := []byte("# Secret\nnothing to see\n")
+	sealed, err := key.Seal(plain)
+	if err != nil {
+		t.Fatalf("Seal: %v", err)
+	}
+	if !IsSealed(sealed) || bytes.Contains(sealed, []byte("Secret")) {
+		t.Fatalf("Seal = %q, want sealed data", sealed)
+	}
+	again, _ := key.Seal(plain)
+	if bytes.Equal(sealed, again) {
+		t.Fatal("Seal reused a nonce")
+	}
+
+	// The key unlocked later from the passphrase opens what was sealed.
+	other, err := Unlock(dir, "correct horse")
+	if err != nil {
+		t.Fatalf("Unlock: %v", err)
+	}
+	defer other.Wipe()
+	got, err := other.Open(sealed)
+	if err != nil || !bytes.Equal(got, plain) {
+		t.Fatalf("Open = %q, %v; want %q", got, err, plain)
+	}
+
+	// Plain files of a store being migrated read as they are.
+	if got, err := other.Open(plain); err != nil || !bytes.Equal(got, plain) {
+		t.Fatalf("Open(plain) = %q, %v", got, err)
+	}
+
+	tampered := bytes.Clone(sealed)
+	tampered[len(tampered)-1] ^= 1
+	if _, err := other.Open(tampered); err == nil {
+		t.Fatal("Open accepted tampered data")
+	}
+	if _, err := other.Open(sealed[:len(magic)+2]); err == nil {
+		t.Fatal("Open accepted truncated data")
+	}
+}
+
+func TestUnlockWrongPassphrase(t *testing.T) {
+	dir := t.TempDir()
+	key, err := Create(dir, "correct horse")
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	key.Wipe()
+
+	if _, err := Unlock(dir, "battery staple"); !errors.Is(err, ErrPassphrase) {
+		t.Fatalf("Unlock with a wrong passphrase = %v, want ErrPassphrase", err)
+	}
+	if _, err := Create(dir, "battery staple"); err == nil {
+		t.Fatal("Create replaced an existing vault")
+	}
+	if !Exists(dir) {
+		t.Fatal("Exists = false for a created vault")
+	}
+}
+
+func TestWipe(t *testing.T) {
+	key, err := Create(t.TempDir(), "correct horse")
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	sealed, _ := key.Seal([]byte("body"))
+	key.Wipe()
+	if _, err := key.Seal([]byte("body")); !errors.Is(err, ErrLocked) {
+		t.Fatalf("Seal after Wipe = %v, want ErrLocked", err)
+	}
+	if _, err := key.Open(sealed); !errors.Is(err, ErrLocked) {
+		t.Fatalf("Open after Wipe = %v, want ErrLocked", err)
+	}
+}
@@ -0,0 +1,280 @@
+// Package watch reports changes made to note directories by anyone: other
+// tenote instances, sync tools or editors. It uses the platform's file
+// notifications and falls back to polling where those are unavailable,
+// e.g. on some network filesystems or when the watch limit is reached.
+package watch
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+const (
+	// settle groups the bursts of events a single save produces.
+	settle = 150 * time.Millisecond
+
+	DefaultPollInterval = 2 * time.Second
+)
+
+// newNotifier opens the platform's file notifications. Tests replace it to
+// make them unavailable.
+var newNotifier = fsnotify.NewWatcher
+
+// Event lists the notes and directories that were added, removed, renamed
+// or modified since the previous Event. Empty Paths means anything may
+// have changed, e.g. after the system dropped notifications.
+type Event struct {
+	Paths []string
+}
+
+// Watcher watches directory trees for changes to notes and notebooks.
+type Watcher struct {
+	events chan Event
+	done   chan struct{}
+	once   sync.Once
+
+	roots   []string
+	poll    time.Duration
+	polling bool
+	fsw     *fsnotify.Watcher
+}
+
+// Option configures a Watcher.
+type Option func(*Watcher)
+
+// WithPolling makes the Watcher poll every interval instead of using file
+// notifications.
+func WithPolling(interval time.Duration) Option {
+	return func(w *Watcher) {
+		w.polling = true
+		w.poll = interval
+	}
+}
+
+// New watches the trees below roots. Missing roots are ignored.
+func New(roots []string, opts ...Option) *Watcher {
+	w := &Watcher{
+		events: make(chan Event),
+		done:   make(chan struct{}),
+		roots:  roots,
+		poll:   DefaultPollInterval,
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+
+	if !w.polling {
+		if err := w.notify(); err != nil {
+			w.polling = true
+		}
+	}
+	if w.polling {
+		go w.pollLoop(w.snapshot())
+	} else {
+		go w.notifyLoop()
+	}
+	return w
+}
+
+// Events delivers one Event per settled batch of changes. It is closed by
+// Close.
+func (w *Watcher) Events() <-chan Event { return w.events }
+
+// Polling reports whether the Watcher fell back to polling.
+func (w *Watcher) Polling() bool { return w.polling }
+
+// Close stops watching.
+func (w *Watcher) Close() error {
+	var err error
+	w.once.Do(func() {
+		close(w.done)
+		if w.fsw != nil {
+			err = w.fsw.Close()
+		}
+	})
+	return err
+}
+
+// relevant reports whether a change to path can affect the note list:
+// notes and directories, not editor swap and backup files.
+func relevant(path string, isDir bool) bool {
+	name := filepath.Base(path)
+	if strings.HasPrefix(name, ".") {
+		return false
+	}
+	return isDir || filepath.Ext(name) == ".md"
+}
+
+// ---------------------------------------------------------------------------
+// notifications
+// ---------------------------------------------------------------------------
+
+func (w *Watcher) notify() error {
+	fsw, err := newNotifier()
+	if err != nil {
+		return err
+	}
+	for _, root := range w.roots {
+		if err := addTree(fsw, root); err != nil {
+			fsw.Close()
+			return err
+		}
+	}
+	w.fsw = fsw
+	return nil
+}
+
+// addTree watches dir and every directory below it; notifications are not
+// recursive.
+func addTree(fsw *fsnotify.Watcher, dir string) error {
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return fsw.Add(path)
+		}
+		return nil
+	})
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+func (w *Watcher) notifyLoop() {
+	defer close(w.events)
+
+	pending := map[string]bool{}
+	var timer <-chan time.Time
+	for {
+		select {
+		case <-w.done:
+			return
+
+		case ev, ok := <-w.fsw.Events:
+			if !ok {
+				return
+			}
+			info, err := os.Stat(ev.Name)
+			gone := err != nil
+			isDir := !gone && info.IsDir()
+			if isDir && ev.Has(fsnotify.Create) {
+				// A new notebook, possibly moved in with notes inside.
+				_ = addTree(w.fsw, ev.Name)
+			}
+			// A path that is gone may have been a notebook; without an
+			// extension it is taken to be one.
+			if !relevant(ev.Name, isDir || (gone && filepath.Ext(ev.Name) == "")) {
+				continue
+			}
+			pending[ev.Name] = true
+			if timer == nil {
+				timer = time.After(settle)
+			}
+
+		case _, ok := <-w.fsw.Errors:
+			if !ok {
+				return
+			}
+			// Overflows and the like: report everything as changed.
+			pending[""] = true
+			if timer == nil {
+				timer = time.After(settle)
+			}
+
+		case <-timer:
+			timer = nil
+			if !w.send(pending) {
+				return
+			}
+			pending = map[string]bool{}
+		}
+	}
+}
+
+// send delivers the pending paths as one Event. An error among them means
+// anything may have changed, so the Event names no paths at all.
+func (w *Watcher) send(pending map[string]bool) bool {
+	var ev Event
+	if !pending[""] {
+		ev.Paths = make([]string, 0, len(pending))
+		for p := range pending {
+			ev.Paths = append(ev.Paths, p)
+		}
+	}
+	select {
+	case w.events <- ev:
+		return true
+	case <-w.done:
+		return false
+	}
+}
+
+// ---------------------------------------------------------------------------
+// polling
+// ---------------------------------------------------------------------------
+
+type stamp struct {
+	mod  time.Time
+	size int64
+}
+
+func (w *Watcher) snapshot() map[string]stamp {
+	out := map[string]stamp{}
+	for _, root := range w.roots {
+		_ = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || path == root {
+				return nil
+			}
+			if !relevant(path, d.IsDir()) {
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if info, err := d.Info(); err == nil {
+				out[path] = stamp{mod: info.ModTime(), size: info.Size()}
+			}
+			return nil
+		})
+	}
+	return out
+}
+
+func (w *Watcher) pollLoop(prev map[string]stamp) {
+	defer close(w.events)
+
+	tick := time.NewTicker(w.poll)
+	defer tick.Stop()
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-tick.C:
+		}
+
+		cur := w.snapshot()
+		pending := map[string]bool{}
+		for p, s := range cur {
+			if old, ok := prev[p]; !ok || !old.mod.Equal(s.mod) || old.size != s.size {
+				pending[p] = true
+			}
+		}
+		for p := range prev {
+			if _, ok := cur[p]; !ok {
+				pending[p] = true
+			}
+		}
+		prev = cur
+		if len(pending) > 0 && !w.send(pending) {
+			return
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package watch
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// wait is how long a test waits for an event before giving up.
+const wait = 5 * time.Second
+
+// modes runs test once with file notifications and once polling.
+func modes(t *testing.T, test func(t *testing.T, opts ...Option)) {
+	t.Run("notify", func(t *testing.T) { test(t) })
+	t.Run("poll", func(t *testing.T) { test(t, WithPolling(50*time.Millisecond)) })
+}
+
+func start(t *testing.T, root string, opts ...Option) *Watcher {
+	t.Helper()
+	w := New([]string{root}, opts...)
+	t.Cleanup(func() { w.Close() })
+	if len(opts) == 0 && w.Polling() {
+		t.Skip("file notifications are unavailable")
+	}
+	return w
+}
+
+// expect waits until the events delivered name every one of paths.
+func expect(t *testing.T, w *Watcher, paths ...string) {
+	t.Helper()
+	seen := map[string]bool{}
+	deadline := time.After(wait)
+	for {
+		missing := slices.DeleteFunc(slices.Clone(paths), func(p string) bool { return seen[p] })
+		if len(missing) == 0 {
+			return
+		}
+		select {
+		case ev, ok := <-w.Events():
+			if !ok {
+				t.Fatalf("events closed while waiting for %v", missing)
+			}
+			for _, p := range ev.Paths {
+				seen[p] = true
+			}
+		case <-deadline:
+			t.Fatalf("no event for %v", missing)
+		}
+	}
+}
+
+func write(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEvents(t *testing.T) {
+	modes(t, func(t *testing.T, opts ...Option) {
+		root := t.TempDir()
+		w := start(t, root, opts...)
+		note := filepath.Join(root, "01A.md")
+		renamed := filepath.Join(root, "01B.md")
+
+		write(t, note, "# Added\n")
+		expect(t, w, note)
+
+		write(t, note, "# Modified, and longer\n")
+		expect(t, w, note)
+
+		if err := os.Rename(note, renamed); err != nil {
+			t.Fatal(err)
+		}
+		expect(t, w, note, renamed)
+
+		if err := os.Remove(renamed); err != nil {
+			t.Fatal(err)
+		}
+		expect(t, w, renamed)
+	})
+}
+
+func TestNotebookEvents(t *testing.T) {
+	modes(t, func(t *testing.T, opts ...Option) {
+		root := t.TempDir()
+		w := start(t, root, opts...)
+		nb := filepath.Join(root, "work")
+
+		if err := os.Mkdir(nb, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		expect(t, w, nb)
+
+		// Notes in a notebook made after watching started are seen too.
+		note := filepath.Join(nb, "01A.md")
+		write(t, note, "# In a notebook\n")
+		expect(t, w, note)
+	})
+}
+
+func TestIgnoresHiddenFiles(t *testing.T) {
+	modes(t, func(t *testing.T, opts ...Option) {
+		root := t.TempDir()
+		w := start(t, root, opts...)
+
+		write(t, filepath.Join(root, ".01A.md.swp"), "swap")
+		write(t, filepath.Join(root, "notes.txt"), "not a note")
+		note := filepath.Join(root, "01A.md")
+		write(t, note, "# Note\n")
+
+		select {
+		case ev := <-w.Events():
+			if !slices.Equal(ev.Paths, []string{note}) {
+				t.Fatalf("event paths = %v, want only %s", ev.Paths, note)
+			}
+		case <-time.After(wait):
+			t.Fatal("no event")
+		}
+	})
+}
+
+func TestErrorMeansEverything(t *testing.T) {
+	root := t.TempDir()
+	w := start(t, root)
+
+	// A change and an overflow settle into the same batch.
+	note := filepath.Join(root, "01A.md")
+	write(t, note, "# Note\n")
+	w.fsw.Errors <- fsnotify.ErrEventOverflow
+
+	select {
+	case ev := <-w.Events():
+		if len(ev.Paths) != 0 {
+			t.Fatalf("event paths = %v after an overflow, want none for a full rescan", ev.Paths)
+		}
+	case <-time.After(wait):
+		t.Fatal("no event")
+	}
+}
+
+func TestFallBackToPolling(t *testing.T) {
+	orig := newNotifier
+	newNotifier = func() (*fsnotify.Watcher, error) { return nil, errors.New("too many watches") }
+	t.Cleanup(func() { newNotifier = orig })
+
+	root := t.TempDir()
+	w := New([]string{root}, func(w *Watcher) { w.poll = 50 * time.Millisecond })
+	defer w.Close()
+	if !w.Polling() {
+		t.Fatal("Polling() = false without file notifications")
+	}
+
+	note := filepath.Join(root, "01A.md")
+	write(t, note, "# Polled\n")
+	expect(t, w, note)
+}
+
+func TestPollInterval(t *testing.T) {
+	const interval = 400 * time.Millisecond
+	root := t.TempDir()
+	begin := time.Now()
+	w := New([]string{root}, WithPolling(interval))
+	defer w.Close()
+
+	note := filepath.Join(root, "01A.md")
+	write(t, note, "# Polled\n")
+	expect(t, w, note)
+	if took := time.Since(begin); took < interval {
+		t.Fatalf("change reported after %v, before the first poll at %v", took, interval)
+	}
+
+	// Nothing is reported while nothing changes.
+	select {
+	case ev := <-w.Events():
+		t.Fatalf("event %v without a change", ev.Paths)
+	case <-time.After(2 * interval):
+	}
+}
+
+func TestClose(t *testing.T) {
+	modes(t, func(t *testing.T, opts ...Option) {
+		w := start(t, t.TempDir(), opts...)
+		if err := w.Close(); err != nil {
+			t.Fatalf("Close: %v", err)
+		}
+		select {
+		case _, ok := <-w.Events():
+			if ok {
+				t.Fatal("event after Close")
+			}
+		case <-time.After(wait):
+			t.Fatal("events not closed by Close")
+		}
+		if err := w.Close(); err != nil {
+			t.Fatalf("second Close: %v", err)
+		}
+	})
+}
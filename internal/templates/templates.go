@@ -0,0 +1,158 @@
+// Package templates fills in the note templates kept in the templates
+// directory under the storage root.
+//
+// A template is a Markdown file whose name, without the extension, names
+// the template. Its body may use variables in double braces:
+//
+//	{{date}}   today's date, 2006-01-02
+//	{{time}}   the time, 15:04
+//	{{uuid}}   a random UUID
+//	{{title}}  the note's title, asked for
+//
+// date and time take a Go layout after a colon, e.g. {{date:Monday, January
+// 2}}. Any other variable is a field asked for when a note is made from the
+// template; text after a colon is the field's default, e.g.
+// {{status:draft}}.
+package templates
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Ext is the extension of template files.
+const Ext = ".md"
+
+// ErrNotFound is returned by Find for a template that does not exist.
+var ErrNotFound = errors.New("template not found")
+
+// Template is a note template.
+type Template struct {
+	Name string
+	Body string
+}
+
+// Field is a value asked for when a note is made from a template.
+type Field struct {
+	Name    string
+	Default string
+}
+
+// variable matches {{name}} and {{name:arg}}. Names may contain spaces, so
+// fields can read as prompts; spaces around the name and arg are not part
+// of them.
+var variable = regexp.MustCompile(`\{\{\s*([\pL\pN_][\pL\pN_ .-]*?)\s*(?::\s*([^}]*?))?\s*\}\}`)
+
+// List reads the templates in dir, sorted by name. A missing dir has none.
+func List(dir string) ([]Template, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read templates %q: %w", dir, err)
+	}
+	var out []Template
+	for _, e := range entries {
+		name, ok := strings.CutSuffix(e.Name(), Ext)
+		if !ok || e.IsDir() || strings.HasPrefix(name, ".") {
+			continue
+		}
+		t, err := read(dir, name)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, t)
+	}
+	sort.Slice(out, func(i, j int) bool { return strings.ToLower(out[i].Name) < strings.ToLower(out[j].Name) })
+	return out, nil
+}
+
+// Find reads the template called name from dir.
+func Find(dir, name string) (Template, error) {
+	if name == "" || strings.ContainsAny(name, `/\`) || strings.HasPrefix(name, ".") {
+		return Template{}, fmt.Errorf("template %q: %w", name, ErrNotFound)
+	}
+	t, err := read(dir, strings.TrimSuffix(name, Ext))
+	if errors.Is(err, os.ErrNotExist) {
+		return Template{}, fmt.Errorf("template %q: %w", name, ErrNotFound)
+	}
+	return t, err
+}
+
+func read(dir, name string) (Template, error) {
+	path := filepath.Join(dir, name+Ext)
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return Template{}, fmt.Errorf("read template %q: %w", path, err)
+	}
+	return Template{Name: name, Body: string(b)}, nil
+}
+
+// Fields returns the fields t asks for, in the order they first appear.
+// The title is one of them when the template uses it.
+func (t Template) Fields() []Field {
+	var out []Field
+	seen := make(map[string]bool)
+	for _, m := range variable.FindAllStringSubmatch(t.Body, -1) {
+		name := m[1]
+		if builtin(name) || seen[name] {
+			continue
+		}
+		seen[name] = true
+		out = append(out, Field{Name: name, Default: m[2]})
+	}
+	return out
+}
+
+// Fill returns the body of a note made from t at now. values holds the
+// fields by name; a field without a value gets its default.
+func (t Template) Fill(now time.Time, values map[string]string) string {
+	return variable.ReplaceAllStringFunc(t.Body, func(s string) string {
+		m := variable.FindStringSubmatch(s)
+		name, arg := m[1], m[2]
+		switch name {
+		case "date":
+			if arg == "" {
+				arg = "2006-01-02"
+			}
+			return now.Format(arg)
+		case "time":
+			if arg == "" {
+				arg = "15:04"
+			}
+			return now.Format(arg)
+		case "uuid":
+			return newUUID()
+		}
+		if v, ok := values[name]; ok {
+			return v
+		}
+		return arg
+	})
+}
+
+// builtin reports whether name is a variable filled in without asking.
+func builtin(name string) bool {
+	switch name {
+	case "date", "time", "uuid":
+		return true
+	}
+	return false
+}
+
+// newUUID returns a random (version 4) UUID.
+func newUUID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
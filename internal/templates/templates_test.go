@@ -0,0 +1,115 @@
+package templates
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestFields(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want []Field
+	}{
+		{
+			name: "built-ins only",
+			body: "{{date}} {{time:15:04:05}} {{uuid}}",
+		},
+		{
+			name: "title",
+			body: "# {{title}}\n",
+			want: []Field{{Name: "title"}},
+		},
+		{
+			name: "default after the colon",
+			body: "status: {{status:draft}}\nurl: {{link:http://example.com}}",
+			want: []Field{{Name: "status", Default: "draft"}, {Name: "link", Default: "http://example.com"}},
+		},
+		{
+			name: "names with spaces",
+			body: "{{ Who was there }} {{ What did we decide : nothing yet }} {{ Why? }}",
+			want: []Field{{Name: "Who was there"}, {Name: "What did we decide", Default: "nothing yet"}},
+		},
+		{
+			name: "duplicate fields",
+			body: "{{project:tenote}} and {{project}} again, {{ project : other }}",
+			want: []Field{{Name: "project", Default: "tenote"}},
+		},
+		{
+			name: "not variables",
+			body: "{{}} {{ }} {{-x}} {single}",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Template{Body: tt.body}.Fields()
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("Fields = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFill(t *testing.T) {
+	now := time.Date(2026, 10, 17, 9, 5, 7, 0, time.UTC)
+	tests := []struct {
+		name   string
+		body   string
+		values map[string]string
+		want   string
+	}{
+		{
+			name: "date and time",
+			body: "{{date}} {{time}}",
+			want: "2026-10-17 09:05",
+		},
+		{
+			name: "layouts",
+			body: "{{date:Monday, January 2}} at {{time:15:04:05}}",
+			want: "Saturday, October 17 at 09:05:07",
+		},
+		{
+			name:   "values and defaults",
+			body:   "# {{title}}\nstatus: {{status:draft}}\nowner: {{owner}}",
+			values: map[string]string{"title": "Plan"},
+			want:   "# Plan\nstatus: draft\nowner: ",
+		},
+		{
+			name:   "a value over the default",
+			body:   "status: {{status:draft}}",
+			values: map[string]string{"status": "done"},
+			want:   "status: done",
+		},
+		{
+			name:   "names with spaces",
+			body:   "{{ Who was there }}: {{ What did we decide : nothing yet }}",
+			values: map[string]string{"Who was there": "Ann, Bo"},
+			want:   "Ann, Bo: nothing yet",
+		},
+		{
+			name:   "duplicate fields",
+			body:   "{{project:tenote}} / {{project}}",
+			values: map[string]string{"project": "notes"},
+			want:   "notes / notes",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Template{Body: tt.body}.Fill(now, tt.values)
+			if got != tt.want {
+				t.Fatalf("Fill = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFillUUID(t *testing.T) {
+	got := Template{Body: "{{uuid}} {{uuid}}"}.Fill(time.Now(), nil)
+	uuid := `[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}`
+	m := regexp.MustCompile(`^(` + uuid + `) (` + uuid + `)$`).FindStringSubmatch(got)
+	if m == nil || m[1] == m[2] {
+		t.Fatalf("Fill = %q, want two different UUIDs", got)
+	}
+}
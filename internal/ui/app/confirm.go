@@ -0,0 +1,52 @@
+package app
+
+import (
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// startConfirm asks what to do with unsaved changes before leaving the
+// editor, or quitting with quit set.
+func (m *Model) startConfirm(quit bool) {
+	m.confirmFrom = m.mode
+	m.confirmQuit = quit
+	m.mode = modeConfirm
+	m.editor.Blur()
+	if quit {
+		m.status = "Quit with unsaved changes? s save, d discard, esc keep editing"
+	} else {
+		m.status = "Leave with unsaved changes? s save, d discard, esc keep editing"
+	}
+}
+
+func (m Model) updateConfirmMode(msg tea.KeyMsg) (Model, tea.Cmd) {
+	switch {
+	case key.Matches(msg, m.keys.Cancel):
+		m.mode = m.confirmFrom
+		m.status = ""
+		if m.mode == modeEdit {
+			return m, m.editor.Focus()
+		}
+		return m, nil
+
+	case key.Matches(msg, m.keys.SaveChanges):
+		// The editor takes focus again if the save does not go through.
+		m.mode = modeEdit
+		if cmd := m.saveEdit(m.confirmQuit); cmd != nil {
+			return m, cmd
+		}
+		return m, m.editor.Focus()
+
+	case key.Matches(msg, m.keys.Discard):
+		cmd := m.exitEditMode("Discarded changes")
+		if m.confirmQuit {
+			return m, m.quit()
+		}
+		return m, cmd
+	}
+	return m, nil
+}
+
+type confirmKeyMap struct{ KeyMap }
+
+func (k confirmKeyMap) ShortHelp() []key.Binding { return k.KeyMap.ConfirmShortHelp() }
@@ -0,0 +1,90 @@
+package app
+
+import (
+	"fmt"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/internet-kid/tenote/internal/diff"
+	"github.com/internet-kid/tenote/internal/storage/fs"
+)
+
+// startConflict is entered when a save finds that the note changed on
+// disk since editing began; disk and version are the note as it is there
+// now, or err says why it could not be read. The preview shows how the
+// edit differs from the note on disk, and the user picks what to keep.
+func (m *Model) startConflict(disk string, version fs.Version, err error) {
+	if err != nil {
+		m.status = "read error: " + err.Error()
+		return
+	}
+
+	m.mode = modeConflict
+	m.conflictDisk = disk
+	m.conflictVer = version
+	m.editor.Blur()
+	m.focus = focusPreview
+
+	m.previewErr = nil
+	m.previewContent = colorDiff(diff.Unified("on disk", "yours", disk, m.editor.Value(), diffContext))
+	m.preview.SetContent(m.previewContent)
+	m.preview.GotoTop()
+	m.status = "The note changed on disk while you were editing it"
+}
+
+func (m Model) updateConflictMode(msg tea.KeyMsg) (Model, tea.Cmd) {
+	switch {
+	case key.Matches(msg, m.keys.Cancel):
+		m.status = "Back to editing; saving will ask again"
+		return m.resumeEdit(false)
+
+	case key.Matches(msg, m.keys.Overwrite):
+		m.editBase = m.conflictVer
+		m.mode = modeEdit
+		return m, m.saveEdit(false)
+
+	case key.Matches(msg, m.keys.Reload):
+		m.editor.SetValue(m.conflictDisk)
+		m.editor.CursorEnd()
+		m.dirty = false
+		m.status = "Reloaded from disk; your changes were discarded"
+		return m.resumeEdit(true)
+
+	case key.Matches(msg, m.keys.Merge):
+		merged, conflicts := diff.Merge(m.editOrig, m.editor.Value(), m.conflictDisk, "yours", "on disk")
+		m.editor.SetValue(merged)
+		m.editor.CursorEnd()
+		m.dirty = true
+		m.status = "Merged cleanly; ctrl+s to save"
+		if conflicts > 0 {
+			m.status = fmt.Sprintf("Merged with %d conflict(s); resolve the marked parts, then ctrl+s", conflicts)
+		}
+		return m.resumeEdit(true)
+
+	case key.Matches(msg, m.keys.Down):
+		m.preview.LineDown(1)
+		return m, nil
+
+	case key.Matches(msg, m.keys.Up):
+		m.preview.LineUp(1)
+		return m, nil
+	}
+	return m, nil
+}
+
+// resumeEdit goes back to the editor. With rebase the edit continues from
+// the note on disk; otherwise the old base is kept and the next save stops
+// here again.
+func (m Model) resumeEdit(rebase bool) (Model, tea.Cmd) {
+	if rebase {
+		m.editOrig, m.editBase = m.conflictDisk, m.conflictVer
+	}
+	m.mode = modeEdit
+	m.conflictDisk, m.conflictVer = "", fs.Version{}
+	return m, m.editor.Focus()
+}
+
+type conflictKeyMap struct{ KeyMap }
+
+func (k conflictKeyMap) ShortHelp() []key.Binding { return k.KeyMap.ConflictShortHelp() }
@@ -0,0 +1,317 @@
+package app
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/internet-kid/tenote/internal/config"
+	"github.com/internet-kid/tenote/internal/diff"
+	"github.com/internet-kid/tenote/internal/storage/fs"
+)
+
+const defaultDraftInterval = 5 * time.Second
+
+// draftTickMsg fires when the note being edited is due to be autosaved.
+type draftTickMsg struct{}
+
+// draftInterval returns how often the note being edited is saved as a
+// draft, or zero when drafts are off.
+func draftInterval(cfg config.AppConfig) time.Duration {
+	switch {
+	case cfg.DraftInterval < 0:
+		return 0
+	case cfg.DraftInterval == 0:
+		return defaultDraftInterval
+	}
+	return time.Duration(cfg.DraftInterval) * time.Second
+}
+
+func (m Model) scheduleDraft() tea.Cmd {
+	if m.drafter == nil || m.draftEvery <= 0 {
+		return nil
+	}
+	return tea.Tick(m.draftEvery, func(time.Time) tea.Msg { return draftTickMsg{} })
+}
+
+// editing reports whether the editor holds a note being edited.
+func (m Model) editing() bool {
+	return m.mode == modeEdit || m.mode == modeConflict || m.mode == modeConfirm
+}
+
+// draftDoneMsg reports a draft of note saved with body, or deleted when
+// dropped is set.
+type draftDoneMsg struct {
+	note    fs.Note
+	body    string
+	dropped bool
+	err     error
+}
+
+// saveDraft saves unsaved changes in the editor as a draft, unless the
+// last draft already has them.
+func (m *Model) saveDraft() tea.Cmd {
+	if m.drafter == nil || m.selected == nil || !m.dirty || !m.editing() {
+		return nil
+	}
+	body := m.editor.Value()
+	if body == m.draftBody {
+		return nil
+	}
+	n, drafter := *m.selected, m.drafter
+	return func() tea.Msg {
+		return draftDoneMsg{note: n, body: body, err: drafter.SaveDraft(n, body)}
+	}
+}
+
+// dropDraft deletes the draft of the selected note once its edit is saved
+// or given up.
+func (m *Model) dropDraft() tea.Cmd {
+	m.draftBody = ""
+	if m.drafter == nil || m.selected == nil {
+		return nil
+	}
+	return m.deleteDraft(*m.selected)
+}
+
+func (m Model) deleteDraft(n fs.Note) tea.Cmd {
+	drafter := m.drafter
+	return func() tea.Msg {
+		return draftDoneMsg{note: n, dropped: true, err: drafter.DeleteDraft(n)}
+	}
+}
+
+// finishDraft records the body a draft was saved with. A draft saved while
+// its edit was ending is deleted again.
+func (m *Model) finishDraft(msg draftDoneMsg) tea.Cmd {
+	switch {
+	case msg.err != nil:
+		m.status = "draft error: " + msg.err.Error()
+	case msg.dropped:
+	case m.editing() && m.selected != nil && m.selected.ID == msg.note.ID:
+		m.draftBody = msg.body
+	default:
+		return m.deleteDraft(msg.note)
+	}
+	return nil
+}
+
+// ---------- recovery ----------
+
+type draftItem struct {
+	d fs.Draft
+}
+
+// Title is taken from the draft, which may have renamed the note.
+func (i draftItem) Title() string { return fs.TitleFromBody(i.d.Body) }
+
+func (i draftItem) Description() string {
+	desc := i.d.Saved.Format(timeLayout)
+	switch {
+	case i.d.Note.Path == "":
+		desc += " · deleted"
+	case i.d.Note.Section == fs.SectionTrash:
+		desc += " · in trash"
+	}
+	return desc
+}
+
+func (i draftItem) FilterValue() string { return i.d.Note.Title }
+
+// findDrafts loads the drafts left behind by a session that did not end
+// cleanly. A draft that matches its note was saved after all and is
+// deleted.
+func (m Model) findDrafts() tea.Cmd {
+	if m.drafter == nil {
+		return nil
+	}
+	drafter, store := m.drafter, m.store
+	return func() tea.Msg {
+		drafts, err := drafter.Drafts()
+		if err != nil {
+			return draftsFoundMsg{err: err}
+		}
+		var left []fs.Draft
+		for _, d := range drafts {
+			if d.Note.Path != "" {
+				if body, err := store.ReadBody(d.Note.Path); err == nil && body == d.Body {
+					_ = drafter.DeleteDraft(d.Note)
+					continue
+				}
+			}
+			left = append(left, d)
+		}
+		return draftsFoundMsg{drafts: left}
+	}
+}
+
+// draftsFoundMsg carries the drafts findDrafts left to recover.
+type draftsFoundMsg struct {
+	drafts []fs.Draft
+	err    error
+}
+
+func (m *Model) finishFindDrafts(msg draftsFoundMsg) {
+	if msg.err != nil {
+		m.status = "draft error: " + msg.err.Error()
+		return
+	}
+	m.drafts = msg.drafts
+}
+
+// startRecovery lists the drafts that are left in the sidebar, each
+// previewed as the changes it would make to its note.
+func (m *Model) startRecovery() tea.Cmd {
+	if len(m.drafts) == 0 {
+		return nil
+	}
+	m.mode = modeRecover
+	m.focus = focusSidebar
+
+	items := make([]list.Item, 0, len(m.drafts))
+	for _, d := range m.drafts {
+		items = append(items, draftItem{d: d})
+	}
+	m.noteList.SetItems(items)
+	m.noteList.Select(0)
+	m.status = fmt.Sprintf("%d unsaved edit(s) from an earlier session", len(m.drafts))
+	return m.showDraftDiff()
+}
+
+func (m Model) updateRecoverMode(msg tea.KeyMsg) (Model, tea.Cmd) {
+	switch {
+	case key.Matches(msg, m.keys.Cancel):
+		m.drafts = nil
+		m.mode = modeBrowse
+		m.status = "Drafts kept; they will be offered again next time"
+		return m, m.reload(load{})
+
+	case key.Matches(msg, m.keys.Tab):
+		if m.focus == focusSidebar {
+			m.focus = focusPreview
+		} else {
+			m.focus = focusSidebar
+		}
+		return m, nil
+
+	case key.Matches(msg, m.keys.Down):
+		if m.focus == focusSidebar {
+			m.noteList.CursorDown()
+			return m, m.showDraftDiff()
+		}
+		m.preview.LineDown(1)
+		return m, nil
+
+	case key.Matches(msg, m.keys.Up):
+		if m.focus == focusSidebar {
+			m.noteList.CursorUp()
+			return m, m.showDraftDiff()
+		}
+		m.preview.LineUp(1)
+		return m, nil
+
+	case key.Matches(msg, m.keys.Recover):
+		return m.recoverDraft()
+
+	case key.Matches(msg, m.keys.Discard):
+		return m, m.discardDraft()
+	}
+	return m, nil
+}
+
+func (m *Model) selectedDraft() (int, bool) {
+	idx := m.noteList.Index()
+	return idx, idx >= 0 && idx < len(m.drafts)
+}
+
+// showDraftDiff previews what the selected draft changes in its note as
+// saved.
+func (m *Model) showDraftDiff() tea.Cmd {
+	idx, ok := m.selectedDraft()
+	if !ok {
+		return nil
+	}
+	d := m.drafts[idx]
+	m.selected = &d.Note
+	m.fitPreview()
+
+	store := m.store
+	return m.loadDiff(func() (string, error) {
+		saved, label := "", "deleted"
+		if d.Note.Path != "" {
+			body, err := store.ReadBody(d.Note.Path)
+			if err != nil {
+				return "", err
+			}
+			saved, label = body, "saved"
+		}
+		return colorDiff(diff.Unified(label, "draft", saved, d.Body, diffContext)), nil
+	})
+}
+
+// recoverDraft continues the selected draft in the editor. A trashed note
+// is restored first; the draft of a deleted note goes into a new one.
+func (m Model) recoverDraft() (Model, tea.Cmd) {
+	idx, ok := m.selectedDraft()
+	if !ok || m.busy {
+		return m, nil
+	}
+	d := m.drafts[idx]
+	m.drafts = append(m.drafts[:idx:idx], m.drafts[idx+1:]...)
+	m.mode = modeBrowse
+
+	store, drafter := m.store, m.drafter
+	return m, m.change(func() changeDoneMsg {
+		note := d.Note
+		switch {
+		case note.Path == "":
+			n, err := store.Create(fs.SectionNotes)
+			if err != nil {
+				msg := failed("create", err)
+				msg.reload = true
+				return msg
+			}
+			_ = drafter.DeleteDraft(note)
+			note = n
+		case note.Section == fs.SectionTrash:
+			n, err := store.RestoreFromTrash(note, "")
+			if err != nil {
+				msg := failed("restore", err)
+				msg.reload = true
+				return msg
+			}
+			note = n
+		}
+		l := openLoad(note)
+		l.edit, l.draft = true, &d
+		return changeDoneMsg{reload: true, load: l}
+	})
+}
+
+// discardDraft deletes the selected draft for good.
+func (m *Model) discardDraft() tea.Cmd {
+	idx, ok := m.selectedDraft()
+	if !ok {
+		return nil
+	}
+	if err := m.drafter.DeleteDraft(m.drafts[idx].Note); err != nil {
+		m.status = "draft error: " + err.Error()
+		return nil
+	}
+	m.drafts = append(m.drafts[:idx:idx], m.drafts[idx+1:]...)
+	if len(m.drafts) == 0 {
+		m.mode = modeBrowse
+		m.status = "Draft discarded"
+		return m.reload(load{})
+	}
+	m.startRecovery()
+	m.noteList.Select(min(idx, len(m.drafts)-1))
+	return m.showDraftDiff()
+}
+
+type recoverKeyMap struct{ KeyMap }
+
+func (k recoverKeyMap) ShortHelp() []key.Binding { return k.KeyMap.RecoverShortHelp() }
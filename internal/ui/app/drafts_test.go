@@ -0,0 +1,261 @@
+package app
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/internet-kid/tenote/internal/config"
+	"github.com/internet-kid/tenote/internal/storage"
+	"github.com/internet-kid/tenote/internal/storage/fs"
+)
+
+// pumpWait is how long pump waits for a cmd before taking it to be one
+// that waits for the user or a timer, like the draft and lock ticks.
+const pumpWait = 300 * time.Millisecond
+
+// pump runs cmd and feeds the msgs it produces back into m until no cmd
+// is left. It reports whether one of them quit the program.
+func pump(t *testing.T, m Model, cmd tea.Cmd) (Model, bool) {
+	t.Helper()
+	quit := false
+	queue := []tea.Cmd{cmd}
+	for len(queue) > 0 {
+		c := queue[0]
+		queue = queue[1:]
+		if c == nil {
+			continue
+		}
+		got := make(chan tea.Msg, 1)
+		go func() { got <- c() }()
+		var msg tea.Msg
+		select {
+		case msg = <-got:
+		case <-time.After(pumpWait):
+			continue
+		}
+		if _, ok := msg.(tea.QuitMsg); ok {
+			quit = true
+			continue
+		}
+		// Batches and sequences are slices of cmds.
+		if v := reflect.ValueOf(msg); v.Kind() == reflect.Slice && v.Type().Elem() == reflect.TypeOf(tea.Cmd(nil)) {
+			for i := range v.Len() {
+				queue = append(queue, v.Index(i).Interface().(tea.Cmd))
+			}
+			continue
+		}
+		next, c := m.Update(msg)
+		m = next.(Model)
+		queue = append(queue, c)
+	}
+	return m, quit
+}
+
+// press sends a key to m and runs what it starts.
+func press(t *testing.T, m Model, k tea.KeyMsg) (Model, bool) {
+	t.Helper()
+	next, cmd := m.Update(k)
+	return pump(t, next.(Model), cmd)
+}
+
+func runes(s string) tea.KeyMsg { return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(s)} }
+
+// launch sets up a store in a temporary home with one note, lets prepare
+// change it the way an earlier session may have, and starts the app on it.
+func launch(t *testing.T, body string, prepare func(s storage.NoteStore, n fs.Note)) (Model, fs.Note) {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	cfg := config.AppConfig{StorageDir: filepath.Join(home, "notes"), WatchPoll: -1}
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(home, ".config", "tenote"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(home, ".config", "tenote", "config.json"), data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := storage.Open(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := store.Create(fs.SectionNotes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.WriteBody(n.Path, body); err != nil {
+		t.Fatal(err)
+	}
+	if prepare != nil {
+		prepare(store, n)
+	}
+	if err := storage.Close(store); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := NewModel(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(m.Close)
+	next, cmd := m.Update(tea.WindowSizeMsg{Width: 120, Height: 40})
+	m, _ = pump(t, next.(Model), cmd)
+	m, _ = pump(t, m, m.Init())
+	return m, n
+}
+
+func drafts(t *testing.T, m Model) []fs.Draft {
+	t.Helper()
+	d, err := m.drafter.Drafts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func TestRecoverDraftOnLaunch(t *testing.T) {
+	m, n := launch(t, "# Plan\n", func(s storage.NoteStore, n fs.Note) {
+		if err := s.(storage.Drafter).SaveDraft(n, "# Plan\nunsaved\n"); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if m.mode != modeRecover || !strings.Contains(m.status, "1 unsaved edit") {
+		t.Fatalf("mode %v, status %q after launch; want the draft offered", m.mode, m.status)
+	}
+
+	m, _ = press(t, m, runes("r"))
+	if m.mode != modeEdit || m.editor.Value() != "# Plan\nunsaved\n" || !m.dirty {
+		t.Fatalf("mode %v, dirty %v, editor %q; want the draft being edited", m.mode, m.dirty, m.editor.Value())
+	}
+
+	m, _ = press(t, m, tea.KeyMsg{Type: tea.KeyCtrlS})
+	if body, _ := m.store.ReadBody(n.Path); body != "# Plan\nunsaved\n" {
+		t.Fatalf("note after saving the recovered draft = %q", body)
+	}
+	if d := drafts(t, m); len(d) != 0 {
+		t.Fatalf("%d draft(s) left after saving", len(d))
+	}
+}
+
+func TestDiscardDraftOnLaunch(t *testing.T) {
+	m, n := launch(t, "# Plan\n", func(s storage.NoteStore, n fs.Note) {
+		if err := s.(storage.Drafter).SaveDraft(n, "# Plan\nunsaved\n"); err != nil {
+			t.Fatal(err)
+		}
+	})
+	m, _ = press(t, m, runes("d"))
+	if m.mode != modeBrowse {
+		t.Fatalf("mode %v after discarding the only draft, want browse", m.mode)
+	}
+	if d := drafts(t, m); len(d) != 0 {
+		t.Fatalf("%d draft(s) left after discarding", len(d))
+	}
+	if body, _ := m.store.ReadBody(n.Path); body != "# Plan\n" {
+		t.Fatalf("discarding changed the note to %q", body)
+	}
+}
+
+func TestSavedDraftIsNotOffered(t *testing.T) {
+	// The edit was saved, but the session ended before its draft was
+	// deleted.
+	m, _ := launch(t, "# Plan\n", func(s storage.NoteStore, n fs.Note) {
+		if err := s.(storage.Drafter).SaveDraft(n, "# Plan\n"); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if m.mode != modeBrowse {
+		t.Fatalf("mode %v after launch; a draft matching its note is offered", m.mode)
+	}
+	if d := drafts(t, m); len(d) != 0 {
+		t.Fatalf("%d draft(s) left, want the saved one deleted", len(d))
+	}
+}
+
+// dirtyEdit starts editing the note and types into it.
+func dirtyEdit(t *testing.T, m Model) Model {
+	t.Helper()
+	m, _ = press(t, m, runes("e"))
+	if m.mode != modeEdit {
+		t.Fatalf("mode %v after e, want edit; status %q", m.mode, m.status)
+	}
+	m, _ = press(t, m, runes("x"))
+	if !m.dirty {
+		t.Fatal("typing does not mark the edit dirty")
+	}
+	return m
+}
+
+func TestQuitWithUnsavedChangesAsks(t *testing.T) {
+	m, n := launch(t, "# Plan\n", nil)
+	m = dirtyEdit(t, m)
+	edited := m.editor.Value()
+	ctrlC := tea.KeyMsg{Type: tea.KeyCtrlC}
+
+	m, quit := press(t, m, ctrlC)
+	if quit || m.mode != modeConfirm || !strings.Contains(m.status, "Quit with unsaved changes") {
+		t.Fatalf("quit %v, mode %v, status %q; want to be asked", quit, m.mode, m.status)
+	}
+	m, quit = press(t, m, tea.KeyMsg{Type: tea.KeyEsc})
+	if quit || m.mode != modeEdit || m.editor.Value() != edited {
+		t.Fatalf("quit %v, mode %v, editor %q after esc; want to keep editing", quit, m.mode, m.editor.Value())
+	}
+
+	// Asked twice, it quits and keeps the edit as a draft.
+	m, _ = press(t, m, ctrlC)
+	m, quit = press(t, m, ctrlC)
+	if !quit {
+		t.Fatal("ctrl+c twice does not quit")
+	}
+	d := drafts(t, m)
+	if len(d) != 1 || d[0].Body != edited {
+		t.Fatalf("drafts after quitting = %+v, want the edit", d)
+	}
+	if body, _ := m.store.ReadBody(n.Path); body != "# Plan\n" {
+		t.Fatalf("quitting saved the note as %q", body)
+	}
+}
+
+func TestQuitConfirmChoices(t *testing.T) {
+	tests := []struct {
+		name   string
+		key    tea.KeyMsg
+		saved  bool
+		drafts int
+	}{
+		{"save", runes("s"), true, 0},
+		{"discard", runes("d"), false, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, n := launch(t, "# Plan\n", nil)
+			m = dirtyEdit(t, m)
+			edited := m.editor.Value()
+
+			m, _ = press(t, m, tea.KeyMsg{Type: tea.KeyCtrlC})
+			m, quit := press(t, m, tt.key)
+			if !quit {
+				t.Fatalf("no quit after %s; status %q", tt.name, m.status)
+			}
+			want := "# Plan\n"
+			if tt.saved {
+				want = edited
+			}
+			if body, _ := m.store.ReadBody(n.Path); body != want {
+				t.Fatalf("note = %q, want %q", body, want)
+			}
+			if d := drafts(t, m); len(d) != tt.drafts {
+				t.Fatalf("%d draft(s) left, want %d", len(d), tt.drafts)
+			}
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/internet-kid/tenote/internal/editor"
+	"github.com/internet-kid/tenote/internal/storage"
+)
+
+func TestExternalEditWaitsForChange(t *testing.T) {
+	m, n := launch(t, "# Plan\n", nil)
+	body, base, err := storage.ReadVersion(m.store, n.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sess, err := editor.NewSession(t.TempDir(), n.ID, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	script := filepath.Join(t.TempDir(), "edit")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\nprintf '# Plan\\nedited outside\\n' > \"$1\"\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if out, err := sess.Command(script).CombinedOutput(); err != nil {
+		t.Fatalf("editor: %v\n%s", err, out)
+	}
+
+	// The editor exits while a change to the store runs.
+	m.busy = true
+	next, cmd := m.Update(editorFinishedMsg{note: n, sess: sess, orig: body, base: base})
+	m, _ = pump(t, next.(Model), cmd)
+	if got, _ := m.store.ReadBody(n.Path); got != body {
+		t.Fatalf("note saved as %q while a change ran", got)
+	}
+
+	next, cmd = m.Update(changeDoneMsg{status: "Moved"})
+	m, _ = pump(t, next.(Model), cmd)
+	if got, _ := m.store.ReadBody(n.Path); got != "# Plan\nedited outside\n" {
+		t.Fatalf("note after the change = %q, want the external edit; status %q", got, m.status)
+	}
+	if m.finishedEdit != nil || m.busy {
+		t.Fatalf("edit still waiting (%v) or busy (%v) after saving", m.finishedEdit != nil, m.busy)
+	}
+}
@@ -0,0 +1,245 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/internet-kid/tenote/internal/diff"
+	"github.com/internet-kid/tenote/internal/storage"
+	"github.com/internet-kid/tenote/internal/storage/fs"
+	"github.com/internet-kid/tenote/internal/storage/history"
+)
+
+const diffContext = 3
+
+var (
+	diffAddStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("#25b067"))
+	diffDelStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("196"))
+	diffHunkStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("39"))
+)
+
+type revisionItem struct {
+	rev     history.Revision
+	current bool
+}
+
+func (i revisionItem) Title() string {
+	title := i.rev.Time.Format(timeLayout)
+	if i.current {
+		title += " (current)"
+	}
+	return title
+}
+
+func (i revisionItem) Description() string {
+	return fmt.Sprintf("%s · %d bytes", i.rev.Hash[:8], i.rev.Size)
+}
+
+func (i revisionItem) FilterValue() string { return i.rev.Hash }
+
+// historyMsg carries the revisions of a note read by startHistory, along
+// with the note as it is now.
+type historyMsg struct {
+	note    fs.Note
+	revs    []history.Revision
+	current string
+	version fs.Version
+	status  string // why there is no history to show
+}
+
+// startHistory lists the revisions of the selected note in the sidebar
+// once they are read.
+func (m *Model) startHistory() (Model, tea.Cmd) {
+	if m.selected == nil || m.opening {
+		return *m, nil
+	}
+	hs, ok := m.store.(storage.Historian)
+	if !ok {
+		m.status = "history is not available for this storage backend"
+		return *m, nil
+	}
+
+	m.opening = true
+	n, store := *m.selected, m.store
+	return *m, tea.Batch(func() tea.Msg {
+		msg := historyMsg{note: n}
+		revs, err := hs.Revisions(n)
+		if err != nil {
+			msg.status = "history error: " + err.Error()
+			return msg
+		}
+		if len(revs) == 0 {
+			msg.status = "No revisions yet"
+			return msg
+		}
+		current, version, err := storage.ReadVersion(store, n.Path)
+		if err != nil {
+			msg.status = "read error: " + err.Error()
+			return msg
+		}
+		msg.revs, msg.current, msg.version = revs, current, version
+		return msg
+	}, m.spinTick())
+}
+
+// finishHistory shows the revisions, unless the user went elsewhere
+// meanwhile.
+func (m *Model) finishHistory(msg historyMsg) tea.Cmd {
+	m.opening = false
+	if m.mode != modeBrowse || m.selected == nil || m.selected.ID != msg.note.ID {
+		return nil
+	}
+	if msg.status != "" {
+		m.status = msg.status
+		return nil
+	}
+
+	m.mode = modeHistory
+	m.focus = focusSidebar
+	m.historyCurrent = msg.current
+	m.historyBase = msg.version
+	m.revisions = msg.revs
+
+	currentHash := history.Hash(msg.current)
+	items := make([]list.Item, 0, len(msg.revs))
+	for _, r := range msg.revs {
+		items = append(items, revisionItem{rev: r, current: r.Hash == currentHash})
+	}
+	m.noteList.SetItems(items)
+	m.noteList.Select(0)
+	m.status = ""
+	return m.showRevisionDiff()
+}
+
+func (m Model) updateHistoryMode(msg tea.KeyMsg) (Model, tea.Cmd) {
+	switch {
+	case key.Matches(msg, m.keys.Cancel):
+		return m, m.exitHistory()
+
+	case key.Matches(msg, m.keys.Tab):
+		if m.focus == focusSidebar {
+			m.focus = focusPreview
+		} else {
+			m.focus = focusSidebar
+		}
+		return m, nil
+
+	case key.Matches(msg, m.keys.Down):
+		if m.focus == focusSidebar {
+			m.noteList.CursorDown()
+			return m, m.showRevisionDiff()
+		}
+		m.preview.LineDown(1)
+		return m, nil
+
+	case key.Matches(msg, m.keys.Up):
+		if m.focus == focusSidebar {
+			m.noteList.CursorUp()
+			return m, m.showRevisionDiff()
+		}
+		m.preview.LineUp(1)
+		return m, nil
+
+	case key.Matches(msg, m.keys.Rollback):
+		return m, m.restoreRevision()
+	}
+
+	return m, nil
+}
+
+func (m *Model) selectedRevision() (history.Revision, bool) {
+	idx := m.noteList.Index()
+	if idx < 0 || idx >= len(m.revisions) {
+		return history.Revision{}, false
+	}
+	return m.revisions[idx], true
+}
+
+// showRevisionDiff previews what restoring the selected revision would
+// change in the current body.
+func (m *Model) showRevisionDiff() tea.Cmd {
+	rev, ok := m.selectedRevision()
+	if !ok || m.selected == nil {
+		return nil
+	}
+
+	note, hs, current := *m.selected, m.store.(storage.Historian), m.historyCurrent
+	return m.loadDiff(func() (string, error) {
+		body, err := hs.ReadRevision(note, rev.Hash)
+		if err != nil {
+			return "", err
+		}
+		d := diff.Unified("current", rev.Hash[:8], current, body, diffContext)
+		if d == "" {
+			d = blurStyle.Render("Identical to the current note.")
+		}
+		return colorDiff(d), nil
+	})
+}
+
+func (m *Model) restoreRevision() tea.Cmd {
+	rev, ok := m.selectedRevision()
+	if !ok || m.selected == nil || m.busy {
+		return nil
+	}
+
+	note, base := *m.selected, m.historyBase
+	hs, graph, store := m.store.(storage.Historian), m.links, m.store
+	l := m.leaveHistory()
+	return m.change(func() changeDoneMsg {
+		// Back in browse mode the sidebar needs reloading either way.
+		out := changeDoneMsg{status: "Restored revision from " + rev.Time.Format(timeLayout)}
+		if body, err := hs.ReadRevision(note, rev.Hash); err != nil {
+			out = failed("history", err)
+		} else if _, err := saveNote(graph, store, note, body, base); err != nil {
+			out = failed("restore", err)
+		}
+		out.reload, out.load = true, l
+		return out
+	})
+}
+
+// exitHistory goes back to browsing, with the note whose history it was
+// still selected.
+func (m *Model) exitHistory() tea.Cmd {
+	return m.reload(m.leaveHistory())
+}
+
+// leaveHistory switches back to browse mode and returns the reload that
+// brings the section's notes back.
+func (m *Model) leaveHistory() load {
+	id := ""
+	if m.selected != nil {
+		id = m.selected.ID
+	}
+	m.mode = modeBrowse
+	m.revisions = nil
+	m.historyCurrent = ""
+	return load{reselect: id}
+}
+
+func colorDiff(d string) string {
+	lines := strings.Split(d, "\n")
+	for i, l := range lines {
+		switch {
+		case strings.HasPrefix(l, "+++"), strings.HasPrefix(l, "---"):
+			lines[i] = titleStyle.Render(l)
+		case strings.HasPrefix(l, "@@"):
+			lines[i] = diffHunkStyle.Render(l)
+		case strings.HasPrefix(l, "+"):
+			lines[i] = diffAddStyle.Render(l)
+		case strings.HasPrefix(l, "-"):
+			lines[i] = diffDelStyle.Render(l)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+type historyKeyMap struct{ KeyMap }
+
+func (k historyKeyMap) ShortHelp() []key.Binding { return k.KeyMap.HistoryShortHelp() }
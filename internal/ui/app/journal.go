@@ -0,0 +1,203 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/internet-kid/tenote/internal/storage"
+	"github.com/internet-kid/tenote/internal/storage/fs"
+)
+
+var (
+	calendarDayStyle   = lipgloss.NewStyle().Reverse(true)
+	calendarTodayStyle = lipgloss.NewStyle().Underline(true)
+)
+
+// openJournal opens the journal note for day in the editor, creating it
+// from the journal template when there is none yet.
+func (m *Model) openJournal(day time.Time) tea.Cmd {
+	cfg, store := m.cfg, m.store
+	return m.change(func() changeDoneMsg {
+		n, created, err := storage.OpenJournal(cfg, store, day)
+		if err != nil {
+			return failed("journal", err)
+		}
+		status := ""
+		if created {
+			status = "Created " + n.Title
+		}
+		return changeDoneMsg{status: status, reload: true, load: load{section: fs.SectionJournal, reselect: n.ID, edit: true}}
+	})
+}
+
+// ---------- calendar ----------
+
+// calendarMsg carries the journal notes read for the calendar.
+type calendarMsg struct {
+	notes []fs.Note
+	err   error
+}
+
+// startCalendar shows a month of the journal in the preview, once its
+// notes are read.
+func (m *Model) startCalendar() tea.Cmd {
+	if m.opening {
+		return nil
+	}
+	m.opening = true
+	store := m.store
+	return tea.Batch(func() tea.Msg {
+		notes, err := store.List(fs.SectionJournal)
+		return calendarMsg{notes: notes, err: err}
+	}, m.spinTick())
+}
+
+// finishCalendar opens the calendar on the selected journal note's day,
+// or on today, unless the user went elsewhere meanwhile.
+func (m *Model) finishCalendar(msg calendarMsg) {
+	m.opening = false
+	if m.mode != modeBrowse {
+		return
+	}
+	if msg.err != nil {
+		m.status = "journal error: " + msg.err.Error()
+		return
+	}
+
+	m.journal = make(map[string]fs.Note, len(msg.notes))
+	// Notes are listed most recently changed first; that one wins a day.
+	for i := len(msg.notes) - 1; i >= 0; i-- {
+		n := msg.notes[i]
+		m.journal[dayKey(storage.JournalDay(n))] = n
+	}
+	m.calDay = storage.StartOfDay(time.Now())
+	if m.selected != nil && m.selected.Section == fs.SectionJournal {
+		m.calDay = storage.JournalDay(*m.selected)
+	}
+
+	m.mode = modeCalendar
+	m.focus = focusPreview
+	m.cancelPreview()
+	m.status = fmt.Sprintf("%d journal notes", len(m.journal))
+	m.showDay()
+}
+
+func (m Model) updateCalendarMode(msg tea.KeyMsg) (Model, tea.Cmd) {
+	switch {
+	case key.Matches(msg, m.keys.Cancel):
+		m.mode = modeBrowse
+		m.focus = focusSidebar
+		m.status = ""
+		return m, m.syncSelection()
+
+	case key.Matches(msg, m.keys.Left):
+		m.moveDay(0, -1)
+	case key.Matches(msg, m.keys.Right):
+		m.moveDay(0, 1)
+	case key.Matches(msg, m.keys.Up):
+		m.moveDay(0, -7)
+	case key.Matches(msg, m.keys.Down):
+		m.moveDay(0, 7)
+	case key.Matches(msg, m.keys.PrevMonth):
+		m.moveDay(-1, 0)
+	case key.Matches(msg, m.keys.NextMonth):
+		m.moveDay(1, 0)
+	case key.Matches(msg, m.keys.Journal):
+		m.calDay = storage.StartOfDay(time.Now())
+		m.showDay()
+
+	case key.Matches(msg, m.keys.Open):
+		m.mode = modeBrowse
+		m.focus = focusSidebar
+		return m, m.openJournal(m.calDay)
+	}
+	return m, nil
+}
+
+// moveDay moves the calendar cursor by months and days. Moving by months
+// keeps the day of the month where the month is long enough.
+func (m *Model) moveDay(months, days int) {
+	d := m.calDay
+	if months != 0 {
+		first := time.Date(d.Year(), d.Month()+time.Month(months), 1, 0, 0, 0, 0, time.Local)
+		last := first.AddDate(0, 1, -1).Day()
+		d = time.Date(first.Year(), first.Month(), min(d.Day(), last), 0, 0, 0, 0, time.Local)
+	}
+	m.calDay = d.AddDate(0, 0, days)
+	m.showDay()
+}
+
+// showDay puts the journal note of the day under the cursor, if any, in
+// the preview header.
+func (m *Model) showDay() {
+	m.selected = nil
+	if n, ok := m.journal[dayKey(m.calDay)]; ok {
+		m.selected = &n
+	}
+	m.fitPreview()
+}
+
+// renderCalendar draws the month around the cursor, weeks starting on
+// Monday. Days with a journal note are marked, and today is underlined.
+func (m Model) renderCalendar() string {
+	day := m.calDay
+	first := time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, time.Local)
+	today := storage.StartOfDay(time.Now())
+
+	var b strings.Builder
+	b.WriteString(titleStyle.Render(first.Format("January 2006")) + "\n\n")
+	b.WriteString(blurStyle.Render("Mo  Tu  We  Th  Fr  Sa  Su") + "\n")
+
+	// Leading blanks up to the first's weekday.
+	col := (int(first.Weekday()) + 6) % 7
+	b.WriteString(strings.Repeat("    ", col))
+	for d := first; d.Month() == first.Month(); d = d.AddDate(0, 0, 1) {
+		cell := fmt.Sprintf("%2d", d.Day())
+		_, has := m.journal[dayKey(d)]
+		if has {
+			cell += "•"
+		} else {
+			cell += " "
+		}
+
+		style := lipgloss.NewStyle()
+		if has {
+			style = focusStyle
+		}
+		if d.Equal(today) {
+			style = style.Inherit(calendarTodayStyle)
+		}
+		if d.Equal(day) {
+			style = style.Inherit(calendarDayStyle)
+		}
+		b.WriteString(style.Render(cell))
+
+		if col = (col + 1) % 7; col == 0 {
+			b.WriteString("\n")
+		} else {
+			b.WriteString(" ")
+		}
+	}
+
+	b.WriteString("\n\n" + day.Format(storage.JournalTitleLayout) + "\n")
+	if _, ok := m.journal[dayKey(day)]; ok {
+		b.WriteString(blurStyle.Render("enter opens its journal note"))
+	} else {
+		b.WriteString(blurStyle.Render("No journal note · enter creates one"))
+	}
+	return b.String()
+}
+
+// dayKey identifies a day in the calendar's map of journal notes.
+func dayKey(day time.Time) string {
+	return day.Format(time.DateOnly)
+}
+
+type calendarKeyMap struct{ KeyMap }
+
+func (k calendarKeyMap) ShortHelp() []key.Binding { return k.KeyMap.CalendarShortHelp() }
@@ -19,9 +19,64 @@ type KeyMap struct {
 	SectionDn key.Binding
 	New       key.Binding
 	Edit      key.Binding
+	ExtEdit   key.Binding
 	Trash     key.Binding
 	Delete    key.Binding
 	Restore   key.Binding
+	Empty     key.Binding
+	Search    key.Binding
+	History   key.Binding
+	Sync      key.Binding
+
+	// sidebar order
+	Pin   key.Binding
+	Sort  key.Binding
+	Group key.Binding
+
+	// journal
+	Journal   key.Binding
+	Calendar  key.Binding
+	PickDay   key.Binding // for help; days move with Up, Down, Left and Right
+	PrevMonth key.Binding
+	NextMonth key.Binding
+
+	// notebooks
+	NewNotebook key.Binding
+	DelNotebook key.Binding
+	Move        key.Binding
+
+	// links
+	NextLink   key.Binding
+	PrevLink   key.Binding
+	FollowLink key.Binding
+
+	// tags
+	PickTag    key.Binding
+	FilterTags key.Binding
+	TagMatch   key.Binding
+	RenameTag  key.Binding
+
+	// search mode
+	ResultUp   key.Binding
+	ResultDown key.Binding
+	Open       key.Binding
+
+	// history mode
+	Rollback key.Binding
+
+	// conflict mode
+	Overwrite key.Binding
+	Reload    key.Binding
+	Merge     key.Binding
+
+	// draft recovery and unsaved changes
+	Recover     key.Binding
+	Discard     key.Binding
+	SaveChanges key.Binding
+
+	// questions
+	Yes key.Binding
+	No  key.Binding
 
 	// edit mode
 	Save   key.Binding
@@ -78,6 +133,10 @@ func DefaultKeyMap() KeyMap {
 			key.WithKeys("e"),
 			key.WithHelp("e", "edit"),
 		),
+		ExtEdit: key.NewBinding(
+			key.WithKeys("E"),
+			key.WithHelp("E", "open in $EDITOR"),
+		),
 		Trash: key.NewBinding(
 			key.WithKeys("d"),
 			key.WithHelp("d", "to trash"),
@@ -90,6 +149,152 @@ func DefaultKeyMap() KeyMap {
 			key.WithKeys("r"),
 			key.WithHelp("r", "restore"),
 		),
+		Empty: key.NewBinding(
+			key.WithKeys("X"),
+			key.WithHelp("X", "empty trash"),
+		),
+		Search: key.NewBinding(
+			key.WithKeys("/"),
+			key.WithHelp("/", "search"),
+		),
+		History: key.NewBinding(
+			key.WithKeys("H"),
+			key.WithHelp("H", "history"),
+		),
+		Sync: key.NewBinding(
+			key.WithKeys("S"),
+			key.WithHelp("S", "git sync"),
+		),
+
+		Pin: key.NewBinding(
+			key.WithKeys("p"),
+			key.WithHelp("p", "pin/unpin"),
+		),
+		Sort: key.NewBinding(
+			key.WithKeys("s"),
+			key.WithHelp("s", "cycle sort order"),
+		),
+		Group: key.NewBinding(
+			key.WithKeys("v"),
+			key.WithHelp("v", "group by date"),
+		),
+
+		NewNotebook: key.NewBinding(
+			key.WithKeys("N"),
+			key.WithHelp("N", "new notebook"),
+		),
+		DelNotebook: key.NewBinding(
+			key.WithKeys("D"),
+			key.WithHelp("D", "delete empty notebook"),
+		),
+		Move: key.NewBinding(
+			key.WithKeys("m"),
+			key.WithHelp("m", "move to notebook"),
+		),
+
+		Journal: key.NewBinding(
+			key.WithKeys("t"),
+			key.WithHelp("t", "today's journal"),
+		),
+		Calendar: key.NewBinding(
+			key.WithKeys("c"),
+			key.WithHelp("c", "calendar"),
+		),
+		PickDay: key.NewBinding(
+			key.WithKeys("h", "j", "k", "l"),
+			key.WithHelp("hjkl", "pick day"),
+		),
+		PrevMonth: key.NewBinding(
+			key.WithKeys("[", "pgup"),
+			key.WithHelp("[", "prev month"),
+		),
+		NextMonth: key.NewBinding(
+			key.WithKeys("]", "pgdown"),
+			key.WithHelp("]", "next month"),
+		),
+
+		NextLink: key.NewBinding(
+			key.WithKeys("]"),
+			key.WithHelp("]", "next link"),
+		),
+		PrevLink: key.NewBinding(
+			key.WithKeys("["),
+			key.WithHelp("[", "prev link"),
+		),
+		FollowLink: key.NewBinding(
+			key.WithKeys("f"),
+			key.WithHelp("f", "follow link"),
+		),
+
+		PickTag: key.NewBinding(
+			key.WithKeys(" "),
+			key.WithHelp("space", "pick tag"),
+		),
+		FilterTags: key.NewBinding(
+			key.WithKeys("enter"),
+			key.WithHelp("enter", "show notes"),
+		),
+		TagMatch: key.NewBinding(
+			key.WithKeys("o"),
+			key.WithHelp("o", "all/any tags"),
+		),
+		RenameTag: key.NewBinding(
+			key.WithKeys("R"),
+			key.WithHelp("R", "rename/merge tags"),
+		),
+
+		Rollback: key.NewBinding(
+			key.WithKeys("r", "enter"),
+			key.WithHelp("r", "restore revision"),
+		),
+
+		Overwrite: key.NewBinding(
+			key.WithKeys("o"),
+			key.WithHelp("o", "overwrite"),
+		),
+		Reload: key.NewBinding(
+			key.WithKeys("r"),
+			key.WithHelp("r", "reload from disk"),
+		),
+		Merge: key.NewBinding(
+			key.WithKeys("m"),
+			key.WithHelp("m", "merge"),
+		),
+
+		Recover: key.NewBinding(
+			key.WithKeys("r", "enter"),
+			key.WithHelp("r", "recover"),
+		),
+		Discard: key.NewBinding(
+			key.WithKeys("d"),
+			key.WithHelp("d", "discard"),
+		),
+		SaveChanges: key.NewBinding(
+			key.WithKeys("s", "ctrl+s"),
+			key.WithHelp("s", "save"),
+		),
+
+		Yes: key.NewBinding(
+			key.WithKeys("y"),
+			key.WithHelp("y", "yes"),
+		),
+		No: key.NewBinding(
+			key.WithKeys("n", "esc"),
+			key.WithHelp("n", "no"),
+		),
+
+		ResultUp: key.NewBinding(
+			key.WithKeys("up", "ctrl+p"),
+			key.WithHelp("↑", "prev result"),
+		),
+		ResultDown: key.NewBinding(
+			key.WithKeys("down", "ctrl+n"),
+			key.WithHelp("↓", "next result"),
+		),
+		Open: key.NewBinding(
+			key.WithKeys("enter"),
+			key.WithHelp("enter", "open"),
+		),
 
 		Save: key.NewBinding(
 			key.WithKeys("ctrl+s"),
@@ -108,6 +313,7 @@ func (k KeyMap) ShortHelp() []key.Binding {
 		k.Tab,
 		k.New,
 		k.Edit,
+		k.Search,
 		k.Trash,
 		k.Quit,
 	}
@@ -117,7 +323,13 @@ func (k KeyMap) FullHelp() [][]key.Binding {
 	return [][]key.Binding{
 		{k.Up, k.Down},
 		{k.SectionUp, k.SectionDn},
-		{k.New, k.Edit},
+		{k.NewNotebook, k.DelNotebook, k.Move},
+		{k.New, k.Edit, k.ExtEdit},
+		{k.Search, k.History, k.Sync},
+		{k.Pin, k.Sort, k.Group},
+		{k.Journal, k.Calendar},
+		{k.NextLink, k.PrevLink, k.FollowLink},
+		{k.PickTag, k.FilterTags, k.TagMatch, k.RenameTag},
 		{k.Trash, k.Restore},
 		{k.Tab, k.Help},
 		{k.Quit},
@@ -136,6 +348,91 @@ func (k KeyMap) TrashShortHelp() []key.Binding {
 	return []key.Binding{
 		k.Delete,
 		k.Restore,
+		k.Empty,
+		k.Quit,
+	}
+}
+
+func (k KeyMap) SearchShortHelp() []key.Binding {
+	return []key.Binding{
+		k.ResultUp,
+		k.ResultDown,
+		k.Open,
+		k.Cancel,
+	}
+}
+
+func (k KeyMap) HistoryShortHelp() []key.Binding {
+	return []key.Binding{
+		k.Up,
+		k.Down,
+		k.Tab,
+		k.Rollback,
+		k.Cancel,
+	}
+}
+
+func (k KeyMap) TagsShortHelp() []key.Binding {
+	return []key.Binding{
+		k.PickTag,
+		k.FilterTags,
+		k.TagMatch,
+		k.RenameTag,
 		k.Quit,
 	}
 }
+
+func (k KeyMap) PickShortHelp() []key.Binding {
+	return []key.Binding{
+		k.Up,
+		k.Down,
+		k.Open,
+		k.Cancel,
+	}
+}
+
+func (k KeyMap) CalendarShortHelp() []key.Binding {
+	return []key.Binding{
+		k.PickDay,
+		k.PrevMonth,
+		k.NextMonth,
+		k.Journal,
+		k.Open,
+		k.Cancel,
+	}
+}
+
+func (k KeyMap) ConflictShortHelp() []key.Binding {
+	return []key.Binding{
+		k.Overwrite,
+		k.Reload,
+		k.Merge,
+		k.Cancel,
+	}
+}
+
+func (k KeyMap) RecoverShortHelp() []key.Binding {
+	return []key.Binding{
+		k.Up,
+		k.Down,
+		k.Tab,
+		k.Recover,
+		k.Discard,
+		k.Cancel,
+	}
+}
+
+func (k KeyMap) ConfirmShortHelp() []key.Binding {
+	return []key.Binding{
+		k.SaveChanges,
+		k.Discard,
+		k.Cancel,
+	}
+}
+
+func (k KeyMap) YesNoShortHelp() []key.Binding {
+	return []key.Binding{
+		k.Yes,
+		k.No,
+	}
+}
@@ -0,0 +1,186 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/internet-kid/tenote/internal/storage"
+	"github.com/internet-kid/tenote/internal/storage/fs"
+	"github.com/internet-kid/tenote/internal/storage/links"
+)
+
+// linkRef is one entry of the links panel under the preview: an outgoing
+// [[link]] or a note linking back to the selected one.
+type linkRef struct {
+	note     fs.Note
+	target   string
+	resolved bool
+	back     bool
+}
+
+func (r linkRef) label() string {
+	switch {
+	case r.back:
+		return "← " + r.note.Title
+	case r.resolved:
+		return "→ " + r.note.Title
+	default:
+		return "→ " + r.target + " · missing, f creates it"
+	}
+}
+
+// saveNote writes body to n and keeps graph current. Links to the note's
+// old title are rewritten when the title changed; the returned status says
+// so. The save fails with fs.ErrConflict when n no longer matches base; a
+// zero base always saves.
+func saveNote(graph *links.Graph, store storage.NoteStore, n fs.Note, body string, base fs.Version) (string, error) {
+	rewritten, err := graph.Write(storage.Guard(store, n.Path, base), n, body)
+	if err != nil {
+		return "", err
+	}
+	switch len(rewritten) {
+	case 0:
+	case 1:
+		return "Saved · updated links in 1 note", nil
+	default:
+		return fmt.Sprintf("Saved · updated links in %d notes", len(rewritten)), nil
+	}
+	return "Saved", nil
+}
+
+// loadLinks collects the links panel entries for n. The selection is kept
+// while the same note stays selected.
+func (m *Model) loadLinks(n fs.Note) {
+	if m.linksFor != n.ID {
+		m.linksFor = n.ID
+		m.linkIdx = -1
+	}
+
+	m.linkRefs = m.linkRefs[:0]
+	for _, r := range m.links.Outgoing(n.ID) {
+		m.linkRefs = append(m.linkRefs, linkRef{note: r.Note, target: r.Target, resolved: r.Resolved})
+	}
+	for _, b := range m.links.Backlinks(n.ID) {
+		m.linkRefs = append(m.linkRefs, linkRef{note: b, resolved: true, back: true})
+	}
+	if m.linkIdx >= len(m.linkRefs) {
+		m.linkIdx = len(m.linkRefs) - 1
+	}
+}
+
+// linksPanel renders the outgoing links and backlinks of the selected note
+// for the bottom of the preview, and the panel line of the selected entry.
+func (m Model) linksPanel() (string, int) {
+	var b strings.Builder
+	sel := -1
+	line := func(i int) {
+		if i == m.linkIdx {
+			sel = strings.Count(b.String(), "\n")
+			b.WriteString(focusStyle.Render("› "+m.linkRefs[i].label()) + "\n")
+			return
+		}
+		b.WriteString("  " + m.linkRefs[i].label() + "\n")
+	}
+
+	b.WriteString("\n\n")
+	backStart := len(m.linkRefs)
+	for i, r := range m.linkRefs {
+		if r.back {
+			backStart = i
+			break
+		}
+	}
+	if backStart > 0 {
+		b.WriteString(titleStyle.Render("Links") + "\n")
+		for i := 0; i < backStart; i++ {
+			line(i)
+		}
+	}
+	b.WriteString(titleStyle.Render("Backlinks") + "\n")
+	if backStart == len(m.linkRefs) {
+		b.WriteString(blurStyle.Render("  none") + "\n")
+	}
+	for i := backStart; i < len(m.linkRefs); i++ {
+		line(i)
+	}
+	return b.String(), sel
+}
+
+// showPreview puts the rendered note and its links panel in the preview and
+// returns the preview line of the selected link, or -1.
+func (m *Model) showPreview() int {
+	panel, sel := m.linksPanel()
+	m.previewContent = m.previewBody + panel
+	m.preview.SetContent(m.previewContent)
+	if sel < 0 {
+		return -1
+	}
+	return strings.Count(m.previewBody, "\n") + sel
+}
+
+// stepLink moves the panel selection by delta, wrapping around, and scrolls
+// the preview so the selected entry is visible.
+func (m *Model) stepLink(delta int) {
+	if m.selected == nil || len(m.linkRefs) == 0 {
+		return
+	}
+	switch {
+	case m.linkIdx < 0 && delta < 0:
+		m.linkIdx = len(m.linkRefs) - 1
+	case m.linkIdx < 0:
+		m.linkIdx = 0
+	default:
+		m.linkIdx = (m.linkIdx + delta + len(m.linkRefs)) % len(m.linkRefs)
+	}
+	if i := m.showPreview(); i < m.preview.YOffset || i >= m.preview.YOffset+m.preview.Height {
+		m.preview.SetYOffset(max(0, i-m.preview.Height+2))
+	}
+	m.status = m.linkRefs[m.linkIdx].label()
+}
+
+// followLink opens the selected link, or the first one when none is
+// selected. A link to a missing note creates it in the current notebook.
+func (m *Model) followLink() tea.Cmd {
+	if m.selected == nil || len(m.linkRefs) == 0 {
+		m.status = "No links in this note"
+		return nil
+	}
+	if m.linkIdx < 0 {
+		m.linkIdx = 0
+	}
+	r := m.linkRefs[m.linkIdx]
+
+	if r.resolved {
+		m.status = "Opened " + r.note.Title
+		return m.openNote(r.note)
+	}
+
+	sec := m.currentSection()
+	if !sec.IsNotebook() {
+		sec = fs.SectionNotes
+	}
+	graph, store := m.links, m.store
+	return m.change(func() changeDoneMsg {
+		n, err := store.Create(sec)
+		if err != nil {
+			return failed("create", err)
+		}
+		if _, err := saveNote(graph, store, n, "# "+r.target+"\n\n", fs.Version{}); err != nil {
+			return failed("save", err)
+		}
+		return changeDoneMsg{status: "Created " + r.target, reload: true, load: openLoad(n)}
+	})
+}
+
+// openNote switches to the note's section and selects it.
+func (m *Model) openNote(n fs.Note) tea.Cmd {
+	return m.reload(openLoad(n))
+}
+
+// openLoad is the reload that opens n. The link graph knows where n is
+// now, should it have moved since n was read.
+func openLoad(n fs.Note) load {
+	return load{sections: true, section: n.Section, reselect: n.ID, open: true}
+}
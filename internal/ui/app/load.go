@@ -0,0 +1,225 @@
+package app
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/bubbles/spinner"
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/internet-kid/tenote/internal/storage/fs"
+	"github.com/internet-kid/tenote/internal/storage/tags"
+)
+
+// load describes a reload of the sidebar and what to do once it arrives.
+type load struct {
+	sections bool       // reload the section tree too; a change may have altered it
+	section  fs.Section // switch to this section; empty stays in the current one
+	reselect string     // select this note; empty keeps the cursor where it is
+	open     bool       // switch to the section reselect is in, wherever that is
+	scroll   previewScroll
+	edit     bool      // start editing reselect
+	draft    *fs.Draft // continue this draft in the editor; needs edit
+
+	countTagged bool // report how many notes the tag filter matches
+}
+
+// notesLoadedMsg carries the sidebar loaded by reload. Only the latest
+// reload is applied.
+type notesLoadedMsg struct {
+	seq      int
+	load     load
+	section  fs.Section   // the section listed
+	sections []fs.Section // when load.sections is set
+	notes    []fs.Note
+	tags     []tags.Count // for the tag browser without a filter
+	err      error
+	linkErr  error
+}
+
+// changeDoneMsg carries the outcome of a change to the store started by
+// change: the status to show and, with reload set, what to reload.
+type changeDoneMsg struct {
+	status string
+	reload bool
+	load   load
+}
+
+// reload refreshes the sidebar in the background. The link graph is synced
+// first, since the notes may have changed.
+func (m *Model) reload(l load) tea.Cmd {
+	m.loadSeq++
+	m.loading = true
+	return tea.Batch(m.fetchNotes(l), m.spinTick())
+}
+
+// fetchNotes reads what l asks for from the store.
+func (m Model) fetchNotes(l load) tea.Cmd {
+	seq, store, index, graph := m.loadSeq, m.store, m.index, m.links
+	section := l.section
+	if section == "" {
+		section = m.currentSection()
+	}
+	filter, anyOf := m.tagFilter, m.tagAnyOf
+	sorts := m.sorts // replaced, never changed; see cycleSort
+
+	return func() tea.Msg {
+		msg := notesLoadedMsg{seq: seq, load: l}
+		msg.linkErr = graph.Sync(store)
+		if l.open {
+			if n, ok := graph.Resolve(l.reselect); ok {
+				section = n.Section
+			}
+		}
+		if l.sections {
+			secs, err := store.Sections()
+			if err != nil {
+				msg.err = err
+				return msg
+			}
+			msg.sections = secs
+			if section != sectionTags && !slices.Contains(secs, section) && len(secs) > 0 {
+				section = secs[0]
+			}
+		}
+		msg.section = section
+
+		order, ok := sorts[section]
+		if !ok {
+			order = fs.DefaultSortOrder(section)
+		}
+		if section != sectionTags {
+			msg.notes, msg.err = store.List(section)
+			fs.SortNotes(msg.notes, order)
+			return msg
+		}
+		if msg.err = index.Sync(store); msg.err != nil {
+			return msg
+		}
+		if len(filter) > 0 {
+			msg.notes = index.Tagged(filter, anyOf)
+			fs.SortNotes(msg.notes, order)
+		} else {
+			msg.tags = index.Tags()
+		}
+		return msg
+	}
+}
+
+// finishLoad shows a loaded sidebar. Modes that use the list for something
+// else leave it for when they are done.
+func (m *Model) finishLoad(msg notesLoadedMsg) tea.Cmd {
+	if msg.seq != m.loadSeq {
+		return nil
+	}
+	m.loading = false
+	if m.ownsList() {
+		m.diskChanged = true
+		return nil
+	}
+
+	if msg.sections != nil {
+		m.setSections(msg.sections)
+	}
+	for i, s := range m.sections {
+		if s.key == msg.section {
+			m.sectionIdx = i
+		}
+	}
+	if msg.err != nil {
+		m.status = "load error: " + msg.err.Error()
+		return nil
+	}
+	if msg.linkErr != nil {
+		m.status = "link error: " + msg.linkErr.Error()
+	}
+
+	m.notes = msg.notes
+	var items []list.Item
+	if msg.section == sectionTags && len(m.tagFilter) == 0 {
+		items = m.tagItems(msg.tags)
+	} else {
+		items = m.noteItems(m.notes, msg.section)
+	}
+	m.noteList.SetItems(items)
+
+	l := msg.load
+	if l.countTagged && len(m.tagFilter) > 0 {
+		m.status = fmt.Sprintf("%d notes tagged %s · esc for all tags", len(m.notes), m.tagFilterTitle())
+	}
+	if l.reselect != "" {
+		m.reselectByID(l.reselect)
+	}
+	if l.scroll.id != "" {
+		m.scroll = l.scroll
+	}
+	cmd := m.syncSelection()
+	if !l.edit {
+		return cmd
+	}
+	if m.selected == nil || m.selected.ID != l.reselect {
+		if l.draft != nil {
+			m.status = "recover error: note not found"
+		}
+		return cmd
+	}
+	return tea.Batch(cmd, m.startEditing(l.draft))
+}
+
+// ownsList reports whether the current mode fills the sidebar list with
+// something other than the section's notes.
+func (m Model) ownsList() bool {
+	switch m.mode {
+	case modeSearch, modeHistory, modeRecover, modeTemplate, modeTemplateField:
+		return true
+	}
+	return false
+}
+
+// change runs fn, which changes the store, in the background. One change
+// runs at a time; asking for another meanwhile does nothing.
+func (m *Model) change(fn func() changeDoneMsg) tea.Cmd {
+	if m.busy {
+		return nil
+	}
+	m.busy = true
+	return tea.Batch(func() tea.Msg { return fn() }, m.spinTick())
+}
+
+// finishChange reports a change and reloads what it affected.
+func (m *Model) finishChange(msg changeDoneMsg) tea.Cmd {
+	m.busy = false
+	m.status = msg.status
+	if !msg.reload {
+		return nil
+	}
+	return m.reload(msg.load)
+}
+
+// failed is the outcome of a change that failed with err.
+func failed(what string, err error) changeDoneMsg {
+	return changeDoneMsg{status: what + " error: " + err.Error()}
+}
+
+// idle reports whether nothing the UI waits for is running.
+func (m Model) idle() bool {
+	return !m.loading && !m.busy && !m.opening
+}
+
+// ---------- loading indicator ----------
+
+func newSpinner() spinner.Model {
+	return spinner.New(spinner.WithSpinner(spinner.MiniDot), spinner.WithStyle(focusStyle))
+}
+
+// spinTick keeps the spinner turning while something loads. Ticks stop by
+// themselves once everything has arrived; see Update.
+func (m Model) spinTick() tea.Cmd {
+	return m.spin.Tick
+}
+
+// spinning reports whether the loading indicator shows.
+func (m Model) spinning() bool {
+	return !m.idle() || m.previewLoading || m.searching
+}
@@ -0,0 +1,79 @@
+package app
+
+import (
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/internet-kid/tenote/internal/config"
+)
+
+const defaultAutoLock = 10 * time.Minute
+
+// LockedMsg is sent to the parent model when an encrypted store locks
+// after being idle. The parent drops the app and wipes the key.
+type LockedMsg struct{}
+
+// lockCheckMsg fires when the auto-lock timeout may have run out.
+type lockCheckMsg struct{}
+
+// autoLockAfter returns the idle time after which an encrypted store
+// locks, or zero when it never does.
+func autoLockAfter(cfg config.AppConfig) time.Duration {
+	switch {
+	case cfg.AutoLock < 0:
+		return 0
+	case cfg.AutoLock == 0:
+		return defaultAutoLock
+	}
+	return time.Duration(cfg.AutoLock) * time.Minute
+}
+
+// scheduleLock waits for the rest of the idle timeout. Key presses do not
+// reschedule it; the check simply waits again for whatever is left.
+func (m Model) scheduleLock() tea.Cmd {
+	if m.autoLock <= 0 {
+		return nil
+	}
+	wait := m.autoLock - time.Since(m.lastInput)
+	if m.externalEdit {
+		wait = m.autoLock
+	}
+	return tea.Tick(max(wait, time.Second), func(time.Time) tea.Msg { return lockCheckMsg{} })
+}
+
+// checkLock locks the store once it has been idle for the timeout. An edit
+// in progress is saved first, and the store locks when that is done; see
+// finishLockSave. The store never locks while an external editor has the
+// note open.
+func (m Model) checkLock() (Model, tea.Cmd) {
+	if m.externalEdit || time.Since(m.lastInput) < m.autoLock {
+		return m, m.scheduleLock()
+	}
+
+	if m.editing() && m.dirty && m.selected != nil {
+		if m.busy {
+			// Another save is under way; look again once it is done.
+			return m, m.scheduleLock()
+		}
+		return m, m.save(*m.selected, m.editor.Value(), m.editBase, savedMsg{lock: true})
+	}
+	return m, lock
+}
+
+// finishLockSave locks the store once the edit checkLock saved is on
+// disk. If the save failed the lock is postponed rather than losing the
+// edit. Anything typed while it was saving is kept as a draft.
+func (m Model) finishLockSave(msg savedMsg) (Model, tea.Cmd) {
+	if msg.err != nil {
+		m.status = "auto-lock postponed, save error: " + msg.err.Error()
+		m.lastInput = time.Now()
+		return m, m.scheduleLock()
+	}
+	if m.editor.Value() != msg.body {
+		return m, tea.Sequence(m.saveDraft(), lock)
+	}
+	return m, tea.Sequence(m.dropDraft(), lock)
+}
+
+func lock() tea.Msg { return LockedMsg{} }
@@ -0,0 +1,111 @@
+package app
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/bubbles/textarea"
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/internet-kid/tenote/internal/storage"
+	"github.com/internet-kid/tenote/internal/storage/fs"
+	"github.com/internet-kid/tenote/internal/storage/links"
+	"github.com/internet-kid/tenote/internal/storage/memory"
+)
+
+// idleEditing returns a model that has been editing a note, with unsaved
+// changes, for longer than its auto-lock timeout.
+func idleEditing(t *testing.T) Model {
+	t.Helper()
+	store := memory.NewStore()
+	n, err := store.Create(fs.SectionNotes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.WriteBody(n.Path, "# Plan\n"); err != nil {
+		t.Fatal(err)
+	}
+	_, base, err := storage.ReadVersion(store, n.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := Model{
+		store:     store,
+		links:     links.Open(""),
+		editor:    textarea.New(),
+		mode:      modeEdit,
+		selected:  &n,
+		editBase:  base,
+		dirty:     true,
+		autoLock:  time.Minute,
+		lastInput: time.Now().Add(-time.Hour),
+	}
+	m.editor.SetValue("# Plan\nunsaved\n")
+	return m
+}
+
+// savedFrom runs the save cmd started and returns what it reports.
+func savedFrom(t *testing.T, cmd tea.Cmd) savedMsg {
+	t.Helper()
+	if cmd == nil {
+		t.Fatal("no save started")
+	}
+	msgs := []tea.Msg{cmd()}
+	for len(msgs) > 0 {
+		msg := msgs[0]
+		msgs = msgs[1:]
+		switch msg := msg.(type) {
+		case savedMsg:
+			return msg
+		case tea.BatchMsg:
+			for _, c := range msg {
+				if c != nil {
+					msgs = append(msgs, c())
+				}
+			}
+		}
+	}
+	t.Fatal("the save cmd reports no savedMsg")
+	return savedMsg{}
+}
+
+func TestAutoLockSavesFirst(t *testing.T) {
+	m := idleEditing(t)
+	m, cmd := m.checkLock()
+	if body, _ := m.store.ReadBody(m.selected.Path); body != "# Plan\n" {
+		t.Fatalf("checkLock wrote the note itself: %q", body)
+	}
+	saved := savedFrom(t, cmd)
+
+	if body, _ := m.store.ReadBody(m.selected.Path); body != "# Plan\nunsaved\n" {
+		t.Fatalf("note after the save = %q", body)
+	}
+	next, cmd := m.Update(saved)
+	if cmd == nil {
+		t.Fatalf("no lock after the save; status %q", next.(Model).status)
+	}
+	if _, ok := cmd().(LockedMsg); !ok {
+		t.Fatal("the save does not lock the store")
+	}
+}
+
+func TestAutoLockPostponedBySaveError(t *testing.T) {
+	m := idleEditing(t)
+	// The note changes under the editor, so the save is refused.
+	if err := m.store.WriteBody(m.selected.Path, "# Plan\nfrom elsewhere\n"); err != nil {
+		t.Fatal(err)
+	}
+	m, cmd := m.checkLock()
+	next, cmd := m.Update(savedFrom(t, cmd))
+	m = next.(Model)
+	if !strings.Contains(m.status, "auto-lock postponed") || time.Since(m.lastInput) > time.Minute {
+		t.Fatalf("status %q, last input %v; want the lock postponed", m.status, m.lastInput)
+	}
+	if m.mode != modeEdit || m.editor.Value() != "# Plan\nunsaved\n" {
+		t.Fatalf("mode %v with %q; want the edit kept", m.mode, m.editor.Value())
+	}
+	if cmd == nil {
+		t.Fatal("the lock is not checked again")
+	}
+}
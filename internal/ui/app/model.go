@@ -1,19 +1,34 @@
 package app
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/charmbracelet/bubbles/help"
 	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/bubbles/spinner"
 	"github.com/charmbracelet/bubbles/textarea"
+	"github.com/charmbracelet/bubbles/textinput"
 	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
-	"github.com/charmbracelet/glamour"
 	"github.com/charmbracelet/lipgloss"
 
 	"github.com/internet-kid/tenote/internal/config"
+	"github.com/internet-kid/tenote/internal/editor"
+	"github.com/internet-kid/tenote/internal/storage"
 	"github.com/internet-kid/tenote/internal/storage/fs"
+	"github.com/internet-kid/tenote/internal/storage/history"
+	"github.com/internet-kid/tenote/internal/storage/links"
+	"github.com/internet-kid/tenote/internal/storage/search"
+	"github.com/internet-kid/tenote/internal/storage/vault"
+	"github.com/internet-kid/tenote/internal/storage/watch"
+	"github.com/internet-kid/tenote/internal/templates"
 )
 
 type focusArea int
@@ -32,28 +47,51 @@ const (
 const (
 	modeBrowse mode = iota
 	modeEdit
+	modeSearch
+	modeHistory
+	modeNewNotebook
+	modeMove
+	modeRenameTag
+	modeConflict
+	modeRecover
+	modeConfirm
+	modeEmptyTrash
+	modeTemplate
+	modeTemplateField
+	modeCalendar
 )
 
-type sectionItem struct {
-	key   fs.Section
-	title string
+type noteItem struct {
+	n         fs.Note
+	retention time.Duration // for trashed notes; see daysLeft
+	order     fs.SortOrder  // the section's; the description shows what it sorts by
 }
 
-var sections = []sectionItem{
-	{key: fs.SectionNotes, title: "Notes"},
-	{key: fs.SectionTrash, title: "Trash"},
-}
+func (i noteItem) Title() string { return i.n.Title }
 
-type noteItem struct {
-	n fs.Note
+func (i noteItem) Description() string {
+	if left := daysLeft(i.n, i.retention); left != "" {
+		return i.n.DeletedAt.Format("2006-01-02") + " · " + left
+	}
+	desc := i.n.UpdatedAt.Format(timeLayout)
+	switch i.order {
+	case fs.SortCreated:
+		desc = i.n.CreatedOrUpdated().Format(timeLayout)
+	case fs.SortSize:
+		desc = fmt.Sprintf("%d bytes", i.n.Size)
+	}
+	if i.n.Pinned {
+		desc = "pinned · " + desc
+	}
+	return desc
 }
 
-func (i noteItem) Title() string       { return i.n.Title }
-func (i noteItem) Description() string { return i.n.UpdatedAt.Format(timeLayout) }
 func (i noteItem) FilterValue() string { return i.n.Title }
 
 type Model struct {
-	store *fs.Store
+	store storage.NoteStore
+	index *search.Index
+	links *links.Graph
 
 	width  int
 	height int
@@ -65,14 +103,79 @@ type Model struct {
 
 	dirty   bool
 	editErr error
-
+	// editOrig is the body editing started from and editBase its version;
+	// a save is refused when the note no longer matches it.
+	editOrig string
+	editBase fs.Version
+
+	// conflict mode; see conflict.go
+	conflictDisk string
+	conflictVer  fs.Version
+
+	// drafter autosaves the note being edited every draftEvery; drafts
+	// lists those a crash left behind. See drafts.go.
+	drafter    storage.Drafter
+	draftEvery time.Duration
+	draftBody  string // last body saved as a draft
+	drafts     []fs.Draft
+
+	// confirm mode; see confirm.go
+	confirmFrom mode
+	confirmQuit bool
+
+	sections   []sectionItem
 	sectionIdx int
 	noteList   list.Model
 	preview    viewport.Model
 
-	notes      []fs.Note
-	selected   *fs.Note
-	previewErr error
+	// paneH is the inner height of the sidebar and preview boxes; the note
+	// list gets what the section tree leaves of it.
+	paneH int
+	treeH int
+
+	notes          []fs.Note
+	selected       *fs.Note
+	previewErr     error
+	previewBaseH   int
+	previewBody    string // rendered note, without the links panel
+	previewContent string
+
+	// Storage calls and rendering run in the background; see load.go and
+	// preview.go. loadSeq numbers sidebar reloads and previewSeq previews,
+	// so only the latest of each is shown. busy is set while a change to
+	// the store runs, opening while a note is read for editing.
+	loadSeq        int
+	loading        bool
+	busy           bool
+	opening        bool
+	previewSeq     int
+	previewLoading bool
+	previewCancel  context.CancelFunc
+	previews       *previewCache
+	scroll         previewScroll
+	spin           spinner.Model
+
+	linkRefs []linkRef
+	linkIdx  int
+	linksFor string
+
+	searchInput textinput.Model
+	hits        []search.Hit
+	searchSeq   int
+	searching   bool
+
+	revisions      []history.Revision
+	historyCurrent string
+	historyBase    fs.Version
+
+	promptInput textinput.Model
+	moveIdx     int
+
+	// tag browser; see tags.go
+	tagPicked  map[string]bool
+	tagFilter  []string
+	tagAnyOf   bool
+	renameFrom []string
 
 	help     help.Model
 	keys     KeyMap
@@ -80,20 +183,104 @@ type Model struct {
 
 	status string
 
-	renderer *glamour.TermRenderer
+	// editorCmd is the configured external editor; see editor.Command.
+	// finishedEdit holds an external edit that came back while a change
+	// was running, until it is done.
+	editorCmd    string
+	externalEdit bool
+	finishedEdit *editorFinishedMsg
+
+	// autoLock is the idle time after which an encrypted store locks;
+	// zero for plaintext stores. See lock.go.
+	autoLock  time.Duration
+	lastInput time.Time
+
+	// retention is how long notes stay in the trash; zero keeps them.
+	retention time.Duration
+
+	// syncing is set while a sync with the git remote runs.
+	syncing bool
+
+	// watcher reports changes made on disk; diskChanged records one that
+	// arrived while the sidebar was busy. See watch.go.
+	watcher     *watch.Watcher
+	diskChanged bool
+
+	renderer  *markdownRenderer
+	wrapWidth int
+
+	// sorts is the order of each section's notes that is not by update
+	// time, and groupNotes turns on date headings; see sort.go.
+	sorts      map[fs.Section]fs.SortOrder
+	groupNotes bool
+
+	// templateDir holds the templates new notes are made from; templates
+	// are the ones offered by the picker, and tmpl is the one whose fields
+	// are being asked for. See templates.go.
+	templateDir string
+	templates   []templates.Template
+	tmpl        templates.Template
+	tmplFields  []templates.Field
+	tmplValues  map[string]string
+
+	// cfg is the config the store was opened with; the journal takes its
+	// template from it. journal holds the calendar's notes by day and
+	// calDay its cursor. See journal.go.
+	cfg     config.AppConfig
+	journal map[string]fs.Note
+	calDay  time.Time
+}
+
+// editorFinishedMsg is sent when the external editor started by
+// openExternalEditor exits.
+type editorFinishedMsg struct {
+	note fs.Note
+	sess *editor.Session
+	orig string     // body the editor started with
+	base fs.Version // version of orig
+	err  error
 }
 
-func NewModel() (Model, error) {
-	paths, err := config.ResolvePaths()
+// NewModel opens the configured store. key unlocks an encrypted store and
+// is ignored otherwise.
+func NewModel(key *vault.Key) (Model, error) {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return Model{}, err
+	}
+	encrypted, err := storage.Encrypted(cfg)
+	if err != nil {
+		return Model{}, err
+	}
+	index, err := storage.OpenIndex(cfg)
+	if err != nil {
+		return Model{}, err
+	}
+	graph, err := storage.OpenLinks(cfg)
 	if err != nil {
 		return Model{}, err
 	}
-	store := fs.NewStore(paths)
+	watcher, err := storage.Watch(cfg)
+	if err != nil {
+		return Model{}, err
+	}
+	templateDir, err := storage.TemplateDir(cfg)
+	if err != nil {
+		return Model{}, err
+	}
+	// Opened last, as it holds the list cache open until the model closes.
+	store, err := storage.OpenWithKey(cfg, key)
+	if err != nil {
+		if watcher != nil {
+			watcher.Close()
+		}
+		return Model{}, err
+	}
 
 	del := list.NewDefaultDelegate()
 	del.Styles.SelectedTitle = del.Styles.SelectedTitle.Foreground(lipgloss.Color("#25b067")).BorderForeground(lipgloss.Color("#25b067"))
 	del.Styles.SelectedDesc = del.Styles.SelectedDesc.Foreground(lipgloss.Color("#25b067")).BorderForeground(lipgloss.Color("#25b067"))
-	l := list.New([]list.Item{}, del, 0, 0)
+	l := list.New([]list.Item{}, noteDelegate{del}, 0, 0)
 	l.Title = "Notes"
 	l.SetShowTitle(false)
 	l.SetShowStatusBar(false)
@@ -108,31 +295,64 @@ func NewModel() (Model, error) {
 	ta.ShowLineNumbers = true
 	ta.Prompt = ""
 	ta.CharLimit = 0
+	si := textinput.New()
+	si.Prompt = "/ "
+	si.Placeholder = "search notes"
+	pi := textinput.New()
+
 	h := help.New()
 	h.ShowAll = false
 
 	m := Model{
-		store:      store,
-		focus:      focusSidebar,
-		sectionIdx: 0,
-		noteList:   l,
-		preview:    vp,
-		mode:       modeBrowse,
-		editor:     ta,
-		help:       h,
-		keys:       DefaultKeyMap(),
-		showHelp:   false,
-	}
-
-	if err := m.reloadNotes(); err != nil {
-		m.status = "load error: " + err.Error()
-	}
-	m.syncSelection()
+		store:       store,
+		index:       index,
+		links:       graph,
+		linkIdx:     -1,
+		searchInput: si,
+		promptInput: pi,
+		focus:       focusSidebar,
+		sectionIdx:  0,
+		noteList:    l,
+		preview:     vp,
+		mode:        modeBrowse,
+		editor:      ta,
+		help:        h,
+		keys:        DefaultKeyMap(),
+		showHelp:    false,
+		editorCmd:   cfg.Editor,
+		lastInput:   time.Now(),
+		watcher:     watcher,
+		retention:   storage.TrashRetention(cfg),
+		sorts:       sortOrders(cfg),
+		groupNotes:  cfg.GroupNotes,
+		templateDir: templateDir,
+		cfg:         cfg,
+		previews:    newPreviewCache(previewCacheSize),
+		spin:        newSpinner(),
+		loading:     true,
+	}
+	if encrypted {
+		m.autoLock = autoLockAfter(cfg)
+	}
+	if d, ok := store.(storage.Drafter); ok {
+		if m.draftEvery = draftInterval(cfg); m.draftEvery > 0 {
+			m.drafter = d
+		}
+	}
 	return m, nil
 }
 
+// Init cleans up after a crash and the trash, then loads the sidebar;
+// drafts left by a crash are offered once it is there.
 func (m Model) Init() tea.Cmd {
-	return nil
+	return tea.Batch(
+		tea.Sequence(m.recover(), m.purgeTrash(), m.fetchNotes(load{sections: true})),
+		m.findDrafts(),
+		m.spinTick(),
+		m.scheduleLock(),
+		m.scheduleDraft(),
+		waitForChange(m.watcher),
+	)
 }
 
 func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
@@ -140,26 +360,161 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case tea.WindowSizeMsg:
 		m.width = msg.Width
 		m.height = msg.Height
-		m.layout()
-		return m, nil
+		return m, m.layout()
 
-	case tea.KeyMsg:
-		if key.Matches(msg, m.keys.Quit) {
-			return m, tea.Quit
+	case spinner.TickMsg:
+		if !m.spinning() {
+			return m, nil
 		}
+		var cmd tea.Cmd
+		m.spin, cmd = m.spin.Update(msg)
+		return m, cmd
 
-		if m.mode == modeEdit {
-			next, cmd := m.updateEditMode(msg)
-			return next, cmd
+	case notesLoadedMsg:
+		cmd := m.finishLoad(msg)
+		return m, tea.Batch(cmd, m.resume())
+
+	case previewMsg:
+		m.finishPreview(msg)
+		return m, nil
+
+	case diffMsg:
+		m.finishDiff(msg)
+		return m, nil
+
+	case changeDoneMsg:
+		return m, tea.Batch(m.finishChange(msg), m.resumeExternalEdit())
+
+	case editLoadedMsg:
+		return m, m.finishOpen(msg)
+
+	case savedMsg:
+		next, cmd := m.finishSave(msg)
+		return next, tea.Batch(cmd, next.resumeExternalEdit())
+
+	case historyMsg:
+		return m, m.finishHistory(msg)
+
+	case templatesMsg:
+		return m, m.finishTemplates(msg)
+
+	case calendarMsg:
+		m.finishCalendar(msg)
+		return m, nil
+
+	case hitsMsg:
+		return m, m.finishSearch(msg)
+
+	case viewSavedMsg:
+		if msg.err != nil {
+			m.status = "config error: " + msg.err.Error()
 		}
+		return m, nil
+
+	case editorFinishedMsg:
+		m.externalEdit = false
+		m.lastInput = time.Now()
+		return m, m.finishExternalEdit(msg)
 
-		next, cmd := m.updateBrowseMode(msg)
+	case lockCheckMsg:
+		next, cmd := m.checkLock()
 		return next, cmd
+
+	case recoveredMsg:
+		m.finishRecover(msg)
+		return m, nil
+
+	case purgedMsg:
+		m.finishPurge(msg)
+		return m, nil
+
+	case draftsFoundMsg:
+		m.finishFindDrafts(msg)
+		return m, m.resume()
+
+	case draftTickMsg:
+		return m, tea.Batch(m.saveDraft(), m.scheduleDraft())
+
+	case draftDoneMsg:
+		return m, m.finishDraft(msg)
+
+	case syncDoneMsg:
+		return m, m.finishSync(msg)
+
+	case notesChangedMsg:
+		storage.Refresh(m.store, msg.paths)
+		return m, tea.Batch(m.refreshFromDisk(), waitForChange(m.watcher))
+
+	case tea.KeyMsg:
+		m.lastInput = time.Now()
+		// Outside browse mode "q" is text, so only ctrl+c quits there.
+		if key.Matches(msg, m.keys.Quit) && (m.mode == modeBrowse || msg.String() == "ctrl+c") {
+			switch {
+			case m.mode == modeConfirm:
+				// Asked twice: quit, keeping a draft for next time.
+				return m, tea.Sequence(m.saveDraft(), m.quit())
+			case m.editing() && m.dirty:
+				m.startConfirm(true)
+				return m, nil
+			}
+			return m, m.quit()
+		}
+
+		next, cmd := m.updateKeys(msg)
+		return next, tea.Batch(cmd, next.resume())
 	}
 
 	return m, nil
 }
 
+// resume picks up what waits for browse mode: a refresh for changes made
+// on disk meanwhile, and drafts still waiting for recovery.
+func (m *Model) resume() tea.Cmd {
+	if m.mode != modeBrowse {
+		return nil
+	}
+	if m.diskChanged {
+		return m.refreshFromDisk()
+	}
+	if len(m.drafts) > 0 && m.idle() {
+		return m.startRecovery()
+	}
+	return nil
+}
+
+// updateKeys hands a key to the current mode.
+func (m Model) updateKeys(msg tea.KeyMsg) (Model, tea.Cmd) {
+	switch m.mode {
+	case modeEdit:
+		return m.updateEditMode(msg)
+	case modeSearch:
+		return m.updateSearchMode(msg)
+	case modeHistory:
+		return m.updateHistoryMode(msg)
+	case modeNewNotebook:
+		return m.updateNewNotebookMode(msg)
+	case modeMove:
+		return m.updateMoveMode(msg)
+	case modeRenameTag:
+		return m.updateRenameTagMode(msg)
+	case modeConflict:
+		return m.updateConflictMode(msg)
+	case modeRecover:
+		return m.updateRecoverMode(msg)
+	case modeConfirm:
+		return m.updateConfirmMode(msg)
+	case modeEmptyTrash:
+		return m.updateEmptyTrashMode(msg)
+	case modeTemplate:
+		return m.updateTemplateMode(msg)
+	case modeTemplateField:
+		return m.updateTemplateFieldMode(msg)
+	case modeCalendar:
+		return m.updateCalendarMode(msg)
+	}
+	return m.updateBrowseMode(msg)
+}
+
 func (m Model) View() string {
 	if m.width == 0 || m.height == 0 {
 		return loadingMsg
@@ -187,27 +542,21 @@ var (
 )
 
 func (m Model) updateEditMode(msg tea.KeyMsg) (Model, tea.Cmd) {
+	// Keep the editor as it is while a save runs; it may come back for
+	// more editing.
+	if m.busy {
+		return m, nil
+	}
 	switch {
 	case key.Matches(msg, m.keys.Cancel):
-		m.exitEditMode("Canceled")
-		return m, nil
-
-	case key.Matches(msg, m.keys.Save):
-		if m.selected == nil {
+		if m.dirty {
+			m.startConfirm(false)
 			return m, nil
 		}
+		return m, m.exitEditMode("Canceled")
 
-		selectedID := m.selected.ID
-		body := m.editor.Value()
-		if err := m.store.WriteBody(m.selected.Path, body); err != nil {
-			m.status = "save error: " + err.Error()
-			m.editErr = err
-			return m, nil
-		}
-
-		m.exitEditMode("Saved")
-		m.refreshNotesAndReselect(selectedID)
-		return m, nil
+	case key.Matches(msg, m.keys.Save):
+		return m, m.saveEdit(false)
 	}
 
 	var cmd tea.Cmd
@@ -221,9 +570,15 @@ func (m Model) updateEditMode(msg tea.KeyMsg) (Model, tea.Cmd) {
 }
 
 func (m Model) updateBrowseMode(msg tea.KeyMsg) (Model, tea.Cmd) {
+	if m.inTags() {
+		if next, cmd, ok := m.updateTagsKeys(msg); ok {
+			return next, cmd
+		}
+	}
+
 	switch {
 	case key.Matches(msg, m.keys.Help):
-		if sections[m.sectionIdx].key == fs.SectionTrash {
+		if m.inTrash() {
 			return m, nil
 		}
 		m.showHelp = !m.showHelp
@@ -247,20 +602,18 @@ func (m Model) updateBrowseMode(msg tea.KeyMsg) (Model, tea.Cmd) {
 		return m, nil
 
 	case key.Matches(msg, m.keys.SectionDn):
-		m.sectionIdx = min(m.sectionIdx+1, len(sections)-1)
-		m.refreshNotesAndSelection()
-		return m, nil
+		m.sectionIdx = min(m.sectionIdx+1, len(m.sections)-1)
+		return m, m.reload(load{})
 
 	case key.Matches(msg, m.keys.SectionUp):
 		m.sectionIdx = max(m.sectionIdx-1, 0)
-		m.refreshNotesAndSelection()
-		return m, nil
+		return m, m.reload(load{})
 
 	case key.Matches(msg, m.keys.Down):
 		if m.focus == focusSidebar {
 			m.noteList.CursorDown()
-			m.syncSelection()
-			return m, nil
+			m.skipHeading(true)
+			return m, m.syncSelection()
 		}
 		m.preview.LineDown(1)
 		return m, nil
@@ -268,92 +621,201 @@ func (m Model) updateBrowseMode(msg tea.KeyMsg) (Model, tea.Cmd) {
 	case key.Matches(msg, m.keys.Up):
 		if m.focus == focusSidebar {
 			m.noteList.CursorUp()
-			m.syncSelection()
-			return m, nil
+			m.skipHeading(false)
+			return m, m.syncSelection()
 		}
 		m.preview.LineUp(1)
 		return m, nil
 
 	case key.Matches(msg, m.keys.New):
-		if sections[m.sectionIdx].key == fs.SectionTrash {
+		switch m.currentSection() {
+		case fs.SectionTrash:
 			return m, nil
+		case fs.SectionJournal:
+			return m, m.openJournal(time.Now())
 		}
-		note, err := m.store.Create(sections[m.sectionIdx].key)
-		if err != nil {
-			m.status = "create error: " + err.Error()
+		return m, m.newNote()
+
+	case key.Matches(msg, m.keys.Journal):
+		return m, m.openJournal(time.Now())
+
+	case key.Matches(msg, m.keys.Calendar):
+		return m, m.startCalendar()
+
+	case key.Matches(msg, m.keys.NewNotebook):
+		if !m.currentSection().IsNotebook() {
 			return m, nil
 		}
+		return m.startNewNotebook()
 
-		m.refreshNotesAndReselect(note.ID)
-		return m.startEditingSelected()
+	case key.Matches(msg, m.keys.DelNotebook):
+		if !m.currentSection().IsNotebook() {
+			return m, nil
+		}
+		return m, m.deleteNotebook()
+
+	case key.Matches(msg, m.keys.Move):
+		if m.inTrash() {
+			return m, nil
+		}
+		m.startMove()
+		return m, nil
 
 	case key.Matches(msg, m.keys.Edit):
-		if sections[m.sectionIdx].key == fs.SectionTrash {
+		if m.inTrash() {
 			return m, nil
 		}
-		return m.startEditingSelected()
+		return m, m.startEditing(nil)
 
-	case key.Matches(msg, m.keys.Trash):
-		if m.selected == nil {
+	case key.Matches(msg, m.keys.ExtEdit):
+		if m.inTrash() {
 			return m, nil
 		}
-		if sections[m.sectionIdx].key == fs.SectionTrash {
-			if err := m.store.DeleteFromTrash(*m.selected); err != nil {
-				m.status = "delete error: " + err.Error()
-				return m, nil
-			}
+		return m, m.openExternalEditor()
+
+	case key.Matches(msg, m.keys.Search):
+		return m.startSearch()
+
+	case key.Matches(msg, m.keys.NextLink):
+		m.stepLink(1)
+		return m, nil
+
+	case key.Matches(msg, m.keys.PrevLink):
+		m.stepLink(-1)
+		return m, nil
+
+	case key.Matches(msg, m.keys.FollowLink):
+		return m, m.followLink()
+
+	case key.Matches(msg, m.keys.History):
+		if m.inTrash() {
+			return m, nil
+		}
+		return m.startHistory()
+
+	case key.Matches(msg, m.keys.Sync):
+		return m.startSync()
 
-			m.status = "Deleted permanently: " + m.selected.Title
-			m.refreshNotesAndSelection()
+	case key.Matches(msg, m.keys.Pin):
+		if m.inTrash() {
 			return m, nil
 		}
+		return m, m.togglePin()
 
-		updated, err := m.store.MoveToTrash(*m.selected)
-		if err != nil {
-			m.status = "trash error: " + err.Error()
+	case key.Matches(msg, m.keys.Sort):
+		return m, m.cycleSort()
+
+	case key.Matches(msg, m.keys.Group):
+		return m, m.toggleGroup()
+
+	case key.Matches(msg, m.keys.Trash):
+		if m.selected == nil {
 			return m, nil
 		}
+		n, store := *m.selected, m.store
+		if m.inTrash() {
+			return m, m.change(func() changeDoneMsg {
+				if err := store.DeleteFromTrash(n); err != nil {
+					return failed("delete", err)
+				}
+				return changeDoneMsg{status: "Deleted permanently: " + n.Title, reload: true}
+			})
+		}
 
-		m.status = "Moved to Trash: " + updated.Title
-		m.refreshNotesAndSelection()
+		return m, m.change(func() changeDoneMsg {
+			updated, err := store.MoveToTrash(n)
+			if err != nil {
+				return failed("trash", err)
+			}
+			return changeDoneMsg{status: "Moved to Trash: " + updated.Title, reload: true}
+		})
+
+	case key.Matches(msg, m.keys.Empty):
+		if m.inTrash() {
+			m.startEmptyTrash()
+		}
 		return m, nil
 
 	case key.Matches(msg, m.keys.Restore):
 		if m.selected == nil {
 			return m, nil
 		}
-		if sections[m.sectionIdx].key != fs.SectionTrash {
+		if !m.inTrash() {
 			m.status = "restore works only in Trash"
 			return m, nil
 		}
 
-		updated, err := m.store.RestoreFromTrash(*m.selected, fs.SectionNotes)
-		if err != nil {
-			m.status = "restore error: " + err.Error()
-			return m, nil
-		}
-
-		m.status = "Restored: " + updated.Title
-		m.refreshNotesAndSelection()
-		return m, nil
+		n, store := *m.selected, m.store
+		return m, m.change(func() changeDoneMsg {
+			updated, err := store.RestoreFromTrash(n, "")
+			if err != nil {
+				return failed("restore", err)
+			}
+			// Restoring may have recreated a deleted notebook.
+			return changeDoneMsg{
+				status: "Restored to " + sectionTitle(updated.Section) + ": " + updated.Title,
+				reload: true,
+				load:   load{sections: true},
+			}
+		})
 	}
 
 	var cmd tea.Cmd
+	before := m.noteList.Index()
 	m.noteList, cmd = m.noteList.Update(msg)
+	if m.noteList.Index() != before {
+		m.skipHeading(m.noteList.Index() > before)
+		cmd = tea.Batch(cmd, m.syncSelection())
+	}
 	return m, cmd
 }
 
 func (m Model) renderSidebar() string {
-	sec := sections[m.sectionIdx]
-	secLine := titleStyle.Render("tenote") + " " + blurStyle.Render("•") + " " + focusStyle.Render(sec.title)
+	secTitle := sectionTitle(m.currentSection())
+	switch m.mode {
+	case modeSearch:
+		secTitle = "Search"
+	case modeHistory:
+		secTitle = "History"
+	case modeNewNotebook:
+		secTitle = "New notebook"
+	case modeMove:
+		secTitle = "Move to…"
+	case modeRenameTag:
+		secTitle = "Rename tag"
+	case modeRecover:
+		secTitle = "Unsaved drafts"
+	case modeTemplate:
+		secTitle = "New note from…"
+	case modeTemplateField:
+		secTitle = "New " + m.tmpl.Name
+	default:
+		if m.inTags() && len(m.tagFilter) > 0 {
+			secTitle += " · " + m.tagFilterTitle()
+		}
+		if order := m.sortOrder(m.currentSection()); order != fs.DefaultSortOrder(m.currentSection()) {
+			secTitle += " · by " + string(order)
+		}
+	}
+	secLine := titleStyle.Render("tenote") + " " + blurStyle.Render("•") + " " + focusStyle.Render(secTitle)
 	if m.focus != focusSidebar {
-		secLine = titleStyle.Render("tenote") + " " + blurStyle.Render("•") + " " + blurStyle.Render(sec.title)
+		secLine = titleStyle.Render("tenote") + " " + blurStyle.Render("•") + " " + blurStyle.Render(secTitle)
+	}
+	if !m.idle() || m.searching {
+		secLine += " " + m.spin.View()
 	}
 
-	box := border.Width(m.noteList.Width()).Height(m.noteList.Height()+2).Padding(0, 1)
+	box := border.Width(m.noteList.Width()).Height(m.paneH+2).Padding(0, 1)
 
+	tree := m.renderSectionTree()
 	listView := m.noteList.View()
-	return box.Render(secLine + "\n\n" + listView)
+	switch m.mode {
+	case modeSearch:
+		return box.Render(secLine + "\n" + m.searchInput.View() + "\n" + listView)
+	case modeNewNotebook, modeRenameTag, modeTemplateField:
+		return box.Render(secLine + "\n" + m.promptInput.View() + "\n" + tree + "\n\n" + listView)
+	}
+	return box.Render(secLine + "\n\n" + tree + "\n\n" + listView)
 }
 
 func (m Model) renderPreview() string {
@@ -361,15 +823,37 @@ func (m Model) renderPreview() string {
 	content := m.preview.View()
 	meta := m.renderPreviewMeta()
 
-	if m.mode == modeEdit {
+	if m.mode == modeEdit || (m.mode == modeConfirm && m.confirmFrom == modeEdit) {
 		header = titleStyle.Render("Edit")
 		content = m.editor.View()
 	} else {
+		if m.mode == modeHistory {
+			header = titleStyle.Render("Changes if restored")
+		}
+		if m.mode == modeConflict || m.mode == modeConfirm {
+			header = titleStyle.Render("Changed on disk while editing")
+		}
+		if m.mode == modeRecover {
+			header = titleStyle.Render("Changes in the draft")
+		}
+		if m.mode == modeTemplate {
+			header = titleStyle.Render("Template")
+		}
+		if m.mode == modeCalendar {
+			header = titleStyle.Render("Journal")
+			content = m.renderCalendar()
+		}
+		if m.previewLoading {
+			header += " " + m.spin.View()
+		}
 		if m.previewErr != nil {
 			content = "Error: " + m.previewErr.Error()
 		}
 		if strings.TrimSpace(content) == "" {
 			content = blurStyle.Render("Select a note or press 'n' to create one.")
+			if m.previewLoading || m.loading {
+				content = blurStyle.Render(loadingMsg)
+			}
 		}
 	}
 
@@ -378,7 +862,7 @@ func (m Model) renderPreview() string {
 		w = 20
 	}
 
-	box := border.Width(w).Height(m.noteList.Height()+2).Padding(0, 1)
+	box := border.Width(w).Height(m.paneH+2).Padding(0, 1)
 	if m.focus == focusPreview {
 		box = box.BorderForeground(lipgloss.Color("#25b067"))
 	}
@@ -386,19 +870,54 @@ func (m Model) renderPreview() string {
 }
 
 func (m Model) renderPreviewMeta() string {
+	return strings.Join(m.previewMetaLines(), "\n")
+}
+
+// previewMetaLines returns the header lines above the preview body. Lines
+// beyond the title and date only appear when the note's front matter sets
+// them; fitPreview shrinks the body by the same amount.
+func (m Model) previewMetaLines() []string {
 	noteTitle := "-"
 	noteDate := "-"
 	if m.selected != nil {
 		noteTitle = m.selected.Title
+		if m.selected.Pinned {
+			noteTitle += " (pinned)"
+		}
 		noteDate = m.selected.UpdatedAt.Format(timeLayout)
 	}
 
-	return strings.Join([]string{
+	lines := []string{
 		"---",
 		"Note title: " + noteTitle,
 		"Date: " + noteDate,
-		"---",
-	}, "\n")
+	}
+
+	if n := m.selected; n != nil {
+		if !n.Created.IsZero() {
+			lines = append(lines, "Created: "+n.Created.Format(timeLayout))
+		}
+		if len(n.Tags) > 0 {
+			lines = append(lines, "Tags: #"+strings.Join(n.Tags, " #"))
+		}
+		if len(n.Aliases) > 0 {
+			lines = append(lines, "Aliases: "+strings.Join(n.Aliases, ", "))
+		}
+		if !n.DeletedAt.IsZero() {
+			lines = append(lines, m.trashedLine(*n))
+		}
+
+		keys := make([]string, 0, len(n.Fields))
+		for k := range n.Fields {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			lines = append(lines, k+": "+fmt.Sprint(n.Fields[k]))
+		}
+	}
+
+	return append(lines, "---")
 }
 
 func (m Model) renderStatus() string {
@@ -415,11 +934,57 @@ func (m Model) renderHelp() string {
 			m.help.View(editKeyMap{KeyMap: m.keys}),
 		)
 	}
-	if sections[m.sectionIdx].key == fs.SectionTrash {
+	if m.mode == modeSearch {
+		return lipgloss.NewStyle().Padding(0, 1).Render(
+			m.help.View(searchKeyMap{KeyMap: m.keys}),
+		)
+	}
+	if m.mode == modeHistory {
+		return lipgloss.NewStyle().Padding(0, 1).Render(
+			m.help.View(historyKeyMap{KeyMap: m.keys}),
+		)
+	}
+	if m.mode == modeConflict {
+		return lipgloss.NewStyle().Padding(0, 1).Render(
+			m.help.View(conflictKeyMap{KeyMap: m.keys}),
+		)
+	}
+	if m.mode == modeRecover {
+		return lipgloss.NewStyle().Padding(0, 1).Render(
+			m.help.View(recoverKeyMap{KeyMap: m.keys}),
+		)
+	}
+	if m.mode == modeConfirm {
+		return lipgloss.NewStyle().Padding(0, 1).Render(
+			m.help.View(confirmKeyMap{KeyMap: m.keys}),
+		)
+	}
+	if m.mode == modeEmptyTrash {
+		return lipgloss.NewStyle().Padding(0, 1).Render(
+			m.help.View(emptyTrashKeyMap{KeyMap: m.keys}),
+		)
+	}
+	if m.mode == modeCalendar {
+		return lipgloss.NewStyle().Padding(0, 1).Render(
+			m.help.View(calendarKeyMap{KeyMap: m.keys}),
+		)
+	}
+	switch m.mode {
+	case modeNewNotebook, modeMove, modeRenameTag, modeTemplate, modeTemplateField:
+		return lipgloss.NewStyle().Padding(0, 1).Render(
+			m.help.View(pickKeyMap{KeyMap: m.keys}),
+		)
+	}
+	if m.inTrash() {
 		return lipgloss.NewStyle().Padding(0, 1).Render(
 			m.help.View(trashKeyMap{KeyMap: m.keys}),
 		)
 	}
+	if m.inTags() && len(m.tagFilter) == 0 {
+		return lipgloss.NewStyle().Padding(0, 1).Render(
+			m.help.View(tagsKeyMap{KeyMap: m.keys}),
+		)
+	}
 
 	return lipgloss.NewStyle().Padding(0, 1).Render(
 		m.help.View(m.keys),
@@ -428,98 +993,119 @@ func (m Model) renderHelp() string {
 
 // ---------- helpers ----------
 
-func (m *Model) layout() {
-	sidebarW := max(28, min(44, m.width/3))
-	contentH := max(10, m.height-3)
-
-	rightW := m.width - sidebarW - 6
-	rightInnerH := contentH - 4
-	previewBodyH := rightInnerH - 6
-	if previewBodyH < 3 {
-		previewBodyH = 3
-	}
-
-	m.noteList.SetSize(sidebarW-4, contentH-4)
-
-	m.preview = viewport.New(rightW, previewBodyH)
-	m.editor.SetWidth(rightW)
-	m.editor.SetHeight(previewBodyH)
+// recoveredMsg reports the interrupted saves recover kept.
+type recoveredMsg struct {
+	kept []string
+	err  error
+}
 
-	wrapWidth := rightW - 2
-	if wrapWidth < 20 {
-		wrapWidth = 20
+// recover cleans up after saves a crash interrupted.
+func (m Model) recover() tea.Cmd {
+	r, ok := m.store.(storage.Recoverer)
+	if !ok {
+		return nil
 	}
-	if r, err := glamour.NewTermRenderer(
-		glamour.WithAutoStyle(),
-		glamour.WithWordWrap(wrapWidth),
-	); err == nil {
-		m.renderer = r
+	return func() tea.Msg {
+		kept, err := r.Recover()
+		return recoveredMsg{kept: kept, err: err}
 	}
-
-	m.syncSelection()
 }
 
-func (m *Model) refreshNotesAndSelection() {
-	if err := m.reloadNotes(); err != nil {
-		m.status = "load error: " + err.Error()
-		return
+// finishRecover reports the interrupted saves that were kept.
+func (m *Model) finishRecover(msg recoveredMsg) {
+	switch {
+	case msg.err != nil:
+		m.status = "recover error: " + msg.err.Error()
+	case len(msg.kept) == 1:
+		m.status = "Kept an interrupted save in " + msg.kept[0]
+	case len(msg.kept) > 1:
+		m.status = fmt.Sprintf("Kept %d interrupted saves in %s", len(msg.kept), filepath.Dir(msg.kept[0]))
 	}
-
-	m.syncSelection()
 }
 
-func (m *Model) refreshNotesAndReselect(noteID string) {
-	if err := m.reloadNotes(); err != nil {
-		m.status = "load error: " + err.Error()
-		return
-	}
+func (m *Model) layout() tea.Cmd {
+	sidebarW := max(28, min(44, m.width/3))
+	contentH := max(10, m.height-3)
 
-	m.reselectByID(noteID)
-	m.syncSelection()
-}
+	rightW := m.width - sidebarW - 6
+	rightInnerH := contentH - 4
+	m.previewBaseH = rightInnerH - 6
 
-func (m *Model) reloadNotes() error {
-	sec := sections[m.sectionIdx].key
-	notes, err := m.store.List(sec)
-	if err != nil {
-		return err
-	}
-	m.notes = notes
+	m.paneH = contentH - 4
+	m.noteList.SetWidth(sidebarW - 4)
+	m.fitSidebar()
 
-	items := make([]list.Item, 0, len(notes))
-	for _, n := range notes {
-		items = append(items, noteItem{n: n})
+	m.preview = viewport.New(rightW, 0)
+	m.editor.SetWidth(rightW)
+	m.fitPreview()
+
+	// Renderings made for another width are of no use any more.
+	wrapWidth := max(20, rightW-2)
+	if m.renderer == nil || wrapWidth != m.wrapWidth {
+		m.wrapWidth = wrapWidth
+		m.renderer = newMarkdownRenderer(wrapWidth)
+		m.previews.clear()
 	}
-	m.noteList.SetItems(items)
-	m.noteList.Title = ""
-	return nil
+
+	return m.syncSelection()
 }
 
-func (m *Model) syncSelection() {
-	if m.mode == modeEdit {
-		return
+// syncSelection shows the note under the cursor in the preview.
+func (m *Model) syncSelection() tea.Cmd {
+	switch m.mode {
+	case modeRecover:
+		return m.showDraftDiff()
+	case modeTemplate:
+		return m.showTemplate()
+	case modeCalendar:
+		m.showDay()
+		return nil
+	}
+	if m.editing() || m.mode == modeHistory {
+		return nil
 	}
 
 	if len(m.notes) == 0 || len(m.noteList.Items()) == 0 {
+		m.cancelPreview()
 		m.selected = nil
+		m.fitPreview()
+		m.previewErr = nil
+		m.previewBody = ""
+		m.previewContent = ""
 		m.preview.SetContent("")
-		return
+		return nil
 	}
 
 	idx := m.noteList.Index()
-	if idx < 0 || idx >= len(m.notes) {
-		idx = 0
-		m.noteList.Select(idx)
+	if idx < 0 || idx >= len(m.noteList.Items()) {
+		m.noteList.Select(0)
+	}
+	m.skipHeading(true)
+
+	var n fs.Note
+	switch it := m.noteList.SelectedItem().(type) {
+	case noteItem:
+		n = it.n
+	case hitItem:
+		n = it.h.Note
+	default:
+		return nil
 	}
-
-	n := m.notes[idx]
 	m.selected = &n
+	m.fitPreview()
+	m.loadLinks(n)
+	return m.loadPreview(n)
+}
 
-	body, err := m.store.ReadBody(n.Path)
-	m.previewErr = err
-	if err == nil {
-		m.preview.SetContent(m.renderMarkdown(body))
+// fitPreview sizes the preview and editor to the space left under the
+// metadata header of the selected note.
+func (m *Model) fitPreview() {
+	h := m.previewBaseH - (len(m.previewMetaLines()) - 4)
+	if h < 3 {
+		h = 3
 	}
+	m.preview.Height = h
+	m.editor.SetHeight(h)
 }
 
 func (m *Model) reselectByID(id string) {
@@ -535,46 +1121,262 @@ func (m *Model) reselectByID(id string) {
 	}
 }
 
-func (m *Model) startEditingSelected() (Model, tea.Cmd) {
+// savedMsg carries the outcome of a save started by save.
+type savedMsg struct {
+	note   fs.Note
+	body   string
+	status string
+	err    error
+	quit   bool // quit once saved
+	lock   bool // lock once saved; see checkLock
+
+	// disk is the note as it is on disk when the save failed with
+	// fs.ErrConflict, for the conflict view.
+	disk    string
+	diskVer fs.Version
+	diskErr error
+
+	// external is set for an edit made in the external editor, which
+	// started from orig at version base.
+	external bool
+	orig     string
+	base     fs.Version
+}
+
+// saveEdit saves the editor's body and leaves edit mode, then quits with
+// quit set. A note that changed on disk since editing began is not
+// overwritten; see conflict.go.
+func (m *Model) saveEdit(quit bool) tea.Cmd {
 	if m.selected == nil {
-		return *m, nil
+		return nil
 	}
 
-	body, err := m.store.ReadBody(m.selected.Path)
-	if err != nil {
-		m.status = "read error: " + err.Error()
-		return *m, nil
+	body := m.editor.Value()
+	// Refuse to save a front matter block the store could not read back;
+	// the note would silently lose its metadata.
+	if err := fs.ValidateFrontMatter(body); err != nil {
+		m.status = "save error: " + err.Error()
+		m.editErr = err
+		return nil
+	}
+	return m.save(*m.selected, body, m.editBase, savedMsg{quit: quit})
+}
+
+// save writes body to n in the background and reports back with msg
+// filled in.
+func (m *Model) save(n fs.Note, body string, base fs.Version, msg savedMsg) tea.Cmd {
+	if m.busy {
+		return nil
+	}
+	m.busy = true
+	graph, store := m.links, m.store
+	return tea.Batch(func() tea.Msg {
+		msg.note, msg.body = n, body
+		msg.status, msg.err = saveNote(graph, store, n, body, base)
+		if errors.Is(msg.err, fs.ErrConflict) {
+			msg.disk, msg.diskVer, msg.diskErr = storage.ReadVersion(store, n.Path)
+		}
+		return msg
+	}, m.spinTick())
+}
+
+func (m Model) finishSave(msg savedMsg) (Model, tea.Cmd) {
+	m.busy = false
+	if msg.lock {
+		return m.finishLockSave(msg)
+	}
+	if msg.external {
+		return m.finishExternalSave(msg)
+	}
+	switch {
+	case errors.Is(msg.err, fs.ErrConflict):
+		m.startConflict(msg.disk, msg.diskVer, msg.diskErr)
+		return m, nil
+	case msg.err != nil:
+		m.status = "save error: " + msg.err.Error()
+		m.editErr = msg.err
+		if m.mode == modeEdit {
+			return m, m.editor.Focus()
+		}
+		return m, nil
+	}
+
+	if msg.quit {
+		// The draft goes before the program does.
+		m.status = msg.status
+		return m, tea.Sequence(m.dropDraft(), m.quit())
+	}
+	return m, tea.Batch(m.exitEditMode(msg.status), m.reload(load{reselect: msg.note.ID}))
+}
+
+// editLoadedMsg carries a note read for editing by startEditing or
+// openExternalEditor.
+type editLoadedMsg struct {
+	note     fs.Note
+	body     string
+	version  fs.Version
+	err      error
+	draft    *fs.Draft
+	external bool
+	sess     *editor.Session // for external edits
+}
+
+// startEditing opens the selected note in the editor once it is read,
+// with the body of draft in place of the note's when it is set.
+func (m *Model) startEditing(draft *fs.Draft) tea.Cmd {
+	return m.openSelected(false, draft)
+}
+
+// openExternalEditor suspends the program and edits the selected note in
+// the user's editor. The note is saved through the store once it exits.
+func (m *Model) openExternalEditor() tea.Cmd {
+	return m.openSelected(true, nil)
+}
+
+func (m *Model) openSelected(external bool, draft *fs.Draft) tea.Cmd {
+	if m.selected == nil || m.opening {
+		return nil
+	}
+	m.opening = true
+	n, store, cfg := *m.selected, m.store, m.cfg
+	return tea.Batch(func() tea.Msg {
+		body, version, err := storage.ReadVersion(store, n.Path)
+		msg := editLoadedMsg{note: n, body: body, version: version, err: err, draft: draft, external: external}
+		if err == nil && external {
+			var dir string
+			if dir, err = storage.EditDir(cfg); err == nil {
+				msg.sess, err = editor.NewSession(dir, n.ID, body)
+			}
+			if err != nil {
+				msg.err = fmt.Errorf("editor: %w", err)
+			}
+		}
+		return msg
+	}, m.spinTick())
+}
+
+// finishOpen starts the edit, unless the user went elsewhere meanwhile.
+func (m *Model) finishOpen(msg editLoadedMsg) tea.Cmd {
+	m.opening = false
+	if msg.err != nil {
+		m.status = "open error: " + msg.err.Error()
+		return nil
+	}
+	if m.mode != modeBrowse || m.selected == nil || m.selected.ID != msg.note.ID {
+		if msg.sess != nil {
+			return func() tea.Msg { msg.sess.Discard(); return nil }
+		}
+		return nil
+	}
+
+	if msg.external {
+		sess := msg.sess
+		m.externalEdit = true
+		return tea.ExecProcess(sess.Command(m.editorCmd), func(err error) tea.Msg {
+			return editorFinishedMsg{note: msg.note, sess: sess, orig: msg.body, base: msg.version, err: err}
+		})
 	}
 
 	m.mode = modeEdit
 	m.dirty = false
+	m.draftBody = ""
 	m.editErr = nil
-	m.editor.SetValue(body)
+	m.editOrig = msg.body
+	m.editBase = msg.version
+	m.editor.SetValue(msg.body)
 	m.editor.CursorEnd()
 	m.editor.Focus()
 	m.focus = focusPreview
 
-	return *m, nil
+	if d := msg.draft; d != nil {
+		m.editor.SetValue(d.Body)
+		m.editor.CursorEnd()
+		m.dirty = true
+		if msg.note.ID == d.Note.ID {
+			m.draftBody = d.Body
+		}
+		m.status = "Recovered the edit from " + d.Saved.Format(timeLayout) + "; ctrl+s to save"
+	}
+	return nil
 }
 
-func (m *Model) renderMarkdown(body string) string {
-	if m.renderer == nil {
-		return body
+// finishExternalEdit saves what the external editor left behind. While
+// another change runs the edit waits for it; see resumeExternalEdit.
+func (m *Model) finishExternalEdit(msg editorFinishedMsg) tea.Cmd {
+	if m.busy {
+		m.finishedEdit = &msg
+		m.status = "Saving the edit once the running change is done"
+		return nil
 	}
-	rendered, err := m.renderer.Render(body)
-	if err != nil {
-		return body
+	m.busy = true
+	graph, store := m.links, m.store
+	return tea.Batch(func() tea.Msg {
+		out := savedMsg{note: msg.note, external: true, orig: msg.orig, base: msg.base}
+		body, changed, err := msg.sess.Finish()
+		switch {
+		case msg.err != nil:
+			out.status = "editor error: " + msg.err.Error()
+		case err != nil:
+			out.status = "editor error: " + err.Error()
+		case !changed:
+			out.status = "No changes"
+		default:
+			out.body = body
+			out.status, out.err = saveNote(graph, store, msg.note, body, msg.base)
+			if errors.Is(out.err, fs.ErrConflict) {
+				out.disk, out.diskVer, out.diskErr = storage.ReadVersion(store, msg.note.Path)
+			}
+		}
+		return out
+	}, m.spinTick())
+}
+
+// resumeExternalEdit hands back an external edit that waited for a
+// change to finish.
+func (m *Model) resumeExternalEdit() tea.Cmd {
+	if m.finishedEdit == nil || m.busy {
+		return nil
+	}
+	msg := *m.finishedEdit
+	m.finishedEdit = nil
+	return func() tea.Msg { return msg }
+}
+
+func (m Model) finishExternalSave(msg savedMsg) (Model, tea.Cmd) {
+	cmd := m.reload(load{reselect: msg.note.ID})
+	if msg.err == nil {
+		m.status = msg.status
+		return m, cmd
+	}
+
+	// The temporary file is gone; continue in the built-in editor so the
+	// edit is not lost, and can be merged or saved again.
+	note := msg.note
+	m.selected = &note
+	m.mode = modeEdit
+	m.focus = focusPreview
+	m.editor.SetValue(msg.body)
+	m.editor.CursorEnd()
+	m.editOrig, m.editBase = msg.orig, msg.base
+	m.dirty = true
+	m.draftBody = ""
+	if errors.Is(msg.err, fs.ErrConflict) {
+		m.startConflict(msg.disk, msg.diskVer, msg.diskErr)
+		return m, cmd
 	}
-	return rendered
+	m.status = "save error: " + msg.err.Error() + "; your edit is in the editor"
+	m.editErr = msg.err
+	return m, tea.Batch(cmd, m.editor.Focus())
 }
 
-func (m *Model) exitEditMode(status string) {
+func (m *Model) exitEditMode(status string) tea.Cmd {
 	m.mode = modeBrowse
 	m.editor.Blur()
 	m.status = status
+	drop := m.dropDraft()
 	m.dirty = false
 	m.editErr = nil
-	m.syncSelection()
+	return tea.Batch(drop, m.syncSelection())
 }
 
 type editKeyMap struct{ KeyMap }
@@ -0,0 +1,280 @@
+package app
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/internet-kid/tenote/internal/storage"
+	"github.com/internet-kid/tenote/internal/storage/fs"
+)
+
+// maxTreeShare caps the section tree at this fraction of the sidebar; the
+// rest is left to the note list.
+const maxTreeShare = 3
+
+const noNotebooksMsg = "notebooks are not available for this storage backend"
+
+type sectionItem struct {
+	key    fs.Section
+	title  string
+	prefix string // tree glyphs drawn before the title
+}
+
+func sectionTitle(s fs.Section) string {
+	switch s {
+	case fs.SectionNotes:
+		return "Notes"
+	case fs.SectionJournal:
+		return "Journal"
+	case fs.SectionTrash:
+		return "Trash"
+	case sectionTags:
+		return "Tags"
+	}
+	return s.Name()
+}
+
+func (m Model) currentSection() fs.Section {
+	if m.sectionIdx < 0 || m.sectionIdx >= len(m.sections) {
+		return fs.SectionNotes
+	}
+	return m.sections[m.sectionIdx].key
+}
+
+func (m Model) inTrash() bool {
+	return m.currentSection() == fs.SectionTrash
+}
+
+// setSections rebuilds the section tree from the store's sections, keeping
+// the current section selected when it still exists.
+func (m *Model) setSections(secs []fs.Section) {
+	current := m.currentSection()
+
+	m.sections = make([]sectionItem, 0, len(secs)+1)
+	m.sectionIdx = 0
+	for i, s := range secs {
+		// The tag browser sits between the notebooks and the trash.
+		if s == fs.SectionTrash {
+			m.sections = append(m.sections, sectionItem{key: sectionTags, title: sectionTitle(sectionTags)})
+		}
+		m.sections = append(m.sections, sectionItem{key: s, title: sectionTitle(s), prefix: treePrefix(secs, i)})
+	}
+	for i, it := range m.sections {
+		if it.key == current {
+			m.sectionIdx = i
+		}
+	}
+	m.fitSidebar()
+}
+
+// treePrefix draws the branches in front of secs[i]. Top-level sections
+// get none; nested notebooks get one column per ancestor.
+func treePrefix(secs []fs.Section, i int) string {
+	depth := secs[i].Depth()
+	if depth == 0 {
+		return ""
+	}
+
+	// hasNext reports whether a later sibling of the ancestor at level d
+	// follows before the tree climbs above it.
+	hasNext := func(d int) bool {
+		for _, s := range secs[i+1:] {
+			if s.Depth() < d || !s.IsNotebook() {
+				return false
+			}
+			if s.Depth() == d {
+				return true
+			}
+		}
+		return false
+	}
+
+	var b strings.Builder
+	for d := 2; d <= depth; d++ {
+		if hasNext(d - 1) {
+			b.WriteString("│ ")
+		} else {
+			b.WriteString("  ")
+		}
+	}
+	if hasNext(depth) {
+		b.WriteString("├ ")
+	} else {
+		b.WriteString("└ ")
+	}
+	return b.String()
+}
+
+// fitSidebar splits the sidebar between the section tree and the note list.
+func (m *Model) fitSidebar() {
+	m.treeH = max(1, min(len(m.sections), m.paneH/maxTreeShare))
+	m.noteList.SetHeight(max(1, m.paneH-1-m.treeH))
+}
+
+// renderSectionTree renders the window of the tree that holds the cursor:
+// the move target while picking one, the current section otherwise.
+func (m Model) renderSectionTree() string {
+	cursor := m.sectionIdx
+	if m.mode == modeMove {
+		cursor = m.moveIdx
+	}
+
+	from := 0
+	if len(m.sections) > m.treeH {
+		from = min(max(0, cursor-m.treeH/2), len(m.sections)-m.treeH)
+	}
+	to := min(len(m.sections), from+m.treeH)
+
+	lines := make([]string, 0, m.treeH)
+	for i := from; i < to; i++ {
+		it := m.sections[i]
+		line := blurStyle.Render(it.prefix)
+		switch {
+		case i == cursor:
+			line += focusStyle.Render("› " + it.title)
+		case i == m.sectionIdx:
+			line += titleStyle.Render("  " + it.title)
+		default:
+			line += "  " + it.title
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// ---------- creating and deleting notebooks ----------
+
+func (m *Model) startNewNotebook() (Model, tea.Cmd) {
+	if _, ok := m.store.(storage.Notebooks); !ok {
+		m.status = noNotebooksMsg
+		return *m, nil
+	}
+
+	m.mode = modeNewNotebook
+	m.focus = focusSidebar
+	m.promptInput.Prompt = "+ "
+	m.promptInput.Placeholder = "notebook name"
+	m.promptInput.SetValue("")
+	m.promptInput.Width = m.noteList.Width() - 4
+	m.status = "New notebook in " + sectionTitle(m.currentSection())
+	cmd := m.promptInput.Focus()
+	return *m, cmd
+}
+
+func (m Model) updateNewNotebookMode(msg tea.KeyMsg) (Model, tea.Cmd) {
+	switch {
+	case key.Matches(msg, m.keys.Cancel):
+		m.exitPrompt()
+		m.status = ""
+		return m, nil
+
+	case key.Matches(msg, m.keys.Open):
+		name := strings.TrimSpace(m.promptInput.Value())
+		if name == "" {
+			return m, nil
+		}
+		if err := fs.ValidNotebookName(name); err != nil {
+			m.status = "notebook error: " + err.Error()
+			return m, nil
+		}
+		parent, nbs := m.currentSection(), m.store.(storage.Notebooks)
+		m.exitPrompt()
+		return m, m.change(func() changeDoneMsg {
+			nb, err := nbs.CreateNotebook(parent, name)
+			if err != nil {
+				return failed("notebook", err)
+			}
+			return changeDoneMsg{status: "Created notebook " + nb.Rel(), reload: true, load: load{sections: true, section: nb}}
+		})
+	}
+
+	var cmd tea.Cmd
+	m.promptInput, cmd = m.promptInput.Update(msg)
+	return m, cmd
+}
+
+func (m *Model) exitPrompt() {
+	m.mode = modeBrowse
+	m.promptInput.Blur()
+}
+
+func (m *Model) deleteNotebook() tea.Cmd {
+	nb := m.currentSection()
+	nbs, ok := m.store.(storage.Notebooks)
+	if !ok || nb == fs.SectionNotes {
+		return nil
+	}
+	return m.change(func() changeDoneMsg {
+		if err := nbs.DeleteNotebook(nb); err != nil {
+			return failed("notebook", err)
+		}
+		return changeDoneMsg{status: "Deleted notebook " + nb.Rel(), reload: true, load: load{sections: true, section: nb.Parent()}}
+	})
+}
+
+// ---------- moving notes ----------
+
+// startMove lets the user pick a notebook for the selected note in the
+// section tree.
+func (m *Model) startMove() {
+	if m.selected == nil {
+		return
+	}
+	if _, ok := m.store.(storage.Notebooks); !ok {
+		m.status = noNotebooksMsg
+		return
+	}
+	m.mode = modeMove
+	m.focus = focusSidebar
+	m.moveIdx = m.sectionIdx
+	if !m.currentSection().IsNotebook() {
+		m.moveIdx = 0
+	}
+	m.status = "Move " + m.selected.Title + " to…"
+}
+
+func (m Model) updateMoveMode(msg tea.KeyMsg) (Model, tea.Cmd) {
+	switch {
+	case key.Matches(msg, m.keys.Cancel):
+		m.mode = modeBrowse
+		m.status = ""
+		return m, nil
+
+	case key.Matches(msg, m.keys.Down):
+		m.stepMoveTarget(1)
+		return m, nil
+
+	case key.Matches(msg, m.keys.Up):
+		m.stepMoveTarget(-1)
+		return m, nil
+
+	case key.Matches(msg, m.keys.Open):
+		m.mode = modeBrowse
+		target, n, nbs := m.sections[m.moveIdx].key, *m.selected, m.store.(storage.Notebooks)
+		return m, m.change(func() changeDoneMsg {
+			moved, err := nbs.Move(n, target)
+			if err != nil {
+				return failed("move", err)
+			}
+			return changeDoneMsg{status: "Moved to " + sectionTitle(target) + ": " + moved.Title, reload: true}
+		})
+	}
+
+	return m, nil
+}
+
+// stepMoveTarget moves the picker by delta, skipping the trash.
+func (m *Model) stepMoveTarget(delta int) {
+	for i := m.moveIdx + delta; i >= 0 && i < len(m.sections); i += delta {
+		if m.sections[i].key.IsNotebook() {
+			m.moveIdx = i
+			return
+		}
+	}
+}
+
+type pickKeyMap struct{ KeyMap }
+
+func (k pickKeyMap) ShortHelp() []key.Binding { return k.KeyMap.PickShortHelp() }
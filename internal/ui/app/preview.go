@@ -0,0 +1,256 @@
+package app
+
+import (
+	"container/list"
+	"context"
+	"sync"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/glamour"
+
+	"github.com/internet-kid/tenote/internal/storage/fs"
+)
+
+// previewCacheSize is how many rendered notes the preview keeps, so going
+// back to a note does not render it again.
+const previewCacheSize = 64
+
+// previewMsg carries a note read and rendered for the preview by
+// loadPreview. Only the one for the latest request is shown.
+type previewMsg struct {
+	seq  int
+	key  previewKey
+	body string // rendered
+	err  error
+}
+
+// diffMsg carries a diff prepared for the preview in place of a note, in
+// history and draft recovery.
+type diffMsg struct {
+	seq     int
+	content string
+	err     error
+}
+
+// previewScroll is where to scroll the preview of note id once it shows:
+// to the first line with one of terms, or else to offset.
+type previewScroll struct {
+	id     string
+	offset int
+	terms  []string
+}
+
+// loadPreview shows n in the preview: at once when it was rendered before,
+// otherwise once it has been read and rendered in the background. A render
+// still running for another note is abandoned.
+func (m *Model) loadPreview(n fs.Note) tea.Cmd {
+	key := previewKey{id: n.ID, mod: n.UpdatedAt.UnixNano()}
+	if body, ok := m.previews.get(key); ok {
+		m.cancelPreview()
+		m.previewErr = nil
+		m.showBody(n.ID, body)
+		return nil
+	}
+
+	ctx := m.nextPreview()
+	m.previewErr = nil
+	m.previewBody = ""
+	m.previewContent = ""
+	m.preview.SetContent("")
+	seq, store, r := m.previewSeq, m.store, m.renderer
+	return tea.Batch(func() tea.Msg {
+		if ctx.Err() != nil {
+			return nil
+		}
+		msg := previewMsg{seq: seq, key: key}
+		body, err := store.ReadBody(n.Path)
+		if err != nil {
+			msg.err = err
+			return msg
+		}
+		_, content := fs.ParseFrontMatter(body)
+		if msg.body, err = r.render(ctx, content); err != nil {
+			return nil
+		}
+		return msg
+	}, m.spinTick())
+}
+
+// loadDiff shows what prepare returns in the preview once it is ready,
+// abandoning any render still running.
+func (m *Model) loadDiff(prepare func() (string, error)) tea.Cmd {
+	ctx := m.nextPreview()
+	seq := m.previewSeq
+	return tea.Batch(func() tea.Msg {
+		if ctx.Err() != nil {
+			return nil
+		}
+		content, err := prepare()
+		return diffMsg{seq: seq, content: content, err: err}
+	}, m.spinTick())
+}
+
+// nextPreview cancels the preview being prepared and starts the next one.
+func (m *Model) nextPreview() context.Context {
+	m.cancelPreview()
+	ctx, cancel := context.WithCancel(context.Background())
+	m.previewSeq++
+	m.previewCancel = cancel
+	m.previewLoading = true
+	return ctx
+}
+
+// cancelPreview abandons the preview being prepared, if any.
+func (m *Model) cancelPreview() {
+	if m.previewCancel != nil {
+		m.previewCancel()
+		m.previewCancel = nil
+	}
+	m.previewLoading = false
+}
+
+// finishPreview shows a rendered note that is still wanted.
+func (m *Model) finishPreview(msg previewMsg) {
+	if msg.seq != m.previewSeq {
+		return
+	}
+	m.cancelPreview()
+	m.previewErr = msg.err
+	if msg.err != nil {
+		return
+	}
+	m.previews.put(msg.key, msg.body)
+	if m.showsNote() && m.selected != nil && m.selected.ID == msg.key.id {
+		m.showBody(msg.key.id, msg.body)
+	}
+}
+
+// finishDiff shows a diff that is still wanted.
+func (m *Model) finishDiff(msg diffMsg) {
+	if msg.seq != m.previewSeq {
+		return
+	}
+	m.cancelPreview()
+	m.previewErr = msg.err
+	if msg.err != nil {
+		return
+	}
+	m.previewContent = msg.content
+	m.preview.SetContent(m.previewContent)
+	m.preview.GotoTop()
+}
+
+// showBody puts the rendered body of note id in the preview, under its
+// links panel, and scrolls it where it was asked to.
+func (m *Model) showBody(id, body string) {
+	m.previewBody = body
+	m.showPreview()
+	if m.scroll.id != id {
+		return
+	}
+	if len(m.scroll.terms) > 0 {
+		m.jumpToMatch(m.scroll.terms)
+	} else {
+		m.preview.SetYOffset(m.scroll.offset)
+	}
+	m.scroll = previewScroll{}
+}
+
+// showsNote reports whether the preview shows the selected note, rather
+// than the editor or a diff.
+func (m Model) showsNote() bool {
+	switch m.mode {
+	case modeHistory, modeRecover, modeConflict, modeTemplate, modeCalendar:
+		return false
+	}
+	return !m.editing()
+}
+
+// markdownRenderer renders notes for the preview. glamour's renderer keeps
+// state while it renders, so renders take turns.
+type markdownRenderer struct {
+	mu sync.Mutex
+	r  *glamour.TermRenderer
+}
+
+func newMarkdownRenderer(wrap int) *markdownRenderer {
+	r, err := glamour.NewTermRenderer(
+		glamour.WithAutoStyle(),
+		glamour.WithWordWrap(wrap),
+	)
+	if err != nil {
+		return &markdownRenderer{}
+	}
+	return &markdownRenderer{r: r}
+}
+
+// render renders body, or returns it as it is when rendering fails. It
+// gives up with ctx's error when ctx is done before its turn comes.
+func (r *markdownRenderer) render(ctx context.Context, body string) (string, error) {
+	if r == nil || r.r == nil {
+		return body, nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	rendered, err := r.r.Render(body)
+	if err != nil {
+		return body, nil
+	}
+	return rendered, nil
+}
+
+// previewKey identifies a rendering of a note: a note renders the same
+// until its file changes.
+type previewKey struct {
+	id  string
+	mod int64 // modification time in Unix nanoseconds
+}
+
+// previewCache keeps the most recently used renderings. It is only used
+// from the update loop.
+type previewCache struct {
+	size  int
+	order *list.List // of previewEntry, most recently used first
+	items map[previewKey]*list.Element
+}
+
+type previewEntry struct {
+	key  previewKey
+	body string
+}
+
+func newPreviewCache(size int) *previewCache {
+	return &previewCache{size: size, order: list.New(), items: make(map[previewKey]*list.Element)}
+}
+
+func (c *previewCache) get(key previewKey) (string, bool) {
+	el, ok := c.items[key]
+	if !ok {
+		return "", false
+	}
+	c.order.MoveToFront(el)
+	return el.Value.(previewEntry).body, true
+}
+
+func (c *previewCache) put(key previewKey, body string) {
+	if el, ok := c.items[key]; ok {
+		el.Value = previewEntry{key: key, body: body}
+		c.order.MoveToFront(el)
+		return
+	}
+	c.items[key] = c.order.PushFront(previewEntry{key: key, body: body})
+	for c.order.Len() > c.size {
+		last := c.order.Back()
+		c.order.Remove(last)
+		delete(c.items, last.Value.(previewEntry).key)
+	}
+}
+
+// clear forgets every rendering, e.g. when the preview width changes.
+func (c *previewCache) clear() {
+	c.order.Init()
+	clear(c.items)
+}
@@ -0,0 +1,133 @@
+package app
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestPreviewCacheLRU(t *testing.T) {
+	type op struct {
+		get bool // get rather than put
+		id  string
+	}
+	put := func(id string) op { return op{id: id} }
+	get := func(id string) op { return op{get: true, id: id} }
+
+	tests := []struct {
+		name string
+		ops  []op
+		want []string // IDs kept, most recently used first
+	}{
+		{"under size", []op{put("a"), put("b")}, []string{"b", "a"}},
+		{"oldest evicted", []op{put("a"), put("b"), put("c"), put("d")}, []string{"d", "c", "b"}},
+		{"get keeps", []op{put("a"), put("b"), put("c"), get("a"), put("d")}, []string{"d", "a", "c"}},
+		{"put again keeps", []op{put("a"), put("b"), put("c"), put("a"), put("d")}, []string{"d", "a", "c"}},
+		{"miss changes nothing", []op{put("a"), put("b"), put("c"), get("x"), put("d")}, []string{"d", "c", "b"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newPreviewCache(3)
+			for _, o := range tt.ops {
+				if o.get {
+					c.get(previewKey{id: o.id})
+				} else {
+					c.put(previewKey{id: o.id}, "# "+o.id)
+				}
+			}
+			var got []string
+			for el := c.order.Front(); el != nil; el = el.Next() {
+				got = append(got, el.Value.(previewEntry).key.id)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Fatalf("cache holds %v, want %v", got, tt.want)
+			}
+			for _, id := range tt.want {
+				if body, ok := c.get(previewKey{id: id}); !ok || body != "# "+id {
+					t.Fatalf("get(%s) = %q, %v", id, body, ok)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadPreviewCache(t *testing.T) {
+	tests := []struct {
+		name   string
+		cached previewKey // relative to the note: id "" is the note's
+		hit    bool
+	}{
+		{"rendered before", previewKey{}, true},
+		{"changed since", previewKey{mod: -1}, false},
+		{"other note", previewKey{id: "other"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, n := launch(t, "# Plan\n", nil)
+			key := previewKey{id: n.ID, mod: n.UpdatedAt.UnixNano() + tt.cached.mod}
+			if tt.cached.id != "" {
+				key.id = tt.cached.id
+			}
+			m.previews.clear()
+			m.previews.put(key, "cached")
+			seq := m.previewSeq
+
+			cmd := m.loadPreview(n)
+			if hit := cmd == nil; hit != tt.hit {
+				t.Fatalf("cache hit = %v, want %v", hit, tt.hit)
+			}
+			if tt.hit {
+				if m.previewBody != "cached" || m.previewLoading || m.previewSeq != seq {
+					t.Fatalf("preview %q, loading %v, seq %d; want the cached render shown at once", m.previewBody, m.previewLoading, m.previewSeq)
+				}
+				return
+			}
+			if m.previewBody == "cached" || !m.previewLoading || m.previewSeq != seq+1 {
+				t.Fatalf("preview %q, loading %v, seq %d; want a new render", m.previewBody, m.previewLoading, m.previewSeq)
+			}
+		})
+	}
+}
+
+func TestStalePreviewDropped(t *testing.T) {
+	tests := []struct {
+		name  string
+		stale bool // the render finishing is for the note the cursor left
+	}{
+		{"latest", false},
+		{"stale", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, first := launch(t, "# First\n", nil)
+			second, err := m.store.Create(first.Section)
+			if err != nil {
+				t.Fatal(err)
+			}
+			m.previews.clear()
+
+			m.selected = &first
+			m.loadPreview(first)
+			firstSeq := m.previewSeq
+			// The cursor moves on before the first render is done.
+			m.selected = &second
+			m.loadPreview(second)
+
+			msg := previewMsg{seq: m.previewSeq, key: previewKey{id: second.ID, mod: second.UpdatedAt.UnixNano()}, body: "second"}
+			if tt.stale {
+				msg = previewMsg{seq: firstSeq, key: previewKey{id: first.ID, mod: first.UpdatedAt.UnixNano()}, body: "first"}
+			}
+			m.finishPreview(msg)
+
+			_, cached := m.previews.get(msg.key)
+			if tt.stale {
+				if m.previewBody == "first" || cached || !m.previewLoading {
+					t.Fatalf("stale render shown %v, cached %v, loading %v; want it dropped", m.previewBody == "first", cached, m.previewLoading)
+				}
+				return
+			}
+			if m.previewBody != "second" || !cached || m.previewLoading {
+				t.Fatalf("preview %q, cached %v, loading %v; want the latest render shown", m.previewBody, cached, m.previewLoading)
+			}
+		})
+	}
+}
@@ -0,0 +1,210 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+
+	"github.com/internet-kid/tenote/internal/storage/fs"
+	"github.com/internet-kid/tenote/internal/storage/search"
+)
+
+const searchLimit = 100
+
+var matchStyle = lipgloss.NewStyle().Underline(true).Bold(true)
+
+type hitItem struct {
+	h search.Hit
+}
+
+func (i hitItem) Title() string { return i.h.Note.Title }
+
+func (i hitItem) Description() string {
+	desc := highlightSnippet(i.h)
+	if i.h.Note.Section == fs.SectionTrash {
+		desc = "Trash · " + desc
+	}
+	return desc
+}
+
+func (i hitItem) FilterValue() string { return i.h.Note.Title }
+
+func highlightSnippet(h search.Hit) string {
+	var b strings.Builder
+	last := 0
+	for _, r := range h.Highlights {
+		b.WriteString(h.Snippet[last:r[0]])
+		b.WriteString(matchStyle.Render(h.Snippet[r[0]:r[1]]))
+		last = r[1]
+	}
+	b.WriteString(h.Snippet[last:])
+	return b.String()
+}
+
+// hitsMsg carries the results of a search started by runSearch. Only the
+// latest search is shown.
+type hitsMsg struct {
+	seq  int
+	hits []search.Hit
+	err  error
+}
+
+// startSearch opens the search box and brings the index up to date in the
+// background.
+func (m *Model) startSearch() (Model, tea.Cmd) {
+	m.mode = modeSearch
+	m.focus = focusSidebar
+	m.hits = nil
+	m.searchInput.SetValue("")
+	m.searchInput.Width = m.noteList.Width() - 4
+	m.status = ""
+	cmds := []tea.Cmd{m.applyHits(), m.searchInput.Focus()}
+
+	m.searchSeq++
+	m.searching = true
+	seq, index, store := m.searchSeq, m.index, m.store
+	cmds = append(cmds, m.spinTick(), func() tea.Msg {
+		if err := index.Sync(store); err != nil {
+			return hitsMsg{seq: seq, err: fmt.Errorf("index error: %w", err)}
+		}
+		return hitsMsg{seq: seq}
+	})
+	return *m, tea.Batch(cmds...)
+}
+
+func (m Model) updateSearchMode(msg tea.KeyMsg) (Model, tea.Cmd) {
+	switch {
+	case key.Matches(msg, m.keys.Cancel):
+		m.exitSearch()
+		return m, m.reload(load{})
+
+	case key.Matches(msg, m.keys.Open):
+		if m.selected == nil {
+			return m, nil
+		}
+		hit := m.hits[m.noteList.Index()]
+		m.exitSearch()
+		return m, m.reload(load{
+			sections: true,
+			section:  hit.Note.Section,
+			reselect: hit.Note.ID,
+			scroll:   previewScroll{id: hit.Note.ID, terms: hit.Terms},
+		})
+
+	case key.Matches(msg, m.keys.ResultUp):
+		m.noteList.CursorUp()
+		return m, m.syncSearchSelection()
+
+	case key.Matches(msg, m.keys.ResultDown):
+		m.noteList.CursorDown()
+		return m, m.syncSearchSelection()
+	}
+
+	var cmd tea.Cmd
+	before := m.searchInput.Value()
+	m.searchInput, cmd = m.searchInput.Update(msg)
+	if m.searchInput.Value() != before {
+		cmd = tea.Batch(cmd, m.runSearch())
+	}
+	return m, cmd
+}
+
+// runSearch searches for what is typed in the background.
+func (m *Model) runSearch() tea.Cmd {
+	m.searchSeq++
+	m.searching = true
+	seq, index, store := m.searchSeq, m.index, m.store
+	query := liveQuery(m.searchInput.Value())
+	return tea.Batch(func() tea.Msg {
+		hits, err := index.Search(store, query, search.Options{Limit: searchLimit})
+		if err != nil {
+			err = fmt.Errorf("search: %w", err)
+		}
+		return hitsMsg{seq: seq, hits: hits, err: err}
+	}, m.spinTick())
+}
+
+// finishSearch shows the results of the latest search.
+func (m *Model) finishSearch(msg hitsMsg) tea.Cmd {
+	if msg.seq != m.searchSeq || m.mode != modeSearch {
+		return nil
+	}
+	m.searching = false
+	if msg.err != nil {
+		// Usually a half-typed query; keep showing the previous results.
+		m.status = msg.err.Error()
+		return nil
+	}
+	m.status = ""
+	m.hits = msg.hits
+	return m.applyHits()
+}
+
+// liveQuery treats the word being typed as a prefix so results show up
+// before it is complete.
+func liveQuery(q string) string {
+	r, _ := utf8.DecodeLastRuneInString(q)
+	if (unicode.IsLetter(r) || unicode.IsDigit(r)) && strings.Count(q, `"`)%2 == 0 {
+		return q + "*"
+	}
+	return q
+}
+
+// applyHits shows the current hits in the sidebar in place of the section's
+// notes, so the usual selection and preview code works on them unchanged.
+func (m *Model) applyHits() tea.Cmd {
+	m.notes = make([]fs.Note, 0, len(m.hits))
+	items := make([]list.Item, 0, len(m.hits))
+	for _, h := range m.hits {
+		m.notes = append(m.notes, h.Note)
+		items = append(items, hitItem{h: h})
+	}
+	m.noteList.SetItems(items)
+	m.noteList.Select(0)
+	return m.syncSearchSelection()
+}
+
+// syncSearchSelection previews the selected result, scrolled to its first
+// match.
+func (m *Model) syncSearchSelection() tea.Cmd {
+	if idx := m.noteList.Index(); idx >= 0 && idx < len(m.hits) {
+		m.scroll = previewScroll{id: m.hits[idx].Note.ID, terms: m.hits[idx].Terms}
+	}
+	return m.syncSelection()
+}
+
+func (m *Model) exitSearch() {
+	m.mode = modeBrowse
+	m.searchInput.Blur()
+	m.hits = nil
+	m.searching = false
+}
+
+// jumpToMatch scrolls the preview to the first rendered line containing one
+// of terms. Lines are compared after stripping the renderer's styling.
+func (m *Model) jumpToMatch(terms []string) {
+	if len(terms) == 0 {
+		return
+	}
+	lines := strings.Split(ansi.Strip(m.previewContent), "\n")
+	for i, line := range lines {
+		line = strings.ToLower(line)
+		for _, t := range terms {
+			if strings.Contains(line, t) {
+				m.preview.SetYOffset(i)
+				return
+			}
+		}
+	}
+}
+
+type searchKeyMap struct{ KeyMap }
+
+func (k searchKeyMap) ShortHelp() []key.Binding { return k.KeyMap.SearchShortHelp() }
@@ -0,0 +1,211 @@
+package app
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/internet-kid/tenote/internal/config"
+	"github.com/internet-kid/tenote/internal/storage"
+	"github.com/internet-kid/tenote/internal/storage/fs"
+)
+
+var groupStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("241")).Bold(true).PaddingLeft(2)
+
+// groupItem is a heading in the sidebar between notes grouped by date. The
+// cursor never rests on one; see skipHeading.
+type groupItem struct {
+	title string
+}
+
+func (i groupItem) Title() string       { return i.title }
+func (i groupItem) Description() string { return "" }
+func (i groupItem) FilterValue() string { return "" }
+
+// noteDelegate draws the sidebar list: headings its own way, everything
+// else like the default delegate.
+type noteDelegate struct {
+	list.DefaultDelegate
+}
+
+func (d noteDelegate) Render(w io.Writer, m list.Model, index int, item list.Item) {
+	if g, ok := item.(groupItem); ok {
+		// Headings take the height of a note; the blank line sets them
+		// off from the group above.
+		fmt.Fprint(w, "\n"+groupStyle.Render(g.title))
+		return
+	}
+	d.DefaultDelegate.Render(w, m, index, item)
+}
+
+// noteItems lists notes in the sidebar, under date headings when grouping
+// is on and section is sorted by a date. Pinned notes, which come first
+// whatever the date, get a heading of their own.
+func (m Model) noteItems(notes []fs.Note, section fs.Section) []list.Item {
+	order := m.sortOrder(section)
+	group := m.groupNotes && byDate(order) && section != fs.SectionTrash
+
+	items := make([]list.Item, 0, len(notes))
+	now, last := time.Now(), ""
+	for _, n := range notes {
+		if group {
+			title := "Pinned"
+			switch {
+			case n.Pinned:
+			case order == fs.SortCreated:
+				title = dateGroup(n.CreatedOrUpdated(), now)
+			default:
+				title = dateGroup(n.UpdatedAt, now)
+			}
+			if title != last {
+				items = append(items, groupItem{title: title})
+				last = title
+			}
+		}
+		items = append(items, noteItem{n: n, retention: m.retention, order: order})
+	}
+	return items
+}
+
+// byDate reports whether order lists notes by a date, which is what they
+// are grouped by.
+func byDate(order fs.SortOrder) bool {
+	return order == fs.SortUpdated || order == fs.SortCreated
+}
+
+// dateGroup names the heading a note dated t is listed under as seen at
+// now: Today, Yesterday, This week or Older. Weeks start on Monday.
+func dateGroup(t, now time.Time) string {
+	t = t.In(now.Location())
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	monday := today.AddDate(0, 0, -(int(today.Weekday())+6)%7)
+	switch {
+	case !t.Before(today):
+		return "Today"
+	case !t.Before(today.AddDate(0, 0, -1)):
+		return "Yesterday"
+	case !t.Before(monday):
+		return "This week"
+	}
+	return "Older"
+}
+
+// skipHeading moves the cursor off a heading: on in the direction it was
+// going, or back down from a heading at the top.
+func (m *Model) skipHeading(down bool) {
+	if _, ok := m.noteList.SelectedItem().(groupItem); !ok {
+		return
+	}
+	if down || m.noteList.Index() == 0 {
+		m.noteList.CursorDown()
+	} else {
+		m.noteList.CursorUp()
+	}
+}
+
+// sortOrder returns the order the notes of section are listed in.
+func (m Model) sortOrder(section fs.Section) fs.SortOrder {
+	if o, ok := m.sorts[section]; ok {
+		return o
+	}
+	return fs.DefaultSortOrder(section)
+}
+
+// sortOrders reads the sort order of each section from cfg.
+func sortOrders(cfg config.AppConfig) map[fs.Section]fs.SortOrder {
+	out := make(map[fs.Section]fs.SortOrder, len(cfg.Sort))
+	for sec := range cfg.Sort {
+		out[fs.Section(sec)] = storage.SortOrder(cfg, fs.Section(sec))
+	}
+	return out
+}
+
+// cycleSort lists the current section in the next sort order.
+func (m *Model) cycleSort() tea.Cmd {
+	sec := m.currentSection()
+	order := m.sortOrder(sec).Next()
+	// The map is shared by copies of the model and by reloads still
+	// running; replace it rather than change it under them.
+	sorts := make(map[fs.Section]fs.SortOrder, len(m.sorts)+1)
+	maps.Copy(sorts, m.sorts)
+	sorts[sec] = order
+	m.sorts = sorts
+	m.status = "Sorted by " + string(order)
+	return tea.Batch(m.reload(m.keepSelection()), m.saveView())
+}
+
+// toggleGroup turns the date headings on or off.
+func (m *Model) toggleGroup() tea.Cmd {
+	m.groupNotes = !m.groupNotes
+	m.status = "Grouped by date"
+	switch {
+	case !m.groupNotes:
+		m.status = "Not grouped"
+	case !byDate(m.sortOrder(m.currentSection())):
+		m.status = "Grouped by date when sorted by updated or created"
+	}
+	return tea.Batch(m.reload(m.keepSelection()), m.saveView())
+}
+
+// togglePin pins the selected note to the top of its section, or unpins
+// it.
+func (m *Model) togglePin() tea.Cmd {
+	if m.selected == nil {
+		return nil
+	}
+	n, store := *m.selected, m.store
+	return m.change(func() changeDoneMsg {
+		if _, err := storage.SetPinned(store, n, !n.Pinned); err != nil {
+			return failed("pin", err)
+		}
+		status := "Pinned: " + n.Title
+		if n.Pinned {
+			status = "Unpinned: " + n.Title
+		}
+		return changeDoneMsg{status: status, reload: true, load: load{reselect: n.ID}}
+	})
+}
+
+// keepSelection is a reload that keeps the selected note selected.
+func (m Model) keepSelection() load {
+	if m.selected == nil {
+		return load{}
+	}
+	return load{reselect: m.selected.ID}
+}
+
+// viewSavedMsg reports how saving the sort orders and grouping went.
+type viewSavedMsg struct {
+	err error
+}
+
+// viewMu keeps saves of the view settings from interleaving.
+var viewMu sync.Mutex
+
+// saveView saves the sort orders and grouping to the config, so the next
+// session lists notes the same way.
+func (m Model) saveView() tea.Cmd {
+	sorts := make(map[string]string, len(m.sorts))
+	for sec, o := range m.sorts {
+		if o != fs.DefaultSortOrder(sec) {
+			sorts[string(sec)] = string(o)
+		}
+	}
+	group := m.groupNotes
+	return func() tea.Msg {
+		viewMu.Lock()
+		defer viewMu.Unlock()
+		cfg, err := config.LoadConfig()
+		if err != nil {
+			return viewSavedMsg{err: err}
+		}
+		cfg.Sort, cfg.GroupNotes = sorts, group
+		return viewSavedMsg{err: config.SaveConfig(cfg)}
+	}
+}
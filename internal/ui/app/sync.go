@@ -0,0 +1,62 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/internet-kid/tenote/internal/storage"
+	"github.com/internet-kid/tenote/internal/storage/gitstore"
+)
+
+// syncDoneMsg carries the outcome of a sync started by startSync.
+type syncDoneMsg struct {
+	res gitstore.SyncResult
+	err error
+}
+
+// startSync pulls from and pushes to the remote in the background, since
+// both may take a while over the network.
+func (m *Model) startSync() (Model, tea.Cmd) {
+	syncer, ok := m.store.(storage.Syncer)
+	if !ok {
+		m.status = `sync needs the "git" storage backend`
+		return *m, nil
+	}
+	if m.syncing {
+		return *m, nil
+	}
+	m.syncing = true
+	m.status = "Syncing…"
+	return *m, func() tea.Msg {
+		res, err := syncer.Sync()
+		return syncDoneMsg{res: res, err: err}
+	}
+}
+
+// finishSync reloads what the merge may have changed and opens the first
+// conflicted note, if any.
+func (m *Model) finishSync(msg syncDoneMsg) tea.Cmd {
+	m.syncing = false
+	if msg.err != nil {
+		m.status = "sync error: " + msg.err.Error()
+		return nil
+	}
+
+	m.status = fmt.Sprintf("Synced: pulled %d commits", msg.res.Pulled)
+	if msg.res.Pushed {
+		m.status += ", pushed"
+	}
+
+	conflicts := msg.res.Conflicts
+	if len(conflicts) == 0 || m.mode != modeBrowse {
+		return m.reload(load{sections: true})
+	}
+	titles := make([]string, 0, len(conflicts))
+	for _, n := range conflicts {
+		titles = append(titles, n.Title)
+	}
+	m.status = fmt.Sprintf("Conflicts in %d notes, edit to resolve: %s", len(conflicts), strings.Join(titles, ", "))
+	return m.openNote(conflicts[0])
+}
@@ -0,0 +1,215 @@
+package app
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/internet-kid/tenote/internal/storage/fs"
+	"github.com/internet-kid/tenote/internal/storage/tags"
+)
+
+// sectionTags is the sidebar entry of the tag browser. It is not a store
+// section: its list shows every tag, or the notes matching the applied tag
+// filter.
+const sectionTags fs.Section = "tags"
+
+type tagItem struct {
+	c      tags.Count
+	picked bool
+}
+
+func (i tagItem) Title() string {
+	if i.picked {
+		return "✓ #" + i.c.Name
+	}
+	return "#" + i.c.Name
+}
+
+func (i tagItem) Description() string {
+	if i.c.Notes == 1 {
+		return "1 note"
+	}
+	return fmt.Sprintf("%d notes", i.c.Notes)
+}
+
+func (i tagItem) FilterValue() string { return i.c.Name }
+
+func (m Model) inTags() bool {
+	return m.currentSection() == sectionTags
+}
+
+// tagItems lists the tags of all notes, as counted by the index. Picks of
+// tags that were renamed or deleted meanwhile are forgotten.
+func (m *Model) tagItems(counts []tags.Count) []list.Item {
+	items := make([]list.Item, 0, len(counts))
+	known := make(map[string]bool, len(counts))
+	for _, c := range counts {
+		known[c.Name] = true
+		items = append(items, tagItem{c: c, picked: m.tagPicked[c.Name]})
+	}
+	for t := range m.tagPicked {
+		if !known[t] {
+			delete(m.tagPicked, t)
+		}
+	}
+	return items
+}
+
+// tagFilterTitle describes the applied filter, e.g. "#a + #b" when notes
+// need all tags and "#a | #b" when any will do.
+func (m Model) tagFilterTitle() string {
+	sep := " + "
+	if m.tagAnyOf {
+		sep = " | "
+	}
+	return "#" + strings.Join(m.tagFilter, sep+"#")
+}
+
+// pickedTags returns the tags picked in the tag list or, when none are, the
+// tag under the cursor.
+func (m Model) pickedTags() []string {
+	var out []string
+	for t := range m.tagPicked {
+		out = append(out, t)
+	}
+	if len(out) == 0 {
+		if it, ok := m.noteList.SelectedItem().(tagItem); ok {
+			out = append(out, it.c.Name)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// updateTagsKeys handles the keys specific to the tag browser. It reports
+// false for keys it leaves to browse mode.
+func (m Model) updateTagsKeys(msg tea.KeyMsg) (Model, tea.Cmd, bool) {
+	filtered := len(m.tagFilter) > 0
+
+	switch {
+	case key.Matches(msg, m.keys.TagMatch):
+		m.tagAnyOf = !m.tagAnyOf
+		m.status = "Notes must have all picked tags"
+		if m.tagAnyOf {
+			m.status = "Notes may have any picked tag"
+		}
+		if filtered {
+			return m, m.reload(load{}), true
+		}
+		return m, nil, true
+
+	case filtered && key.Matches(msg, m.keys.Cancel):
+		m.tagFilter = nil
+		m.status = ""
+		m.noteList.Select(0)
+		return m, m.reload(load{}), true
+
+	case filtered:
+		return m, nil, false
+
+	case key.Matches(msg, m.keys.PickTag):
+		it, ok := m.noteList.SelectedItem().(tagItem)
+		if !ok {
+			return m, nil, true
+		}
+		if m.tagPicked == nil {
+			m.tagPicked = make(map[string]bool)
+		}
+		if m.tagPicked[it.c.Name] {
+			delete(m.tagPicked, it.c.Name)
+		} else {
+			m.tagPicked[it.c.Name] = true
+		}
+		it.picked = m.tagPicked[it.c.Name]
+		m.noteList.SetItem(m.noteList.Index(), it)
+		return m, nil, true
+
+	case key.Matches(msg, m.keys.FilterTags):
+		picked := m.pickedTags()
+		if len(picked) == 0 {
+			return m, nil, true
+		}
+		m.tagFilter = picked
+		m.noteList.Select(0)
+		return m, m.reload(load{countTagged: true}), true
+
+	case key.Matches(msg, m.keys.RenameTag):
+		next, cmd := m.startRenameTag()
+		return next, cmd, true
+	}
+	return m, nil, false
+}
+
+// ---------- renaming and merging tags ----------
+
+func (m *Model) startRenameTag() (Model, tea.Cmd) {
+	from := m.pickedTags()
+	if len(from) == 0 {
+		return *m, nil
+	}
+
+	m.renameFrom = from
+	m.mode = modeRenameTag
+	m.focus = focusSidebar
+	m.promptInput.Prompt = "# "
+	m.promptInput.Placeholder = "new tag name"
+	m.promptInput.SetValue("")
+	m.promptInput.Width = m.noteList.Width() - 4
+	if len(from) == 1 {
+		m.status = "Rename #" + from[0] + " to…"
+	} else {
+		m.status = "Merge #" + strings.Join(from, ", #") + " into…"
+	}
+	cmd := m.promptInput.Focus()
+	return *m, cmd
+}
+
+func (m Model) updateRenameTagMode(msg tea.KeyMsg) (Model, tea.Cmd) {
+	switch {
+	case key.Matches(msg, m.keys.Cancel):
+		m.exitPrompt()
+		m.status = ""
+		return m, nil
+
+	case key.Matches(msg, m.keys.Open):
+		to := tags.Normalize(m.promptInput.Value())
+		if to == "" {
+			m.status = "invalid tag name"
+			return m, nil
+		}
+		from, store := m.renameFrom, m.store
+		m.exitPrompt()
+		m.tagPicked = nil
+		m.renameFrom = nil
+		return m, m.change(func() changeDoneMsg {
+			changed, err := tags.Retag(store, from, to)
+			if err != nil {
+				done := failed("tag", err)
+				// Notes retagged before the error still need showing.
+				done.reload = len(changed) > 0
+				return done
+			}
+			format := "Renamed #%s to #%s in %d note(s)"
+			if len(from) > 1 {
+				format = "Merged #%s into #%s in %d note(s)"
+			}
+			return changeDoneMsg{
+				status: fmt.Sprintf(format, strings.Join(from, ", #"), to, len(changed)),
+				reload: true,
+			}
+		})
+	}
+
+	var cmd tea.Cmd
+	m.promptInput, cmd = m.promptInput.Update(msg)
+	return m, cmd
+}
+
+type tagsKeyMap struct{ KeyMap }
+
+func (k tagsKeyMap) ShortHelp() []key.Binding { return k.KeyMap.TagsShortHelp() }
@@ -0,0 +1,225 @@
+package app
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/internet-kid/tenote/internal/storage/fs"
+	"github.com/internet-kid/tenote/internal/templates"
+)
+
+// templateItem is a template in the picker n shows when there are several.
+type templateItem struct {
+	t templates.Template
+}
+
+func (i templateItem) Title() string { return i.t.Name }
+
+func (i templateItem) Description() string {
+	switch n := len(i.t.Fields()); n {
+	case 0:
+		return "no fields"
+	case 1:
+		return "1 field"
+	default:
+		return fmt.Sprintf("%d fields", n)
+	}
+}
+
+func (i templateItem) FilterValue() string { return i.t.Name }
+
+// templatesMsg carries the templates read by newNote.
+type templatesMsg struct {
+	list []templates.Template
+	err  error
+}
+
+// newNote starts a new note in the current notebook. Without templates it
+// is blank, with one it is made from that, and with several the user picks
+// one in the sidebar first.
+func (m *Model) newNote() tea.Cmd {
+	if m.opening || m.busy {
+		return nil
+	}
+	m.opening = true
+	dir := m.templateDir
+	return tea.Batch(func() tea.Msg {
+		list, err := templates.List(dir)
+		return templatesMsg{list: list, err: err}
+	}, m.spinTick())
+}
+
+// finishTemplates goes on with the new note once the templates are read,
+// unless the user went elsewhere meanwhile.
+func (m *Model) finishTemplates(msg templatesMsg) tea.Cmd {
+	m.opening = false
+	if m.mode != modeBrowse {
+		return nil
+	}
+	if msg.err != nil {
+		m.status = "template error: " + msg.err.Error()
+		return nil
+	}
+	switch len(msg.list) {
+	case 0:
+		return m.createNote(nil, nil)
+	case 1:
+		return m.fillTemplate(msg.list[0])
+	}
+
+	m.mode = modeTemplate
+	m.focus = focusSidebar
+	m.templates = msg.list
+	items := make([]list.Item, 0, len(msg.list))
+	for _, t := range msg.list {
+		items = append(items, templateItem{t: t})
+	}
+	m.noteList.SetItems(items)
+	m.noteList.Select(0)
+	m.selected = nil
+	m.fitPreview()
+	m.status = "New note from template…"
+	return m.showTemplate()
+}
+
+func (m Model) updateTemplateMode(msg tea.KeyMsg) (Model, tea.Cmd) {
+	switch {
+	case key.Matches(msg, m.keys.Cancel):
+		m.mode = modeBrowse
+		m.templates = nil
+		m.status = ""
+		return m, m.reload(load{})
+
+	case key.Matches(msg, m.keys.Down):
+		m.noteList.CursorDown()
+		return m, m.showTemplate()
+
+	case key.Matches(msg, m.keys.Up):
+		m.noteList.CursorUp()
+		return m, m.showTemplate()
+
+	case key.Matches(msg, m.keys.Open):
+		idx := m.noteList.Index()
+		if idx < 0 || idx >= len(m.templates) {
+			return m, nil
+		}
+		return m, m.fillTemplate(m.templates[idx])
+	}
+	return m, nil
+}
+
+// showTemplate shows the template under the cursor, as written, in the
+// preview.
+func (m *Model) showTemplate() tea.Cmd {
+	idx := m.noteList.Index()
+	if idx < 0 || idx >= len(m.templates) {
+		return nil
+	}
+	body := m.templates[idx].Body
+	return m.loadDiff(func() (string, error) { return body, nil })
+}
+
+// fillTemplate asks for the fields of t one after the other, then creates
+// the note.
+func (m *Model) fillTemplate(t templates.Template) tea.Cmd {
+	m.tmpl = t
+	m.tmplFields = t.Fields()
+	m.tmplValues = make(map[string]string, len(m.tmplFields))
+	return m.nextField()
+}
+
+// nextField asks for the first field without a value, or creates the note
+// once all have one.
+func (m *Model) nextField() tea.Cmd {
+	i := len(m.tmplValues)
+	if i == len(m.tmplFields) {
+		m.exitPrompt()
+		m.templates = nil
+		t, values := m.tmpl, m.tmplValues
+		if cmd := m.createNote(&t, values); cmd != nil {
+			return cmd
+		}
+		// Another change is running; put the notes back in the sidebar.
+		return m.reload(load{})
+	}
+
+	f := m.tmplFields[i]
+	m.mode = modeTemplateField
+	m.focus = focusSidebar
+	m.promptInput.Prompt = "› "
+	m.promptInput.Placeholder = f.Name
+	m.promptInput.SetValue(f.Default)
+	m.promptInput.CursorEnd()
+	m.promptInput.Width = m.noteList.Width() - 4
+	m.status = fmt.Sprintf("%s: %s (%d of %d)", m.tmpl.Name, f.Name, i+1, len(m.tmplFields))
+	return m.promptInput.Focus()
+}
+
+func (m Model) updateTemplateFieldMode(msg tea.KeyMsg) (Model, tea.Cmd) {
+	switch {
+	case key.Matches(msg, m.keys.Cancel):
+		m.exitPrompt()
+		m.templates = nil
+		m.status = ""
+		return m, m.reload(load{})
+
+	case key.Matches(msg, m.keys.Open):
+		m.tmplValues[m.tmplFields[len(m.tmplValues)].Name] = m.promptInput.Value()
+		return m, m.nextField()
+	}
+
+	var cmd tea.Cmd
+	m.promptInput, cmd = m.promptInput.Update(msg)
+	return m, cmd
+}
+
+// createNote creates a note in the current notebook, made from t when it
+// is not nil, and opens it in the editor. From the tag browser it goes to
+// Notes and carries the tags of the applied filter, so it shows up in the
+// list.
+func (m *Model) createNote(t *templates.Template, values map[string]string) tea.Cmd {
+	sec, filter := m.currentSection(), m.tagFilter
+	graph, store := m.links, m.store
+	fromTags := sec == sectionTags
+	if fromTags {
+		sec = fs.SectionNotes
+	}
+	return m.change(func() changeDoneMsg {
+		n, err := store.Create(sec)
+		if err != nil {
+			return failed("create", err)
+		}
+		if t != nil || len(filter) > 0 {
+			var body string
+			if t != nil {
+				body = t.Fill(time.Now(), values)
+			} else if body, err = store.ReadBody(n.Path); err != nil {
+				return failed("create", err)
+			}
+			if len(filter) > 0 {
+				meta, _ := fs.ParseFrontMatter(body)
+				for _, tag := range filter {
+					if !slices.Contains(meta.Tags, tag) {
+						meta.Tags = append(meta.Tags, tag)
+					}
+				}
+				body = fs.WithFrontMatter(body, meta)
+			}
+			if _, err := saveNote(graph, store, n, body, fs.Version{}); err != nil {
+				return failed("create", err)
+			}
+		}
+
+		l := load{reselect: n.ID, edit: true}
+		if fromTags && len(filter) == 0 {
+			l = openLoad(n)
+			l.edit = true
+		}
+		return changeDoneMsg{reload: true, load: l}
+	})
+}
@@ -0,0 +1,101 @@
+package app
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/internet-kid/tenote/internal/storage"
+	"github.com/internet-kid/tenote/internal/storage/fs"
+)
+
+// purgedMsg reports the notes purgeTrash deleted.
+type purgedMsg struct {
+	notes []fs.Note
+	err   error
+}
+
+// purgeTrash deletes the notes that have been in the trash for longer than
+// the configured retention.
+func (m Model) purgeTrash() tea.Cmd {
+	cfg, store := m.cfg, m.store
+	return func() tea.Msg {
+		notes, err := storage.PurgeExpired(cfg, store)
+		return purgedMsg{notes: notes, err: err}
+	}
+}
+
+func (m *Model) finishPurge(msg purgedMsg) {
+	switch {
+	case msg.err != nil:
+		m.status = "purge error: " + msg.err.Error()
+	case len(msg.notes) > 0:
+		m.status = fmt.Sprintf("Purged %d note(s) trashed more than %d days ago", len(msg.notes), int(m.retention.Hours()/24))
+	}
+}
+
+// daysLeft describes how long a trashed note has before it is purged, or
+// returns "" when it is kept until deleted by hand.
+func daysLeft(n fs.Note, retention time.Duration) string {
+	if retention <= 0 || n.DeletedAt.IsZero() {
+		return ""
+	}
+	days := int(math.Ceil(time.Until(n.DeletedAt.Add(retention)).Hours() / 24))
+	switch {
+	case days <= 0:
+		return "purged on next start"
+	case days == 1:
+		return "1 day left"
+	}
+	return fmt.Sprintf("%d days left", days)
+}
+
+// trashedLine is the preview header line saying when and from where the
+// selected note was trashed.
+func (m Model) trashedLine(n fs.Note) string {
+	line := "Deleted: " + n.DeletedAt.Format(timeLayout)
+	if n.Origin != "" {
+		line += " from " + sectionTitle(n.Origin)
+	}
+	if left := daysLeft(n, m.retention); left != "" {
+		line += " · " + left
+	}
+	return line
+}
+
+// startEmptyTrash asks before deleting everything in the trash.
+func (m *Model) startEmptyTrash() {
+	if len(m.notes) == 0 {
+		m.status = "Trash is empty"
+		return
+	}
+	m.mode = modeEmptyTrash
+	m.status = fmt.Sprintf("Delete all %d note(s) in the trash forever? y/n", len(m.notes))
+}
+
+func (m Model) updateEmptyTrashMode(msg tea.KeyMsg) (Model, tea.Cmd) {
+	m.mode = modeBrowse
+	if !key.Matches(msg, m.keys.Yes) {
+		m.status = "Trash kept"
+		return m, nil
+	}
+
+	notes, store := m.notes, m.store
+	return m, m.change(func() changeDoneMsg {
+		for _, n := range notes {
+			if err := store.DeleteFromTrash(n); err != nil {
+				msg := failed("delete", err)
+				msg.reload = true
+				return msg
+			}
+		}
+		return changeDoneMsg{status: fmt.Sprintf("Deleted %d note(s) permanently", len(notes)), reload: true}
+	})
+}
+
+type emptyTrashKeyMap struct{ KeyMap }
+
+func (k emptyTrashKeyMap) ShortHelp() []key.Binding { return k.KeyMap.YesNoShortHelp() }
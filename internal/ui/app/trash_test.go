@@ -0,0 +1,96 @@
+package app
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/internet-kid/tenote/internal/storage"
+	"github.com/internet-kid/tenote/internal/storage/fs"
+)
+
+func TestDaysLeft(t *testing.T) {
+	const day = 24 * time.Hour
+	now := time.Now()
+	tests := []struct {
+		name      string
+		deleted   time.Time
+		retention time.Duration
+		want      string
+	}{
+		{"just trashed", now, 30 * day, "30 days left"},
+		{"part of a day", now.Add(-28*day - time.Hour), 30 * day, "2 days left"},
+		{"last day", now.Add(-29*day - time.Hour), 30 * day, "1 day left"},
+		{"expired", now.Add(-31 * day), 30 * day, "purged on next start"},
+		{"kept forever", now.Add(-31 * day), 0, ""},
+		{"undated", time.Time{}, 30 * day, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := daysLeft(fs.Note{DeletedAt: tt.deleted}, tt.retention); got != tt.want {
+				t.Errorf("daysLeft = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// openTrash launches the app with one trashed note and switches to the
+// trash.
+func openTrash(t *testing.T) Model {
+	t.Helper()
+	m, _ := launch(t, "# Old\n", func(s storage.NoteStore, n fs.Note) {
+		if _, err := s.MoveToTrash(n); err != nil {
+			t.Fatal(err)
+		}
+	})
+	for range 5 {
+		if m.inTrash() {
+			break
+		}
+		m, _ = press(t, m, runes("J"))
+	}
+	if !m.inTrash() || len(m.notes) != 1 {
+		t.Fatalf("in trash %v with %d notes; want the trashed note", m.inTrash(), len(m.notes))
+	}
+	return m
+}
+
+func TestTrashPreviewShowsDaysLeft(t *testing.T) {
+	m := openTrash(t)
+	if line := m.trashedLine(*m.selected); !strings.Contains(line, "30 days left") {
+		t.Fatalf("trashed line = %q, want the days left", line)
+	}
+}
+
+func TestEmptyTrashAsks(t *testing.T) {
+	m := openTrash(t)
+
+	m, _ = press(t, m, runes("X"))
+	if m.mode != modeEmptyTrash || !strings.Contains(m.status, "Delete all 1 note(s)") {
+		t.Fatalf("mode %v, status %q after X; want to be asked", m.mode, m.status)
+	}
+	m, _ = press(t, m, runes("n"))
+	if m.mode != modeBrowse || len(m.notes) != 1 {
+		t.Fatalf("mode %v with %d notes after n; want the trash kept", m.mode, len(m.notes))
+	}
+
+	m, _ = press(t, m, runes("X"))
+	m, _ = press(t, m, runes("y"))
+	if m.mode != modeBrowse || len(m.notes) != 0 {
+		t.Fatalf("mode %v with %d notes after y; want the trash empty", m.mode, len(m.notes))
+	}
+	if trash, err := m.store.List(fs.SectionTrash); err != nil || len(trash) != 0 {
+		t.Fatalf("List(trash) = %+v, %v; want it empty", trash, err)
+	}
+}
+
+func TestEmptyTrashWhenEmpty(t *testing.T) {
+	m := openTrash(t)
+	m, _ = press(t, m, runes("X"))
+	m, _ = press(t, m, runes("y"))
+
+	next, _ := m.Update(runes("X"))
+	if m = next.(Model); m.mode != modeBrowse || m.status != "Trash is empty" {
+		t.Fatalf("mode %v, status %q; want nothing to ask", m.mode, m.status)
+	}
+}
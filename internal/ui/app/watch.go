@@ -0,0 +1,59 @@
+package app
+
+import (
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/internet-kid/tenote/internal/storage"
+	"github.com/internet-kid/tenote/internal/storage/watch"
+)
+
+// notesChangedMsg is sent when notes changed on disk, by this UI or by
+// anyone else. paths are those of the watch.Event.
+type notesChangedMsg struct{ paths []string }
+
+// waitForChange waits for the next batch of changes the watcher sees.
+func waitForChange(w *watch.Watcher) tea.Cmd {
+	if w == nil {
+		return nil
+	}
+	return func() tea.Msg {
+		ev, ok := <-w.Events()
+		if !ok {
+			return nil
+		}
+		return notesChangedMsg{paths: ev.Paths}
+	}
+}
+
+// refreshFromDisk reloads the sidebar and preview, keeping the selected
+// note and how far its preview is scrolled. Modes other than browse own
+// the sidebar or editor, so the refresh waits until they are left.
+func (m *Model) refreshFromDisk() tea.Cmd {
+	if m.mode != modeBrowse {
+		m.diskChanged = true
+		return nil
+	}
+	m.diskChanged = false
+
+	l := load{sections: true}
+	if m.selected != nil {
+		l.reselect = m.selected.ID
+		l.scroll = previewScroll{id: m.selected.ID, offset: m.preview.YOffset}
+	}
+	return m.reload(l)
+}
+
+// Close stops watching the store for changes and closes the store. The
+// command waiting for the next change returns.
+func (m Model) Close() {
+	if m.watcher != nil {
+		m.watcher.Close()
+	}
+	_ = storage.Close(m.store)
+}
+
+// quit closes the model and quits the program.
+func (m Model) quit() tea.Cmd {
+	m.Close()
+	return tea.Quit
+}
@@ -0,0 +1,31 @@
+package app
+
+import (
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/internet-kid/tenote/internal/storage/watch"
+)
+
+func TestQuitClosesWatcher(t *testing.T) {
+	for _, opts := range [][]watch.Option{nil, {watch.WithPolling(time.Hour)}} {
+		m := Model{watcher: watch.New([]string{t.TempDir()}, opts...)}
+		wait := waitForChange(m.watcher)
+		got := make(chan tea.Msg, 1)
+		go func() { got <- wait() }()
+
+		if _, ok := m.quit()().(tea.QuitMsg); !ok {
+			t.Fatal("quit does not quit")
+		}
+		select {
+		case msg := <-got:
+			if msg != nil {
+				t.Fatalf("waitForChange after quit = %#v, want nil", msg)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("waitForChange still waits after quit")
+		}
+	}
+}
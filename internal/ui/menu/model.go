@@ -1,6 +1,7 @@
 package menu
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
 	"strings"
@@ -12,10 +13,21 @@ import (
 	"github.com/charmbracelet/lipgloss"
 
 	"github.com/internet-kid/tenote/internal/config"
+	"github.com/internet-kid/tenote/internal/storage"
+	"github.com/internet-kid/tenote/internal/storage/vault"
 )
 
 // OpenNotesMsg is sent to the parent model when the user picks "Open Notes".
-type OpenNotesMsg struct{}
+// Key unlocks an encrypted store and is nil otherwise.
+type OpenNotesMsg struct {
+	Key *vault.Key
+}
+
+// unlockedMsg carries the result of deriving the key off the UI loop.
+type unlockedMsg struct {
+	key *vault.Key
+	err error
+}
 
 type tickMsg time.Time
 
@@ -28,6 +40,7 @@ const (
 	viewSettings                    // settings screen
 	viewFilePicker                  // folder picker inside settings
 	viewMkdir                       // new-folder dialog (opened from file picker)
+	viewUnlock                      // passphrase prompt for an encrypted store
 )
 
 // logo is the ASCII art for "tenote" in ANSI Shadow style.
@@ -73,6 +86,11 @@ type Model struct {
 
 	mkdirInput textinput.Model
 	mkdirErr   string
+
+	passInput textinput.Model
+	unlockErr string
+	unlocking bool
+	lockNote  string
 }
 
 // New returns a fresh Model ready to animate.
@@ -90,7 +108,23 @@ func New() Model {
 	mi.CharLimit = 255
 	mi.Width = 52
 
-	return Model{input: ti, mkdirInput: mi}
+	pi := textinput.New()
+	pi.Placeholder = "passphrase"
+	pi.EchoMode = textinput.EchoPassword
+	pi.EchoCharacter = '•'
+	pi.Width = 52
+
+	return Model{input: ti, mkdirInput: mi, passInput: pi}
+}
+
+// Locked returns to the passphrase prompt after the app locked an
+// encrypted store.
+func (m Model) Locked() (Model, tea.Cmd) {
+	m.view = viewUnlock
+	m.lockNote = "Locked after inactivity"
+	m.unlockErr = ""
+	m.passInput.SetValue("")
+	return m, m.passInput.Focus()
 }
 
 func (m Model) Init() tea.Cmd {
@@ -110,6 +144,8 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m, nil
 	case tickMsg:
 		return m.onTick()
+	case unlockedMsg:
+		return m.onUnlocked(msg)
 	case tea.KeyMsg:
 		return m.onKey(msg)
 	}
@@ -183,7 +219,13 @@ func (m Model) onKey(msg tea.KeyMsg) (Model, tea.Cmd) {
 				m.inputErr = "Path cannot be empty"
 				return m, nil
 			}
-			if err := config.SaveConfig(config.AppConfig{StorageDir: dir}); err != nil {
+			cfg, err := config.LoadConfig()
+			if err != nil {
+				m.inputErr = err.Error()
+				return m, nil
+			}
+			cfg.StorageDir = dir
+			if err := config.SaveConfig(cfg); err != nil {
 				m.inputErr = err.Error()
 				return m, nil
 			}
@@ -224,6 +266,31 @@ func (m Model) onKey(msg tea.KeyMsg) (Model, tea.Cmd) {
 			return m, cmd
 		}
 
+	case viewUnlock:
+		if m.unlocking {
+			return m, nil
+		}
+		switch msg.String() {
+		case "esc":
+			m.passInput.SetValue("")
+			m.passInput.Blur()
+			m.unlockErr = ""
+			m.lockNote = ""
+			m.view = viewMenu
+		case "enter":
+			pass := m.passInput.Value()
+			if pass == "" {
+				return m, nil
+			}
+			m.unlocking = true
+			m.unlockErr = ""
+			return m, unlock(pass)
+		default:
+			var cmd tea.Cmd
+			m.passInput, cmd = m.passInput.Update(msg)
+			return m, cmd
+		}
+
 	case viewMkdir:
 		switch msg.String() {
 		case "esc":
@@ -261,6 +328,16 @@ func (m Model) onKey(msg tea.KeyMsg) (Model, tea.Cmd) {
 func (m Model) pick() (Model, tea.Cmd) {
 	switch menuItems[m.cursor].id {
 	case idNotes:
+		cfg, err := config.LoadConfig()
+		if err == nil {
+			if enc, _ := storage.Encrypted(cfg); enc {
+				m.view = viewUnlock
+				m.unlockErr = ""
+				m.lockNote = ""
+				m.passInput.SetValue("")
+				return m, m.passInput.Focus()
+			}
+		}
 		return m, func() tea.Msg { return OpenNotesMsg{} }
 	case idSettings:
 		cfg, _ := config.LoadConfig()
@@ -276,6 +353,38 @@ func (m Model) pick() (Model, tea.Cmd) {
 	return m, nil
 }
 
+// unlock derives the store key in the background; it takes a moment by
+// design.
+func unlock(pass string) tea.Cmd {
+	return func() tea.Msg {
+		cfg, err := config.LoadConfig()
+		if err != nil {
+			return unlockedMsg{err: err}
+		}
+		key, err := storage.Unlock(cfg, pass)
+		return unlockedMsg{key: key, err: err}
+	}
+}
+
+func (m Model) onUnlocked(msg unlockedMsg) (Model, tea.Cmd) {
+	m.unlocking = false
+	switch {
+	case errors.Is(msg.err, vault.ErrPassphrase):
+		m.unlockErr = "Wrong passphrase"
+		m.passInput.SetValue("")
+		return m, nil
+	case msg.err != nil:
+		m.unlockErr = msg.err.Error()
+		return m, nil
+	}
+
+	m.passInput.SetValue("")
+	m.passInput.Blur()
+	m.lockNote = ""
+	m.view = viewMenu
+	return m, func() tea.Msg { return OpenNotesMsg{Key: msg.key} }
+}
+
 // initFilePicker sets up the filepicker, starting from the current input value.
 func (m Model) initFilePicker() Model {
 	fp := filepicker.New()
@@ -345,6 +454,8 @@ func (m Model) View() string {
 		return m.viewFilePicker()
 	case viewMkdir:
 		return m.viewMkdir()
+	case viewUnlock:
+		return m.viewUnlock()
 	default:
 		return m.viewMain()
 	}
@@ -404,15 +515,28 @@ func (m Model) viewInfo() string {
 		"A minimal TUI note-taking application.",
 		"",
 		boldStyle.Render("Sections"),
-		"  Notes    — regular notes",
+		"  Notes    — regular notes, nested in notebooks",
+		"  Tags     — browse and filter notes by #tag",
 		"  Trash    — deleted notes",
 		"",
 		boldStyle.Render("Shortcuts"),
 		"  ↑↓ / jk   navigate list",
+		"  J / K      next / previous notebook",
+		"  N          new notebook",
+		"  m          move note to notebook",
 		"  n          new note (Notes only)",
 		"  e          edit note (Notes only)",
+		"  E          open note in external editor",
 		"  d          move to trash / delete forever",
-		"  r          restore from trash",
+		"  r          restore to original notebook",
+		"  /          search notes",
+		"  H          revision history",
+		"  S          git sync",
+		"  ] / [      select link",
+		"  f          follow link",
+		"  space      pick tag (Tags)",
+		"  o          all / any picked tags",
+		"  R          rename or merge tags",
 		"  Ctrl+S     save",
 		"  ?          toggle help",
 		"  Tab        switch focus",
@@ -477,3 +601,26 @@ func (m Model) viewMkdir() string {
 	return lipgloss.Place(m.width, m.height, lipgloss.Center, lipgloss.Center,
 		panelStyle.Render(body))
 }
+
+func (m Model) viewUnlock() string {
+	rows := []string{headStyle.Render("Unlock Notes")}
+	if m.lockNote != "" {
+		rows = append(rows, dimStyle.Render(m.lockNote))
+	} else {
+		rows = append(rows, dimStyle.Render("This note store is encrypted"))
+	}
+	rows = append(rows, "", m.passInput.View(), "")
+
+	switch {
+	case m.unlocking:
+		rows = append(rows, dimStyle.Render("Unlocking…"), "")
+	case m.unlockErr != "":
+		rows = append(rows, errorStyle.Render(m.unlockErr), "")
+	}
+
+	rows = append(rows, hintStyle.Render("enter  unlock  •  esc  back"))
+
+	body := lipgloss.JoinVertical(lipgloss.Left, rows...)
+	return lipgloss.Place(m.width, m.height, lipgloss.Center, lipgloss.Center,
+		panelStyle.Render(body))
+}